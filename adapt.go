@@ -0,0 +1,221 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// adaptSources are the ambient light sources adapt can sample.
+var adaptSources = []string{"webcam", "command"}
+
+// defaultAdaptCurves map each source's readings to brightness: average
+// webcam luminance (0-255), or sensor readings in lux.
+var defaultAdaptCurves = map[string]string{
+	"webcam":  "0:5, 64:40, 160:80, 255:100",
+	"command": "0:5, 50:30, 300:70, 1000:100",
+}
+
+// webcamFrameSize is the width and height webcam frames are scaled to
+// before averaging.
+const webcamFrameSize = 32
+
+// curvePoint is a point on a brightnessCurve.
+type curvePoint struct {
+	Level      float64
+	Brightness int
+}
+
+// brightnessCurve maps ambient light levels to brightness, interpolating
+// linearly between points sorted by level.
+type brightnessCurve []curvePoint
+
+// parseBrightnessCurve parses `<level>:<brightness>` pairs separated by
+// commas, e.g. `0:5, 255:100`.
+func parseBrightnessCurve(s string) (brightnessCurve, error) {
+	var curve brightnessCurve
+	for _, pair := range strings.Split(s, ",") {
+		levelArg, brightnessArg, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid curve point %q, expected <level>:<brightness>", pair)
+		}
+		level, err := strconv.ParseFloat(levelArg, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid curve level %q", levelArg)
+		}
+		brightness, err := strconv.Atoi(brightnessArg)
+		if err != nil || brightness < 0 || brightness > 100 {
+			return nil, fmt.Errorf("invalid curve brightness %q, expected 0-100", brightnessArg)
+		}
+		curve = append(curve, curvePoint{level, brightness})
+	}
+	sort.Slice(curve, func(i, j int) bool { return curve[i].Level < curve[j].Level })
+	return curve, nil
+}
+
+// brightness returns the brightness for an ambient light level. Levels
+// outside the curve get the brightness of the nearest end.
+func (c brightnessCurve) brightness(level float64) int {
+	if level <= c[0].Level {
+		return c[0].Brightness
+	}
+	for i := 1; i < len(c); i++ {
+		if level <= c[i].Level {
+			a, b := c[i-1], c[i]
+			t := (level - a.Level) / (b.Level - a.Level)
+			return a.Brightness + int(t*float64(b.Brightness-a.Brightness)+0.5)
+		}
+	}
+	return c[len(c)-1].Brightness
+}
+
+// webcamArgs returns ffmpeg arguments that capture one frame from a camera
+// as raw grayscale pixels on stdout. An empty device uses the default
+// camera.
+func webcamArgs(goos, device string) []string {
+	var input []string
+	switch goos {
+	case "darwin":
+		if device == "" {
+			device = "0"
+		}
+		input = []string{"-f", "avfoundation", "-i", device}
+	case "windows":
+		if device == "" {
+			device = "Integrated Camera"
+		}
+		input = []string{"-f", "dshow", "-i", "video=" + device}
+	default:
+		if device == "" {
+			device = "/dev/video0"
+		}
+		input = []string{"-f", "v4l2", "-i", device}
+	}
+
+	args := append([]string{"-loglevel", "error"}, input...)
+	return append(args,
+		"-frames:v", "1",
+		"-vf", fmt.Sprintf("scale=%d:%d", webcamFrameSize, webcamFrameSize),
+		"-f", "rawvideo", "-pix_fmt", "gray", "-",
+	)
+}
+
+// meanLuminance averages grayscale pixels.
+func meanLuminance(pixels []byte) float64 {
+	if len(pixels) == 0 {
+		return 0
+	}
+	var total int
+	for _, p := range pixels {
+		total += int(p)
+	}
+	return float64(total) / float64(len(pixels))
+}
+
+// webcamLevel captures a frame with ffmpeg and returns its average
+// luminance, 0-255.
+func webcamLevel(device string) (float64, error) {
+	out, err := exec.Command("ffmpeg", webcamArgs(runtime.GOOS, device)...).Output()
+	if err != nil {
+		return 0, fmt.Errorf("ffmpeg: %v", err)
+	}
+	return meanLuminance(out), nil
+}
+
+// commandLevel runs a sensor command and parses the number it prints.
+func commandLevel(command string) (float64, error) {
+	out, err := shellCommand(command).Output()
+	if err != nil {
+		return 0, err
+	}
+	fields := strings.Fields(string(out))
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("%q printed nothing", command)
+	}
+	return strconv.ParseFloat(fields[0], 64)
+}
+
+// doAdaptCommand periodically samples the ambient light and sets the
+// brightness to match, until interrupted. It leaves the panels alone while
+// they're off.
+func doAdaptCommand(client Client, args []string) {
+	section := cfg.Section("adapt")
+	flags := flag.NewFlagSet("adapt", flag.ExitOnError)
+	source := flags.String("source", section.Key("source").MustString("webcam"), "Where to sample light: webcam or command")
+	command := flags.String("command", section.Key("command").String(), "Sensor command that prints a light level, for --source command")
+	device := flags.String("device", section.Key("device").String(), "Camera for --source webcam (default the system's first camera)")
+	curveArg := flags.String("curve", section.Key("curve").String(), "Light levels and brightness, like 0:5,255:100")
+	every := flags.Duration("every", section.Key("every").MustDuration(time.Minute), "How often to sample the light")
+	minChange := flags.Int("min-change", section.Key("min_change").MustInt(3), "Smallest brightness change to make, to avoid flicker")
+	flags.Usage = func() {
+		fmt.Println("usage: picoleaf adapt [--source webcam|command] [--command <command>] [--device <camera>]")
+		fmt.Println("                      [--curve <level>:<brightness>,...] [--every <duration>] [--min-change <n>]")
+		exit(1)
+	}
+	flags.Parse(args)
+
+	if flags.NArg() > 0 || *every <= 0 || *minChange < 0 {
+		flags.Usage()
+	}
+
+	var sample func() (float64, error)
+	switch *source {
+	case "webcam":
+		sample = func() (float64, error) { return webcamLevel(*device) }
+	case "command":
+		if *command == "" {
+			fmt.Println("error: --source command needs --command")
+			exit(1)
+		}
+		sample = func() (float64, error) { return commandLevel(*command) }
+	default:
+		fmt.Printf("error: unknown source %q, expected %s\n", *source, strings.Join(adaptSources, " or "))
+		exit(1)
+	}
+
+	if *curveArg == "" {
+		*curveArg = defaultAdaptCurves[*source]
+	}
+	curve, err := parseBrightnessCurve(*curveArg)
+	if err != nil {
+		fmt.Println("error:", err)
+		exit(1)
+	}
+
+	slog.Info("adapting brightness", "source", *source, "every", *every)
+	for {
+		level, err := sample()
+		if err != nil {
+			fmt.Println("error: failed to sample light:", err)
+			exit(1)
+		}
+
+		snapshot, err := client.Snapshot()
+		if err != nil {
+			slog.Warn("failed to get Nanoleaf state", "err", err)
+		} else if snapshot.On {
+			brightness := curve.brightness(level)
+			diff := brightness - snapshot.Brightness
+			if diff < 0 {
+				diff = -diff
+			}
+			if diff > 0 && diff >= *minChange {
+				slog.Info("setting brightness", "level", level, "brightness", brightness)
+				err = client.SetBrightness(brightness)
+				if err != nil {
+					slog.Warn("failed to set brightness", "err", err)
+				}
+			}
+		}
+
+		if !sleepOrCancel(*every) {
+			return
+		}
+	}
+}
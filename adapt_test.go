@@ -0,0 +1,73 @@
+package main
+
+import (
+	"runtime"
+	"slices"
+	"testing"
+)
+
+func TestBrightnessCurve(t *testing.T) {
+	curve, err := parseBrightnessCurve("255:100, 0:10, 100:50")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		level float64
+		want  int
+	}{
+		{-5, 10},
+		{0, 10},
+		{50, 30},
+		{100, 50},
+		{177.5, 75},
+		{300, 100},
+	}
+	for _, tt := range tests {
+		if got := curve.brightness(tt.level); got != tt.want {
+			t.Errorf("brightness(%v) = %d, want %d", tt.level, got, tt.want)
+		}
+	}
+
+	for _, s := range []string{"", "0:5,", "dark:5", "0:101", "0"} {
+		if _, err := parseBrightnessCurve(s); err == nil {
+			t.Errorf("parseBrightnessCurve(%q): want an error", s)
+		}
+	}
+	for source, s := range defaultAdaptCurves {
+		if _, err := parseBrightnessCurve(s); err != nil {
+			t.Errorf("default %s curve: %v", source, err)
+		}
+	}
+}
+
+func TestWebcamArgs(t *testing.T) {
+	args := webcamArgs("linux", "")
+	if !slices.Contains(args, "/dev/video0") || args[len(args)-1] != "-" {
+		t.Errorf("webcamArgs(linux) = %q, want /dev/video0 to stdout", args)
+	}
+	if args := webcamArgs("darwin", "1"); !slices.Contains(args, "avfoundation") || !slices.Contains(args, "1") {
+		t.Errorf("webcamArgs(darwin, 1) = %q, want avfoundation camera 1", args)
+	}
+	if args := webcamArgs("windows", "USB Camera"); !slices.Contains(args, "video=USB Camera") {
+		t.Errorf("webcamArgs(windows) = %q, want the named dshow camera", args)
+	}
+
+	if got := meanLuminance([]byte{0, 100, 200}); got != 100 {
+		t.Errorf("meanLuminance() = %v, want 100", got)
+	}
+}
+
+func TestCommandLevel(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+
+	level, err := commandLevel("echo 312.5 lux")
+	if err != nil || level != 312.5 {
+		t.Errorf("commandLevel() = %v, %v, want 312.5", level, err)
+	}
+	if _, err := commandLevel("true"); err == nil {
+		t.Error("commandLevel() with no output: want an error")
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// aliasDepthLimit bounds nested alias expansion, so aliases that refer to
+// each other fail instead of recursing forever.
+const aliasDepthLimit = 10
+
+// aliasDepth is the number of aliases currently being run.
+var aliasDepth int
+
+// isAlias reports whether name is defined in the `[aliases]` config section.
+func isAlias(name string) bool {
+	return cfg.Section("aliases").HasKey(name)
+}
+
+// aliasNames returns the names of all aliases, sorted.
+func aliasNames() []string {
+	names := cfg.Section("aliases").KeyStrings()
+	sort.Strings(names)
+	return names
+}
+
+// expandAlias expands an alias into the commands it runs. Commands are
+// separated by `&&`, and any extra arguments are appended to the last
+// command, e.g. given:
+//
+//	movie = scene movie-night && brightness
+//
+// `movie 15` expands to `scene movie-night` and `brightness 15`.
+func expandAlias(args []string) ([][]string, error) {
+	value := cfg.Section("aliases").Key(args[0]).String()
+	words, err := splitArgs(value)
+	if err != nil {
+		return nil, fmt.Errorf("alias %s: %v", args[0], err)
+	}
+
+	var commands [][]string
+	var command []string
+	for _, word := range append(words, "&&") {
+		if word != "&&" {
+			command = append(command, word)
+			continue
+		}
+		if len(command) == 0 {
+			return nil, fmt.Errorf("alias %s: empty command", args[0])
+		}
+		commands = append(commands, command)
+		command = nil
+	}
+
+	last := len(commands) - 1
+	commands[last] = append(commands[last], args[1:]...)
+	return commands, nil
+}
+
+// runAlias runs each of an alias's commands in turn, stopping at the first
+// one that fails.
+func runAlias(client Client, args []string) {
+	if aliasDepth >= aliasDepthLimit {
+		fmt.Printf("error: alias %s: too many nested aliases\n", args[0])
+		exit(1)
+	}
+
+	commands, err := expandAlias(args)
+	if err != nil {
+		fmt.Println("error:", err)
+		exit(1)
+	}
+
+	aliasDepth++
+	defer func() { aliasDepth-- }()
+	for _, command := range commands {
+		runCommand(client, command)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// setTestConfig replaces the config file for the duration of a test.
+func setTestConfig(t *testing.T, data string) {
+	t.Helper()
+
+	f, err := loadINI([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	prev := cfg
+	cfg = f
+	t.Cleanup(func() { cfg = prev })
+}
+
+func TestExpandAlias(t *testing.T) {
+	setTestConfig(t, `
+[aliases]
+movie = scene movie-night && brightness 15
+dim   = brightness
+focus = effect select "Northern Lights"
+bad   = on && && off
+`)
+
+	tests := []struct {
+		args []string
+		want [][]string
+	}{
+		{[]string{"movie"}, [][]string{{"scene", "movie-night"}, {"brightness", "15"}}},
+		{[]string{"dim", "10"}, [][]string{{"brightness", "10"}}},
+		{[]string{"focus"}, [][]string{{"effect", "select", "Northern Lights"}}},
+	}
+	for _, tt := range tests {
+		got, err := expandAlias(tt.args)
+		if err != nil {
+			t.Errorf("expandAlias(%q): %v", tt.args, err)
+		} else if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("expandAlias(%q) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+
+	if _, err := expandAlias([]string{"bad"}); err == nil {
+		t.Error("expandAlias(bad) succeeded, want error")
+	}
+}
+
+func TestRunAlias(t *testing.T) {
+	client, server := newTestClient(t)
+	t.Setenv("HOME", t.TempDir()) // brightness records undo history
+	setTestConfig(t, `
+[aliases]
+late  = dim 5 && off
+dim   = brightness
+loop  = again
+again = loop
+`)
+
+	if code := runCommandInProcess(client, []string{"late"}); code != 0 {
+		t.Fatalf("exit code = %d, want 0", code)
+	}
+	if state := server.Device().State; state.On || state.Brightness != 5 {
+		t.Errorf("state = %+v, want off at brightness 5", state)
+	}
+
+	if code := runCommandInProcess(client, []string{"loop"}); code != 1 {
+		t.Errorf("recursive alias exit code = %d, want 1", code)
+	}
+	if aliasDepth != 0 {
+		t.Errorf("aliasDepth = %d after failure, want 0", aliasDepth)
+	}
+}
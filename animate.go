@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Keyframe is the panel colors at one point in an animation.
+type Keyframe struct {
+	At     time.Duration
+	Frames []SetPanelColor
+}
+
+// Easing maps linear progress between two keyframes, from 0 to 1, to eased
+// progress.
+type Easing func(float64) float64
+
+// easings are the easing functions accepted by --ease.
+var easings = map[string]Easing{
+	"linear":  func(x float64) float64 { return x },
+	"ease-in": func(x float64) float64 { return x * x * x },
+	"ease-out": func(x float64) float64 {
+		return 1 - math.Pow(1-x, 3)
+	},
+	"ease": func(x float64) float64 {
+		if x < 0.5 {
+			return 4 * x * x * x
+		}
+		return 1 - math.Pow(-2*x+2, 3)/2
+	},
+}
+
+// parseKeyframes reads keyframes, one per line, each a time offset followed
+// by frames in the `effect custom` format, e.g. `1.5s 101 255 0 0 0`.
+// Transition times are ignored, since picoleaf generates the frames in
+// between. Offsets must increase from line to line.
+func parseKeyframes(r io.Reader, rgbw bool) ([]Keyframe, error) {
+	var keyframes []Keyframe
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		at, err := time.ParseDuration(fields[0])
+		if err != nil || at < 0 {
+			return nil, fmt.Errorf("line %d: expected a time offset like 1.5s, got %s", line, fields[0])
+		}
+		if len(keyframes) > 0 && at <= keyframes[len(keyframes)-1].At {
+			return nil, fmt.Errorf("line %d: keyframe at %s isn't after the previous one", line, at)
+		}
+
+		frames, err := parseFrameLine(fields[1:], rgbw)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		keyframes = append(keyframes, Keyframe{At: at, Frames: frames})
+	}
+	return keyframes, scanner.Err()
+}
+
+// interpolateKeyframes returns the panel colors at time t. Panels missing
+// from one of the surrounding keyframes hold their color from the other.
+func interpolateKeyframes(keyframes []Keyframe, t time.Duration, ease Easing) []SetPanelColor {
+	next := 0
+	for next < len(keyframes) && keyframes[next].At <= t {
+		next++
+	}
+	switch {
+	case next == 0:
+		return keyframes[0].Frames
+	case next == len(keyframes):
+		return keyframes[len(keyframes)-1].Frames
+	}
+
+	from, to := keyframes[next-1], keyframes[next]
+	progress := ease(float64(t-from.At) / float64(to.At-from.At))
+
+	targets := make(map[uint16]SetPanelColor, len(to.Frames))
+	for _, frame := range to.Frames {
+		targets[frame.PanelID] = frame
+	}
+
+	frames := make([]SetPanelColor, 0, len(from.Frames))
+	seen := make(map[uint16]bool, len(from.Frames))
+	for _, a := range from.Frames {
+		seen[a.PanelID] = true
+		b, ok := targets[a.PanelID]
+		if !ok {
+			b = a
+		}
+		frames = append(frames, SetPanelColor{
+			PanelID: a.PanelID,
+			Red:     lerpChannel(a.Red, b.Red, progress),
+			Green:   lerpChannel(a.Green, b.Green, progress),
+			Blue:    lerpChannel(a.Blue, b.Blue, progress),
+			White:   lerpChannel(a.White, b.White, progress),
+		})
+	}
+	for _, b := range to.Frames {
+		if !seen[b.PanelID] {
+			b.TransitionTime = 0
+			frames = append(frames, b)
+		}
+	}
+	return frames
+}
+
+func lerpChannel(a, b uint8, progress float64) uint8 {
+	return uint8(math.Round(float64(a) + (float64(b)-float64(a))*progress))
+}
+
+// animationStats describes how closely frames kept to their schedule.
+type animationStats struct {
+	Frames  int
+	Dropped int
+
+	// MeanDrift and MaxDrift measure how late frames were sent, relative
+	// to when they were due.
+	MeanDrift time.Duration
+	MaxDrift  time.Duration
+}
+
+// animate sends interpolated frames fps times a second until the last
+// keyframe, or until ctx is done.
+//
+// Frames are scheduled against the animation's start time rather than the
+// previous frame, so send jitter doesn't accumulate, and frames that fall a
+// whole interval behind are dropped to catch up. Each frame shows the
+// animation as it should look latency from now, when the Nanoleaf displays
+// it.
+func animate(ctx context.Context, keyframes []Keyframe, fps int, ease Easing, latency time.Duration, send func([]SetPanelColor) error) (animationStats, error) {
+	var stats animationStats
+	var totalDrift time.Duration
+	defer func() {
+		if stats.Frames > 0 {
+			stats.MeanDrift = totalDrift / time.Duration(stats.Frames)
+		}
+	}()
+
+	interval := time.Second / time.Duration(fps)
+	end := keyframes[len(keyframes)-1].At
+	start := time.Now()
+	for n := 0; ; n++ {
+		due := start.Add(time.Duration(n) * interval)
+		if wait := time.Until(due); wait > 0 {
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+				return stats, ctx.Err()
+			}
+		} else if behind := int(-wait / interval); behind > 0 {
+			n += behind
+			stats.Dropped += behind
+			due = start.Add(time.Duration(n) * interval)
+		}
+
+		t := min(due.Sub(start)+latency, end)
+		err := send(interpolateKeyframes(keyframes, t, ease))
+		if err != nil {
+			return stats, err
+		}
+
+		drift := time.Since(due)
+		stats.Frames++
+		totalDrift += drift
+		stats.MaxDrift = max(stats.MaxDrift, drift)
+		if t == end {
+			return stats, nil
+		}
+	}
+}
+
+// measureLatency estimates the one-way network latency to the Nanoleaf as
+// half the median round trip of a few REST requests.
+func measureLatency(client Client) (time.Duration, error) {
+	var rtts []time.Duration
+	for i := 0; i < 5; i++ {
+		start := time.Now()
+		_, err := client.Get("state")
+		if err != nil {
+			return 0, err
+		}
+		rtts = append(rtts, time.Since(start))
+	}
+	sort.Slice(rtts, func(i, j int) bool { return rtts[i] < rtts[j] })
+	return percentile(rtts, 50) / 2, nil
+}
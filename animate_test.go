@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseKeyframes(t *testing.T) {
+	keyframes, err := parseKeyframes(strings.NewReader("# fade\n0s 1 255 0 0 0\n1.5s 1 0 0 255 0 2 9 9 9 0\n"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Keyframe{
+		{At: 0, Frames: []SetPanelColor{{PanelID: 1, Red: 255}}},
+		{At: 1500 * time.Millisecond, Frames: []SetPanelColor{{PanelID: 1, Blue: 255}, {PanelID: 2, Red: 9, Green: 9, Blue: 9}}},
+	}
+	if !reflect.DeepEqual(keyframes, want) {
+		t.Errorf("parseKeyframes = %+v, want %+v", keyframes, want)
+	}
+
+	for _, input := range []string{"soon 1 0 0 0 0", "1s 1 0 0 0 0\n1s 1 0 0 0 0", "0s 1 0 0"} {
+		if _, err := parseKeyframes(strings.NewReader(input), false); err == nil {
+			t.Errorf("parseKeyframes(%q) succeeded, want error", input)
+		}
+	}
+}
+
+func TestInterpolateKeyframes(t *testing.T) {
+	keyframes := []Keyframe{
+		{At: time.Second, Frames: []SetPanelColor{{PanelID: 1, Red: 200}, {PanelID: 2, Green: 50}}},
+		{At: 3 * time.Second, Frames: []SetPanelColor{{PanelID: 1, Blue: 100, White: 20}, {PanelID: 3, Red: 7}}},
+	}
+
+	tests := []struct {
+		t    time.Duration
+		ease string
+		want []SetPanelColor
+	}{
+		{0, "linear", keyframes[0].Frames},
+		{2 * time.Second, "linear", []SetPanelColor{
+			{PanelID: 1, Red: 100, Blue: 50, White: 10},
+			{PanelID: 2, Green: 50},
+			{PanelID: 3, Red: 7},
+		}},
+		{1500 * time.Millisecond, "ease-in", []SetPanelColor{
+			{PanelID: 1, Red: 197, Blue: 2},
+			{PanelID: 2, Green: 50},
+			{PanelID: 3, Red: 7},
+		}},
+		{4 * time.Second, "linear", keyframes[1].Frames},
+	}
+	for _, tt := range tests {
+		got := interpolateKeyframes(keyframes, tt.t, easings[tt.ease])
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("interpolateKeyframes(%s, %s) = %+v, want %+v", tt.t, tt.ease, got, tt.want)
+		}
+	}
+}
+
+func TestAnimate(t *testing.T) {
+	keyframes := []Keyframe{
+		{At: 0, Frames: []SetPanelColor{{PanelID: 1}}},
+		{At: 100 * time.Millisecond, Frames: []SetPanelColor{{PanelID: 1, Red: 250}}},
+	}
+
+	var sent [][]SetPanelColor
+	_, err := animate(context.Background(), keyframes, 50, easings["linear"], 0, func(frames []SetPanelColor) error {
+		sent = append(sent, frames)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sent) < 3 {
+		t.Fatalf("sent %d frames, want intermediate frames at 50 fps", len(sent))
+	}
+	if last := sent[len(sent)-1]; !reflect.DeepEqual(last, keyframes[1].Frames) {
+		t.Errorf("last frame = %+v, want the final keyframe", last)
+	}
+	for i := 1; i < len(sent); i++ {
+		if sent[i][0].Red < sent[i-1][0].Red {
+			t.Errorf("frame %d went backwards: %d after %d", i, sent[i][0].Red, sent[i-1][0].Red)
+		}
+	}
+}
+
+func TestAnimateCompensates(t *testing.T) {
+	keyframes := []Keyframe{
+		{At: 0, Frames: []SetPanelColor{{PanelID: 1}}},
+		{At: time.Second, Frames: []SetPanelColor{{PanelID: 1, Red: 200}}},
+	}
+
+	// With 500ms of latency, the first frame shows the animation halfway
+	// through, and a slow send drops frames instead of stretching it out.
+	var sent [][]SetPanelColor
+	stats, err := animate(context.Background(), keyframes, 20, easings["linear"], 500*time.Millisecond, func(frames []SetPanelColor) error {
+		if len(sent) == 1 {
+			time.Sleep(200 * time.Millisecond)
+		}
+		sent = append(sent, frames)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sent[0][0].Red != 100 {
+		t.Errorf("first frame red = %d, want 100", sent[0][0].Red)
+	}
+	if stats.Dropped < 2 {
+		t.Errorf("dropped %d frames after a 200ms stall, want at least 2", stats.Dropped)
+	}
+	if stats.Frames != len(sent) || stats.MaxDrift < stats.MeanDrift {
+		t.Errorf("inconsistent stats %+v for %d frames", stats, len(sent))
+	}
+}
+
+func TestAnimateCancel(t *testing.T) {
+	keyframes := []Keyframe{
+		{At: 0, Frames: []SetPanelColor{{PanelID: 1}}},
+		{At: time.Hour, Frames: []SetPanelColor{{PanelID: 1, Red: 200}}},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := animate(ctx, keyframes, 20, easings["linear"], 0, func([]SetPanelColor) error { return nil })
+	if err != context.DeadlineExceeded {
+		t.Errorf("animate() = %v, want context.DeadlineExceeded", err)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// prettyJSON indents a JSON response for reading, leaving anything else as
+// it is.
+func prettyJSON(body string) string {
+	var out bytes.Buffer
+	if json.Indent(&out, []byte(body), "", "  ") != nil {
+		return body
+	}
+	return out.String()
+}
+
+// doAPICommand sends a raw request to any API endpoint, printing the
+// response. The body may be given as an argument, or as - to read stdin.
+func doAPICommand(client Client, args []string) {
+	if len(args) < 2 || len(args) > 3 {
+		fmt.Println("usage: picoleaf api <method> <path> [<body> | -]")
+		exit(1)
+	}
+
+	method := strings.ToUpper(args[0])
+	path := strings.TrimPrefix(args[1], "/")
+
+	var body []byte
+	if len(args) == 3 {
+		body = []byte(args[2])
+		if args[2] == "-" {
+			var err error
+			body, err = io.ReadAll(os.Stdin)
+			if err != nil {
+				fmt.Println("error: failed to read body:", err)
+				exit(1)
+			}
+		}
+	}
+
+	status, res, err := client.Request(method, path, body)
+	if err != nil {
+		fmt.Println("error: request failed:", err)
+		exit(1)
+	}
+
+	if res != "" {
+		fmt.Println(prettyJSON(res))
+	}
+	if status < 200 || status > 299 {
+		fmt.Printf("error: %s %s: status %d\n", method, path, status)
+		exit(1)
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestPrettyJSON(t *testing.T) {
+	if got := prettyJSON(`{"on":{"value":true}}`); got != "{\n  \"on\": {\n    \"value\": true\n  }\n}" {
+		t.Errorf("prettyJSON = %q", got)
+	}
+	if got := prettyJSON("not json"); got != "not json" {
+		t.Errorf("prettyJSON(not json) = %q", got)
+	}
+}
+
+func TestAPICommand(t *testing.T) {
+	client, server := newTestClient(t)
+	t.Setenv("HOME", t.TempDir()) // writes record undo history
+
+	code := runCommandInProcess(client, []string{"api", "put", "/state", `{"brightness":{"value":12}}`})
+	if code != 0 {
+		t.Fatalf("api PUT state: exit code = %d", code)
+	}
+	if b := server.Device().State.Brightness; b != 12 {
+		t.Errorf("brightness = %d, want 12", b)
+	}
+
+	code = runCommandInProcess(client, []string{"api", "PUT", "state", `{"nope":{"value":1}}`})
+	if code != 1 {
+		t.Errorf("api PUT with a bad body: exit code = %d, want 1", code)
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+)
+
+// ArtNetPort is the UDP port Art-Net is sent to.
+const ArtNetPort = 6454
+
+// Art-Net ArtDmx packet layout.
+const (
+	artNetOpDmx = 0x5000
+
+	artNetOpCodeOffset   = 8
+	artNetSubUniOffset   = 14
+	artNetNetOffset      = 15
+	artNetLengthOffset   = 16
+	artNetDataOffset     = 18
+	artNetMaxPortAddress = 0x7fff
+)
+
+// artNetID starts every Art-Net packet.
+var artNetID = []byte("Art-Net\x00")
+
+// errArtNetNotDmx is returned for valid Art-Net packets that don't carry DMX
+// data, e.g. ArtPoll.
+var errArtNetNotDmx = errors.New("not an ArtDmx packet")
+
+// parseArtNet parses an ArtDmx packet, returning its 15-bit port address
+// (net, sub-net, and universe) and DMX channel values. Channel 1 is
+// data[0].
+func parseArtNet(packet []byte) (int, []byte, error) {
+	if len(packet) < artNetDataOffset || !bytes.Equal(packet[:8], artNetID) {
+		return 0, nil, errors.New("not an Art-Net packet")
+	}
+	if binary.LittleEndian.Uint16(packet[artNetOpCodeOffset:]) != artNetOpDmx {
+		return 0, nil, errArtNetNotDmx
+	}
+
+	universe := int(packet[artNetNetOffset]&0x7f)<<8 | int(packet[artNetSubUniOffset])
+	length := int(binary.BigEndian.Uint16(packet[artNetLengthOffset:]))
+	if artNetDataOffset+length > len(packet) {
+		return universe, nil, errors.New("invalid length")
+	}
+	return universe, packet[artNetDataOffset : artNetDataOffset+length], nil
+}
+
+// doArtNetCommand drives the Nanoleaf from Art-Net DMX data, until
+// interrupted.
+func doArtNetCommand(client Client, args []string) {
+	flags := flag.NewFlagSet("artnet", flag.ExitOnError)
+	universe := flags.Int("universe", 0, "Art-Net port address to listen to (0-32767)")
+	start := flags.Int("start", 1, "First DMX channel (1-512)")
+	listen := flags.String("listen", fmt.Sprintf(":%d", ArtNetPort), "Address to receive Art-Net on")
+	order := flags.String("order", "layout", panelOrderUsage)
+	selection := flags.String("panels", "", "Panels to drive, e.g. x<300, row:2, nearest:250,400, or a zone (default all)")
+	flags.Usage = func() {
+		fmt.Println("usage: picoleaf artnet [--universe <n>] [--start <channel>] [--listen <addr>] [--order <order>] [--panels <selection>]")
+		exit(1)
+	}
+	flags.Parse(args)
+
+	if flags.NArg() > 0 || *universe < 0 || *universe > artNetMaxPortAddress || *start < 1 || *start > 512 {
+		flags.Usage()
+	}
+
+	panelInfo, err := client.CachedPanelInfo()
+	if err != nil {
+		fmt.Println("error: failed to get Nanoleaf info:", err)
+		exit(1)
+	}
+	panels, err := selectPanels(panelInfo.PanelLayout.Layout.PositionData, *selection)
+	if err != nil {
+		fmt.Println("error:", err)
+		exit(1)
+	}
+	panels, err = orderPanels(panels, *order)
+	if err != nil {
+		fmt.Println("error:", err)
+		exit(1)
+	}
+
+	addr, err := net.ResolveUDPAddr("udp", *listen)
+	if err != nil {
+		fmt.Println("error: invalid listen address:", err)
+		exit(1)
+	}
+	conn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		fmt.Println("error: failed to listen for Art-Net:", err)
+		exit(1)
+	}
+	defer conn.Close()
+
+	ctx, restore := restoreOnInterrupt(client)
+	defer restore()
+
+	sink, err := openFrameSink(client, panelInfo)
+	if err != nil {
+		fmt.Println("error: failed to start external control:", err)
+		exit(1)
+	}
+	defer sink.Close()
+
+	// Close the socket on Ctrl-C, so the read loop exits.
+	context.AfterFunc(ctx, func() { conn.Close() })
+
+	slog.Info("listening for Art-Net", "addr", conn.LocalAddr(), "universe", *universe, "start", *start, "panels", len(panels))
+	buf := make([]byte, 1500)
+	for {
+		n, _, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			slog.Error("failed to receive Art-Net", "err", err)
+			continue
+		}
+
+		u, data, err := parseArtNet(buf[:n])
+		if err != nil {
+			if err != errArtNetNotDmx {
+				slog.Debug("ignoring packet", "err", err)
+			}
+			continue
+		}
+		if u != *universe {
+			continue
+		}
+
+		err = sink.Send(dmxToFrames(data, *start, panels))
+		if err != nil {
+			slog.Error("failed to send frame", "err", err)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+func TestParseArtNet(t *testing.T) {
+	packet := make([]byte, artNetDataOffset+4)
+	copy(packet, artNetID)
+	binary.LittleEndian.PutUint16(packet[artNetOpCodeOffset:], artNetOpDmx)
+	packet[10], packet[11] = 0, 14 // protocol version
+	packet[artNetSubUniOffset] = 0x21
+	packet[artNetNetOffset] = 0x03
+	binary.BigEndian.PutUint16(packet[artNetLengthOffset:], 4)
+	copy(packet[artNetDataOffset:], []byte{1, 2, 3, 4})
+
+	universe, data, err := parseArtNet(packet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if universe != 0x321 || !reflect.DeepEqual(data, []byte{1, 2, 3, 4}) {
+		t.Errorf("parseArtNet() = %#x, %v, want 0x321, [1 2 3 4]", universe, data)
+	}
+
+	binary.LittleEndian.PutUint16(packet[artNetOpCodeOffset:], 0x2000) // ArtPoll
+	_, _, err = parseArtNet(packet)
+	if err != errArtNetNotDmx {
+		t.Errorf("ArtPoll err = %v, want %v", err, errArtNetNotDmx)
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// autooffCheckInterval is how often autooff checks whether the panels have
+// been idle for long enough.
+const autooffCheckInterval = 30 * time.Second
+
+// hourRange is a daily window of wall-clock time, like 22:00-07:00. It may
+// wrap past midnight.
+type hourRange struct {
+	Start, End time.Duration // since midnight
+}
+
+// parseHourRange parses a range like `22:00-07:00`.
+func parseHourRange(s string) (hourRange, error) {
+	from, to, ok := strings.Cut(s, "-")
+	if !ok {
+		return hourRange{}, fmt.Errorf("invalid hours %q, expected HH:MM-HH:MM", s)
+	}
+
+	var r hourRange
+	for i, part := range []string{from, to} {
+		t, err := time.Parse("15:04", strings.TrimSpace(part))
+		if err != nil {
+			return hourRange{}, fmt.Errorf("invalid hours %q, expected HH:MM-HH:MM", s)
+		}
+		offset := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+		if i == 0 {
+			r.Start = offset
+		} else {
+			r.End = offset
+		}
+	}
+	return r, nil
+}
+
+// Contains reports whether t's wall-clock time falls in the range.
+func (r hourRange) Contains(t time.Time) bool {
+	offset := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	if r.Start <= r.End {
+		return offset >= r.Start && offset < r.End
+	}
+	return offset >= r.Start || offset < r.End
+}
+
+// idleTracker tracks when the Nanoleaf was last changed, from its event
+// stream, to decide when it's been left on idle.
+type idleTracker struct {
+	mu    sync.Mutex
+	after time.Duration
+	hours *hourRange // nil for any time of day
+
+	on   bool
+	last time.Time
+}
+
+// Handle records a state or effects event at time now.
+func (t *idleTracker) Handle(event Event, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.last = now
+	if event.Type == StateEvent && event.Attr == AttrOn {
+		var on bool
+		if err := json.Unmarshal(event.Value, &on); err == nil {
+			t.on = on
+		}
+	}
+}
+
+// Due reports whether the panels are on and have been idle for long enough
+// to turn off at time now.
+func (t *idleTracker) Due(now time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.on || now.Sub(t.last) < t.after {
+		return false
+	}
+	return t.hours == nil || t.hours.Contains(now)
+}
+
+// TurnedOff records that the panels were turned off at time now.
+func (t *idleTracker) TurnedOff(now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.on, t.last = false, now
+}
+
+// doAutooffCommand turns the Nanoleaf off once nothing has changed it for a
+// while, until interrupted.
+func doAutooffCommand(client Client, args []string) {
+	section := cfg.Section("autooff")
+	flags := flag.NewFlagSet("autooff", flag.ExitOnError)
+	after := flags.Duration("after", section.Key("after").MustDuration(2*time.Hour), "How long the panels may sit unchanged before turning off")
+	hoursArg := flags.String("hours", section.Key("hours").String(), "Only turn off within these hours, like 22:00-07:00")
+	flags.Usage = func() {
+		fmt.Println("usage: picoleaf autooff [--after <duration>] [--hours <HH:MM-HH:MM>]")
+		exit(1)
+	}
+	flags.Parse(args)
+
+	if flags.NArg() > 0 || *after <= 0 {
+		flags.Usage()
+	}
+
+	tracker := &idleTracker{after: *after, last: time.Now()}
+	if *hoursArg != "" {
+		hours, err := parseHourRange(*hoursArg)
+		if err != nil {
+			fmt.Println("error:", err)
+			exit(1)
+		}
+		tracker.hours = &hours
+	}
+
+	snapshot, err := client.Snapshot()
+	if err != nil {
+		fmt.Println("error: failed to get Nanoleaf state:", err)
+		exit(1)
+	}
+	tracker.on = snapshot.On
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		for {
+			err := client.Subscribe(ctx, []int{StateEvent, EffectsEvent}, func(event Event) {
+				tracker.Handle(event, time.Now())
+			})
+			if ctx.Err() != nil {
+				return
+			}
+			slog.Error("lost connection to event stream", "err", err)
+			if !sleepOrCancel(linkRetryInterval) {
+				return
+			}
+		}
+	}()
+
+	slog.Info("watching for idle panels", "after", *after, "hours", *hoursArg)
+	ticker := time.NewTicker(autooffCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		now := time.Now()
+		if !tracker.Due(now) {
+			continue
+		}
+		slog.Info("panels idle, turning off", "after", *after)
+		if err := client.Off(); err != nil {
+			slog.Error("failed to turn off", "err", err)
+			continue
+		}
+		tracker.TurnedOff(now)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestHourRange(t *testing.T) {
+	night, err := parseHourRange("22:00-07:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	day, err := parseHourRange("09:00-17:30")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	at := func(hour, min int) time.Time { return time.Date(2024, 1, 1, hour, min, 0, 0, time.UTC) }
+	tests := []struct {
+		r    hourRange
+		t    time.Time
+		want bool
+	}{
+		{night, at(23, 0), true},
+		{night, at(3, 0), true},
+		{night, at(7, 0), false},
+		{night, at(12, 0), false},
+		{day, at(9, 0), true},
+		{day, at(17, 29), true},
+		{day, at(17, 30), false},
+	}
+	for _, tt := range tests {
+		if got := tt.r.Contains(tt.t); got != tt.want {
+			t.Errorf("%+v.Contains(%s) = %v, want %v", tt.r, tt.t.Format("15:04"), got, tt.want)
+		}
+	}
+
+	if _, err := parseHourRange("22:00"); err == nil {
+		t.Error("parseHourRange(22:00) succeeded, want an error")
+	}
+}
+
+func TestIdleTracker(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tracker := &idleTracker{after: time.Hour, on: true, last: start}
+
+	if tracker.Due(start.Add(59 * time.Minute)) {
+		t.Error("due before the idle period")
+	}
+
+	// Any change restarts the idle period.
+	tracker.Handle(Event{Type: StateEvent, Attr: AttrBrightness, Value: json.RawMessage("40")}, start.Add(30*time.Minute))
+	if tracker.Due(start.Add(time.Hour)) {
+		t.Error("due after a change restarted the idle period")
+	}
+	if !tracker.Due(start.Add(90 * time.Minute)) {
+		t.Error("not due after the idle period")
+	}
+
+	tracker.Handle(Event{Type: StateEvent, Attr: AttrOn, Value: json.RawMessage("false")}, start.Add(time.Hour))
+	if tracker.Due(start.Add(3 * time.Hour)) {
+		t.Error("due while off")
+	}
+
+	night := hourRange{Start: 22 * time.Hour, End: 7 * time.Hour}
+	tracker = &idleTracker{after: time.Hour, hours: &night, on: true, last: start}
+	if tracker.Due(start.Add(2 * time.Hour)) {
+		t.Error("due outside the configured hours")
+	}
+	if !tracker.Due(start.Add(11 * time.Hour)) {
+		t.Error("not due within the configured hours")
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// percentile returns the p-th percentile (0-100) of sorted durations.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	return sorted[i]
+}
+
+// printLatencies prints p50/p95/max of the given durations.
+func printLatencies(durations []time.Duration) {
+	sorted := append([]time.Duration(nil), durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	fmt.Printf("  p50: %8.2fms\n", float64(percentile(sorted, 50))/float64(time.Millisecond))
+	fmt.Printf("  p95: %8.2fms\n", float64(percentile(sorted, 95))/float64(time.Millisecond))
+	fmt.Printf("  max: %8.2fms\n", float64(percentile(sorted, 100))/float64(time.Millisecond))
+}
+
+func doBenchCommand(client Client, args []string) {
+	flags := flag.NewFlagSet("bench", flag.ExitOnError)
+	requests := flags.Int("requests", 50, "Number of REST requests")
+	frames := flags.Int("frames", 100, "Number of UDP frames (0 to skip)")
+	fps := flags.Int("fps", 30, "UDP frame rate")
+	flags.Usage = func() {
+		fmt.Println("usage: picoleaf bench [--requests <n>] [--frames <n>] [--fps <n>]")
+		exit(1)
+	}
+	flags.Parse(args)
+
+	if flags.NArg() > 0 || *requests < 1 || *frames < 0 || *fps < 1 {
+		flags.Usage()
+	}
+
+	benchREST(client, *requests)
+	if *frames > 0 {
+		fmt.Println()
+		benchUDP(client, *frames, *fps)
+	}
+}
+
+// benchREST measures REST API round trip latency.
+func benchREST(client Client, n int) {
+	var durations []time.Duration
+	errors := 0
+	for i := 0; i < n; i++ {
+		start := time.Now()
+		_, err := client.Get("state")
+		if err != nil {
+			errors++
+			continue
+		}
+		durations = append(durations, time.Since(start))
+	}
+
+	fmt.Printf("REST round trip (%d requests, %d errors)\n", n, errors)
+	if len(durations) == 0 {
+		fmt.Println("error: all requests failed")
+		exit(1)
+	}
+	printLatencies(durations)
+}
+
+// benchUDP streams frames at the given rate, measuring how long each send
+// takes and how far the actual frame interval drifts from the target. The
+// frames alternate between two dim whites, and the previous state is
+// restored afterwards.
+func benchUDP(client Client, n int, fps int) {
+	panelInfo, err := client.GetPanelInfo()
+	if err != nil {
+		fmt.Println("error: failed to get Nanoleaf layout:", err)
+		exit(1)
+	}
+
+	snapshot, err := client.Snapshot()
+	if err != nil {
+		fmt.Println("error: failed to get Nanoleaf state:", err)
+		exit(1)
+	}
+	defer func() {
+		err := client.Restore(*snapshot)
+		if err != nil {
+			fmt.Println("error: failed to restore Nanoleaf state:", err)
+		}
+	}()
+
+	caps := DetectCapabilities(panelInfo)
+	port, err := client.startExternalControl(caps.ExtControlVersion)
+	if err != nil {
+		fmt.Println("error: failed to start external control:", err)
+		return
+	}
+
+	conn, err := client.dialExternalControl(port)
+	if err != nil {
+		fmt.Println("error: failed to open UDP socket:", err)
+		return
+	}
+	defer conn.Close()
+
+	bufs := make([][]byte, 2)
+	for i := range bufs {
+		level := uint8(16 * (i + 1))
+		var frame []SetPanelColor
+		for _, panel := range panelInfo.PanelLayout.Layout.PositionData {
+			frame = append(frame, SetPanelColor{PanelID: uint16(panel.PanelID), Red: level, Green: level, Blue: level})
+		}
+		bufs[i], err = encodeControlFrame(caps.ExtControlVersion, frame)
+		if err != nil {
+			fmt.Println("error:", err)
+			return
+		}
+	}
+
+	interval := time.Second / time.Duration(fps)
+	var sends, jitter []time.Duration
+	errors := 0
+
+	start := time.Now()
+	last := start
+	for i := 0; i < n; i++ {
+		time.Sleep(time.Until(start.Add(time.Duration(i) * interval)))
+
+		now := time.Now()
+		if i > 0 {
+			jitter = append(jitter, (now.Sub(last) - interval).Abs())
+		}
+		last = now
+
+		err := writeControlFrame(conn, caps.ExtControlVersion, bufs[i%2])
+		if err != nil {
+			errors++
+		}
+		sends = append(sends, time.Since(now))
+	}
+	elapsed := time.Since(start)
+
+	fmt.Printf("UDP frames (%d frames at %d fps, %d errors)\n", n, fps, errors)
+	fmt.Printf("  throughput: %.1f fps\n", float64(n)/elapsed.Seconds())
+	fmt.Println()
+	fmt.Println("Send time:")
+	printLatencies(sends)
+	if len(jitter) > 0 {
+		fmt.Println()
+		fmt.Println("Frame interval jitter:")
+		printLatencies(jitter)
+	}
+}
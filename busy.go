@@ -0,0 +1,125 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Busy light statuses.
+const (
+	busyInCall = "busy"
+	busyFree   = "free"
+)
+
+// defaultBusyCommands are used for statuses missing from the `[busy]`
+// section.
+var defaultBusyCommands = map[string][]string{
+	busyInCall: {"hsl", "0", "100", "50"},
+	busyFree:   {"hsl", "120", "100", "50"},
+}
+
+// isCaptureDevice reports whether a device path is a camera or an audio
+// capture device on Linux.
+func isCaptureDevice(path string) bool {
+	if strings.HasPrefix(path, "/dev/video") {
+		return true
+	}
+	// ALSA capture PCMs are named like /dev/snd/pcmC0D0c.
+	name := filepath.Base(path)
+	return filepath.Dir(path) == "/dev/snd" && strings.HasPrefix(name, "pcmC") && strings.HasSuffix(name, "c")
+}
+
+// consentStoreInUse reports whether `reg query` output for Windows'
+// camera or microphone consent store shows an app still using the device,
+// which it marks with a zero LastUsedTimeStop.
+func consentStoreInUse(out string) bool {
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 3 && fields[0] == "LastUsedTimeStop" && fields[2] == "0x0" {
+			return true
+		}
+	}
+	return false
+}
+
+// shellCommand returns a command that runs a command line in the system
+// shell.
+func shellCommand(command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.Command("cmd", "/C", command)
+	}
+	return exec.Command("sh", "-c", command)
+}
+
+// commandInCall runs a command line, reporting a call when it exits 0.
+func commandInCall(command string) (bool, error) {
+	err := shellCommand(command).Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// doBusyCommand shows whether you're in a meeting: red when busy and green
+// otherwise, or the commands in the `[busy]` section.
+func doBusyCommand(client Client, args []string) {
+	usage := func() {
+		fmt.Println("usage: picoleaf busy on|off")
+		fmt.Println("       picoleaf busy auto [--command <command>] [--every <duration>]")
+		exit(1)
+	}
+
+	if len(args) < 1 {
+		usage()
+	}
+
+	light := &statusLight{section: "busy", defaults: defaultBusyCommands}
+	switch args[0] {
+	case "on":
+		runCommand(client, light.Command(busyInCall))
+	case "off":
+		runCommand(client, light.Command(busyFree))
+	case "auto":
+		flags := flag.NewFlagSet("busy auto", flag.ExitOnError)
+		command := flags.String("command", "", "Command that exits 0 during a call, instead of watching the camera and microphone")
+		every := flags.Duration("every", 5*time.Second, "How often to check for a call")
+		flags.Usage = usage
+		flags.Parse(args[1:])
+
+		if flags.NArg() > 0 || *every <= 0 {
+			usage()
+		}
+
+		check := deviceInCall
+		if *command != "" {
+			check = func() (bool, error) { return commandInCall(*command) }
+		}
+
+		slog.Info("watching for calls", "every", *every)
+		for {
+			inCall, err := check()
+			if err != nil {
+				fmt.Println("error: failed to check for a call:", err)
+				exit(1)
+			}
+			if inCall {
+				light.Update(busyInCall)
+			} else {
+				light.Update(busyFree)
+			}
+			if !sleepOrCancel(*every) {
+				return
+			}
+		}
+	default:
+		usage()
+	}
+}
@@ -0,0 +1,24 @@
+//go:build linux
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// deviceInCall reports whether any process has a camera or microphone open.
+// Only processes we're allowed to inspect, usually our own, are checked.
+func deviceInCall() (bool, error) {
+	fds, err := filepath.Glob("/proc/[0-9]*/fd/*")
+	if err != nil {
+		return false, err
+	}
+	for _, fd := range fds {
+		target, err := os.Readlink(fd)
+		if err == nil && isCaptureDevice(target) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,11 @@
+//go:build !linux && !windows
+
+package main
+
+import "errors"
+
+// deviceInCall would check the camera and microphone, but there's no
+// supported way to on this platform yet.
+func deviceInCall() (bool, error) {
+	return false, errors.New("can't detect calls on this platform, use --command")
+}
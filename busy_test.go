@@ -0,0 +1,48 @@
+package main
+
+import "testing"
+
+func TestIsCaptureDevice(t *testing.T) {
+	tests := map[string]bool{
+		"/dev/video0":        true,
+		"/dev/snd/pcmC0D0c":  true,
+		"/dev/snd/pcmC0D0p":  false,
+		"/dev/snd/controlC0": false,
+		"/dev/null":          false,
+		"socket:[12345]":     false,
+	}
+	for path, want := range tests {
+		if got := isCaptureDevice(path); got != want {
+			t.Errorf("isCaptureDevice(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestConsentStoreInUse(t *testing.T) {
+	idle := `
+HKEY_CURRENT_USER\Software\Microsoft\Windows\CurrentVersion\CapabilityAccessManager\ConsentStore\webcam\NonPackaged\C:#Program Files#Zoom#Zoom.exe
+    LastUsedTimeStart    REG_QWORD    0x1d9f1c6a2b3c4d5
+    LastUsedTimeStop    REG_QWORD    0x1d9f1c7b3c4d5e6
+`
+	if consentStoreInUse(idle) {
+		t.Error("consentStoreInUse = true for a stopped app")
+	}
+
+	inUse := idle + `
+HKEY_CURRENT_USER\Software\Microsoft\Windows\CurrentVersion\CapabilityAccessManager\ConsentStore\webcam\Microsoft.Teams
+    LastUsedTimeStart    REG_QWORD    0x1d9f1c8c4d5e6f7
+    LastUsedTimeStop    REG_QWORD    0x0
+`
+	if !consentStoreInUse(inUse) {
+		t.Error("consentStoreInUse = false for an app still using the camera")
+	}
+}
+
+func TestCommandInCall(t *testing.T) {
+	if inCall, err := commandInCall("exit 0"); err != nil || !inCall {
+		t.Errorf("commandInCall(exit 0) = %v, %v, want true", inCall, err)
+	}
+	if inCall, err := commandInCall("exit 1"); err != nil || inCall {
+		t.Errorf("commandInCall(exit 1) = %v, %v, want false", inCall, err)
+	}
+}
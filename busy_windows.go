@@ -0,0 +1,23 @@
+//go:build windows
+
+package main
+
+import "os/exec"
+
+// consentStoreKey is where Windows records which apps use privacy-sensitive
+// devices, and when.
+const consentStoreKey = `HKCU\Software\Microsoft\Windows\CurrentVersion\CapabilityAccessManager\ConsentStore\`
+
+// deviceInCall reports whether any app is using the camera or microphone.
+func deviceInCall() (bool, error) {
+	for _, device := range []string{"webcam", "microphone"} {
+		out, err := exec.Command("reg", "query", consentStoreKey+device, "/s").Output()
+		if err != nil {
+			return false, err
+		}
+		if consentStoreInUse(string(out)) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
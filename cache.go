@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// cacheDir is the directory in the state directory that panel info is
+// cached in, one file per host.
+const cacheDir = "cache"
+
+// cachedPanelInfo is a panel info cache file.
+type cachedPanelInfo struct {
+	Fetched time.Time `json:"fetched"`
+	Info    PanelInfo `json:"info"`
+}
+
+// panelInfoCachePath returns the path panel info for host is cached at.
+func panelInfoCachePath(host string) (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	dir = filepath.Join(dir, cacheDir)
+	err = os.MkdirAll(dir, 0700)
+	if err != nil {
+		return "", err
+	}
+	name := strings.NewReplacer(":", "_", "/", "_", "[", "", "]", "").Replace(host)
+	return filepath.Join(dir, name+".json"), nil
+}
+
+// CachedPanelInfo returns panel info fetched within CacheTTL if there is
+// any, and otherwise fetches and caches it. The layout and model change
+// rarely, but the state in cached info may be out of date, so use
+// GetPanelInfo for that.
+func (c Client) CachedPanelInfo() (*PanelInfo, error) {
+	if c.CacheTTL <= 0 {
+		return c.GetPanelInfo()
+	}
+
+	path, err := panelInfoCachePath(c.Host)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err == nil {
+		var cached cachedPanelInfo
+		err = json.Unmarshal(data, &cached)
+		if err == nil && time.Since(cached.Fetched) < c.CacheTTL {
+			return &cached.Info, nil
+		}
+	}
+
+	info, err := c.GetPanelInfo()
+	if err != nil {
+		return nil, err
+	}
+	data, err = json.Marshal(cachedPanelInfo{Fetched: time.Now(), Info: *info})
+	if err == nil {
+		err = writeFileAtomic(path, data)
+	}
+	if err != nil {
+		slog.Warn("failed to cache panel info", "error", err)
+	}
+	return info, nil
+}
+
+// clearPanelInfoCache removes any cached panel info for host.
+func clearPanelInfoCache(host string) error {
+	path, err := panelInfoCachePath(host)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
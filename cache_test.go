@@ -0,0 +1,61 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/paulrosania/picoleaf/internal/nltest"
+)
+
+func TestCachedPanelInfo(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	client, server := newTestClient(t)
+	client.CacheTTL = time.Hour
+
+	info, err := client.CachedPanelInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Model != "NL22" {
+		t.Errorf("model = %q, want NL22", info.Model)
+	}
+
+	server.Update(func(d *nltest.Device) { d.Model = "NL29" })
+	info, err = client.CachedPanelInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Model != "NL22" {
+		t.Errorf("cached model = %q, want NL22", info.Model)
+	}
+
+	err = clearPanelInfoCache(client.Host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err = client.CachedPanelInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Model != "NL29" {
+		t.Errorf("refreshed model = %q, want NL29", info.Model)
+	}
+}
+
+func TestCachedPanelInfoDisabled(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	client, server := newTestClient(t)
+
+	_, err := client.CachedPanelInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.Update(func(d *nltest.Device) { d.Model = "NL29" })
+	info, err := client.CachedPanelInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Model != "NL29" {
+		t.Errorf("model = %q, want NL29 without a cache_ttl", info.Model)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+// Calibration adjusts RGB colors for a particular device before they're
+// sent, e.g. so that Canvas and Shapes panels showing the same color match.
+type Calibration struct {
+	// Gamma is applied to each channel, on a 0-1 scale: out = in^Gamma.
+	Gamma float64
+
+	// Red, Green, and Blue scale each channel after gamma correction, to
+	// adjust the white point. 1 leaves a channel unchanged.
+	Red   float64
+	Green float64
+	Blue  float64
+}
+
+// Apply returns the calibrated color. A nil Calibration leaves colors
+// unchanged.
+func (cal *Calibration) Apply(c RGB) RGB {
+	if cal == nil {
+		return c
+	}
+	return RGB{
+		Red:   cal.channel(c.Red, cal.Red),
+		Green: cal.channel(c.Green, cal.Green),
+		Blue:  cal.channel(c.Blue, cal.Blue),
+	}
+}
+
+func (cal *Calibration) channel(v uint8, scale float64) uint8 {
+	out := math.Pow(float64(v)/255, cal.Gamma) * scale * 255
+	return uint8(math.Round(math.Min(out, 255)))
+}
+
+// ApplyFrames returns a calibrated copy of frames.
+func (cal *Calibration) ApplyFrames(frames []SetPanelColor) []SetPanelColor {
+	if cal == nil {
+		return frames
+	}
+
+	calibrated := make([]SetPanelColor, len(frames))
+	for i, frame := range frames {
+		c := cal.Apply(RGB{frame.Red, frame.Green, frame.Blue})
+		frame.Red, frame.Green, frame.Blue = c.Red, c.Green, c.Blue
+		calibrated[i] = frame
+	}
+	return calibrated
+}
+
+// deviceCalibration reads a device's `gamma` and `white_point` settings, e.g.:
+//
+//	gamma       = 2.2
+//	white_point = 1.0,0.92,0.85
+//
+// It returns nil if neither is set.
+func deviceCalibration(section *ini.Section) (*Calibration, error) {
+	if !section.HasKey("gamma") && !section.HasKey("white_point") {
+		return nil, nil
+	}
+
+	cal := &Calibration{Gamma: 1, Red: 1, Green: 1, Blue: 1}
+	if section.HasKey("gamma") {
+		gamma, err := section.Key("gamma").Float64()
+		if err != nil || gamma < 0.1 || gamma > 5 {
+			return nil, fmt.Errorf("gamma must be a number 0.1-5")
+		}
+		cal.Gamma = gamma
+	}
+
+	if section.HasKey("white_point") {
+		parts := strings.Split(section.Key("white_point").String(), ",")
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("white_point must be three numbers 0-1, e.g. 1.0,0.92,0.85")
+		}
+		scales := []*float64{&cal.Red, &cal.Green, &cal.Blue}
+		for i, part := range parts {
+			v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+			if err != nil || v < 0 || v > 1 {
+				return nil, fmt.Errorf("white_point must be three numbers 0-1, e.g. 1.0,0.92,0.85")
+			}
+			*scales[i] = v
+		}
+	}
+	return cal, nil
+}
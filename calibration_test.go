@@ -0,0 +1,83 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"gopkg.in/ini.v1"
+)
+
+func TestCalibrationApply(t *testing.T) {
+	cal := &Calibration{Gamma: 2, Red: 1, Green: 0.5, Blue: 0}
+
+	tests := []struct {
+		in, want RGB
+	}{
+		{RGB{255, 255, 255}, RGB{255, 128, 0}},
+		{RGB{128, 0, 64}, RGB{64, 0, 0}},
+		{RGB{0, 0, 0}, RGB{0, 0, 0}},
+	}
+	for _, tt := range tests {
+		if got := cal.Apply(tt.in); got != tt.want {
+			t.Errorf("Apply(%v) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+
+	var none *Calibration
+	if got := none.Apply(RGB{1, 2, 3}); got != (RGB{1, 2, 3}) {
+		t.Errorf("nil Apply changed the color to %v", got)
+	}
+}
+
+func TestDeviceCalibration(t *testing.T) {
+	tests := []struct {
+		config string
+		want   *Calibration
+	}{
+		{"", nil},
+		{"gamma=2.2", &Calibration{Gamma: 2.2, Red: 1, Green: 1, Blue: 1}},
+		{"white_point=1, 0.9, 0.8", &Calibration{Gamma: 1, Red: 1, Green: 0.9, Blue: 0.8}},
+	}
+	for _, tt := range tests {
+		f, err := ini.Load([]byte(tt.config))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := deviceCalibration(f.Section(""))
+		if err != nil {
+			t.Errorf("deviceCalibration(%q): %v", tt.config, err)
+		} else if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("deviceCalibration(%q) = %+v, want %+v", tt.config, got, tt.want)
+		}
+	}
+
+	for _, config := range []string{"gamma=0", "gamma=bright", "white_point=1,1", "white_point=1,1,2"} {
+		f, err := ini.Load([]byte(config))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := deviceCalibration(f.Section("")); err == nil {
+			t.Errorf("deviceCalibration(%q) succeeded, want error", config)
+		}
+	}
+}
+
+func TestSetCustomColorsCalibrated(t *testing.T) {
+	client, server := newTestClient(t)
+	client.Calibration = &Calibration{Gamma: 1, Red: 1, Green: 0.5, Blue: 0.5}
+
+	err := client.SetCustomColors([]SetPanelColor{{PanelID: 101, Red: 200, Green: 200, Blue: 100, TransitionTime: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	frames, err := server.WaitForFrames(1, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{0, 1, 0, 101, 200, 100, 50, 0, 0, 1}
+	if !reflect.DeepEqual(frames[0], want) {
+		t.Errorf("frame = %v, want %v", frames[0], want)
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// minExtControlV2Firmware is the oldest Light Panels (Aurora) firmware that
+// supports v2 external control. Newer models have always supported it.
+const minExtControlV2Firmware = "3.1.0"
+
+// modelNames maps Nanoleaf model numbers to product names.
+var modelNames = map[string]string{
+	"NL22": "Light Panels",
+	"NL29": "Canvas",
+	"NL42": "Shapes Hexagons",
+	"NL45": "Shapes Triangles",
+	"NL47": "Shapes Mini Triangles",
+	"NL48": "Shapes Controller",
+	"NL52": "Elements",
+	"NL59": "Lines",
+}
+
+// touchModels lists the models with touch-sensitive panels.
+var touchModels = map[string]bool{
+	"NL29": true,
+	"NL42": true,
+	"NL45": true,
+	"NL47": true,
+	"NL48": true,
+	"NL52": true,
+}
+
+// whiteModels lists the models that only show shades of white.
+var whiteModels = map[string]bool{
+	"NL52": true,
+}
+
+// valueRange is the range of values a Nanoleaf accepts for a state property.
+type valueRange struct {
+	Min, Max int
+}
+
+// check returns an error describing the range if v is outside it.
+func (r valueRange) check(name string, v int) error {
+	if v < r.Min || v > r.Max {
+		return fmt.Errorf("%s must be an integer %d-%d on this Nanoleaf", name, r.Min, r.Max)
+	}
+	return nil
+}
+
+// propertyRange returns the range reported for a state property, or def if
+// the Nanoleaf didn't report one.
+func propertyRange(min, max *int, def valueRange) valueRange {
+	if min == nil || max == nil {
+		return def
+	}
+	return valueRange{*min, *max}
+}
+
+// Capabilities describes the API features a Nanoleaf supports, based on its
+// model and firmware.
+type Capabilities struct {
+	Model           string
+	ProductName     string
+	FirmwareVersion string
+
+	// ExtControlVersion is the newest external control protocol supported,
+	// 1 or 2.
+	ExtControlVersion int
+
+	// TouchEvents is set for models with touch-sensitive panels.
+	TouchEvents bool
+
+	// FullColor is unset for models that only show shades of white.
+	FullColor bool
+
+	// The ranges of state values the Nanoleaf accepts.
+	Brightness       valueRange
+	Hue              valueRange
+	Saturation       valueRange
+	ColorTemperature valueRange
+}
+
+// DetectCapabilities works out what a Nanoleaf supports from its panel info.
+// Unknown models are assumed to be recent.
+func DetectCapabilities(info *PanelInfo) Capabilities {
+	caps := Capabilities{
+		Model:             info.Model,
+		ProductName:       modelNames[info.Model],
+		FirmwareVersion:   info.FirmwareVersion,
+		ExtControlVersion: 2,
+		TouchEvents:       touchModels[info.Model],
+		FullColor:         !whiteModels[info.Model],
+		Brightness:        valueRange{0, 100},
+		Hue:               valueRange{0, 360},
+		Saturation:        valueRange{0, 100},
+		ColorTemperature:  valueRange{1200, 6500},
+	}
+
+	state := info.State
+	if state.Brightness != nil {
+		caps.Brightness = propertyRange(state.Brightness.Min, state.Brightness.Max, caps.Brightness)
+	}
+	if state.Hue != nil {
+		caps.Hue = propertyRange(state.Hue.Min, state.Hue.Max, caps.Hue)
+	}
+	if state.Saturation != nil {
+		caps.Saturation = propertyRange(state.Saturation.Min, state.Saturation.Max, caps.Saturation)
+	}
+	if state.ColorTemperature != nil {
+		caps.ColorTemperature = propertyRange(state.ColorTemperature.Min, state.ColorTemperature.Max, caps.ColorTemperature)
+	}
+	if caps.ProductName == "" {
+		caps.ProductName = "Unknown model"
+	}
+
+	if info.Model == "NL22" && compareVersions(info.FirmwareVersion, minExtControlV2Firmware) < 0 {
+		caps.ExtControlVersion = 1
+	}
+	return caps
+}
+
+// Capabilities returns the features the Nanoleaf supports. Clients created
+// with NewClient only ask the Nanoleaf once.
+func (c Client) Capabilities() (*Capabilities, error) {
+	if c.session != nil {
+		c.session.capsMu.Lock()
+		defer c.session.capsMu.Unlock()
+		if c.session.caps != nil {
+			return c.session.caps, nil
+		}
+	}
+
+	info, err := c.CachedPanelInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	caps := DetectCapabilities(info)
+	if c.session != nil {
+		c.session.caps = &caps
+	}
+	return &caps, nil
+}
+
+// compareVersions compares dotted version strings numerically, returning -1,
+// 0, or 1.
+func compareVersions(a, b string) int {
+	as := strings.Split(a, ".")
+	bs := strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		if x < y {
+			return -1
+		} else if x > y {
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CI pipeline statuses.
+const (
+	ciSuccess = "success"
+	ciFailure = "failure"
+	ciPending = "pending"
+)
+
+// defaultCICommands are used for statuses missing from the `[ci]` section.
+var defaultCICommands = map[string][]string{
+	ciSuccess: {"hsl", "120", "100", "50"},
+	ciFailure: {"hsl", "0", "100", "50"},
+	ciPending: {"hsl", "50", "100", "50"},
+}
+
+// statusLight applies a command whenever a status changes. Each status's
+// command is read from a config section, falling back to defaults.
+type statusLight struct {
+	section  string
+	defaults map[string][]string
+
+	mu      sync.Mutex
+	current string
+}
+
+// Update runs the command configured for the status, if it has changed.
+func (l *statusLight) Update(status string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if status == l.current {
+		return
+	}
+
+	command := l.Command(status)
+	slog.Info("status changed", "source", l.section, "status", status, "command", strings.Join(command, " "))
+	err := runSubcommand(command)
+	if err != nil {
+		slog.Error("status command failed", "source", l.section, "status", status, "err", err)
+		return
+	}
+	l.current = status
+}
+
+// Command returns the command configured for a status.
+func (l *statusLight) Command(status string) []string {
+	if key, err := cfg.Section(l.section).GetKey(status); err == nil {
+		return strings.Fields(key.String())
+	}
+	return l.defaults[status]
+}
+
+// githubRun is the subset of a GitHub Actions workflow run we care about.
+type githubRun struct {
+	Status     string `json:"status"`
+	Conclusion string `json:"conclusion"`
+	HeadBranch string `json:"head_branch"`
+}
+
+// ciStatus maps a workflow run to a pipeline status.
+func (r githubRun) ciStatus() string {
+	if r.Status != "completed" {
+		return ciPending
+	}
+	switch r.Conclusion {
+	case "success", "neutral", "skipped":
+		return ciSuccess
+	}
+	return ciFailure
+}
+
+// normalizeCIStatus maps common status names onto success, failure, or
+// pending.
+func normalizeCIStatus(s string) (string, bool) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "success", "succeeded", "passed", "pass", "ok", "green":
+		return ciSuccess, true
+	case "failure", "failed", "fail", "error", "errored", "red":
+		return ciFailure, true
+	case "pending", "running", "queued", "in_progress", "started", "yellow":
+		return ciPending, true
+	}
+	return "", false
+}
+
+// fetchGitHubStatus returns the status of the latest workflow run on a branch.
+func fetchGitHubStatus(repo, branch, token string) (string, error) {
+	u := fmt.Sprintf("https://api.github.com/repos/%s/actions/runs?per_page=1&branch=%s", repo, url.QueryEscape(branch))
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("Accept", "application/vnd.github+json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	client := http.Client{Timeout: 30 * time.Second}
+	res, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("github: %s", res.Status)
+	}
+
+	var body struct {
+		WorkflowRuns []githubRun `json:"workflow_runs"`
+	}
+	err = json.NewDecoder(res.Body).Decode(&body)
+	if err != nil {
+		return "", err
+	}
+	if len(body.WorkflowRuns) == 0 {
+		return "", fmt.Errorf("no workflow runs found for %s on %s", repo, branch)
+	}
+	return body.WorkflowRuns[0].ciStatus(), nil
+}
+
+// fetchURLStatus polls a generic status URL. The response may be a JSON
+// object with a `status` field, or a plain-text status.
+func fetchURLStatus(u string) (string, error) {
+	client := http.Client{Timeout: 30 * time.Second}
+	res, err := client.Get(u)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return "", err
+	}
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s: %s", u, res.Status)
+	}
+
+	return parseCIStatusBody(body)
+}
+
+// parseCIStatusBody extracts a status from a generic status payload.
+func parseCIStatusBody(body []byte) (string, error) {
+	raw := string(body)
+
+	var payload struct {
+		Status string `json:"status"`
+	}
+	if json.Unmarshal(body, &payload) == nil && payload.Status != "" {
+		raw = payload.Status
+	}
+
+	status, ok := normalizeCIStatus(raw)
+	if !ok {
+		return "", fmt.Errorf("unrecognized status %q", strings.TrimSpace(raw))
+	}
+	return status, nil
+}
+
+// ciWebhookHandler accepts GitHub `workflow_run` webhooks, or generic JSON
+// payloads with a `status` field.
+func ciWebhookHandler(light *statusLight, branch, secret string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if secret != "" && !validGitHubSignature(body, secret, r.Header.Get("X-Hub-Signature-256")) {
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+
+		var event struct {
+			WorkflowRun *githubRun `json:"workflow_run"`
+		}
+		json.Unmarshal(body, &event)
+
+		var status string
+		if event.WorkflowRun != nil {
+			if branch != "" && event.WorkflowRun.HeadBranch != branch {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			status = event.WorkflowRun.ciStatus()
+		} else {
+			status, err = parseCIStatusBody(body)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		light.Update(status)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// validGitHubSignature checks a GitHub `X-Hub-Signature-256` header.
+func validGitHubSignature(body []byte, secret, signature string) bool {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	expected := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
+
+// configCIWebhookHandler returns a webhook handler for the daemon, using the
+// branch and secret from the `[ci]` section.
+func configCIWebhookHandler() http.HandlerFunc {
+	section := cfg.Section("ci")
+	light := &statusLight{section: "ci", defaults: defaultCICommands}
+	return ciWebhookHandler(light, section.Key("branch").MustString("main"), section.Key("secret").String())
+}
+
+func doCICommand(client Client, args []string) {
+	section := cfg.Section("ci")
+
+	flags := flag.NewFlagSet("ci", flag.ExitOnError)
+	repo := flags.String("github", "", "GitHub repository to poll (owner/repo)")
+	branch := flags.String("branch", section.Key("branch").MustString("main"), "Branch to watch")
+	token := flags.String("token", os.Getenv("GITHUB_TOKEN"), "GitHub API token")
+	statusURL := flags.String("url", "", "Generic status URL to poll")
+	listen := flags.String("listen", "", "Address to receive webhooks on, e.g. :8080")
+	secret := flags.String("secret", section.Key("secret").String(), "Webhook secret for verifying GitHub signatures")
+	every := flags.Duration("every", time.Minute, "Time between polls")
+	flags.Usage = func() {
+		fmt.Println("usage: picoleaf ci --github <owner/repo> [--branch <branch>] [--every <duration>]")
+		fmt.Println("       picoleaf ci --url <status url> [--every <duration>]")
+		fmt.Println("       picoleaf ci --listen <address> [--branch <branch>] [--secret <secret>]")
+		exit(1)
+	}
+	flags.Parse(args)
+	addLogSecret(*token)
+
+	modes := 0
+	for _, mode := range []string{*repo, *statusURL, *listen} {
+		if mode != "" {
+			modes++
+		}
+	}
+	if modes != 1 || flags.NArg() > 0 || *every <= 0 {
+		flags.Usage()
+	}
+
+	light := &statusLight{section: "ci", defaults: defaultCICommands}
+
+	if *listen != "" {
+		// A mux of our own, like the daemon's, so running this again from
+		// the REPL doesn't panic on a duplicate DefaultServeMux handler.
+		mux := http.NewServeMux()
+		mux.Handle("/", ciWebhookHandler(light, *branch, *secret))
+		slog.Info("listening for CI webhooks", "addr", *listen)
+		err := http.ListenAndServe(*listen, mux)
+		fmt.Println("error: webhook server failed:", err)
+		exit(1)
+	}
+
+	for {
+		var status string
+		var err error
+		if *repo != "" {
+			status, err = fetchGitHubStatus(*repo, *branch, *token)
+		} else {
+			status, err = fetchURLStatus(*statusURL)
+		}
+
+		if err != nil {
+			slog.Error("failed to fetch CI status", "err", err)
+		} else {
+			light.Update(status)
+		}
+
+		if !sleepOrCancel(*every) {
+			return
+		}
+	}
+}
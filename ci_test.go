@@ -0,0 +1,148 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseCIStatusBody(t *testing.T) {
+	tests := []struct {
+		body string
+		want string
+	}{
+		{`{"status":"passed"}`, ciSuccess},
+		{`{"status":"FAILED"}`, ciFailure},
+		{`{"status":"in_progress"}`, ciPending},
+		{"green\n", ciSuccess},
+		{" errored ", ciFailure},
+		{"queued", ciPending},
+	}
+	for _, tt := range tests {
+		got, err := parseCIStatusBody([]byte(tt.body))
+		if err != nil {
+			t.Errorf("parseCIStatusBody(%q): %v", tt.body, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseCIStatusBody(%q) = %q, want %q", tt.body, got, tt.want)
+		}
+	}
+
+	for _, body := range []string{"", "maybe", `{"status":"unknown"}`, `{"state":"passed"}`} {
+		if _, err := parseCIStatusBody([]byte(body)); err == nil {
+			t.Errorf("parseCIStatusBody(%q) succeeded, want error", body)
+		}
+	}
+}
+
+func TestGitHubRunStatus(t *testing.T) {
+	tests := []struct {
+		run  githubRun
+		want string
+	}{
+		{githubRun{Status: "queued"}, ciPending},
+		{githubRun{Status: "in_progress"}, ciPending},
+		{githubRun{Status: "completed", Conclusion: "success"}, ciSuccess},
+		{githubRun{Status: "completed", Conclusion: "skipped"}, ciSuccess},
+		{githubRun{Status: "completed", Conclusion: "failure"}, ciFailure},
+		{githubRun{Status: "completed", Conclusion: "cancelled"}, ciFailure},
+	}
+	for _, tt := range tests {
+		if got := tt.run.ciStatus(); got != tt.want {
+			t.Errorf("%+v.ciStatus() = %q, want %q", tt.run, got, tt.want)
+		}
+	}
+}
+
+func TestStatusLightCommand(t *testing.T) {
+	setTestConfig(t, `
+[ci]
+failure = notify --color red
+`)
+	light := &statusLight{section: "ci", defaults: defaultCICommands}
+	if got, want := light.Command(ciFailure), []string{"notify", "--color", "red"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("failure command = %q, want %q", got, want)
+	}
+	if got := light.Command(ciSuccess); !reflect.DeepEqual(got, defaultCICommands[ciSuccess]) {
+		t.Errorf("success command = %q, want the default", got)
+	}
+}
+
+func TestCIWebhookHandler(t *testing.T) {
+	setTestConfig(t, "")
+	const secret = "webhook-secret"
+	sign := func(body string) string {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(body))
+		return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	}
+
+	// The light already shows success, so nothing here runs a command.
+	light := &statusLight{section: "ci", defaults: defaultCICommands, current: ciSuccess}
+	handler := ciWebhookHandler(light, "main", secret)
+
+	tests := []struct {
+		name      string
+		method    string
+		body      string
+		signature string
+		want      int
+	}{
+		{"github run", http.MethodPost, `{"workflow_run":{"status":"completed","conclusion":"success","head_branch":"main"}}`, "", http.StatusNoContent},
+		{"other branch", http.MethodPost, `{"workflow_run":{"status":"completed","conclusion":"failure","head_branch":"dev"}}`, "", http.StatusNoContent},
+		{"generic", http.MethodPost, `{"status":"ok"}`, "", http.StatusNoContent},
+		{"unknown status", http.MethodPost, `{"status":"maybe"}`, "", http.StatusBadRequest},
+		{"bad signature", http.MethodPost, `{"status":"ok"}`, "sha256=00", http.StatusUnauthorized},
+		{"no signature", http.MethodPost, `{"status":"ok"}`, "-", http.StatusUnauthorized},
+		{"get", http.MethodGet, "", "", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, "/", strings.NewReader(tt.body))
+		switch tt.signature {
+		case "":
+			req.Header.Set("X-Hub-Signature-256", sign(tt.body))
+		case "-":
+		default:
+			req.Header.Set("X-Hub-Signature-256", tt.signature)
+		}
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, rec.Code, tt.want)
+		}
+	}
+	if light.current != ciSuccess {
+		t.Errorf("status = %q, want it left at success", light.current)
+	}
+}
+
+func TestConfigCIWebhookHandler(t *testing.T) {
+	setTestConfig(t, "[ci]\nbranch = release\nsecret = webhook-secret\n")
+	handler := configCIWebhookHandler()
+
+	body := `{"workflow_run":{"status":"completed","conclusion":"failure","head_branch":"main"}}`
+	req := httptest.NewRequest(http.MethodPost, "/ci", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("unsigned: status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+
+	// Signed, but for a branch other than the configured one, so it's
+	// ignored without running a command.
+	mac := hmac.New(sha256.New, []byte("webhook-secret"))
+	mac.Write([]byte(body))
+	req = httptest.NewRequest(http.MethodPost, "/ci", strings.NewReader(body))
+	req.Header.Set("X-Hub-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	rec = httptest.NewRecorder()
+	handler(rec, req)
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("other branch: status = %d, want %d", rec.Code, http.StatusNoContent)
+	}
+}
@@ -2,108 +2,513 @@ package main
 
 import (
 	"bytes"
+	"crypto/tls"
 	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"math"
 	"net"
 	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// DefaultAPIPort is the port the Nanoleaf REST API usually listens on.
+const DefaultAPIPort = 16021
+
+// DefaultHTTPSPort is the port assumed for HTTPS hosts without one, e.g. a
+// reverse proxy in front of the Nanoleaf.
+const DefaultHTTPSPort = 443
+
 // ExternalControlPort is the UDP port for Nanoleaf external control.
 const ExternalControlPort = 60222
 
+// ExternalControlV1Port is the UDP port for v1 external control, used by old
+// Light Panels firmware if it doesn't say otherwise.
+const ExternalControlV1Port = 60221
+
+// extControlIdleTimeout is how long a client trusts the Nanoleaf to still be
+// in external control mode after the last frame it sent. Past this, the
+// next frame repeats the REST handshake, in case something else changed the
+// effect in the meantime.
+const extControlIdleTimeout = 10 * time.Second
+
 // Client is a Nanoleaf REST API client.
+//
+// Clients created with NewClient keep their HTTP connections and external
+// control socket open across calls, which matters for streaming. Clients
+// created as struct literals work too, but set up external control from
+// scratch for every SetCustomColors call.
+//
+// A Client, and copies of it, may be used by multiple goroutines at once, as
+// long as its fields aren't changed while it's in use. Copies share one
+// external control session, whose frames are written by a single goroutine
+// in the order they're sent.
 type Client struct {
 	Host  string
 	Token string
 
-	Verbose bool
+	// HTTPS makes the client use HTTPS instead of plain HTTP. The Nanoleaf
+	// itself only speaks HTTP, so this is for reverse proxies in front of it.
+	HTTPS bool
+
+	// UDPPort overrides ExternalControlPort, e.g. for testing.
+	UDPPort int
+
+	// Calibration, if set, adjusts RGB colors before they're sent.
+	Calibration *Calibration
+
+	// Transition, if set, fades brightness, color, and color temperature
+	// changes over this long: with the API's duration field for brightness,
+	// and in steps sent by the client for the rest.
+	Transition time.Duration
+
+	// FrameRetransmits, if set, makes SetCustomColors send each frame this
+	// many extra times, frameRetransmitInterval apart, unless a newer frame
+	// replaces it. This makes up for UDP packets lost on busy networks.
+	FrameRetransmits int
+
+	// MaxBrightness, if set, caps the brightness SetBrightness, SetHSL, and
+	// PutState set.
+	MaxBrightness int
+
+	// Defaults are the device's configured defaults for picoleaf commands.
+	// The client itself doesn't use them.
+	Defaults Defaults
+
+	// CacheTTL, if set, lets CachedPanelInfo reuse panel info fetched within
+	// this long, even by an earlier picoleaf process.
+	CacheTTL time.Duration
+
+	// Logger receives requests and responses at debug level. If nil, the
+	// default slog logger is used.
+	Logger *slog.Logger
+
+	// Middleware wraps every REST request, the first entry outermost, e.g.
+	// for tracing, metrics, or extra auth headers.
+	Middleware []Middleware
+
+	client  http.Client
+	session *clientSession
+	limiter *writeLimiter
+}
+
+// clientSession is the connection state shared by copies of a Client.
+type clientSession struct {
+	mu      sync.Mutex
+	udp     *net.UDPConn
+	udpPort int
+
+	// frames carries encoded frames to the goroutine that writes them to
+	// udp, which closes writerDone when frames is closed, and reports the
+	// first failed write since the last frame was queued on writeErr.
+	// Guarded by mu.
+	frames     chan []byte
+	writerDone chan struct{}
+	writeErr   chan error
+
+	capsMu sync.Mutex
+	caps   *Capabilities
+
+	// extControlAt is when the last frame was sent in external control mode,
+	// in Unix nanoseconds, or zero if external control needs to be started.
+	extControlAt atomic.Int64
+
+	// streamed holds the panel colors sent by SetCustomColors since external
+	// control started, keyed by panel ID. Guarded by mu.
+	streamed map[int]RGB
+}
+
+// NewClient returns a client that reuses connections across calls. Call Close
+// when done with it.
+func NewClient(host string, token string) Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = 4
+
+	return Client{
+		Host:    host,
+		Token:   token,
+		client:  http.Client{Transport: transport},
+		session: &clientSession{},
+		limiter: newWriteLimiter(DefaultWriteRate, DefaultWriteBurst),
+	}
+}
+
+// SetTLSConfig sets the TLS configuration used for HTTPS connections, e.g.
+// to trust a custom CA.
+func (c *Client) SetTLSConfig(config *tls.Config) {
+	transport := c.httpTransport()
+	transport.TLSClientConfig = config
+	c.client.Transport = transport
+}
+
+// SetProxy sends REST requests through the proxy at proxyURL, which may be
+// an http, https, or socks5 URL. Otherwise, the HTTP_PROXY, HTTPS_PROXY, and
+// NO_PROXY environment variables are honored. External control uses UDP,
+// so it can't be proxied.
+func (c *Client) SetProxy(proxyURL *url.URL) {
+	transport := c.httpTransport()
+	transport.Proxy = http.ProxyURL(proxyURL)
+	c.client.Transport = transport
+}
+
+// SetTransport sets the transport REST requests are sent through, e.g. to
+// route them over an SSH tunnel. SetTLSConfig and SetProxy replace
+// transports that aren't an *http.Transport.
+func (c *Client) SetTransport(transport http.RoundTripper) {
+	c.client.Transport = transport
+}
+
+// httpTransport returns a copy of the client's transport to modify, or of
+// the default transport if it has a custom one.
+func (c Client) httpTransport() *http.Transport {
+	transport, ok := c.client.Transport.(*http.Transport)
+	if ok {
+		return transport.Clone()
+	}
+	return http.DefaultTransport.(*http.Transport).Clone()
+}
+
+// Middleware wraps the transport REST requests are sent through. It can
+// change a request before calling next, and inspect the response or error
+// it returns.
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+// RoundTripFunc adapts a function to an http.RoundTripper, for writing
+// Middleware.
+type RoundTripFunc func(*http.Request) (*http.Response, error)
+
+// RoundTrip calls f(req).
+func (f RoundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// transport returns the client's transport, wrapped in its middleware.
+func (c Client) transport() http.RoundTripper {
+	transport := c.client.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	for i := len(c.Middleware) - 1; i >= 0; i-- {
+		transport = c.Middleware[i](transport)
+	}
+	return transport
+}
+
+// Close releases the client's connections.
+func (c Client) Close() error {
+	c.client.CloseIdleConnections()
+	if c.session == nil {
+		return nil
+	}
+
+	c.session.mu.Lock()
+	defer c.session.mu.Unlock()
+
+	return c.session.closeUDP()
+}
 
-	client http.Client
+// frameQueueSize bounds the frames waiting for a session's writer goroutine.
+const frameQueueSize = 4
+
+// frameRetransmitInterval is how long to wait before resending a frame, with
+// FrameRetransmits set.
+const frameRetransmitInterval = 20 * time.Millisecond
+
+// openUDP starts a goroutine writing frames for the given external control
+// version to conn, which becomes the session's socket. first is the frame
+// already written to it. Each frame is resent up to retransmits times until a
+// newer one arrives. The caller must hold mu.
+func (s *clientSession) openUDP(conn *net.UDPConn, version int, first []byte, retransmits int) {
+	s.udp = conn
+	s.frames = make(chan []byte, frameQueueSize)
+	s.writerDone = make(chan struct{})
+	s.writeErr = make(chan error, 1)
+	go func(frames <-chan []byte, done chan<- struct{}, errs chan<- error) {
+		defer close(done)
+		last, remaining := first, retransmits
+		for {
+			var resend <-chan time.Time
+			if remaining > 0 {
+				resend = time.After(frameRetransmitInterval)
+			}
+			select {
+			case buf, ok := <-frames:
+				if !ok {
+					return
+				}
+				last, remaining = buf, retransmits
+			case <-resend:
+				remaining--
+			}
+
+			if err := writeControlFrame(conn, version, last); err != nil {
+				select {
+				case errs <- err:
+				default:
+				}
+			}
+		}
+	}(s.frames, s.writerDone, s.writeErr)
 }
 
-// Get performs a GET request.
+// closeUDP waits for queued frames to be written, then closes the session's
+// socket, if it has one. The caller must hold mu.
+func (s *clientSession) closeUDP() error {
+	if s.udp == nil {
+		return nil
+	}
+
+	close(s.frames)
+	<-s.writerDone
+	err := s.udp.Close()
+	s.udp, s.frames, s.writerDone, s.writeErr = nil, nil, nil, nil
+	return err
+}
+
+// Get performs a GET request. Error responses are returned as an *APIError.
 func (c Client) Get(path string) (string, error) {
-	if c.Verbose {
-		fmt.Println("GET", path)
+	return c.checkedRequest(http.MethodGet, path, nil)
+}
+
+// Put performs a PUT request. Error responses are returned as an *APIError.
+// State changes are subject to the client's rate limit; see SetRateLimit.
+func (c Client) Put(path string, body []byte) (string, error) {
+	if path == "state" {
+		return c.putState(body)
 	}
+	return c.checkedRequest(http.MethodPut, path, body)
+}
 
-	url := c.Endpoint(path)
-	req, err := http.NewRequest(http.MethodGet, url, nil)
+// checkedRequest performs a request, turning error responses into an
+// *APIError.
+func (c Client) checkedRequest(method, path string, body []byte) (string, error) {
+	status, responseBody, err := c.Request(method, path, body)
 	if err != nil {
 		return "", err
 	}
+	if status < 200 || status > 299 {
+		return "", &APIError{
+			Method:  method,
+			Path:    c.redact(path),
+			Status:  status,
+			Message: apiErrorMessage(responseBody),
+		}
+	}
+	return responseBody, nil
+}
+
+// APIError is an error response from the Nanoleaf, e.g. for an unknown
+// effect or an out-of-range value.
+type APIError struct {
+	Method  string
+	Path    string
+	Status  int
+	Message string // from the response body, if it had one
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("%s %s: %d %s", e.Method, e.Path, e.Status, http.StatusText(e.Status))
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	return msg
+}
+
+// apiErrorMessage extracts a message from an error response body: an
+// "error" or "message" field if it's a JSON object, a JSON string, or
+// otherwise the text itself.
+func apiErrorMessage(body string) string {
+	body = strings.TrimSpace(body)
+	var obj map[string]interface{}
+	if json.Unmarshal([]byte(body), &obj) == nil {
+		for _, key := range []string{"error", "message"} {
+			if msg, ok := obj[key].(string); ok {
+				return msg
+			}
+		}
+		return body
+	}
+	var msg string
+	if json.Unmarshal([]byte(body), &msg) == nil {
+		return msg
+	}
+	return body
+}
+
+// Request performs a request with any method, and returns the response's
+// status code and body. Get and Put are usually more convenient.
+func (c Client) Request(method, path string, body []byte) (int, string, error) {
+	if body != nil {
+		c.logger().Debug("request", "method", method, "path", c.redact(path), "body", string(body))
+	} else {
+		c.logger().Debug("request", "method", method, "path", c.redact(path))
+	}
+
+	// Any write may take the Nanoleaf out of external control mode.
+	if method != http.MethodGet && c.session != nil {
+		c.session.extControlAt.Store(0)
+	}
+
+	url := c.Endpoint(path)
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, "", c.redactError(err)
+	}
 
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
 	req.Header.Set("Accept", "application/json")
 
-	res, err := c.client.Do(req)
+	httpClient := c.client
+	httpClient.Transport = c.transport()
+	res, err := httpClient.Do(req)
 	if err != nil {
-		return "", err
+		return 0, "", c.redactError(err)
 	}
 
 	if res.Body != nil {
 		defer res.Body.Close()
 	}
 
-	body, err := io.ReadAll(res.Body)
+	responseBody, err := io.ReadAll(res.Body)
 	if err != nil {
-		return "", err
+		return 0, "", c.redactError(err)
 	}
 
-	if c.Verbose {
-		fmt.Println("<===", string(body))
-		fmt.Println()
+	c.logger().Debug("response", "status", res.Status, "body", string(responseBody))
+	return res.StatusCode, string(responseBody), nil
+}
+
+// logger returns the client's logger.
+func (c Client) logger() *slog.Logger {
+	if c.Logger != nil {
+		return c.Logger
 	}
-	return string(body), nil
+	return slog.Default()
 }
 
-// Put performs a PUT request.
-func (c Client) Put(path string, body []byte) (string, error) {
-	if c.Verbose {
-		fmt.Println("PUT", path)
-		fmt.Println("===>", string(body))
+// redact replaces the access token in s.
+func (c Client) redact(s string) string {
+	if c.Token == "" {
+		return s
 	}
+	return strings.ReplaceAll(s, c.Token, "<token>")
+}
 
-	url := c.Endpoint(path)
-	req, err := http.NewRequest(http.MethodPut, url, nil)
-	if err != nil {
-		return "", err
+// redactError removes the access token from request URLs in err, since
+// net/http includes the full URL in its errors.
+func (c Client) redactError(err error) error {
+	if urlErr, ok := err.(*url.Error); ok {
+		redacted := *urlErr
+		redacted.URL = c.redact(urlErr.URL)
+		return &redacted
 	}
+	return err
+}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Accept", "application/json")
+// Endpoint returns the full URL for an API endpoint.
+func (c Client) Endpoint(path string) string {
+	return c.apiURL(c.Token + "/" + path)
+}
 
-	req.Body = io.NopCloser(bytes.NewReader(body))
+// apiURL returns the full URL for a path under /api/v1/.
+func (c Client) apiURL(path string) string {
+	scheme := "http"
+	if c.HTTPS {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s/api/v1/%s", scheme, c.address(), path)
+}
+
+// errNotPairing is returned by Pair when the Nanoleaf isn't handing out
+// access tokens.
+var errNotPairing = errors.New("the Nanoleaf isn't in pairing mode")
 
-	res, err := c.client.Do(req)
+// Pair requests a new access token. The Nanoleaf only grants them for 30
+// seconds after its power button is held for 5-7 seconds; otherwise, Pair
+// returns errNotPairing.
+func (c Client) Pair() (string, error) {
+	req, err := http.NewRequest(http.MethodPost, c.apiURL("new"), nil)
 	if err != nil {
 		return "", err
 	}
 
-	if res.Body != nil {
-		defer res.Body.Close()
+	httpClient := http.Client{Timeout: probeTimeout, Transport: c.transport()}
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return "", err
 	}
+	defer res.Body.Close()
 
-	responseBody, err := io.ReadAll(res.Body)
+	body, err := io.ReadAll(res.Body)
 	if err != nil {
 		return "", err
 	}
+	switch {
+	case res.StatusCode == http.StatusForbidden || res.StatusCode == http.StatusUnauthorized:
+		return "", errNotPairing
+	case res.StatusCode < 200 || res.StatusCode > 299:
+		return "", &APIError{Method: http.MethodPost, Path: "new", Status: res.StatusCode, Message: apiErrorMessage(string(body))}
+	}
 
-	if c.Verbose {
-		fmt.Println("<===", res.Status)
-		if len(responseBody) > 0 {
-			fmt.Println("<===", string(responseBody))
-		}
-		fmt.Println()
+	var token struct {
+		AuthToken string `json:"auth_token"`
+	}
+	err = json.Unmarshal(body, &token)
+	if err == nil && token.AuthToken == "" {
+		err = errors.New("no auth_token in response")
 	}
-	return string(responseBody), nil
+	return token.AuthToken, err
 }
 
-// Endpoint returns the full URL for an API endpoint.
-func (c Client) Endpoint(path string) string {
-	return fmt.Sprintf("http://%s/api/v1/%s/%s", c.Host, c.Token, path)
+// address returns the client's host in host:port form, suitable for URLs.
+// IPv6 zones are escaped, e.g. `[fe80::1%25en0]:16021`.
+func (c Client) address() string {
+	defaultPort := DefaultAPIPort
+	if c.HTTPS {
+		defaultPort = DefaultHTTPSPort
+	}
+	hostname, port, err := splitHost(c.Host, defaultPort)
+	if err != nil {
+		return c.Host
+	}
+	address := net.JoinHostPort(hostname, strconv.Itoa(port))
+	return strings.ReplaceAll(address, "%", "%25")
+}
+
+// splitHost splits a host setting into a hostname and port. The host may be
+// a hostname, an IPv4 address, or an IPv6 address, with or without brackets,
+// and with or without a port. Hosts without a port get defaultPort. An IPv6
+// address with a port must be bracketed, e.g. `[fe80::1]:16021`.
+func splitHost(host string, defaultPort int) (string, int, error) {
+	hostname, portStr, err := net.SplitHostPort(host)
+	if err != nil {
+		// No port: either a plain hostname or a bare IPv6 address.
+		hostname = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+		if hostname == "" {
+			return "", 0, fmt.Errorf("invalid host %q", host)
+		}
+		return hostname, defaultPort, nil
+	}
+
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port < 1 || port > 65535 {
+		return "", 0, fmt.Errorf("invalid port in host %q", host)
+	}
+	if hostname == "" {
+		return "", 0, fmt.Errorf("invalid host %q", host)
+	}
+	return hostname, port, nil
 }
 
 // Effects represents the Nanoleaf panel effects state.
@@ -128,18 +533,21 @@ type Rhythm struct {
 	} `json:"rhythmPos"`
 }
 
+// PanelPosition represents the position of a single panel in the layout.
+type PanelPosition struct {
+	PanelID   int `json:"panelId"`
+	X         int `json:"x"`
+	Y         int `json:"y"`
+	O         int `json:"o"`
+	ShapeType int `json:"shapeType"`
+}
+
 // PanelLayout represents the Nanoleaf panel layout.
 type PanelLayout struct {
 	Layout struct {
-		NumPanels    int `json:"numPanels"`
-		SideLength   int `json:"sideLength"`
-		PositionData []struct {
-			PanelID   int `json:"panelId"`
-			X         int `json:"x"`
-			Y         int `json:"y"`
-			O         int `json:"o"`
-			ShapeType int `json:"shapeType"`
-		} `json:"positionData"`
+		NumPanels    int             `json:"numPanels"`
+		SideLength   int             `json:"sideLength"`
+		PositionData []PanelPosition `json:"positionData"`
 	} `json:"layout"`
 	GlobalOrientation struct {
 		Value int `json:"value"`
@@ -211,8 +619,28 @@ func (c Client) On() error {
 	return err
 }
 
+// PutState applies the non-nil properties of state.
+func (c Client) PutState(state State) error {
+	if state.Brightness != nil {
+		brightness := *state.Brightness
+		brightness.Value = c.clampBrightness(brightness.Value)
+		state.Brightness = &brightness
+	}
+	bytes, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	_, err = c.Put("state", bytes)
+	return err
+}
+
 // SelectEffect activates the specified effect.
 func (c Client) SelectEffect(name string) error {
+	err := c.limitBrightness()
+	if err != nil {
+		return err
+	}
+
 	req := effectsSelectRequest{
 		Select: name,
 	}
@@ -221,14 +649,128 @@ func (c Client) SelectEffect(name string) error {
 		return err
 	}
 
-	c.Put("effects/select", bytes)
-	return nil
+	_, err = c.Put("effects/select", bytes)
+	return err
+}
+
+// errDynamicEffect is returned by EffectColors for effects that animate, and
+// so don't have fixed panel colors.
+var errDynamicEffect = errors.New("effect is animated")
+
+// EffectColors returns the panel colors of a static or custom effect, keyed
+// by panel ID. Only the first frame of each panel is used.
+func (c Client) EffectColors(name string) (map[int]RGB, error) {
+	req, err := json.Marshal(map[string]interface{}{
+		"write": map[string]string{"command": "request", "animName": name},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := c.Put("effects", req)
+	if err != nil {
+		return nil, err
+	}
+
+	var effect struct {
+		AnimType string `json:"animType"`
+		AnimData string `json:"animData"`
+	}
+	err = json.Unmarshal([]byte(body), &effect)
+	if err != nil {
+		return nil, err
+	}
+	if effect.AnimType != "static" && effect.AnimType != "custom" {
+		return nil, errDynamicEffect
+	}
+	return parseAnimData(effect.AnimData)
+}
+
+// AddStaticEffect saves panel colors, keyed by panel ID, as a static effect
+// on the Nanoleaf.
+func (c Client) AddStaticEffect(name string, colors map[int]RGB) error {
+	if c.Calibration != nil {
+		calibrated := make(map[int]RGB, len(colors))
+		for id, color := range colors {
+			calibrated[id] = c.Calibration.Apply(color)
+		}
+		colors = calibrated
+	}
+
+	req, err := json.Marshal(map[string]interface{}{
+		"write": map[string]interface{}{
+			"command":  "add",
+			"animName": name,
+			"animType": "static",
+			"animData": formatAnimData(colors),
+			"loop":     false,
+			"palette":  []interface{}{},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = c.Put("effects", req)
+	return err
+}
+
+// formatAnimData formats panel colors as single-frame effect animation data,
+// ordered by panel ID.
+func formatAnimData(colors map[int]RGB) string {
+	ids := make([]int, 0, len(colors))
+	for id := range colors {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	fields := []string{strconv.Itoa(len(ids))}
+	for _, id := range ids {
+		c := colors[id]
+		fields = append(fields, fmt.Sprintf("%d 1 %d %d %d 0 1", id, c.Red, c.Green, c.Blue))
+	}
+	return strings.Join(fields, " ")
+}
+
+// parseAnimData parses the first frame of each panel from effect animation
+// data: the number of panels, then for each panel its ID, number of frames,
+// and that many frames of red, green, blue, white, and transition time.
+func parseAnimData(data string) (map[int]RGB, error) {
+	var fields []int
+	for _, f := range strings.Fields(data) {
+		n, err := strconv.Atoi(f)
+		if err != nil {
+			return nil, fmt.Errorf("invalid animation data: %v", err)
+		}
+		fields = append(fields, n)
+	}
+
+	errShort := errors.New("invalid animation data: too short")
+	if len(fields) < 1 {
+		return nil, errShort
+	}
+
+	colors := make(map[int]RGB)
+	i := 1
+	for n := 0; n < fields[0]; n++ {
+		if i+2 > len(fields) {
+			return nil, errShort
+		}
+		panelID, numFrames := fields[i], fields[i+1]
+		i += 2
+		if numFrames < 1 || i+5*numFrames > len(fields) {
+			return nil, errShort
+		}
+		colors[panelID] = RGB{Red: uint8(fields[i]), Green: uint8(fields[i+1]), Blue: uint8(fields[i+2])}
+		i += 5 * numFrames
+	}
+	return colors, nil
 }
 
 // SetBrightness sets the Nanoleaf's brightness.
 func (c Client) SetBrightness(brightness int) error {
 	state := State{
-		Brightness: &BrightnessProperty{Value: brightness},
+		Brightness: &BrightnessProperty{Value: c.clampBrightness(brightness), Duration: transitionSeconds(c.Transition)},
 	}
 
 	bytes, err := json.Marshal(state)
@@ -236,12 +778,47 @@ func (c Client) SetBrightness(brightness int) error {
 		return err
 	}
 
-	c.Put("state", bytes)
+	_, err = c.Put("state", bytes)
+	return err
+}
+
+// limitBrightness lowers the Nanoleaf's brightness to MaxBrightness, if it's
+// set and the brightness is higher, e.g. after another app changed it.
+// Effects and external control frames play at this brightness, so it's
+// checked before starting them.
+func (c Client) limitBrightness() error {
+	if c.MaxBrightness <= 0 {
+		return nil
+	}
+	body, err := c.Get("state/brightness")
+	if err != nil {
+		return err
+	}
+	var brightness BrightnessProperty
+	err = json.Unmarshal([]byte(body), &brightness)
+	if err != nil {
+		return err
+	}
+	if brightness.Value > c.MaxBrightness {
+		return c.SetBrightness(c.MaxBrightness)
+	}
 	return nil
 }
 
+// clampBrightness caps brightness at MaxBrightness, if it's set.
+func (c Client) clampBrightness(brightness int) int {
+	if c.MaxBrightness > 0 && brightness > c.MaxBrightness {
+		return c.MaxBrightness
+	}
+	return brightness
+}
+
 // SetColorTemperature sets the Nanoleaf's color temperature.
 func (c Client) SetColorTemperature(temperature int) error {
+	if c.Transition > 0 {
+		return c.rampColorTemperature(temperature)
+	}
+
 	state := State{
 		ColorTemperature: &ColorTemperatureProperty{Value: temperature},
 	}
@@ -251,14 +828,36 @@ func (c Client) SetColorTemperature(temperature int) error {
 		return err
 	}
 
-	c.Put("state", bytes)
-	return nil
+	_, err = c.Put("state", bytes)
+	return err
+}
+
+// EmulateColorTemperature approximates a color temperature, in kelvin, with
+// hue and saturation, leaving the brightness alone. This works for
+// temperatures outside the range the Nanoleaf's ct mode supports.
+func (c Client) EmulateColorTemperature(kelvin int) error {
+	hue, sat, _ := RGBToHSV(c.Calibration.Apply(KelvinToRGB(kelvin)))
+	if c.Transition > 0 {
+		return c.rampHueSaturation(&hue, &sat)
+	}
+	return c.PutState(State{
+		Hue:        &HueProperty{Value: hue},
+		Saturation: &SaturationProperty{Value: sat},
+	})
 }
 
 // SetHSL sets the Nanoleaf's hue, saturation, and lightness (brightness).
 func (c Client) SetHSL(hue int, sat int, lightness int) error {
+	if c.Transition > 0 {
+		err := c.SetBrightness(lightness)
+		if err != nil {
+			return err
+		}
+		return c.rampHueSaturation(&hue, &sat)
+	}
+
 	state := State{
-		Brightness: &BrightnessProperty{Value: lightness},
+		Brightness: &BrightnessProperty{Value: c.clampBrightness(lightness)},
 		Hue:        &HueProperty{Value: hue},
 		Saturation: &SaturationProperty{Value: sat},
 	}
@@ -268,20 +867,43 @@ func (c Client) SetHSL(hue int, sat int, lightness int) error {
 		return err
 	}
 
-	c.Put("state", bytes)
-	return nil
+	_, err = c.Put("state", bytes)
+	return err
 }
 
 // SetRGB sets the Nanoleaf's color by converting RGB to HSL.
 func (c Client) SetRGB(red int, green int, blue int) error {
-	h, s, l := rgbToHSL(red, green, blue)
+	color := c.Calibration.Apply(RGB{uint8(red), uint8(green), uint8(blue)})
+	h, s, l := RGBToHSL(color)
 	return c.SetHSL(h, s, l)
 }
 
-// startExternalControl sets Nanoleaf to accept UDP input.
-func (c Client) startExternalControl() error {
-	_, err := c.Put("effects", []byte(`{"write":{"command":"display","animType":"extControl","extControlVersion":"v2"}}`))
-	return err
+// startExternalControl sets Nanoleaf to accept UDP input using the given
+// external control protocol version, and returns the UDP port to send frames
+// to.
+func (c Client) startExternalControl(version int) (int, error) {
+	err := c.limitBrightness()
+	if err != nil {
+		return 0, err
+	}
+
+	if version == 2 {
+		_, err := c.Put("effects", []byte(`{"write":{"command":"display","animType":"extControl","extControlVersion":"v2"}}`))
+		return ExternalControlPort, err
+	}
+
+	body, err := c.Put("effects", []byte(`{"write":{"command":"display","animType":"extControl"}}`))
+	if err != nil {
+		return 0, err
+	}
+
+	var res struct {
+		Port int `json:"streamControlPort"`
+	}
+	if json.Unmarshal([]byte(body), &res) != nil || res.Port == 0 {
+		return ExternalControlV1Port, nil
+	}
+	return res.Port, nil
 }
 
 // SetPanelColor represents a frame of external color data.
@@ -294,36 +916,206 @@ type SetPanelColor struct {
 	TransitionTime uint16
 }
 
-// SetCustomColors sets individual Nanoleaf pane colors.
+// SetCustomColors sets individual Nanoleaf pane colors. The first frame sent
+// on a new socket is checked for getting through. After that, frames are
+// written in the background, so a failed write is returned by the next call.
 func (c Client) SetCustomColors(frames []SetPanelColor) error {
-	err := c.startExternalControl()
+	caps, err := c.Capabilities()
 	if err != nil {
 		return err
 	}
 
-	hostAddr, err := net.ResolveTCPAddr("tcp", c.Host)
+	buf, err := encodeControlFrame(caps.ExtControlVersion, c.Calibration.ApplyFrames(frames))
 	if err != nil {
 		return err
 	}
 
-	laddr, err := net.ResolveUDPAddr("udp", ":0")
-	if err != nil {
+	if c.session == nil {
+		port, err := c.startExternalControl(caps.ExtControlVersion)
+		if err != nil {
+			return err
+		}
+
+		conn, err := c.dialExternalControl(port)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		err = verifyExternalControl(conn, caps.ExtControlVersion, buf)
+		for i := 0; i < c.FrameRetransmits && err == nil; i++ {
+			time.Sleep(frameRetransmitInterval)
+			err = writeControlFrame(conn, caps.ExtControlVersion, buf)
+		}
 		return err
 	}
 
-	raddr, err := net.ResolveUDPAddr("udp", fmt.Sprintf("%s:%d", hostAddr.IP, ExternalControlPort))
+	c.session.mu.Lock()
+	defer c.session.mu.Unlock()
+
+	last := time.Unix(0, c.session.extControlAt.Load())
+	if time.Since(last) > extControlIdleTimeout {
+		c.session.udpPort, err = c.startExternalControl(caps.ExtControlVersion)
+		if err != nil {
+			return err
+		}
+		c.session.streamed = make(map[int]RGB)
+	}
+
+	if c.session.udp == nil {
+		conn, err := c.dialExternalControl(c.session.udpPort)
+		if err != nil {
+			return err
+		}
+		err = verifyExternalControl(conn, caps.ExtControlVersion, buf)
+		if err != nil {
+			conn.Close()
+			return err
+		}
+		c.session.openUDP(conn, caps.ExtControlVersion, buf, c.FrameRetransmits)
+	} else {
+		select {
+		case err := <-c.session.writeErr:
+			return fmt.Errorf("failed to send frame: %w", err)
+		default:
+		}
+		c.session.frames <- buf
+	}
+
+	c.session.extControlAt.Store(time.Now().UnixNano())
+	for _, f := range frames {
+		c.session.streamed[int(f.PanelID)] = RGB{f.Red, f.Green, f.Blue}
+	}
+	return nil
+}
+
+// StreamedColors returns the panel colors this client has sent over external
+// control, keyed by panel ID, or nil if it isn't in external control mode.
+// Only frames sent through this client are known.
+func (c Client) StreamedColors() map[int]RGB {
+	if c.session == nil || c.session.extControlAt.Load() == 0 {
+		return nil
+	}
+
+	c.session.mu.Lock()
+	defer c.session.mu.Unlock()
+
+	colors := make(map[int]RGB, len(c.session.streamed))
+	for id, color := range c.session.streamed {
+		colors[id] = color
+	}
+	return colors
+}
+
+// dialExternalControl opens a UDP socket to the given external control port
+// on the Nanoleaf.
+func (c Client) dialExternalControl(port int) (*net.UDPConn, error) {
+	hostname, _, err := splitHost(c.Host, DefaultAPIPort)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	conn, err := net.DialUDP("udp", laddr, raddr)
+	laddr, err := net.ResolveUDPAddr("udp", ":0")
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	if c.UDPPort != 0 {
+		port = c.UDPPort
+	}
+
+	raddr, err := net.ResolveUDPAddr("udp", net.JoinHostPort(hostname, strconv.Itoa(port)))
+	if err != nil {
+		return nil, err
+	}
+
+	return net.DialUDP("udp", laddr, raddr)
+}
+
+// udpVerifyTimeout is how long verifyExternalControl waits to hear that the
+// Nanoleaf's external control port is unreachable.
+const udpVerifyTimeout = 100 * time.Millisecond
+
+// verifyExternalControl sends the first frame on a new external control
+// socket, and checks that it got through. The Nanoleaf never replies, but if
+// the port is unreachable, e.g. because a firewall rejects the packet, the
+// ICMP error it triggers fails the read.
+func verifyExternalControl(conn *net.UDPConn, version int, frame []byte) error {
+	err := writeControlFrame(conn, version, frame)
+	if err == nil {
+		conn.SetReadDeadline(time.Now().Add(udpVerifyTimeout))
+		_, err = conn.Read(make([]byte, 1))
+		conn.SetReadDeadline(time.Time{})
+		var netErr net.Error
+		if err == nil || errors.As(err, &netErr) && netErr.Timeout() {
+			return nil
+		}
 	}
+	return fmt.Errorf("external control frames to %s aren't getting through; check that UDP isn't blocked by a firewall or the network (%v)", conn.RemoteAddr(), err)
+}
+
+// maxControlDatagramSize bounds the size of the UDP datagrams frames are sent
+// in. It leaves room under a 1500-byte Ethernet MTU for IP and UDP headers,
+// and for VPN or PPPoE overhead, so datagrams are never fragmented or dropped.
+const maxControlDatagramSize = 1400
+
+// controlFrameLayout returns the header and per-panel record sizes of
+// external control frames for the given protocol version.
+func controlFrameLayout(version int) (headerSize, recordSize int) {
+	if version == 1 {
+		return 1, 7
+	}
+	return 2, 8
+}
 
+// splitControlFrame splits an encoded external control frame into frames of
+// at most maxSize bytes, each with its own panel count. The Nanoleaf applies
+// each as it arrives, leaving panels missing from it alone, so large
+// installations can be updated with several datagrams.
+func splitControlFrame(version int, frame []byte, maxSize int) [][]byte {
+	if len(frame) <= maxSize {
+		return [][]byte{frame}
+	}
+
+	headerSize, recordSize := controlFrameLayout(version)
+	perChunk := (maxSize - headerSize) / recordSize
+	records := frame[headerSize:]
+	var chunks [][]byte
+	for len(records) > 0 {
+		n := min(perChunk, len(records)/recordSize)
+		chunk := make([]byte, headerSize, headerSize+n*recordSize)
+		if version == 1 {
+			chunk[0] = uint8(n)
+		} else {
+			binary.BigEndian.PutUint16(chunk, uint16(n))
+		}
+		chunks = append(chunks, append(chunk, records[:n*recordSize]...))
+		records = records[n*recordSize:]
+	}
+	return chunks
+}
+
+// writeControlFrame writes an encoded external control frame to conn, split
+// into datagrams no larger than maxControlDatagramSize.
+func writeControlFrame(conn io.Writer, version int, frame []byte) error {
+	for _, chunk := range splitControlFrame(version, frame, maxControlDatagramSize) {
+		if _, err := conn.Write(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeControlFrame encodes panel colors as an external control frame for
+// the given protocol version. Frames for many panels may need to be sent in
+// several datagrams, with writeControlFrame.
+func encodeControlFrame(version int, frames []SetPanelColor) ([]byte, error) {
+	if version == 1 {
+		return encodeControlFrameV1(frames)
+	}
 	numPanels := len(frames)
 	if numPanels < 0 || numPanels > math.MaxUint16 {
-		return fmt.Errorf("Expected between 0-%d panels, got %d", math.MaxUint16, numPanels)
+		return nil, fmt.Errorf("Expected between 0-%d panels, got %d", math.MaxUint16, numPanels)
 	}
 
 	headerSize := 2
@@ -340,10 +1132,29 @@ func (c Client) SetCustomColors(frames []SetPanelColor) error {
 		buf[offset+5] = panel.White
 		binary.BigEndian.PutUint16(buf[offset+6:], panel.TransitionTime)
 	}
+	return buf, nil
+}
 
-	conn.Write(buf)
-	conn.Close()
-	return nil
+// encodeControlFrameV1 encodes panel colors as a v1 external control frame,
+// which has one-byte panel IDs and transition times.
+func encodeControlFrameV1(frames []SetPanelColor) ([]byte, error) {
+	numPanels := len(frames)
+	if numPanels > math.MaxUint8 {
+		return nil, fmt.Errorf("Expected between 0-%d panels, got %d", math.MaxUint8, numPanels)
+	}
+
+	buf := make([]byte, 1, 1+7*numPanels)
+	buf[0] = uint8(numPanels)
+	for _, panel := range frames {
+		if panel.PanelID > math.MaxUint8 {
+			return nil, fmt.Errorf("panel ID %d is too large for v1 external control", panel.PanelID)
+		}
+		if panel.TransitionTime > math.MaxUint8 {
+			return nil, fmt.Errorf("transition time %d is too long for v1 external control", panel.TransitionTime)
+		}
+		buf = append(buf, uint8(panel.PanelID), 1, panel.Red, panel.Green, panel.Blue, panel.White, uint8(panel.TransitionTime))
+	}
+	return buf, nil
 }
 
 // BrightnessProperty represents the brightness of the Nanoleaf.
@@ -394,39 +1205,3 @@ type State struct {
 type effectsSelectRequest struct {
 	Select string `json:"select"`
 }
-
-func rgbToHSL(red, green, blue int) (int, int, int) {
-	r := float64(red) / 255.0
-	g := float64(green) / 255.0
-	b := float64(blue) / 255.0
-
-	min := math.Min(math.Min(r, g), b)
-	max := math.Max(math.Max(r, g), b)
-
-	c := max - min
-	l := (max + min) / 2
-
-	if c == 0 { // achromatic
-		return 0, 0, int(math.Round(100 * l))
-	}
-
-	v := max
-
-	h := 0.0
-	switch v {
-	case r:
-		h = 0 + (g-b)/c
-	case g:
-		h = 2 + (b-r)/c
-	case b:
-		h = 4 + (r-g)/c
-	}
-	h *= 60
-	if h < 0 {
-		h += 360
-	}
-
-	s := (v - l) / math.Min(l, 1-l)
-
-	return int(math.Round(h)), int(math.Round(100 * s)), int(math.Round(100 * l))
-}
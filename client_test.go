@@ -0,0 +1,1144 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/paulrosania/picoleaf/internal/nltest"
+)
+
+func newTestClient(t *testing.T) (Client, *nltest.Server) {
+	t.Helper()
+
+	server := nltest.NewServer()
+	t.Cleanup(server.Close)
+
+	client := Client{
+		Host:    server.Host(),
+		Token:   server.Token,
+		UDPPort: server.UDPPort(),
+	}
+	return client, server
+}
+
+func TestEndpoint(t *testing.T) {
+	tests := []struct {
+		host string
+		want string
+	}{
+		{"nanoleaf.local:16021", "http://nanoleaf.local:16021/api/v1/abc/state/on"},
+		{"nanoleaf.local", "http://nanoleaf.local:16021/api/v1/abc/state/on"},
+		{"192.168.1.20:8080", "http://192.168.1.20:8080/api/v1/abc/state/on"},
+		{"fe80::1", "http://[fe80::1]:16021/api/v1/abc/state/on"},
+		{"[fe80::1%en0]:16021", "http://[fe80::1%25en0]:16021/api/v1/abc/state/on"},
+	}
+
+	for _, tt := range tests {
+		client := Client{Host: tt.host, Token: "abc"}
+		got := client.Endpoint("state/on")
+		if got != tt.want {
+			t.Errorf("Endpoint() with host %q = %q, want %q", tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestHTTPS(t *testing.T) {
+	server := nltest.NewTLSServer()
+	t.Cleanup(server.Close)
+
+	client := NewClient(server.Host(), server.Token)
+	client.HTTPS = true
+	defer client.Close()
+
+	_, err := client.GetPanelInfo()
+	if err == nil {
+		t.Fatal("GetPanelInfo() trusted a self-signed certificate")
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(server.HTTP.Certificate())
+	client.SetTLSConfig(&tls.Config{RootCAs: pool})
+
+	info, err := client.GetPanelInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Name != "Fake Nanoleaf" {
+		t.Errorf("name = %q, want %q", info.Name, "Fake Nanoleaf")
+	}
+}
+
+func TestSplitHost(t *testing.T) {
+	tests := []struct {
+		host     string
+		hostname string
+		port     int
+		wantErr  bool
+	}{
+		{host: "nanoleaf.local", hostname: "nanoleaf.local", port: DefaultAPIPort},
+		{host: "nanoleaf.local:1234", hostname: "nanoleaf.local", port: 1234},
+		{host: "10.0.0.5", hostname: "10.0.0.5", port: DefaultAPIPort},
+		{host: "::1", hostname: "::1", port: DefaultAPIPort},
+		{host: "[::1]", hostname: "::1", port: DefaultAPIPort},
+		{host: "[::1]:1234", hostname: "::1", port: 1234},
+		{host: "nanoleaf.local:http", wantErr: true},
+		{host: "nanoleaf.local:70000", wantErr: true},
+		{host: ":16021", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		hostname, port, err := splitHost(tt.host, DefaultAPIPort)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("splitHost(%q) succeeded, want error", tt.host)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("splitHost(%q): %v", tt.host, err)
+			continue
+		}
+		if hostname != tt.hostname || port != tt.port {
+			t.Errorf("splitHost(%q) = %q, %d, want %q, %d", tt.host, hostname, port, tt.hostname, tt.port)
+		}
+	}
+}
+
+func TestGet(t *testing.T) {
+	client, _ := newTestClient(t)
+
+	body, err := client.Get("state/brightness/value")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(body) != "50" {
+		t.Errorf("Get() = %q, want 50", body)
+	}
+}
+
+func TestPut(t *testing.T) {
+	client, server := newTestClient(t)
+
+	_, err := client.Put("state", []byte(`{"brightness":{"value":12}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := server.Device().State.Brightness; got != 12 {
+		t.Errorf("brightness = %d, want 12", got)
+	}
+
+	requests := server.Requests()
+	last := requests[len(requests)-1]
+	if last.Method != "PUT" || last.Path != "state" {
+		t.Errorf("last request = %s %s, want PUT state", last.Method, last.Path)
+	}
+}
+
+func TestGetPanelInfo(t *testing.T) {
+	client, _ := newTestClient(t)
+
+	info, err := client.GetPanelInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Name != "Fake Nanoleaf" || info.Model != "NL22" || info.FirmwareVersion != "3.3.4" {
+		t.Errorf("unexpected panel info: %+v", info)
+	}
+	if !info.State.On.Value || info.State.Brightness.Value != 50 || info.State.ColorMode != "hs" {
+		t.Errorf("unexpected state: %+v", info.State)
+	}
+	if *info.State.ColorTemperature.Min != 1200 || *info.State.ColorTemperature.Max != 6500 {
+		t.Errorf("unexpected color temperature range: %+v", info.State.ColorTemperature)
+	}
+	if info.PanelLayout.Layout.NumPanels != 3 || len(info.PanelLayout.Layout.PositionData) != 3 {
+		t.Errorf("unexpected layout: %+v", info.PanelLayout.Layout)
+	}
+	if info.PanelLayout.Layout.PositionData[1].PanelID != 102 {
+		t.Errorf("panel 1 ID = %d, want 102", info.PanelLayout.Layout.PositionData[1].PanelID)
+	}
+}
+
+func TestListEffects(t *testing.T) {
+	client, _ := newTestClient(t)
+
+	effects, err := client.ListEffects()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"Flames", "Northern Lights"}
+	if !reflect.DeepEqual(effects, want) {
+		t.Errorf("ListEffects() = %v, want %v", effects, want)
+	}
+}
+
+func TestOnOff(t *testing.T) {
+	client, server := newTestClient(t)
+
+	err := client.Off()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if server.Device().State.On {
+		t.Error("Off() left the Nanoleaf on")
+	}
+
+	err = client.On()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !server.Device().State.On {
+		t.Error("On() left the Nanoleaf off")
+	}
+}
+
+func TestSelectEffect(t *testing.T) {
+	client, server := newTestClient(t)
+
+	err := client.SelectEffect("Northern Lights")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	device := server.Device()
+	if device.Effect != "Northern Lights" || device.State.ColorMode != "effect" {
+		t.Errorf("effect = %q (mode %q), want Northern Lights (mode effect)", device.Effect, device.State.ColorMode)
+	}
+}
+
+func TestSelectUnknownEffect(t *testing.T) {
+	client, _ := newTestClient(t)
+
+	err := client.SelectEffect("Nope")
+	apiErr, ok := err.(*APIError)
+	if !ok {
+		t.Fatalf("SelectEffect(Nope) = %v, want an *APIError", err)
+	}
+	if apiErr.Status != 422 || apiErr.Message != "unknown effect Nope" {
+		t.Errorf("error = %+v, want 422 with the server's message", apiErr)
+	}
+}
+
+func TestAPIErrorMessage(t *testing.T) {
+	tests := []struct {
+		body string
+		want string
+	}{
+		{"", ""},
+		{"unknown effect Nope\n", "unknown effect Nope"},
+		{`{"error":"value out of range"}`, "value out of range"},
+		{`{"message":"bad request"}`, "bad request"},
+		{`"invalid"`, "invalid"},
+		{`{"code":7}`, `{"code":7}`},
+	}
+	for _, tt := range tests {
+		if got := apiErrorMessage(tt.body); got != tt.want {
+			t.Errorf("apiErrorMessage(%q) = %q, want %q", tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestSetBrightness(t *testing.T) {
+	client, server := newTestClient(t)
+
+	err := client.SetBrightness(75)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := server.Device().State.Brightness; got != 75 {
+		t.Errorf("brightness = %d, want 75", got)
+	}
+}
+
+func TestMaxBrightness(t *testing.T) {
+	client, server := newTestClient(t)
+	client.MaxBrightness = 30
+
+	steps := map[string]func() error{
+		"SetBrightness": func() error { return client.SetBrightness(90) },
+		"SetHSL":        func() error { return client.SetHSL(10, 100, 80) },
+		"PutState":      func() error { return client.PutState(State{Brightness: &BrightnessProperty{Value: 100}}) },
+		"SelectEffect":  func() error { return client.SelectEffect("Flames") },
+		"SetCustomColors": func() error {
+			return client.SetCustomColors([]SetPanelColor{{PanelID: 101, Red: 255}})
+		},
+	}
+	for name, step := range steps {
+		// Another app turned it up.
+		server.Update(func(d *nltest.Device) { d.State.Brightness = 100 })
+		if err := step(); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if got := server.Device().State.Brightness; got != 30 {
+			t.Errorf("brightness after %s = %d, want max 30", name, got)
+		}
+	}
+
+	client.MaxBrightness = 0
+	if err := client.SetBrightness(90); err != nil {
+		t.Fatal(err)
+	}
+	if got := server.Device().State.Brightness; got != 90 {
+		t.Errorf("brightness without a max = %d, want 90", got)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	_, server := newTestClient(t)
+	client := NewClient(server.Host(), server.Token)
+	defer client.Close()
+	client.SetRateLimit(10, 1)
+
+	if err := client.SetBrightness(20); err != nil {
+		t.Fatal(err)
+	}
+
+	// These arrive while the first to wait for the limit is still waiting,
+	// so they're sent as one write.
+	writes := []func() error{
+		func() error { return client.SetBrightness(40) },
+		func() error { return client.SetHSL(100, 50, 50) },
+		func() error { return client.SetBrightness(60) },
+		func() error { return client.SetColorTemperature(3000) },
+	}
+	var wg sync.WaitGroup
+	errs := make([]error, len(writes))
+	for i, write := range writes {
+		wg.Add(1)
+		go func(i int, write func() error) {
+			defer wg.Done()
+			errs[i] = write()
+		}(i, write)
+		time.Sleep(10 * time.Millisecond)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var puts []string
+	for _, req := range server.Requests() {
+		if req.Method == http.MethodPut && req.Path == "state" {
+			puts = append(puts, req.Body)
+		}
+	}
+	want := []string{
+		`{"brightness":{"value":20}}`,
+		`{"brightness":{"value":60},"ct":{"value":3000}}`,
+	}
+	if !reflect.DeepEqual(puts, want) {
+		t.Errorf("state writes = %q, want %q", puts, want)
+	}
+	state := server.Device().State
+	if state.Brightness != 60 || state.ColorTemperature != 3000 || state.ColorMode != "ct" {
+		t.Errorf("state = %+v, want brightness 60 and ct 3000", state)
+	}
+
+	increment := map[string]json.RawMessage{"brightness": json.RawMessage(`{"increment":10}`)}
+	if canMergeState(map[string]json.RawMessage{"brightness": json.RawMessage(`{"value":5}`)}, increment) {
+		t.Error("merged an increment into a pending brightness")
+	}
+	if !canMergeState(map[string]json.RawMessage{"on": json.RawMessage(`{"value":true}`)}, increment) {
+		t.Error("didn't merge an increment into a pending write without brightness")
+	}
+}
+
+func TestCoalesceWindow(t *testing.T) {
+	client, server := newTestClient(t)
+	client.SetCoalesceWindow(50 * time.Millisecond)
+	client.SetRateLimit(0, 0)
+	if client.limiter == nil || client.limiter.window != 50*time.Millisecond {
+		t.Fatal("disabling the rate limit dropped the coalescing window")
+	}
+
+	// Without a rate limit to wait for, the first write still waits for the
+	// window, and the second merges into it.
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i, brightness := range []int{30, 70} {
+		wg.Add(1)
+		go func(i, brightness int) {
+			defer wg.Done()
+			errs[i] = client.SetBrightness(brightness)
+		}(i, brightness)
+		time.Sleep(10 * time.Millisecond)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var puts []string
+	for _, req := range server.Requests() {
+		if req.Method == http.MethodPut && req.Path == "state" {
+			puts = append(puts, req.Body)
+		}
+	}
+	if want := []string{`{"brightness":{"value":70}}`}; !reflect.DeepEqual(puts, want) {
+		t.Errorf("state writes = %q, want %q", puts, want)
+	}
+
+	client.SetCoalesceWindow(0)
+	if client.limiter != nil {
+		t.Error("a zero window without a rate limit left a limiter")
+	}
+}
+
+func TestSetColorTemperature(t *testing.T) {
+	client, server := newTestClient(t)
+
+	err := client.SetColorTemperature(2700)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state := server.Device().State
+	if state.ColorTemperature != 2700 || state.ColorMode != "ct" {
+		t.Errorf("ct = %d (mode %q), want 2700 (mode ct)", state.ColorTemperature, state.ColorMode)
+	}
+}
+
+func TestSetHSL(t *testing.T) {
+	client, server := newTestClient(t)
+
+	err := client.SetHSL(200, 60, 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state := server.Device().State
+	if state.Hue != 200 || state.Saturation != 60 || state.Brightness != 40 {
+		t.Errorf("hsl = (%d, %d, %d), want (200, 60, 40)", state.Hue, state.Saturation, state.Brightness)
+	}
+}
+
+func TestSetRGB(t *testing.T) {
+	client, server := newTestClient(t)
+
+	err := client.SetRGB(0, 0, 255)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state := server.Device().State
+	if state.Hue != 240 || state.Saturation != 100 || state.Brightness != 50 {
+		t.Errorf("hsl = (%d, %d, %d), want (240, 100, 50)", state.Hue, state.Saturation, state.Brightness)
+	}
+}
+
+func TestPutState(t *testing.T) {
+	client, server := newTestClient(t)
+
+	err := client.PutState(State{Hue: &HueProperty{Value: 10}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state := server.Device().State
+	if state.Hue != 10 || state.Saturation != 90 {
+		t.Errorf("hue, sat = %d, %d, want 10, 90", state.Hue, state.Saturation)
+	}
+}
+
+func TestSetCustomColors(t *testing.T) {
+	client, server := newTestClient(t)
+
+	err := client.SetCustomColors([]SetPanelColor{
+		{PanelID: 101, Red: 255, TransitionTime: 1},
+		{PanelID: 103, Green: 128, Blue: 64, White: 7, TransitionTime: 300},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !server.Device().ExtControl {
+		t.Error("SetCustomColors() didn't enable external control")
+	}
+
+	frames, err := server.WaitForFrames(1, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []byte{
+		0, 2,
+		0, 101, 255, 0, 0, 0, 0, 1,
+		0, 103, 0, 128, 64, 7, 1, 44,
+	}
+	if !reflect.DeepEqual(frames[0], want) {
+		t.Errorf("frame = %v, want %v", frames[0], want)
+	}
+	if n := binary.BigEndian.Uint16(frames[0]); n != 2 {
+		t.Errorf("frame panel count = %d, want 2", n)
+	}
+}
+
+func TestSetCustomColorsV1(t *testing.T) {
+	client, server := newTestClient(t)
+	server.Update(func(d *nltest.Device) {
+		d.FirmwareVersion = "2.2.0"
+	})
+
+	err := client.SetCustomColors([]SetPanelColor{
+		{PanelID: 101, Red: 255, TransitionTime: 1},
+		{PanelID: 103, Green: 128, Blue: 64, White: 7, TransitionTime: 30},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	frames, err := server.WaitForFrames(1, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []byte{
+		2,
+		101, 1, 255, 0, 0, 0, 1,
+		103, 1, 0, 128, 64, 7, 30,
+	}
+	if !reflect.DeepEqual(frames[0], want) {
+		t.Errorf("frame = %v, want %v", frames[0], want)
+	}
+
+	err = client.SetCustomColors([]SetPanelColor{{PanelID: 101, TransitionTime: 300}})
+	if err == nil {
+		t.Error("SetCustomColors() accepted a transition time v1 can't encode")
+	}
+}
+
+func TestDetectCapabilities(t *testing.T) {
+	tests := []struct {
+		model    string
+		firmware string
+		version  int
+		touch    bool
+	}{
+		{"NL22", "1.5.0", 1, false},
+		{"NL22", "3.1.0", 2, false},
+		{"NL29", "1.1.0", 2, true},
+		{"NL59", "3.0.0", 2, false},
+		{"NL99", "1.0.0", 2, false},
+	}
+
+	for _, tt := range tests {
+		caps := DetectCapabilities(&PanelInfo{Model: tt.model, FirmwareVersion: tt.firmware})
+		if caps.ExtControlVersion != tt.version || caps.TouchEvents != tt.touch {
+			t.Errorf("DetectCapabilities(%s %s) = v%d, touch %v, want v%d, touch %v",
+				tt.model, tt.firmware, caps.ExtControlVersion, caps.TouchEvents, tt.version, tt.touch)
+		}
+	}
+}
+
+func TestCapabilityRanges(t *testing.T) {
+	min, max := 1500, 4000
+	caps := DetectCapabilities(&PanelInfo{
+		Model: "NL52",
+		State: State{ColorTemperature: &ColorTemperatureProperty{Min: &min, Max: &max}},
+	})
+	if caps.FullColor {
+		t.Error("Elements FullColor = true, want false")
+	}
+	if caps.ColorTemperature != (valueRange{1500, 4000}) || caps.Brightness != (valueRange{0, 100}) {
+		t.Errorf("ranges = ct %v, brightness %v, want ct 1500-4000 and default brightness", caps.ColorTemperature, caps.Brightness)
+	}
+	if err := caps.ColorTemperature.check("temperature", 6500); err == nil {
+		t.Error("check(6500) succeeded, want error")
+	}
+	if err := caps.ColorTemperature.check("temperature", 2700); err != nil {
+		t.Errorf("check(2700): %v", err)
+	}
+}
+
+func TestColorCommandsCheckRanges(t *testing.T) {
+	client, server := newTestClient(t)
+	t.Setenv("HOME", t.TempDir()) // writes record undo history
+
+	for _, args := range [][]string{{"brightness", "101"}, {"temp", "7000"}, {"hsl", "361", "50", "50"}} {
+		if code := runCommandInProcess(client, args); code != 1 {
+			t.Errorf("%v: exit code = %d, want 1", args, code)
+		}
+	}
+
+	server.Update(func(d *nltest.Device) { d.Model = "NL52" })
+	if code := runCommandInProcess(client, []string{"rgb", "255", "0", "0"}); code != 1 {
+		t.Errorf("rgb on Elements: exit code = %d, want 1", code)
+	}
+
+	for _, req := range server.Requests() {
+		if req.Method == http.MethodPut {
+			t.Errorf("unexpected %s %s", req.Method, req.Path)
+		}
+	}
+}
+
+func TestEmulateColorTemperature(t *testing.T) {
+	client, server := newTestClient(t)
+	t.Setenv("HOME", t.TempDir()) // writes record undo history
+
+	if code := runCommandInProcess(client, []string{"temp", "1000"}); code != 1 {
+		t.Errorf("temp 1000: exit code = %d, want 1", code)
+	}
+	if code := runCommandInProcess(client, []string{"temp", "1000", "--emulate"}); code != 0 {
+		t.Fatalf("temp 1000 --emulate: exit code = %d, want 0", code)
+	}
+
+	state := server.Device().State
+	if state.ColorMode != "hs" || state.Hue < 10 || state.Hue > 30 || state.Saturation < 90 {
+		t.Errorf("state = %s mode, hue %d, sat %d, want a saturated orange", state.ColorMode, state.Hue, state.Saturation)
+	}
+}
+
+func TestDeviceDefaults(t *testing.T) {
+	_, server := newTestClient(t)
+	t.Setenv("HOME", t.TempDir()) // writes record undo history
+
+	setTestConfig(t, "host="+server.Host()+"\naccess_token="+server.Token+"\n"+
+		"default_transition=100ms\ndefault_brightness=40\ndefault_color_mode=hs\nmax_brightness=70\n")
+	device, err := findDevice("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Defaults{Transition: 100 * time.Millisecond, Brightness: 40, ColorMode: "hs"}
+	if device.Defaults != want || device.MaxBrightness != 70 {
+		t.Errorf("defaults = %+v, max %d, want %+v, max 70", device.Defaults, device.MaxBrightness, want)
+	}
+	client := device.Client()
+	client.UDPPort = server.UDPPort()
+
+	if code := runCommandInProcess(client, []string{"on"}); code != 0 {
+		t.Fatalf("on: exit code = %d, want 0", code)
+	}
+	if got := server.Device().State.Brightness; got != 40 {
+		t.Errorf("brightness after on = %d, want the default 40", got)
+	}
+	if code := runCommandInProcess(client, []string{"on", "--brightness", "90"}); code != 0 {
+		t.Fatalf("on --brightness 90: exit code = %d, want 0", code)
+	}
+	if got := server.Device().State.Brightness; got != 70 {
+		t.Errorf("brightness after on --brightness 90 = %d, want max_brightness 70", got)
+	}
+
+	if code := runCommandInProcess(client, []string{"temp", "1000"}); code != 0 {
+		t.Fatalf("temp 1000: exit code = %d, want 0 with default_color_mode=hs", code)
+	}
+	if mode := server.Device().State.ColorMode; mode != "hs" {
+		t.Errorf("color mode after temp = %q, want hs", mode)
+	}
+
+	for _, setting := range []string{"default_transition=-1s", "default_brightness=0", "default_color_mode=rgb", "max_brightness=101"} {
+		setTestConfig(t, "host=192.0.2.1\n"+setting)
+		if _, err := findDevice(""); err == nil {
+			t.Errorf("findDevice with %s: want an error", setting)
+		}
+	}
+}
+
+// closedUDPPort returns a local UDP port nothing is listening on.
+func closedUDPPort(t *testing.T) int {
+	t.Helper()
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := conn.LocalAddr().(*net.UDPAddr).Port
+	conn.Close()
+	return port
+}
+
+func TestSetCustomColorsBlockedUDP(t *testing.T) {
+	_, server := newTestClient(t)
+
+	oneShot := Client{Host: server.Host(), Token: server.Token, UDPPort: closedUDPPort(t)}
+	session := NewClient(server.Host(), server.Token)
+	session.UDPPort = closedUDPPort(t)
+	defer session.Close()
+
+	for name, client := range map[string]Client{"one-shot": oneShot, "session": session} {
+		err := client.SetCustomColors([]SetPanelColor{{PanelID: 101, Red: 255}})
+		if err == nil || !strings.Contains(err.Error(), "aren't getting through") {
+			t.Errorf("%s: SetCustomColors() = %v, want an error about UDP being blocked", name, err)
+		}
+	}
+}
+
+func TestSetCustomColorsRetransmits(t *testing.T) {
+	server := nltest.NewServer()
+	t.Cleanup(server.Close)
+
+	client := NewClient(server.Host(), server.Token)
+	client.UDPPort = server.UDPPort()
+	client.FrameRetransmits = 2
+	defer client.Close()
+
+	err := client.SetCustomColors([]SetPanelColor{{PanelID: 101, Red: 255}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	frames, err := server.WaitForFrames(3, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(3 * frameRetransmitInterval)
+	frames = server.Frames()
+	if len(frames) != 3 {
+		t.Fatalf("frames = %d, want the frame and 2 retransmits", len(frames))
+	}
+	for _, frame := range frames[1:] {
+		if !reflect.DeepEqual(frame, frames[0]) {
+			t.Errorf("retransmitted frame = %v, want %v", frame, frames[0])
+		}
+	}
+
+	oneShot := Client{Host: server.Host(), Token: server.Token, UDPPort: server.UDPPort(), FrameRetransmits: 1}
+	err = oneShot.SetCustomColors([]SetPanelColor{{PanelID: 101, Green: 255}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := server.WaitForFrames(5, time.Second); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSplitControlFrame(t *testing.T) {
+	small := []byte{0, 1, 0, 101, 255, 0, 0, 0, 0, 1}
+	if chunks := splitControlFrame(2, small, maxControlDatagramSize); len(chunks) != 1 || !reflect.DeepEqual(chunks[0], small) {
+		t.Errorf("small frame split into %v, want it unchanged", chunks)
+	}
+
+	var frames []SetPanelColor
+	for i := 0; i < 5; i++ {
+		frames = append(frames, SetPanelColor{PanelID: uint16(i + 1), Red: uint8(i)})
+	}
+	for _, version := range []int{1, 2} {
+		frame, err := encodeControlFrame(version, frames)
+		if err != nil {
+			t.Fatal(err)
+		}
+		headerSize, recordSize := controlFrameLayout(version)
+		chunks := splitControlFrame(version, frame, headerSize+2*recordSize)
+
+		var want [][]byte
+		for _, part := range [][]SetPanelColor{frames[:2], frames[2:4], frames[4:]} {
+			chunk, err := encodeControlFrame(version, part)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want = append(want, chunk)
+		}
+		if !reflect.DeepEqual(chunks, want) {
+			t.Errorf("v%d: chunks = %v, want %v", version, chunks, want)
+		}
+	}
+}
+
+func TestSetCustomColorsLargeInstallation(t *testing.T) {
+	client, server := newTestClient(t)
+	const numPanels = 400
+	server.Update(func(d *nltest.Device) {
+		d.Model = "NL42"
+		d.Panels = nil
+		for i := 0; i < numPanels; i++ {
+			d.Panels = append(d.Panels, nltest.Panel{ID: i + 1, X: 100 * (i % 20), Y: 100 * (i / 20), ShapeType: 7})
+		}
+	})
+
+	var frames []SetPanelColor
+	for i := 0; i < numPanels; i++ {
+		frames = append(frames, SetPanelColor{PanelID: uint16(i + 1), Red: uint8(i), Blue: 255, TransitionTime: 1})
+	}
+	err := client.SetCustomColors(frames)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want, err := encodeControlFrame(2, frames)
+	if err != nil {
+		t.Fatal(err)
+	}
+	datagrams, err := server.WaitForFrames(3, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Reassemble the datagrams' panel records, which should add up to the
+	// whole frame.
+	var panels int
+	records := []byte{}
+	for _, d := range datagrams {
+		if len(d) > maxControlDatagramSize {
+			t.Errorf("datagram is %d bytes, want at most %d", len(d), maxControlDatagramSize)
+		}
+		n := int(binary.BigEndian.Uint16(d))
+		if len(d) != 2+8*n {
+			t.Fatalf("datagram of %d bytes has a count of %d panels", len(d), n)
+		}
+		panels += n
+		records = append(records, d[2:]...)
+	}
+	if panels != numPanels || !reflect.DeepEqual(records, want[2:]) {
+		t.Errorf("datagrams carried %d panels, want all %d in order", panels, numPanels)
+	}
+}
+
+func TestSetCustomColorsReusesSession(t *testing.T) {
+	server := nltest.NewServer()
+	t.Cleanup(server.Close)
+
+	client := NewClient(server.Host(), server.Token)
+	client.UDPPort = server.UDPPort()
+	defer client.Close()
+
+	for i := 0; i < 3; i++ {
+		err := client.SetCustomColors([]SetPanelColor{{PanelID: 101, Red: uint8(i)}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err := server.WaitForFrames(3, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var handshakes int
+	for _, req := range server.Requests() {
+		if req.Method == http.MethodPut && req.Path == "effects" {
+			handshakes++
+		}
+	}
+	if handshakes != 1 {
+		t.Errorf("external control handshakes = %d, want 1", handshakes)
+	}
+
+	// Any other write may end external control, so the next frame must
+	// start it again.
+	err = client.SetHSL(10, 90, 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if server.Device().ExtControl {
+		t.Fatal("SetHSL() didn't end external control")
+	}
+	err = client.SetCustomColors([]SetPanelColor{{PanelID: 101}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !server.Device().ExtControl {
+		t.Error("SetCustomColors() didn't restart external control after a state change")
+	}
+}
+
+func TestClientConcurrentUse(t *testing.T) {
+	server := nltest.NewServer()
+	t.Cleanup(server.Close)
+
+	client := NewClient(server.Host(), server.Token)
+	client.UDPPort = server.UDPPort()
+
+	const workers, frames = 4, 10
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*workers*frames)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(c Client, w int) {
+			defer wg.Done()
+			for i := 0; i < frames; i++ {
+				errs <- c.SetCustomColors([]SetPanelColor{{PanelID: uint16(101 + w%3), Red: uint8(i)}})
+				c.StreamedColors()
+				if _, err := c.Capabilities(); err != nil {
+					errs <- err
+				}
+			}
+		}(client, w)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := client.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = server.WaitForFrames(workers*frames, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(client.StreamedColors()); got != 3 {
+		t.Errorf("streamed colors for %d panels, want 3", got)
+	}
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	client, server := newTestClient(t)
+
+	err := client.SelectEffect("Flames")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = client.SetBrightness(30)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot, err := client.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.Effect != "Flames" || snapshot.ColorMode != "effect" || snapshot.Brightness != 30 || !snapshot.On {
+		t.Errorf("unexpected snapshot: %+v", snapshot)
+	}
+
+	err = client.SetColorTemperature(6500)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = client.Off()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = client.Restore(*snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	device := server.Device()
+	if device.Effect != "Flames" || device.State.Brightness != 30 || !device.State.On {
+		t.Errorf("restored effect = %q, brightness = %d, on = %v", device.Effect, device.State.Brightness, device.State.On)
+	}
+}
+
+func TestRestoreOnInterrupt(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("can't send os.Interrupt on Windows")
+	}
+	client, server := newTestClient(t)
+	err := client.SelectEffect("Flames")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A mode that finishes on its own leaves the panels as they are.
+	_, restore := restoreOnInterrupt(client)
+	err = client.SetCustomColors([]SetPanelColor{{PanelID: 101, Red: 255}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	restore()
+	if effect := server.Device().Effect; effect != "*ExtControl*" {
+		t.Errorf("effect after finishing = %q, want *ExtControl*", effect)
+	}
+
+	err = client.SelectEffect("Flames")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, restore := restoreOnInterrupt(client)
+	err = client.SetCustomColors([]SetPanelColor{{PanelID: 101, Red: 255}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = p.Signal(os.Interrupt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("context wasn't cancelled by the interrupt")
+	}
+	restore()
+	if effect := server.Device().Effect; effect != "Flames" {
+		t.Errorf("effect after interrupt = %q, want Flames", effect)
+	}
+}
+
+func TestApplyScene(t *testing.T) {
+	client, server := newTestClient(t)
+
+	ct, brightness := 3000, 20
+	err := client.ApplyScene(Scene{ColorTemperature: &ct, Brightness: &brightness})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state := server.Device().State
+	if state.ColorTemperature != 3000 || state.Brightness != 20 || state.ColorMode != "ct" {
+		t.Errorf("unexpected state: %+v", state)
+	}
+}
+
+func TestApplySceneLeavesPowerAlone(t *testing.T) {
+	client, server := newTestClient(t)
+	server.Update(func(d *nltest.Device) { d.State.On = false })
+
+	brightness := 40
+	if err := client.ApplyScene(Scene{Brightness: &brightness}); err != nil {
+		t.Fatal(err)
+	}
+	for _, req := range server.Requests() {
+		if req.Method == http.MethodPut && strings.Contains(req.Body, `"on"`) {
+			t.Errorf("scene without on changed power: PUT %s %s", req.Path, req.Body)
+		}
+	}
+
+	on := true
+	if err := client.ApplyScene(Scene{On: &on, Brightness: &brightness}); err != nil {
+		t.Fatal(err)
+	}
+	if !server.Device().State.On {
+		t.Error("scene with on=true left the light off")
+	}
+}
+
+func TestClientMiddleware(t *testing.T) {
+	client, server := newTestClient(t)
+
+	var order []string
+	var statuses []int
+	trace := func(name string) Middleware {
+		return func(next http.RoundTripper) http.RoundTripper {
+			return RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+				order = append(order, name)
+				res, err := next.RoundTrip(req)
+				if err == nil {
+					statuses = append(statuses, res.StatusCode)
+				}
+				return res, err
+			})
+		}
+	}
+	client.Middleware = []Middleware{trace("outer"), trace("inner")}
+
+	_, err := client.Get("state/on")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(order, []string{"outer", "inner"}) {
+		t.Errorf("middleware order = %q, want outer then inner", order)
+	}
+	if !reflect.DeepEqual(statuses, []int{200, 200}) {
+		t.Errorf("statuses seen = %v, want 200 from both", statuses)
+	}
+	if n := len(server.Requests()); n != 1 {
+		t.Errorf("server saw %d requests, want 1", n)
+	}
+}
+
+func TestClientProxy(t *testing.T) {
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.URL.Host)
+		w.Write([]byte(`{"value":true}`))
+	}))
+	defer proxy.Close()
+
+	setTestConfig(t, "host=192.0.2.1\naccess_token=abc\nproxy="+proxy.URL)
+	device, err := findDevice("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := device.Client()
+
+	body, err := client.Get("state/on")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body != `{"value":true}` {
+		t.Errorf("body = %q, want the proxy's response", body)
+	}
+	if !reflect.DeepEqual(proxied, []string{"192.0.2.1:16021"}) {
+		t.Errorf("proxied hosts = %q, want the Nanoleaf's", proxied)
+	}
+
+	for _, proxy := range []string{"ftp://example.com", "socks5://", "localhost:1080"} {
+		setTestConfig(t, "host=192.0.2.1\nproxy="+proxy)
+		if _, err := findDevice(""); err == nil {
+			t.Errorf("findDevice with proxy=%s: want an error", proxy)
+		}
+	}
+}
+
+func TestClientSetTransport(t *testing.T) {
+	client := NewClient("192.0.2.1", "abc")
+	client.SetTransport(RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Status:     "200 OK",
+			Body:       io.NopCloser(strings.NewReader(`{"value":42}`)),
+		}, nil
+	}))
+
+	body, err := client.Get("state/brightness")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body != `{"value":42}` {
+		t.Errorf("body = %q, want the transport's response", body)
+	}
+}
+
+func TestPair(t *testing.T) {
+	var mu sync.Mutex
+	pairing := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Method != http.MethodPost || r.URL.Path != "/api/v1/new" {
+			http.NotFound(w, r)
+			return
+		}
+		if !pairing {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"auth_token":"abc"}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(strings.TrimPrefix(server.URL, "http://"), "")
+	if _, err := client.Pair(); !errors.Is(err, errNotPairing) {
+		t.Fatalf("Pair() before pairing = %v, want errNotPairing", err)
+	}
+
+	mu.Lock()
+	pairing = true
+	mu.Unlock()
+	token, err := client.Pair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "abc" {
+		t.Errorf("token = %q, want abc", token)
+	}
+}
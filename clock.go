@@ -0,0 +1,170 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Canvas square panel shape types.
+const (
+	shapeSquare               = 2
+	shapeControlSquareMaster  = 3
+	shapeControlSquarePassive = 4
+)
+
+// clockFont is a 3x5 pixel font for the clock, one string per row from the
+// top.
+var clockFont = map[rune][]string{
+	'0': {"###", "#.#", "#.#", "#.#", "###"},
+	'1': {".#.", "##.", ".#.", ".#.", "###"},
+	'2': {"###", "..#", "###", "#..", "###"},
+	'3': {"###", "..#", ".##", "..#", "###"},
+	'4': {"#.#", "#.#", "###", "..#", "..#"},
+	'5': {"###", "#..", "###", "..#", "###"},
+	'6': {"###", "#..", "###", "#.#", "###"},
+	'7': {"###", "..#", ".#.", ".#.", ".#."},
+	'8': {"###", "#.#", "###", "#.#", "###"},
+	'9': {"###", "#.#", "###", "..#", "###"},
+	':': {".", "#", ".", "#", "."},
+}
+
+// clockFontHeight is the height of every glyph in clockFont.
+const clockFontHeight = 5
+
+// clockScrollSpeed is how fast text too wide for the grid scrolls, in
+// columns per second.
+const clockScrollSpeed = 2
+
+// clockText formats a time for the clock.
+func clockText(t time.Time, twelveHour bool) string {
+	if twelveHour {
+		return t.Format("3:04")
+	}
+	return t.Format("15:04")
+}
+
+// rasterize draws text in clockFont, with a blank column between
+// characters. It returns the lit pixels by row, from the top.
+func rasterize(text string) [][]bool {
+	pixels := make([][]bool, clockFontHeight)
+	for i, r := range text {
+		glyph := clockFont[r]
+		for y := range pixels {
+			if i > 0 {
+				pixels[y] = append(pixels[y], false)
+			}
+			for _, c := range glyph[y] {
+				pixels[y] = append(pixels[y], c == '#')
+			}
+		}
+	}
+	return pixels
+}
+
+// panelGrid maps square panels to grid cells, by their distinct x and y
+// coordinates. Row 0 is at the top.
+type panelGrid struct {
+	Width, Height int
+	Cells         map[int][2]int // panel ID to column and row
+}
+
+// newPanelGrid lays out the square panels among panels on a grid.
+func newPanelGrid(panels []PanelPosition) panelGrid {
+	var squares []PanelPosition
+	for _, p := range panels {
+		switch p.ShapeType {
+		case shapeSquare, shapeControlSquareMaster, shapeControlSquarePassive:
+			squares = append(squares, p)
+		}
+	}
+
+	xs := distinctCoords(squares, func(p PanelPosition) int { return p.X })
+	ys := distinctCoords(squares, func(p PanelPosition) int { return p.Y })
+	grid := panelGrid{Width: len(xs), Height: len(ys), Cells: make(map[int][2]int)}
+	for _, p := range squares {
+		col := sort.SearchInts(xs, p.X)
+		row := len(ys) - 1 - sort.SearchInts(ys, p.Y)
+		grid.Cells[p.PanelID] = [2]int{col, row}
+	}
+	return grid
+}
+
+// clockFrames draws text centered on the grid, or scrolling across it at
+// time t if it doesn't fit. Panels off the grid show the background.
+func clockFrames(grid panelGrid, panels []PanelPosition, text string, t time.Duration, fg, bg RGB) []SetPanelColor {
+	pixels := rasterize(text)
+	width := len(pixels[0])
+
+	offsetX := (width - grid.Width) / 2
+	if width > grid.Width {
+		// Scroll in from the right, until the text has left on the left.
+		offsetX = int(t.Seconds()*clockScrollSpeed)%(width+grid.Width) - grid.Width
+	}
+	offsetY := (clockFontHeight - grid.Height) / 2
+
+	frames := make([]SetPanelColor, len(panels))
+	for i, p := range panels {
+		c := bg
+		if cell, ok := grid.Cells[p.PanelID]; ok {
+			x, y := cell[0]+offsetX, cell[1]+offsetY
+			if x >= 0 && x < width && y >= 0 && y < clockFontHeight && pixels[y][x] {
+				c = fg
+			}
+		}
+		frames[i] = SetPanelColor{PanelID: uint16(p.PanelID), Red: c.Red, Green: c.Green, Blue: c.Blue}
+	}
+	return frames
+}
+
+// doFxClockCommand shows the time on a grid of Canvas panels.
+func doFxClockCommand(client Client, args []string) {
+	flags := flag.NewFlagSet("clock", flag.ExitOnError)
+	twelveHour := flags.Bool("12h", false, "Show a 12-hour clock")
+	colorArg := flags.String("color", "white", "Digit color, as a name or #rrggbb")
+	bgArg := flags.String("background", "black", "Background color, as a name or #rrggbb")
+	selection := flags.String("panels", "", "Panels to drive, as for sacn --panels (default all)")
+	flags.Usage = func() {
+		fmt.Println("usage: picoleaf fx clock [--12h] [--color <color>] [--background <color>] [--panels <selection>]")
+		exit(1)
+	}
+	flags.Parse(args)
+
+	if flags.NArg() > 0 {
+		flags.Usage()
+	}
+
+	fg, err := parseColor(*colorArg)
+	if err != nil {
+		fmt.Println("error:", err)
+		exit(1)
+	}
+	bg, err := parseColor(*bgArg)
+	if err != nil {
+		fmt.Println("error:", err)
+		exit(1)
+	}
+
+	panelInfo, err := client.CachedPanelInfo()
+	if err != nil {
+		fmt.Println("error: failed to get Nanoleaf info:", err)
+		exit(1)
+	}
+	panels, err := selectPanels(panelInfo.PanelLayout.Layout.PositionData, *selection)
+	if err != nil {
+		fmt.Println("error:", err)
+		exit(1)
+	}
+	grid := newPanelGrid(panels)
+	if grid.Height < clockFontHeight {
+		fmt.Printf("error: the clock needs square panels at least %d rows tall, found %d\n", clockFontHeight, grid.Height)
+		exit(1)
+	}
+
+	// The time is redrawn every frame, so it changes as soon as the minute
+	// does.
+	runFx(client, *selection, func(t time.Duration, panels []PanelPosition) []SetPanelColor {
+		return clockFrames(grid, panels, clockText(time.Now(), *twelveHour), t, fg, bg)
+	})
+}
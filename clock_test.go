@@ -0,0 +1,92 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClockText(t *testing.T) {
+	at := time.Date(2024, 1, 1, 21, 5, 0, 0, time.UTC)
+	if got := clockText(at, false); got != "21:05" {
+		t.Errorf("24-hour clockText = %q, want 21:05", got)
+	}
+	if got := clockText(at, true); got != "9:05" {
+		t.Errorf("12-hour clockText = %q, want 9:05", got)
+	}
+}
+
+// squareGrid returns Canvas squares on a width x height grid, with IDs
+// numbered row by row from the top left.
+func squareGrid(width, height int) []PanelPosition {
+	var panels []PanelPosition
+	for row := 0; row < height; row++ {
+		for col := 0; col < width; col++ {
+			panels = append(panels, PanelPosition{
+				PanelID:   row*width + col + 1,
+				X:         col * 100,
+				Y:         (height - 1 - row) * 100,
+				ShapeType: shapeSquare,
+			})
+		}
+	}
+	return panels
+}
+
+func TestClockFrames(t *testing.T) {
+	panels := squareGrid(13, 5)
+	panels = append(panels, PanelPosition{PanelID: 99, X: 50, Y: 600, ShapeType: 0})
+
+	grid := newPanelGrid(panels)
+	if grid.Width != 13 || grid.Height != 5 {
+		t.Fatalf("grid is %dx%d, want 13x5", grid.Width, grid.Height)
+	}
+
+	fg, bg := RGB{255, 255, 255}, RGB{0, 0, 1}
+	frames := clockFrames(grid, panels, "9:05", 0, fg, bg)
+
+	// Draw the lit panels back into rows, to compare with the font.
+	want := []string{
+		"###...###.###",
+		"#.#.#.#.#.#..",
+		"###...#.#.###",
+		"..#.#.#.#...#",
+		"###...###.###",
+	}
+	for row, line := range want {
+		var got strings.Builder
+		for col := range line {
+			if frames[row*13+col].Red == 255 {
+				got.WriteByte('#')
+			} else {
+				got.WriteByte('.')
+			}
+		}
+		if got.String() != line {
+			t.Errorf("row %d = %s, want %s", row, got.String(), line)
+		}
+	}
+
+	if last := frames[len(frames)-1]; last.PanelID != 99 || last.Blue != 1 {
+		t.Errorf("non-square panel = %+v, want the background", last)
+	}
+}
+
+func TestClockFramesScroll(t *testing.T) {
+	panels := squareGrid(3, 5)
+	grid := newPanelGrid(panels)
+	fg := RGB{255, 255, 255}
+
+	// The text starts just off the right edge, and scrolls in.
+	frames := clockFrames(grid, panels, "11", 0, fg, RGB{})
+	for _, f := range frames {
+		if f.Red != 0 {
+			t.Fatalf("panel %d lit before the text scrolled in", f.PanelID)
+		}
+	}
+
+	frames = clockFrames(grid, panels, "11", 1500*time.Millisecond, fg, RGB{})
+	if frames[1].Red != 255 {
+		t.Errorf("panel 2 is unlit once the text has scrolled into place")
+	}
+}
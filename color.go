@@ -0,0 +1,211 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// RGB is a 24-bit color.
+type RGB struct {
+	Red   uint8
+	Green uint8
+	Blue  uint8
+}
+
+// namedColors maps the color names accepted on the command line to RGB.
+var namedColors = map[string]RGB{
+	"black":     {0, 0, 0},
+	"white":     {255, 255, 255},
+	"warmwhite": {255, 180, 107},
+	"red":       {255, 0, 0},
+	"orange":    {255, 128, 0},
+	"amber":     {255, 191, 0},
+	"yellow":    {255, 255, 0},
+	"lime":      {128, 255, 0},
+	"green":     {0, 255, 0},
+	"teal":      {0, 128, 128},
+	"cyan":      {0, 255, 255},
+	"blue":      {0, 0, 255},
+	"indigo":    {75, 0, 130},
+	"purple":    {128, 0, 255},
+	"violet":    {238, 130, 238},
+	"magenta":   {255, 0, 255},
+	"pink":      {255, 105, 180},
+}
+
+// parseColor parses a color name or a `#rrggbb` hex color.
+func parseColor(s string) (RGB, error) {
+	if c, ok := namedColors[strings.ToLower(s)]; ok {
+		return c, nil
+	}
+
+	c, err := ParseHex(s)
+	if err != nil {
+		return RGB{}, fmt.Errorf("invalid color %q, expected a name or #rrggbb", s)
+	}
+	return c, nil
+}
+
+// ParseHex parses a `#rrggbb` or `#rgb` hex color. The # is optional.
+func ParseHex(s string) (RGB, error) {
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) == 6 {
+		v, err := strconv.ParseUint(hex, 16, 32)
+		if err == nil {
+			return RGB{uint8(v >> 16), uint8(v >> 8), uint8(v)}, nil
+		}
+	}
+	return RGB{}, fmt.Errorf("invalid hex color %q, expected #rrggbb or #rgb", s)
+}
+
+// HSVToRGB converts a hue (0-359), saturation (0-100), and value (0-100) to
+// RGB.
+func HSVToRGB(hue, sat, val int) RGB {
+	h := math.Mod(float64(hue), 360) / 60
+	s := float64(sat) / 100
+	v := float64(val) / 100
+
+	c := v * s
+	x := c * (1 - math.Abs(math.Mod(h, 2)-1))
+	var r, g, b float64
+	switch {
+	case h < 1:
+		r, g, b = c, x, 0
+	case h < 2:
+		r, g, b = x, c, 0
+	case h < 3:
+		r, g, b = 0, c, x
+	case h < 4:
+		r, g, b = 0, x, c
+	case h < 5:
+		r, g, b = x, 0, c
+	default:
+		r, g, b = c, 0, x
+	}
+
+	m := v - c
+	return RGB{
+		Red:   uint8(math.Round(255 * (r + m))),
+		Green: uint8(math.Round(255 * (g + m))),
+		Blue:  uint8(math.Round(255 * (b + m))),
+	}
+}
+
+// RGBToHSV converts RGB to a hue (0-359), saturation (0-100), and value
+// (0-100).
+func RGBToHSV(c RGB) (int, int, int) {
+	r := float64(c.Red) / 255
+	g := float64(c.Green) / 255
+	b := float64(c.Blue) / 255
+
+	v := math.Max(math.Max(r, g), b)
+	chroma := v - math.Min(math.Min(r, g), b)
+	if chroma == 0 {
+		return 0, 0, int(math.Round(100 * v))
+	}
+
+	var h float64
+	switch v {
+	case r:
+		h = (g - b) / chroma
+	case g:
+		h = 2 + (b-r)/chroma
+	default:
+		h = 4 + (r-g)/chroma
+	}
+	h *= 60
+	if h < 0 {
+		h += 360
+	}
+	return int(math.Round(h)) % 360, int(math.Round(100 * chroma / v)), int(math.Round(100 * v))
+}
+
+// KelvinToRGB approximates the color of white light at the given
+// temperature, in kelvin, using Tanner Helland's fit to black body colors.
+func KelvinToRGB(kelvin int) RGB {
+	t := float64(kelvin) / 100
+	var r, g, b float64
+	if t <= 66 {
+		r = 255
+		g = 99.4708025861*math.Log(t) - 161.1195681661
+	} else {
+		r = 329.698727446 * math.Pow(t-60, -0.1332047592)
+		g = 288.1221695283 * math.Pow(t-60, -0.0755148492)
+	}
+	switch {
+	case t >= 66:
+		b = 255
+	case t <= 19:
+		b = 0
+	default:
+		b = 138.5177312231*math.Log(t-10) - 305.0447927307
+	}
+
+	channel := func(v float64) uint8 {
+		return uint8(math.Round(math.Max(0, math.Min(255, v))))
+	}
+	return RGB{channel(r), channel(g), channel(b)}
+}
+
+// RGBToHSL converts RGB to a hue (0-359), saturation (0-100), and lightness
+// (0-100).
+func RGBToHSL(c RGB) (int, int, int) {
+	r := float64(c.Red) / 255
+	g := float64(c.Green) / 255
+	b := float64(c.Blue) / 255
+
+	max := math.Max(math.Max(r, g), b)
+	min := math.Min(math.Min(r, g), b)
+	chroma := max - min
+	l := (max + min) / 2
+	if chroma == 0 {
+		return 0, 0, int(math.Round(100 * l))
+	}
+
+	var h float64
+	switch max {
+	case r:
+		h = (g - b) / chroma
+	case g:
+		h = 2 + (b-r)/chroma
+	default:
+		h = 4 + (r-g)/chroma
+	}
+	h *= 60
+	if h < 0 {
+		h += 360
+	}
+	s := chroma / (1 - math.Abs(2*l-1))
+	return int(math.Round(h)) % 360, int(math.Round(100 * s)), int(math.Round(100 * l))
+}
+
+// HSLToHSV converts an HSL saturation and lightness (0-100) to an HSV
+// saturation and value (0-100). Hue is the same in both.
+func HSLToHSV(sat, lightness int) (int, int) {
+	s := float64(sat) / 100
+	l := float64(lightness) / 100
+
+	v := l + s*math.Min(l, 1-l)
+	if v == 0 {
+		return 0, 0
+	}
+	return int(math.Round(200 * (1 - l/v))), int(math.Round(100 * v))
+}
+
+// HSVToHSL converts an HSV saturation and value (0-100) to an HSL saturation
+// and lightness (0-100). Hue is the same in both.
+func HSVToHSL(sat, val int) (int, int) {
+	s := float64(sat) / 100
+	v := float64(val) / 100
+
+	l := v * (1 - s/2)
+	if l == 0 || l == 1 {
+		return 0, int(math.Round(100 * l))
+	}
+	return int(math.Round(100 * (v - l) / math.Min(l, 1-l))), int(math.Round(100 * l))
+}
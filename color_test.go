@@ -0,0 +1,149 @@
+package main
+
+import "testing"
+
+func TestHSVToRGB(t *testing.T) {
+	tests := []struct {
+		h, s, v int
+		want    RGB
+	}{
+		{0, 100, 100, RGB{255, 0, 0}},
+		{120, 100, 100, RGB{0, 255, 0}},
+		{240, 100, 50, RGB{0, 0, 128}},
+		{60, 50, 100, RGB{255, 255, 128}},
+		{300, 0, 100, RGB{255, 255, 255}},
+	}
+
+	for _, tt := range tests {
+		got := HSVToRGB(tt.h, tt.s, tt.v)
+		if got != tt.want {
+			t.Errorf("HSVToRGB(%d, %d, %d) = %v, want %v", tt.h, tt.s, tt.v, got, tt.want)
+		}
+	}
+}
+
+func TestColorPickerHandleKey(t *testing.T) {
+	p := colorPicker{Hue: 2, Saturation: 98, Brightness: 50}
+
+	p.handleKey(keyLeft)
+	if p.Hue != 357 {
+		t.Errorf("hue after left = %d, want 357", p.Hue)
+	}
+	p.handleKey(keyUp)
+	if p.Saturation != 100 {
+		t.Errorf("saturation after up = %d, want 100", p.Saturation)
+	}
+	if p.handleKey("x") {
+		t.Error("handleKey() reported a change for an unbound key")
+	}
+}
+
+func TestRGBToHSV(t *testing.T) {
+	tests := []struct {
+		c       RGB
+		h, s, v int
+	}{
+		{RGB{255, 0, 0}, 0, 100, 100},
+		{RGB{0, 0, 128}, 240, 100, 50},
+		{RGB{255, 255, 128}, 60, 50, 100},
+		{RGB{255, 0, 1}, 0, 100, 100},
+		{RGB{64, 64, 64}, 0, 0, 25},
+	}
+
+	for _, tt := range tests {
+		h, s, v := RGBToHSV(tt.c)
+		if h != tt.h || s != tt.s || v != tt.v {
+			t.Errorf("RGBToHSV(%v) = %d, %d, %d, want %d, %d, %d", tt.c, h, s, v, tt.h, tt.s, tt.v)
+		}
+	}
+}
+
+func TestRGBToHSL(t *testing.T) {
+	tests := []struct {
+		c       RGB
+		h, s, l int
+	}{
+		{RGB{0, 0, 0}, 0, 0, 0},
+		{RGB{255, 255, 255}, 0, 0, 100},
+		{RGB{255, 0, 0}, 0, 100, 50},
+		{RGB{0, 255, 0}, 120, 100, 50},
+		{RGB{0, 0, 255}, 240, 100, 50},
+		{RGB{255, 128, 0}, 30, 100, 50},
+		{RGB{255, 0, 1}, 0, 100, 50},
+	}
+
+	for _, tt := range tests {
+		h, s, l := RGBToHSL(tt.c)
+		if h != tt.h || s != tt.s || l != tt.l {
+			t.Errorf("RGBToHSL(%v) = (%d, %d, %d), want (%d, %d, %d)", tt.c, h, s, l, tt.h, tt.s, tt.l)
+		}
+	}
+}
+
+func TestHSLToHSV(t *testing.T) {
+	tests := []struct {
+		sl, l int
+		sv, v int
+	}{
+		{0, 0, 0, 0},
+		{0, 100, 0, 100},
+		{100, 50, 100, 100},
+		{100, 25, 100, 50},
+		{100, 75, 50, 100},
+		{0, 40, 0, 40},
+	}
+
+	for _, tt := range tests {
+		sv, v := HSLToHSV(tt.sl, tt.l)
+		if sv != tt.sv || v != tt.v {
+			t.Errorf("HSLToHSV(%d, %d) = (%d, %d), want (%d, %d)", tt.sl, tt.l, sv, v, tt.sv, tt.v)
+		}
+		sl, l := HSVToHSL(tt.sv, tt.v)
+		if sl != tt.sl || l != tt.l {
+			t.Errorf("HSVToHSL(%d, %d) = (%d, %d), want (%d, %d)", tt.sv, tt.v, sl, l, tt.sl, tt.l)
+		}
+	}
+}
+
+func TestRGBToHSVRoundTrip(t *testing.T) {
+	for r := 0; r < 256; r += 15 {
+		for g := 0; g < 256; g += 15 {
+			for b := 0; b < 256; b += 15 {
+				c := RGB{uint8(r), uint8(g), uint8(b)}
+				h, s, v := RGBToHSV(c)
+				if h < 0 || h > 359 {
+					t.Fatalf("RGBToHSV(%v) hue = %d, want 0-359", c, h)
+				}
+				got := HSVToRGB(h, s, v)
+				for _, d := range []int{int(got.Red) - r, int(got.Green) - g, int(got.Blue) - b} {
+					if d < -5 || d > 5 {
+						t.Fatalf("HSVToRGB(RGBToHSV(%v)) = %v, want within 5 per channel", c, got)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestParseHex(t *testing.T) {
+	tests := []struct {
+		s    string
+		want RGB
+	}{
+		{"#ff8000", RGB{255, 128, 0}},
+		{"FF8000", RGB{255, 128, 0}},
+		{"#f80", RGB{255, 136, 0}},
+	}
+	for _, tt := range tests {
+		got, err := ParseHex(tt.s)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseHex(%q) = %v, %v, want %v", tt.s, got, err, tt.want)
+		}
+	}
+
+	for _, s := range []string{"", "#ff80", "#ggg", "#ff80000"} {
+		if _, err := ParseHex(s); err == nil {
+			t.Errorf("ParseHex(%q): want an error", s)
+		}
+	}
+}
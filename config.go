@@ -0,0 +1,279 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+// configSectionKeys are the keys each fixed config section accepts.
+var configSectionKeys = map[string][]string{
+	"adapt":      {"source", "command", "device", "curve", "every", "min_change"},
+	"autooff":    {"after", "hours"},
+	"busy":       {busyInCall, busyFree},
+	"ci":         {ciSuccess, ciFailure, ciPending, "branch", "secret"},
+	"daemon":     {"listen", "secret", "tls_cert", "tls_key", "api_key", "username", "password"},
+	"hyperion":   {"instance"},
+	"nightlight": {"max_brightness", "temperature"},
+	"slack":      {},
+	"telegram":   {"bot_token", "allow"},
+}
+
+// configSectionKeyPrefixes are the prefixes of keys named by the user, e.g.
+// `zone.<name>`, that each fixed section accepts.
+var configSectionKeyPrefixes = map[string][]string{
+	"hyperion": {zoneKeyPrefix},
+	"slack":    {"presence.", "emoji."},
+}
+
+// deviceKeyPrefixes are the prefixes of zone and panel names, which device
+// sections accept.
+var deviceKeyPrefixes = []string{zoneKeyPrefix, panelKeyPrefix}
+
+// freeformSections have keys named by the user, e.g. aliases and cron
+// entries, so any key is accepted.
+var freeformSections = []string{"aliases", "cron", paletteSection, triggerSection, "weather"}
+
+// topLevelKeys are the keys the top-level section accepts, besides the
+// default device's settings.
+var topLevelKeys = []string{"latitude", "longitude"}
+
+// sceneKeys are the keys a scene section accepts.
+var sceneKeys = []string{"on", "effect", "color", "hue", "sat", "ct", "brightness"}
+
+// unterminatedSections finds section headers missing their closing bracket,
+// which stop the config from being parsed at all.
+func unterminatedSections(data []byte) []string {
+	var problems []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") && !strings.Contains(line, "]") {
+			problems = append(problems, fmt.Sprintf("line %d: unterminated section header %s", n, line))
+		}
+	}
+	return problems
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := []int{i}
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur = append(cur, min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost))
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// checkKeys reports keys in a section that aren't among known or under one
+// of prefixes, suggesting the closest known key for likely typos.
+func checkKeys(section *ini.Section, label string, known, prefixes []string) []string {
+	var problems []string
+	for _, key := range section.KeyStrings() {
+		if slices.Contains(known, key) || slices.ContainsFunc(prefixes, func(p string) bool {
+			return strings.HasPrefix(key, p) && len(key) > len(p)
+		}) {
+			continue
+		}
+		problem := fmt.Sprintf("%s: unknown key %q", label, key)
+		best, bestDistance := "", 3
+		for _, k := range known {
+			if d := editDistance(key, k); d < bestDistance {
+				best, bestDistance = k, d
+			}
+		}
+		if best != "" {
+			problem += fmt.Sprintf(" (did you mean %q?)", best)
+		}
+		problems = append(problems, problem)
+	}
+	return problems
+}
+
+// checkConfig checks the loaded config for unknown sections and keys,
+// devices missing a host or token, and groups and scenes that can't be used.
+func checkConfig() []string {
+	var problems []string
+	devices := configuredDeviceNames()
+
+	for _, section := range cfg.Sections() {
+		name := section.Name()
+		label := "[" + name + "]"
+		switch {
+		case name == ini.DefaultSection:
+			known := append(slices.Clone(deviceKeys), topLevelKeys...)
+			problems = append(problems, checkKeys(section, "top level", known, deviceKeyPrefixes)...)
+		case strings.HasPrefix(name, deviceSectionPrefix):
+			if name == deviceSectionPrefix {
+				problems = append(problems, label+": device has no name")
+			}
+			problems = append(problems, checkKeys(section, label, deviceKeys, deviceKeyPrefixes)...)
+		case strings.HasPrefix(name, groupSectionPrefix):
+			if name == groupSectionPrefix {
+				problems = append(problems, label+": group has no name")
+			}
+			problems = append(problems, checkKeys(section, label, []string{"devices"}, nil)...)
+			members := section.Key("devices").Strings(",")
+			if len(members) == 0 {
+				problems = append(problems, label+": group has no devices")
+			}
+			for _, member := range members {
+				if !slices.Contains(devices, member) {
+					problems = append(problems, fmt.Sprintf("%s: unknown device %q", label, member))
+				}
+			}
+		case strings.HasPrefix(name, sceneSectionPrefix):
+			if name == sceneSectionPrefix {
+				problems = append(problems, label+": scene has no name")
+			}
+			problems = append(problems, checkKeys(section, label, sceneKeys, nil)...)
+			if _, err := parseScene(strings.TrimPrefix(name, sceneSectionPrefix), section); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %v", label, err))
+			}
+		case name == sceneScheduleSection:
+			for _, key := range section.Keys() {
+				if _, err := parseScheduledScene(key); err != nil {
+					problems = append(problems, fmt.Sprintf("%s: %v", label, err))
+				}
+			}
+		case slices.Contains(freeformSections, name):
+		default:
+			known, ok := configSectionKeys[name]
+			if !ok {
+				problems = append(problems, label+": unknown section")
+				continue
+			}
+			problems = append(problems, checkKeys(section, label, known, configSectionKeyPrefixes[name])...)
+		}
+	}
+
+	top := cfg.Section("")
+	if !top.HasKey("host") && top.HasKey("access_token") {
+		problems = append(problems, "top level: access_token is set, but host isn't")
+	}
+	if len(devices) == 0 {
+		problems = append(problems, "no devices configured; set host and access_token, or run picoleaf device add")
+	}
+	for _, name := range devices {
+		device, err := findDevice(name)
+		switch {
+		case name == "":
+			// Already reported as a device with no name.
+		case err != nil:
+			problems = append(problems, fmt.Sprintf("device %s: %v", name, err))
+		case device.Host == "":
+			problems = append(problems, fmt.Sprintf("device %s: no host", name))
+		case device.Token == "":
+			problems = append(problems, fmt.Sprintf("device %s: no access_token", name))
+		}
+	}
+	return problems
+}
+
+// probeConfiguredDevices checks that each configured device answers, and
+// accepts its access token.
+func probeConfiguredDevices(d *doctor) {
+	names := configuredDeviceNames()
+	hosts := make([]string, len(names))
+	errs := runParallel(len(names), *parallelism, func(i int) error {
+		device, err := findDevice(names[i])
+		if err != nil {
+			// Already reported by checkConfig.
+			return nil
+		}
+		hosts[i] = device.Host
+		client := device.Client()
+		client.client.Timeout = probeTimeout
+		defer client.Close()
+
+		_, err = client.GetPanelInfo()
+		return err
+	})
+
+	for i, name := range names {
+		var apiErr *APIError
+		switch err := errs[i]; {
+		case hosts[i] == "":
+		case err == nil:
+			d.ok("device %s answered at %s", name, hosts[i])
+		case errors.As(err, &apiErr) && (apiErr.Status == http.StatusUnauthorized || apiErr.Status == http.StatusForbidden):
+			d.fail("device %s rejected its access token", name)
+			d.hint("pair again with `picoleaf device remove %s` and `picoleaf device add`", name)
+		default:
+			d.fail("device %s: %v", name, err)
+		}
+	}
+}
+
+// doConfigCommand checks the config file. It runs before the config file is
+// loaded, so it can report problems that stop it from loading.
+func doConfigCommand(args []string) {
+	usage := func() {
+		fmt.Println("usage: picoleaf config check [--probe]")
+		exit(1)
+	}
+	if len(args) == 0 || args[0] != "check" {
+		usage()
+	}
+
+	flags := flag.NewFlagSet("config check", flag.ExitOnError)
+	probe := flags.Bool("probe", false, "Also check that each device answers and accepts its access token")
+	flags.Usage = usage
+	flags.Parse(args[1:])
+
+	if flags.NArg() > 0 {
+		flags.Usage()
+	}
+
+	d := &doctor{}
+	data, err := os.ReadFile(configFilePath)
+	if err != nil {
+		d.fail("failed to read config file: %v", err)
+		exit(1)
+	}
+
+	if problems := unterminatedSections(data); len(problems) > 0 {
+		for _, problem := range problems {
+			d.fail("%s", problem)
+		}
+		exit(1)
+	}
+	cfg, err = loadINI(data)
+	if err != nil {
+		d.fail("config file %s could not be parsed: %v", configFilePath, err)
+		exit(1)
+	}
+
+	problems := checkConfig()
+	for _, problem := range problems {
+		d.fail("%s", problem)
+	}
+	if len(problems) == 0 {
+		d.ok("config file %s", configFilePath)
+	}
+
+	if *probe {
+		probeConfiguredDevices(d)
+	}
+	if d.failed {
+		exit(1)
+	}
+}
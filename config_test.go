@@ -0,0 +1,102 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUnterminatedSections(t *testing.T) {
+	data := "host=192.168.1.10\n[group.downstairs\ndevices=a\n  [scene.evening # comment\n[device.office]\n"
+	got := unterminatedSections([]byte(data))
+	want := []string{
+		"line 2: unterminated section header [group.downstairs",
+		"line 4: unterminated section header [scene.evening # comment",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unterminatedSections = %q, want %q", got, want)
+	}
+}
+
+func TestCheckConfig(t *testing.T) {
+	setTestConfig(t, `
+host=192.168.1.10
+access_token=abc
+latitude=51.5
+zone.left=1,2
+
+[device.office]
+hots=192.168.1.20
+
+[device.desk]
+host=192.168.1.30
+access_token=def
+brightnes=50
+
+[group.all]
+devices=office,kitchen
+
+[scene.evening]
+ct=9000
+colour=#ff8000
+
+[aliases]
+movie=scene evening
+
+[nightlight]
+max_brightness=20
+
+[daemon.schedule]
+07:00=evening
+25:00=evening
+sunset=party
+
+[ambient]
+x=1
+`)
+
+	got := checkConfig()
+	want := []string{
+		`[device.office]: unknown key "hots" (did you mean "host"?)`,
+		`[device.desk]: unknown key "brightnes"`,
+		`[group.all]: unknown device "kitchen"`,
+		`[scene.evening]: unknown key "colour" (did you mean "color"?)`,
+		`[scene.evening]: ct must be an integer 1200-6500`,
+		`[daemon.schedule]: invalid time "25:00", expected HH:MM`,
+		`[daemon.schedule]: sunset: no scene named "party"`,
+		`[ambient]: unknown section`,
+		`device office: no host`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("checkConfig() =\n%q\nwant\n%q", got, want)
+	}
+
+	setTestConfig(t, "access_token=abc\n[group.empty]\n")
+	got = checkConfig()
+	want = []string{
+		"[group.empty]: group has no devices",
+		"top level: access_token is set, but host isn't",
+		"no devices configured; set host and access_token, or run picoleaf device add",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("checkConfig() =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestProbeConfiguredDevices(t *testing.T) {
+	_, server := newTestClient(t)
+	setTestConfig(t, "host="+server.Host()+"\naccess_token="+server.Token+"\n"+
+		"[device.stale]\nhost="+server.Host()+"\naccess_token=old\n")
+
+	d := &doctor{}
+	probeConfiguredDevices(d)
+	if !d.failed {
+		t.Error("probe passed with a rejected access token")
+	}
+
+	setTestConfig(t, "host="+server.Host()+"\naccess_token="+server.Token+"\n")
+	d = &doctor{}
+	probeConfiguredDevices(d)
+	if d.failed {
+		t.Error("probe failed for a working device")
+	}
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronShortcuts maps the predefined cron schedules to their expressions.
+var cronShortcuts = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// CronSchedule is a parsed five-field cron expression.
+type CronSchedule struct {
+	Minute     []bool
+	Hour       []bool
+	DayOfMonth []bool
+	Month      []bool
+	DayOfWeek  []bool
+
+	// domStar and dowStar record whether the day fields were unrestricted,
+	// which changes how they combine (see Matches).
+	domStar bool
+	dowStar bool
+}
+
+// CronEntry is a named schedule entry from the `[cron]` config section.
+// Entries fire either on a cron schedule or at a solar time.
+type CronEntry struct {
+	Name     string
+	Schedule CronSchedule
+	Solar    *SolarTime
+	Command  []string
+}
+
+// ParseCronSchedule parses a standard five-field cron expression, or one of
+// the predefined @-shortcuts.
+func ParseCronSchedule(expr string) (CronSchedule, error) {
+	if shortcut, ok := cronShortcuts[expr]; ok {
+		expr = shortcut
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return CronSchedule{}, fmt.Errorf("expected 5 fields, got %d", len(fields))
+	}
+
+	var sched CronSchedule
+	var err error
+	if sched.Minute, err = parseCronField(fields[0], 0, 59); err != nil {
+		return sched, fmt.Errorf("minute: %v", err)
+	}
+	if sched.Hour, err = parseCronField(fields[1], 0, 23); err != nil {
+		return sched, fmt.Errorf("hour: %v", err)
+	}
+	if sched.DayOfMonth, err = parseCronField(fields[2], 1, 31); err != nil {
+		return sched, fmt.Errorf("day of month: %v", err)
+	}
+	if sched.Month, err = parseCronField(fields[3], 1, 12); err != nil {
+		return sched, fmt.Errorf("month: %v", err)
+	}
+	if sched.DayOfWeek, err = parseCronField(fields[4], 0, 7); err != nil {
+		return sched, fmt.Errorf("day of week: %v", err)
+	}
+	// Both 0 and 7 mean Sunday.
+	if sched.DayOfWeek[7] {
+		sched.DayOfWeek[0] = true
+	}
+
+	sched.domStar = fields[2] == "*"
+	sched.dowStar = fields[4] == "*"
+	return sched, nil
+}
+
+// parseCronField parses a comma-separated list of values, ranges, and steps.
+func parseCronField(field string, min, max int) ([]bool, error) {
+	set := make([]bool, max+1)
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid step %q", part[i+1:])
+			}
+			step = n
+			part = part[:i]
+		}
+
+		lo, hi := min, max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+			n, err := strconv.Atoi(bounds[0])
+			if err != nil {
+				return nil, fmt.Errorf("invalid value %q", bounds[0])
+			}
+			lo, hi = n, n
+			if len(bounds) == 2 {
+				hi, err = strconv.Atoi(bounds[1])
+				if err != nil {
+					return nil, fmt.Errorf("invalid value %q", bounds[1])
+				}
+			} else if step > 1 {
+				hi = max
+			}
+		}
+
+		if lo < min || hi > max || lo > hi {
+			return nil, fmt.Errorf("%q out of range %d-%d", part, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			set[v] = true
+		}
+	}
+	return set, nil
+}
+
+// Matches reports whether the schedule fires during the minute containing t.
+func (s CronSchedule) Matches(t time.Time) bool {
+	if !s.Minute[t.Minute()] || !s.Hour[t.Hour()] || !s.Month[int(t.Month())] {
+		return false
+	}
+
+	dom := s.DayOfMonth[t.Day()]
+	dow := s.DayOfWeek[int(t.Weekday())]
+	// As in cron(8), if both day fields are restricted, either may match.
+	if !s.domStar && !s.dowStar {
+		return dom || dow
+	}
+	return dom && dow
+}
+
+// Next returns the first minute after t in which the schedule fires, or the
+// zero time if it never does (e.g. `0 0 30 2 *`). Like Matches, it goes by
+// the wall clock: a time skipped by a DST change doesn't fire that day, and
+// one repeated fires twice.
+func (s CronSchedule) Next(t time.Time) time.Time {
+	limit := t.AddDate(cronSearchYears, 0, 0)
+	for t = t.Truncate(time.Minute).Add(time.Minute); t.Before(limit); {
+		year, month, day := t.Date()
+		switch {
+		case !s.Month[int(month)]:
+			t = time.Date(year, month+1, 1, 0, 0, 0, 0, t.Location())
+		case !s.Matches(time.Date(year, month, day, 0, 0, 0, 0, time.UTC).Add(s.firstMinute())):
+			t = time.Date(year, month, day+1, 0, 0, 0, 0, t.Location())
+		case !s.Matches(t):
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// cronSearchYears bounds how far ahead Next looks. Every schedule that can
+// fire at all fires within 8 years, since February 29th can be 8 years
+// apart across a century.
+const cronSearchYears = 9
+
+// firstMinute returns the offset into a day of the first minute the schedule
+// allows, for checking whether it fires on a day at all.
+func (s CronSchedule) firstMinute() time.Duration {
+	var h, m int
+	for !s.Hour[h] {
+		h++
+	}
+	for !s.Minute[m] {
+		m++
+	}
+	return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute
+}
+
+// Matches reports whether the entry fires during the minute containing t.
+func (e CronEntry) Matches(t time.Time, coords Coordinates) bool {
+	if e.Solar == nil {
+		return e.Schedule.Matches(t)
+	}
+
+	// Large offsets can push a solar time onto the previous or next day.
+	minute := t.Truncate(time.Minute)
+	for i := -1; i <= 1; i++ {
+		when, err := e.Solar.On(t.AddDate(0, 0, i), coords)
+		if err == nil && when.Truncate(time.Minute).Equal(minute) {
+			return true
+		}
+	}
+	return false
+}
+
+// loadCronEntries reads schedule entries from the `[cron]` config section.
+// Each value is a cron expression or solar time followed by a picoleaf
+// command, e.g.:
+//
+//	lights-out = 30 23 * * 1-5 off
+//	evening    = @sunset-30m on
+func loadCronEntries() ([]CronEntry, error) {
+	var entries []CronEntry
+	for _, key := range cfg.Section("cron").Keys() {
+		fields := strings.Fields(key.String())
+
+		numScheduleFields := 5
+		if len(fields) > 0 && strings.HasPrefix(fields[0], "@") {
+			numScheduleFields = 1
+		}
+		if len(fields) <= numScheduleFields {
+			return nil, fmt.Errorf("%s: expected a schedule followed by a command", key.Name())
+		}
+
+		entry := CronEntry{
+			Name:    key.Name(),
+			Command: fields[numScheduleFields:],
+		}
+
+		expr := strings.Join(fields[:numScheduleFields], " ")
+		if isSolarTime(strings.TrimPrefix(expr, "@")) {
+			st, err := ParseSolarTime(strings.TrimPrefix(expr, "@"))
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", key.Name(), err)
+			}
+			entry.Solar = &st
+		} else {
+			sched, err := ParseCronSchedule(expr)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", key.Name(), err)
+			}
+			entry.Schedule = sched
+		}
+
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func doCronCommand(client Client, args []string) {
+	if len(args) > 0 {
+		fmt.Println("usage: picoleaf cron")
+		exit(1)
+	}
+
+	entries, err := loadCronEntries()
+	if err != nil {
+		fmt.Println("error: invalid cron entry:", err)
+		exit(1)
+	}
+	if len(entries) == 0 {
+		fmt.Println("error: no entries found in [cron] section of", configFilePath)
+		exit(1)
+	}
+
+	var coords Coordinates
+	for _, entry := range entries {
+		if entry.Solar != nil {
+			coords, err = loadCoordinates()
+			if err != nil {
+				fmt.Println("error: failed to determine location:", err)
+				exit(1)
+			}
+			break
+		}
+	}
+
+	slog.Info("running scheduled commands", "entries", len(entries))
+	for _, entry := range entries {
+		if entry.Solar == nil {
+			slog.Info("next scheduled run", "entry", entry.Name, "at", entry.Schedule.Next(time.Now()))
+		}
+	}
+	for {
+		now := time.Now()
+		next := now.Truncate(time.Minute).Add(time.Minute)
+		if !sleepOrCancel(next.Sub(now)) {
+			return
+		}
+
+		for _, entry := range entries {
+			if entry.Matches(next, coords) {
+				go runCronEntry(entry, next)
+				if entry.Solar == nil {
+					slog.Info("next scheduled run", "entry", entry.Name, "at", entry.Schedule.Next(next))
+				}
+			}
+		}
+	}
+}
+
+// runCronEntry runs a scheduled command in a child picoleaf process, so a
+// failing command can't take down the scheduler.
+func runCronEntry(entry CronEntry, t time.Time) {
+	slog.Info("running scheduled command", "entry", entry.Name, "command", strings.Join(entry.Command, " "), "scheduled", t)
+
+	err := runSubcommand(entry.Command)
+	if err != nil {
+		slog.Error("scheduled command failed", "entry", entry.Name, "err", err)
+	}
+}
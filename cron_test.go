@@ -0,0 +1,249 @@
+package main
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestParseCronField(t *testing.T) {
+	tests := []struct {
+		field    string
+		min, max int
+		want     []int
+		wantErr  bool
+	}{
+		{field: "*", min: 0, max: 5, want: []int{0, 1, 2, 3, 4, 5}},
+		{field: "3", min: 0, max: 59, want: []int{3}},
+		{field: "1-4", min: 0, max: 59, want: []int{1, 2, 3, 4}},
+		{field: "*/15", min: 0, max: 59, want: []int{0, 15, 30, 45}},
+		{field: "1-10/2", min: 0, max: 59, want: []int{1, 3, 5, 7, 9}},
+		{field: "50/5", min: 0, max: 59, want: []int{50, 55}},
+		{field: "1,5,9", min: 0, max: 59, want: []int{1, 5, 9}},
+		{field: "1-3,10-20/5,30", min: 0, max: 59, want: []int{1, 2, 3, 10, 15, 20, 30}},
+		{field: "1-31", min: 1, max: 31, want: []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21, 22, 23, 24, 25, 26, 27, 28, 29, 30, 31}},
+
+		{field: "60", min: 0, max: 59, wantErr: true},
+		{field: "0", min: 1, max: 31, wantErr: true},
+		{field: "5-70", min: 0, max: 59, wantErr: true},
+		{field: "10-5", min: 0, max: 59, wantErr: true},
+		{field: "-1", min: 0, max: 59, wantErr: true},
+		{field: "*/0", min: 0, max: 59, wantErr: true},
+		{field: "*/x", min: 0, max: 59, wantErr: true},
+		{field: "*/", min: 0, max: 59, wantErr: true},
+		{field: "a", min: 0, max: 59, wantErr: true},
+		{field: "1-b", min: 0, max: 59, wantErr: true},
+		{field: "1,,2", min: 0, max: 59, wantErr: true},
+		{field: "", min: 0, max: 59, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseCronField(tt.field, tt.min, tt.max)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseCronField(%q) succeeded, want error", tt.field)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseCronField(%q): %v", tt.field, err)
+			continue
+		}
+		var values []int
+		for v, ok := range got {
+			if ok {
+				values = append(values, v)
+			}
+		}
+		if !equalInts(values, tt.want) {
+			t.Errorf("parseCronField(%q) = %v, want %v", tt.field, values, tt.want)
+		}
+	}
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestParseCronScheduleErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"@reboot",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 32 * *",
+		"* * * 13 *",
+		"* * * 0 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"* * * JAN *",
+	} {
+		if _, err := ParseCronSchedule(expr); err == nil {
+			t.Errorf("ParseCronSchedule(%q) succeeded, want error", expr)
+		}
+	}
+}
+
+func TestCronScheduleMatches(t *testing.T) {
+	// 2024-06-03 is a Monday, and 2024-06-09 a Sunday.
+	at := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2024, month, day, hour, minute, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		expr string
+		t    time.Time
+		want bool
+	}{
+		{"* * * * *", at(6, 3, 12, 34), true},
+		{"30 23 * * 1-5", at(6, 3, 23, 30), true},
+		{"30 23 * * 1-5", at(6, 9, 23, 30), false},
+		{"30 23 * * 1-5", at(6, 3, 23, 31), false},
+		{"*/15 * * * *", at(6, 3, 8, 45), true},
+		{"*/15 * * * *", at(6, 3, 8, 50), false},
+		{"0 9-17/2 * * *", at(6, 3, 13, 0), true},
+		{"0 9-17/2 * * *", at(6, 3, 14, 0), false},
+		{"0 0 1 1 *", at(1, 1, 0, 0), true},
+		{"0 0 * 6 *", at(7, 1, 0, 0), false},
+
+		// Sunday is both 0 and 7.
+		{"0 0 * * 0", at(6, 9, 0, 0), true},
+		{"0 0 * * 7", at(6, 9, 0, 0), true},
+		{"0 0 * * 7", at(6, 3, 0, 0), false},
+
+		// With only one day field restricted, it alone decides.
+		{"0 0 15 * *", at(6, 15, 0, 0), true},
+		{"0 0 15 * *", at(6, 3, 0, 0), false},
+		{"0 0 * * 1", at(6, 3, 0, 0), true},
+		{"0 0 * * 1", at(6, 15, 0, 0), false},
+
+		// With both restricted, either may match.
+		{"0 0 15 * 1", at(6, 15, 0, 0), true},
+		{"0 0 15 * 1", at(6, 3, 0, 0), true},
+		{"0 0 15 * 1", at(6, 4, 0, 0), false},
+
+		{"@hourly", at(6, 3, 5, 0), true},
+		{"@hourly", at(6, 3, 5, 1), false},
+		{"@daily", at(6, 3, 0, 0), true},
+		{"@weekly", at(6, 9, 0, 0), true},
+		{"@weekly", at(6, 3, 0, 0), false},
+		{"@monthly", at(6, 1, 0, 0), true},
+		{"@yearly", at(1, 1, 0, 0), true},
+		{"@annually", at(6, 1, 0, 0), false},
+	}
+	for _, tt := range tests {
+		sched, err := ParseCronSchedule(tt.expr)
+		if err != nil {
+			t.Errorf("ParseCronSchedule(%q): %v", tt.expr, err)
+			continue
+		}
+		if got := sched.Matches(tt.t); got != tt.want {
+			t.Errorf("%q.Matches(%v) = %v, want %v", tt.expr, tt.t, got, tt.want)
+		}
+	}
+}
+
+func TestCronScheduleNext(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		{
+			name: "later today",
+			expr: "30 23 * * *",
+			from: time.Date(2024, 6, 3, 12, 0, 0, 0, time.UTC),
+			want: time.Date(2024, 6, 3, 23, 30, 0, 0, time.UTC),
+		},
+		{
+			name: "not the current minute",
+			expr: "* * * * *",
+			from: time.Date(2024, 6, 3, 12, 0, 30, 0, time.UTC),
+			want: time.Date(2024, 6, 3, 12, 1, 0, 0, time.UTC),
+		},
+		{
+			name: "across a month boundary",
+			expr: "0 8 1 * *",
+			from: time.Date(2024, 1, 31, 9, 0, 0, 0, time.UTC),
+			want: time.Date(2024, 2, 1, 8, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "day 31 skips short months",
+			expr: "0 0 31 * *",
+			from: time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC),
+			want: time.Date(2024, 5, 31, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "across a year boundary",
+			expr: "*/15 * * * *",
+			from: time.Date(2024, 12, 31, 23, 50, 0, 0, time.UTC),
+			want: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "leap day",
+			expr: "0 0 29 2 *",
+			from: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+			want: time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "day of month or week",
+			expr: "0 0 15 * 1",
+			from: time.Date(2024, 6, 11, 0, 0, 0, 0, time.UTC),
+			want: time.Date(2024, 6, 15, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			// Clocks go from 01:59 EST to 03:00 EDT on 2024-03-10.
+			name: "into daylight saving time",
+			expr: "0 * * * *",
+			from: time.Date(2024, 3, 10, 1, 30, 0, 0, newYork),
+			want: time.Date(2024, 3, 10, 3, 0, 0, 0, newYork),
+		},
+		{
+			name: "time skipped by daylight saving time",
+			expr: "30 2 * * *",
+			from: time.Date(2024, 3, 10, 0, 0, 0, 0, newYork),
+			want: time.Date(2024, 3, 11, 2, 30, 0, 0, newYork),
+		},
+		{
+			// Clocks go from 01:59 EDT back to 01:00 EST on 2024-11-03.
+			name: "time repeated by daylight saving time",
+			expr: "30 1 * * *",
+			from: time.Date(2024, 11, 3, 5, 31, 0, 0, time.UTC).In(newYork), // 01:31 EDT
+			want: time.Date(2024, 11, 3, 6, 30, 0, 0, time.UTC),             // 01:30 EST
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sched, err := ParseCronSchedule(tt.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := sched.Next(tt.from); !got.Equal(tt.want) {
+				t.Errorf("%q.Next(%v) = %v, want %v", tt.expr, tt.from, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCronScheduleNextNever(t *testing.T) {
+	sched, err := ParseCronSchedule("0 0 30 2 *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sched.Next(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)); !got.IsZero() {
+		t.Errorf("Next() = %v, want the zero time", got)
+	}
+}
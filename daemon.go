@@ -0,0 +1,258 @@
+package main
+
+import (
+	"crypto/subtle"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// triggerSection maps daemon trigger names to the commands they run.
+const triggerSection = "trigger"
+
+// validSecret reports whether a request carries the shared secret, in an
+// `X-Picoleaf-Secret` header or a `secret` query parameter. Any request is
+// valid if no secret is set.
+func validSecret(r *http.Request, secret string) bool {
+	if secret == "" {
+		return true
+	}
+	got := r.Header.Get("X-Picoleaf-Secret")
+	if got == "" {
+		got = r.URL.Query().Get("secret")
+	}
+	return subtle.ConstantTimeCompare([]byte(got), []byte(secret)) == 1
+}
+
+// triggerHandler runs the command configured for `/trigger/<name>` in the
+// `[trigger]` section. Commands run in the background, one at a time, so
+// slow ones like notify don't keep the caller waiting.
+func triggerHandler(secret string, run func(args []string) error) http.HandlerFunc {
+	var mu sync.Mutex
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !validSecret(r, secret) {
+			http.Error(w, "invalid secret", http.StatusUnauthorized)
+			return
+		}
+
+		name := strings.TrimPrefix(r.URL.Path, "/trigger/")
+		key, err := cfg.Section(triggerSection).GetKey(name)
+		if name == "" || err != nil {
+			http.Error(w, "unknown trigger", http.StatusNotFound)
+			return
+		}
+		command := strings.Fields(key.String())
+
+		slog.Info("trigger fired", "name", name, "command", key.String())
+		go func() {
+			mu.Lock()
+			defer mu.Unlock()
+
+			err := run(command)
+			if err != nil {
+				slog.Error("trigger command failed", "name", name, "err", err)
+			}
+		}()
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
+// daemonAuth is the credentials API proxy requests must carry: an API key,
+// as a bearer token or `X-API-Key` header, or a basic auth username and
+// password.
+type daemonAuth struct {
+	APIKey   string
+	Username string
+	Password string
+}
+
+// Enabled reports whether any credentials are configured.
+func (a daemonAuth) Enabled() bool {
+	return a.APIKey != "" || (a.Username != "" && a.Password != "")
+}
+
+// Valid reports whether a request carries valid credentials.
+func (a daemonAuth) Valid(r *http.Request) bool {
+	equal := func(got, want string) bool {
+		return want != "" && subtle.ConstantTimeCompare([]byte(got), []byte(want)) == 1
+	}
+
+	key := r.Header.Get("X-API-Key")
+	if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		key = bearer
+	}
+	if equal(key, a.APIKey) {
+		return true
+	}
+
+	username, password, ok := r.BasicAuth()
+	return ok && equal(username, a.Username) && equal(password, a.Password)
+}
+
+// Wrap rejects requests to h without valid credentials.
+func (a daemonAuth) Wrap(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !a.Valid(r) {
+			if a.Username != "" {
+				w.Header().Set("WWW-Authenticate", `Basic realm="picoleaf"`)
+			}
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// daemonWriteInterval is how long the daemon collects state changes for
+// before sending them, so a burst of them, e.g. from dragging a slider,
+// becomes one write of the latest values.
+const daemonWriteInterval = 50 * time.Millisecond
+
+// putStateHandler handles a state PUT through the client's rate limit and
+// coalescing window, reporting errors from the Nanoleaf with their original
+// status.
+func putStateHandler(client Client, w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	response, err := client.Put("state", body)
+	var apiErr *APIError
+	switch {
+	case errors.As(err, &apiErr):
+		http.Error(w, apiErr.Message, apiErr.Status)
+	case err != nil:
+		slog.Error("proxy request failed", "path", r.URL.Path, "err", err)
+		http.Error(w, "bad gateway", http.StatusBadGateway)
+	case response == "":
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		io.WriteString(w, response)
+	}
+}
+
+// apiProxy forwards `/api/<path>` to the Nanoleaf's API, adding its access
+// token, so API clients never need it. Only GET and PUT are forwarded, which
+// keeps the Nanoleaf's token endpoints out of reach. State changes are
+// sent through the client's rate limit and coalescing window.
+func apiProxy(client Client) http.Handler {
+	target, err := url.Parse(client.Endpoint(""))
+	if err != nil {
+		panic(err)
+	}
+
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.Out.URL.Scheme = target.Scheme
+			r.Out.URL.Host = target.Host
+			r.Out.URL.Path = target.Path + strings.TrimPrefix(r.In.URL.Path, "/api/")
+			r.Out.URL.RawPath = ""
+			r.Out.Host = target.Host
+			r.Out.Header.Del("Authorization")
+			r.Out.Header.Del("X-API-Key")
+		},
+		Transport: client.transport(),
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			slog.Error("proxy request failed", "path", r.URL.Path, "err", client.redactError(err))
+			http.Error(w, "bad gateway", http.StatusBadGateway)
+		},
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPut && strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/"), "/") == "state":
+			putStateHandler(client, w, r)
+		case r.Method == http.MethodGet || r.Method == http.MethodPut:
+			proxy.ServeHTTP(w, r)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+// doDaemonCommand serves an HTTP API for poking the lights, until
+// interrupted.
+func doDaemonCommand(client Client, args []string) {
+	section := cfg.Section("daemon")
+	flags := flag.NewFlagSet("daemon", flag.ExitOnError)
+	listen := flags.String("listen", section.Key("listen").MustString("localhost:8080"), "Address to listen on")
+	secret := flags.String("secret", section.Key("secret").String(), "Shared secret that trigger requests must carry")
+	tlsCert := flags.String("tls-cert", section.Key("tls_cert").String(), "TLS certificate file, to serve HTTPS")
+	tlsKey := flags.String("tls-key", section.Key("tls_key").String(), "TLS private key file, to serve HTTPS")
+	flags.Usage = func() {
+		fmt.Println("usage: picoleaf daemon [--listen <address>] [--secret <secret>] [--tls-cert <path> --tls-key <path>]")
+		exit(1)
+	}
+	flags.Parse(args)
+
+	if flags.NArg() > 0 || (*tlsCert == "") != (*tlsKey == "") {
+		flags.Usage()
+	}
+
+	client.SetCoalesceWindow(daemonWriteInterval)
+
+	mux := http.NewServeMux()
+	mux.Handle("/trigger/", triggerHandler(*secret, runSubcommand))
+	mux.Handle("/ci", configCIWebhookHandler())
+
+	// Without credentials, the proxy would hand out full control of the
+	// Nanoleaf to anyone who can reach the daemon.
+	auth := daemonAuth{
+		APIKey:   section.Key("api_key").String(),
+		Username: section.Key("username").String(),
+		Password: section.Key("password").String(),
+	}
+	if auth.Enabled() {
+		mux.Handle("/api/", auth.Wrap(apiProxy(client)))
+
+		// With a username, the browser asks for it when the page loads, and
+		// sends it with the page's API requests. Otherwise, the page asks for
+		// the API key.
+		var ui http.Handler = uiHandler()
+		if auth.Username != "" && auth.Password != "" {
+			ui = auth.Wrap(ui)
+		}
+		mux.Handle("/ui", ui)
+	} else {
+		slog.Warn("API proxy and web UI disabled, set api_key or username and password in [daemon] to enable them")
+	}
+
+	schedule, err := loadSceneSchedule()
+	if err != nil {
+		fmt.Println("error: invalid scene schedule:", err)
+		exit(1)
+	}
+	if len(schedule) > 0 {
+		var coords Coordinates
+		if slices.ContainsFunc(schedule, func(s ScheduledScene) bool { return s.Solar != nil }) {
+			coords, err = loadCoordinates()
+			if err != nil {
+				fmt.Println("error: failed to determine location:", err)
+				exit(1)
+			}
+		}
+		go runSceneSchedule(client, schedule, coords)
+	}
+
+	slog.Info("daemon listening", "addr", *listen, "tls", *tlsCert != "", "proxy", auth.Enabled(), "triggers", len(cfg.Section(triggerSection).Keys()), "scenes", len(schedule))
+	if *tlsCert != "" {
+		err = http.ListenAndServeTLS(*listen, *tlsCert, *tlsKey, mux)
+	} else {
+		err = http.ListenAndServe(*listen, mux)
+	}
+	fmt.Println("error: daemon failed:", err)
+	exit(1)
+}
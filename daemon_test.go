@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTriggerHandler(t *testing.T) {
+	setTestConfig(t, "[trigger]\ndoorbell = notify --color blue --times 3\n")
+
+	ran := make(chan []string, 1)
+	handler := triggerHandler("s3cret", func(args []string) error {
+		ran <- args
+		return nil
+	})
+
+	tests := []struct {
+		method, target, header string
+		want                   int
+	}{
+		{http.MethodGet, "/trigger/doorbell", "", http.StatusUnauthorized},
+		{http.MethodGet, "/trigger/doorbell?secret=wrong", "", http.StatusUnauthorized},
+		{http.MethodGet, "/trigger/nope?secret=s3cret", "", http.StatusNotFound},
+		{http.MethodDelete, "/trigger/doorbell?secret=s3cret", "", http.StatusMethodNotAllowed},
+		{http.MethodPost, "/trigger/doorbell", "s3cret", http.StatusAccepted},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.target, nil)
+		if tt.header != "" {
+			req.Header.Set("X-Picoleaf-Secret", tt.header)
+		}
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s %s: status = %d, want %d", tt.method, tt.target, rec.Code, tt.want)
+		}
+	}
+
+	select {
+	case args := <-ran:
+		if want := []string{"notify", "--color", "blue", "--times", "3"}; !reflect.DeepEqual(args, want) {
+			t.Errorf("ran %q, want %q", args, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("trigger command didn't run")
+	}
+	select {
+	case args := <-ran:
+		t.Errorf("rejected request ran %q", args)
+	default:
+	}
+}
+
+func TestDaemonAuth(t *testing.T) {
+	auth := daemonAuth{APIKey: "key", Username: "me", Password: "pw"}
+	tests := []struct {
+		name  string
+		setup func(*http.Request)
+		want  bool
+	}{
+		{"none", func(r *http.Request) {}, false},
+		{"bearer", func(r *http.Request) { r.Header.Set("Authorization", "Bearer key") }, true},
+		{"header", func(r *http.Request) { r.Header.Set("X-API-Key", "key") }, true},
+		{"wrong key", func(r *http.Request) { r.Header.Set("X-API-Key", "nope") }, false},
+		{"basic", func(r *http.Request) { r.SetBasicAuth("me", "pw") }, true},
+		{"wrong password", func(r *http.Request) { r.SetBasicAuth("me", "nope") }, false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/api/state", nil)
+		tt.setup(req)
+		if got := auth.Valid(req); got != tt.want {
+			t.Errorf("%s: Valid = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	if (daemonAuth{Username: "me"}).Enabled() {
+		t.Error("Enabled = true with a username but no password")
+	}
+}
+
+func TestAPIProxy(t *testing.T) {
+	client, server := newTestClient(t)
+	handler := daemonAuth{APIKey: "key"}.Wrap(apiProxy(client))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/state", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("without a key: status = %d, want 401", rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPut, "/api/state", strings.NewReader(`{"brightness":{"value":33}}`))
+	req.Header.Set("X-API-Key", "key")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code/100 != 2 {
+		t.Fatalf("PUT state: status = %d, want success", rec.Code)
+	}
+	if b := server.Device().State.Brightness; b != 33 {
+		t.Errorf("brightness = %d, want 33", b)
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/api/", nil)
+	req.Header.Set("X-API-Key", "key")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("DELETE: status = %d, want 405", rec.Code)
+	}
+}
+
+func TestAPIProxyCoalescesState(t *testing.T) {
+	client, server := newTestClient(t)
+	client.SetCoalesceWindow(daemonWriteInterval)
+	handler := apiProxy(client)
+
+	// A slider being dragged.
+	var wg sync.WaitGroup
+	codes := make([]int, 5)
+	for i := range codes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			body := fmt.Sprintf(`{"brightness":{"value":%d}}`, 10*(i+1))
+			req := httptest.NewRequest(http.MethodPut, "/api/state", strings.NewReader(body))
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			codes[i] = rec.Code
+		}(i)
+		time.Sleep(5 * time.Millisecond)
+	}
+	wg.Wait()
+
+	for i, code := range codes {
+		if code != http.StatusNoContent {
+			t.Errorf("PUT %d: status = %d, want 204", i, code)
+		}
+	}
+	var puts []string
+	for _, req := range server.Requests() {
+		if req.Method == http.MethodPut {
+			puts = append(puts, req.Body)
+		}
+	}
+	if want := []string{`{"brightness":{"value":50}}`}; !reflect.DeepEqual(puts, want) {
+		t.Errorf("state writes = %q, want %q", puts, want)
+	}
+
+	req := httptest.NewRequest(http.MethodPut, "/api/state", strings.NewReader(`{"brightness":{"value":"high"}}`))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("invalid state: status = %d, want the Nanoleaf's 422", rec.Code)
+	}
+}
+
+func TestUIHandler(t *testing.T) {
+	rec := httptest.NewRecorder()
+	uiHandler()(rec, httptest.NewRequest(http.MethodGet, "/ui", nil))
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/html") {
+		t.Errorf("GET /ui: status = %d, content type %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(rec.Body.String(), `id="brightness"`) {
+		t.Error("GET /ui didn't serve the page")
+	}
+
+	rec = httptest.NewRecorder()
+	uiHandler()(rec, httptest.NewRequest(http.MethodPost, "/ui", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST /ui: status = %d, want 405", rec.Code)
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+)
+
+// DDPPort is the UDP port DDP (Distributed Display Protocol) is sent to.
+const DDPPort = 4048
+
+// DDP header flags and layout.
+const (
+	ddpFlagVersionMask = 0xc0
+	ddpFlagVersion1    = 0x40
+	ddpFlagTimecode    = 0x10
+	ddpFlagQuery       = 0x02
+	ddpFlagPush        = 0x01
+
+	ddpHeaderLen         = 10
+	ddpTimecodeHeaderLen = 14
+
+	// ddpMaxFrameLen bounds reassembled frames, well above any Nanoleaf.
+	ddpMaxFrameLen = 3 * 1024
+)
+
+// ddpPacket is a single DDP data packet. Frames may be split across several
+// packets, each writing to a different offset; the packet with the push flag
+// set completes the frame.
+type ddpPacket struct {
+	Offset int
+	Data   []byte
+	Push   bool
+}
+
+// parseDDP parses a DDP packet.
+func parseDDP(packet []byte) (*ddpPacket, error) {
+	if len(packet) < ddpHeaderLen {
+		return nil, errors.New("packet too short")
+	}
+
+	flags := packet[0]
+	if flags&ddpFlagVersionMask != ddpFlagVersion1 {
+		return nil, errors.New("unsupported DDP version")
+	}
+	if flags&ddpFlagQuery != 0 {
+		return nil, errors.New("queries aren't supported")
+	}
+
+	headerLen := ddpHeaderLen
+	if flags&ddpFlagTimecode != 0 {
+		headerLen = ddpTimecodeHeaderLen
+	}
+
+	// Checked before converting, since offsets of 2^31 and up would be
+	// negative as a 32-bit int.
+	offset := binary.BigEndian.Uint32(packet[4:])
+	if offset > ddpMaxFrameLen {
+		return nil, errors.New("offset out of range")
+	}
+	length := int(binary.BigEndian.Uint16(packet[8:]))
+	if headerLen+length > len(packet) {
+		return nil, errors.New("invalid length")
+	}
+	return &ddpPacket{
+		Offset: int(offset),
+		Data:   packet[headerLen : headerLen+length],
+		Push:   flags&ddpFlagPush != 0,
+	}, nil
+}
+
+// doDDPCommand drives the Nanoleaf from DDP pixel data, one RGB pixel per
+// panel, until interrupted.
+func doDDPCommand(client Client, args []string) {
+	flags := flag.NewFlagSet("ddp", flag.ExitOnError)
+	listen := flags.String("listen", fmt.Sprintf(":%d", DDPPort), "Address to receive DDP on")
+	order := flags.String("order", "x", panelOrderUsage)
+	selection := flags.String("panels", "", "Panels to drive, e.g. x<300, row:2, nearest:250,400, or a zone (default all)")
+	flags.Usage = func() {
+		fmt.Println("usage: picoleaf ddp [--listen <addr>] [--order <order>] [--panels <selection>]")
+		exit(1)
+	}
+	flags.Parse(args)
+
+	if flags.NArg() > 0 {
+		flags.Usage()
+	}
+
+	panelInfo, err := client.CachedPanelInfo()
+	if err != nil {
+		fmt.Println("error: failed to get Nanoleaf info:", err)
+		exit(1)
+	}
+	panels, err := selectPanels(panelInfo.PanelLayout.Layout.PositionData, *selection)
+	if err != nil {
+		fmt.Println("error:", err)
+		exit(1)
+	}
+	panels, err = orderPanels(panels, *order)
+	if err != nil {
+		fmt.Println("error:", err)
+		exit(1)
+	}
+
+	addr, err := net.ResolveUDPAddr("udp", *listen)
+	if err != nil {
+		fmt.Println("error: invalid listen address:", err)
+		exit(1)
+	}
+	conn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		fmt.Println("error: failed to listen for DDP:", err)
+		exit(1)
+	}
+	defer conn.Close()
+
+	ctx, restore := restoreOnInterrupt(client)
+	defer restore()
+
+	sink, err := openFrameSink(client, panelInfo)
+	if err != nil {
+		fmt.Println("error: failed to start external control:", err)
+		exit(1)
+	}
+	defer sink.Close()
+
+	// Close the socket on Ctrl-C, so the read loop exits.
+	context.AfterFunc(ctx, func() { conn.Close() })
+
+	slog.Info("listening for DDP", "addr", conn.LocalAddr(), "pixels", len(panels), "order", *order)
+	frame := make([]byte, 3*len(panels))
+	buf := make([]byte, 1500)
+	for {
+		n, _, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			slog.Error("failed to receive DDP", "err", err)
+			continue
+		}
+
+		packet, err := parseDDP(buf[:n])
+		if err != nil {
+			slog.Debug("ignoring packet", "err", err)
+			continue
+		}
+		if packet.Offset+len(packet.Data) > ddpMaxFrameLen {
+			slog.Debug("ignoring packet", "err", "offset out of range")
+			continue
+		}
+
+		// Pixels beyond the last panel are dropped.
+		if packet.Offset < len(frame) {
+			copy(frame[packet.Offset:], packet.Data)
+		}
+		if !packet.Push {
+			continue
+		}
+
+		err = sink.Send(dmxToFrames(frame, 1, panels))
+		if err != nil {
+			slog.Error("failed to send frame", "err", err)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseDDP(t *testing.T) {
+	packet := []byte{
+		ddpFlagVersion1 | ddpFlagPush, 1, 0x0b, 1,
+		0, 0, 0, 6,
+		0, 3,
+		10, 20, 30,
+	}
+
+	got, err := parseDDP(packet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &ddpPacket{Offset: 6, Data: []byte{10, 20, 30}, Push: true}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseDDP() = %+v, want %+v", got, want)
+	}
+
+	packet[9] = 4
+	_, err = parseDDP(packet)
+	if err == nil {
+		t.Error("parseDDP() accepted a length past the end of the packet")
+	}
+	packet[9] = 3
+
+	for _, offset := range [][4]byte{{0x80, 0, 0, 0}, {0xff, 0xff, 0xff, 0xfd}, {0, 0, 0x0c, 0x01}} {
+		copy(packet[4:], offset[:])
+		if _, err := parseDDP(packet); err == nil {
+			t.Errorf("parseDDP() accepted offset %#x", offset)
+		}
+	}
+}
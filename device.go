@@ -0,0 +1,346 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/ini.v1"
+)
+
+// Config section name prefixes for devices and groups, e.g. `[device.office]`
+// and `[group.downstairs]`.
+const (
+	deviceSectionPrefix = "device."
+	groupSectionPrefix  = "group."
+)
+
+// defaultDeviceName names the device configured in the top-level section.
+const defaultDeviceName = "default"
+
+// Device is a Nanoleaf configured in the config file.
+type Device struct {
+	Name  string
+	Host  string
+	Token string
+
+	// HTTPS is set for hosts configured as `https://...`, and TLSConfig
+	// holds any custom CA or -insecure setting.
+	HTTPS     bool
+	TLSConfig *tls.Config
+
+	// Calibration adjusts colors sent to the device, if configured.
+	Calibration *Calibration
+
+	// CacheTTL is how long panel info may be cached for, if configured.
+	CacheTTL time.Duration
+
+	// Proxy is the proxy to send REST requests through, if configured.
+	Proxy *url.URL
+
+	// MaxBrightness caps the brightness picoleaf sets, if configured.
+	MaxBrightness int
+
+	// Defaults are the defaults for commands run on the device.
+	Defaults Defaults
+
+	// FrameRetransmits is how many times to resend each external control
+	// frame, if configured.
+	FrameRetransmits int
+
+	// RateLimit is the most state writes to send a second, if configured.
+	// Zero disables the limit.
+	RateLimit *float64
+}
+
+// maxFrameRetransmits bounds the udp_retransmits setting.
+const maxFrameRetransmits = 5
+
+// Defaults are a device's configured defaults for commands. Flags given on
+// the command line override them.
+type Defaults struct {
+	Transition time.Duration // for -transition
+	Brightness int           // for `on`, if set
+	ColorMode  string        // "ct" or "hs", for `temp`
+}
+
+// Client returns an API client for the device.
+func (d Device) Client() Client {
+	client := NewClient(d.Host, d.Token)
+	client.HTTPS = d.HTTPS
+	if d.TLSConfig != nil {
+		client.SetTLSConfig(d.TLSConfig)
+	}
+	client.Calibration = d.Calibration
+	client.CacheTTL = d.CacheTTL
+	client.MaxBrightness = d.MaxBrightness
+	client.FrameRetransmits = d.FrameRetransmits
+	client.Defaults = d.Defaults
+	if d.RateLimit != nil {
+		client.SetRateLimit(*d.RateLimit, DefaultWriteBurst)
+	}
+	if d.Proxy != nil {
+		client.SetProxy(d.Proxy)
+	}
+	return client
+}
+
+// currentDeviceName returns the name of the device selected with -d.
+func currentDeviceName() string {
+	if *deviceName == "" {
+		return defaultDeviceName
+	}
+	return *deviceName
+}
+
+// findDevice returns the named device. The top-level `host` and
+// `access_token` settings define the device named "default".
+func findDevice(name string) (*Device, error) {
+	if name == "" {
+		name = defaultDeviceName
+	}
+	section, err := deviceSection(name)
+	if err != nil {
+		return nil, err
+	}
+
+	host, https, err := deviceHost(section)
+	if err != nil {
+		return nil, fmt.Errorf("device %q: %v", name, err)
+	}
+	tlsConfig, err := deviceTLSConfig(section)
+	if err != nil {
+		return nil, fmt.Errorf("device %q: %v", name, err)
+	}
+	calibration, err := deviceCalibration(section)
+	if err != nil {
+		return nil, fmt.Errorf("device %q: %v", name, err)
+	}
+	proxy, err := deviceProxy(section)
+	if err != nil {
+		return nil, fmt.Errorf("device %q: %v", name, err)
+	}
+	var cacheTTL time.Duration
+	if section.HasKey("cache_ttl") {
+		cacheTTL, err = section.Key("cache_ttl").Duration()
+		if err != nil {
+			return nil, fmt.Errorf("device %q: invalid cache_ttl: %v", name, err)
+		}
+	}
+	defaults, err := deviceDefaults(section)
+	if err != nil {
+		return nil, fmt.Errorf("device %q: %v", name, err)
+	}
+	maxBrightness, err := deviceBrightnessKey(section, "max_brightness")
+	if err != nil {
+		return nil, fmt.Errorf("device %q: %v", name, err)
+	}
+	var retransmits int
+	if section.HasKey("udp_retransmits") {
+		retransmits, err = section.Key("udp_retransmits").Int()
+		if err != nil || retransmits < 0 || retransmits > maxFrameRetransmits {
+			return nil, fmt.Errorf("device %q: udp_retransmits must be an integer 0-%d", name, maxFrameRetransmits)
+		}
+	}
+	var rateLimit *float64
+	if section.HasKey("rate_limit") {
+		limit, err := section.Key("rate_limit").Float64()
+		if err != nil || limit < 0 {
+			return nil, fmt.Errorf("device %q: rate_limit must be a number of writes per second, or 0 for no limit", name)
+		}
+		rateLimit = &limit
+	}
+	return &Device{
+		Name:        name,
+		Host:        host,
+		Token:       section.Key("access_token").String(),
+		HTTPS:       https,
+		TLSConfig:   tlsConfig,
+		Calibration: calibration,
+		CacheTTL:    cacheTTL,
+		Proxy:       proxy,
+
+		MaxBrightness:    maxBrightness,
+		Defaults:         defaults,
+		FrameRetransmits: retransmits,
+		RateLimit:        rateLimit,
+	}, nil
+}
+
+// deviceBrightnessKey reads a brightness setting, returning 0 if it's unset.
+func deviceBrightnessKey(section *ini.Section, key string) (int, error) {
+	if !section.HasKey(key) {
+		return 0, nil
+	}
+	brightness, err := section.Key(key).Int()
+	if err != nil || brightness < 1 || brightness > 100 {
+		return 0, fmt.Errorf("%s must be an integer 1-100", key)
+	}
+	return brightness, nil
+}
+
+// deviceDefaults reads a device's `default_transition`,
+// `default_brightness`, and `default_color_mode` settings.
+func deviceDefaults(section *ini.Section) (Defaults, error) {
+	var defaults Defaults
+	var err error
+	if section.HasKey("default_transition") {
+		defaults.Transition, err = section.Key("default_transition").Duration()
+		if err != nil || defaults.Transition < 0 {
+			return Defaults{}, fmt.Errorf("invalid default_transition %q", section.Key("default_transition").String())
+		}
+	}
+	defaults.Brightness, err = deviceBrightnessKey(section, "default_brightness")
+	if err != nil {
+		return Defaults{}, err
+	}
+	defaults.ColorMode = section.Key("default_color_mode").String()
+	if defaults.ColorMode != "" && defaults.ColorMode != "ct" && defaults.ColorMode != "hs" {
+		return Defaults{}, fmt.Errorf("invalid default_color_mode %q, expected ct or hs", defaults.ColorMode)
+	}
+	return defaults, nil
+}
+
+// deviceSection returns the named device's config section.
+func deviceSection(name string) (*ini.Section, error) {
+	if name == defaultDeviceName {
+		return cfg.Section(""), nil
+	}
+	section, err := cfg.GetSection(deviceSectionPrefix + name)
+	if err != nil {
+		return nil, fmt.Errorf("no device named %q", name)
+	}
+	return section, nil
+}
+
+// deviceHost returns a device's address in host:port form, and whether it
+// uses HTTPS. The `host` setting may start with `http://` or `https://`, and
+// may include a port; otherwise the `port` setting is used, falling back to
+// the default port for the scheme.
+func deviceHost(section *ini.Section) (string, bool, error) {
+	host := section.Key("host").String()
+	https := strings.HasPrefix(host, "https://")
+	host = strings.TrimPrefix(host, "https://")
+	host = strings.TrimPrefix(host, "http://")
+	host = strings.TrimSuffix(host, "/")
+	if host == "" {
+		return "", https, nil
+	}
+
+	port := DefaultAPIPort
+	if https {
+		port = DefaultHTTPSPort
+	}
+	if section.HasKey("port") {
+		var err error
+		port, err = section.Key("port").Int()
+		if err != nil || port < 1 || port > 65535 {
+			return "", false, fmt.Errorf("invalid port %q", section.Key("port").String())
+		}
+	}
+
+	hostname, hostPort, err := splitHost(host, port)
+	if err != nil {
+		return "", false, err
+	}
+	if section.HasKey("port") && hostPort != port {
+		return "", false, fmt.Errorf("host %q and port %d disagree", host, port)
+	}
+	return net.JoinHostPort(hostname, strconv.Itoa(hostPort)), https, nil
+}
+
+// deviceTLSConfig returns the TLS configuration for a device, or nil to use
+// the defaults. The `ca_file` setting names a PEM file of extra certificates
+// to trust, and `insecure=true` (or the -insecure flag) skips verification
+// entirely, e.g. for self-signed certificates.
+func deviceTLSConfig(section *ini.Section) (*tls.Config, error) {
+	insecure := *insecureTLS || section.Key("insecure").MustBool(false)
+	caFile := section.Key("ca_file").String()
+	if !insecure && caFile == "" {
+		return nil, nil
+	}
+
+	config := &tls.Config{InsecureSkipVerify: insecure}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %v", err)
+		}
+
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", caFile)
+		}
+		config.RootCAs = pool
+	}
+	return config, nil
+}
+
+// deviceProxy returns the proxy set with a device's `proxy` setting, e.g.
+// `socks5://localhost:1080`, or nil if it has none.
+func deviceProxy(section *ini.Section) (*url.URL, error) {
+	proxy := section.Key("proxy").String()
+	if proxy == "" {
+		return nil, nil
+	}
+
+	proxyURL, err := url.Parse(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy: %v", err)
+	}
+	switch proxyURL.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("proxy %q must be an http, https, or socks5 URL", proxy)
+	}
+	if proxyURL.Host == "" {
+		return nil, fmt.Errorf("proxy %q has no host", proxy)
+	}
+	return proxyURL, nil
+}
+
+// isGroup reports whether name is a configured group.
+func isGroup(name string) bool {
+	_, err := cfg.GetSection(groupSectionPrefix + name)
+	return err == nil
+}
+
+// resolveDevices returns the devices named by a device or group name.
+// Groups list their members in a comma-separated `devices` setting.
+func resolveDevices(name string) ([]Device, error) {
+	section, err := cfg.GetSection(groupSectionPrefix + name)
+	if err != nil {
+		device, err := findDevice(name)
+		if err != nil {
+			return nil, fmt.Errorf("no device or group named %q", name)
+		}
+		return []Device{*device}, nil
+	}
+
+	var devices []Device
+	for _, member := range strings.Split(section.Key("devices").String(), ",") {
+		member = strings.TrimSpace(member)
+		if member == "" {
+			continue
+		}
+
+		device, err := findDevice(member)
+		if err != nil {
+			return nil, fmt.Errorf("group %q: %v", name, err)
+		}
+		devices = append(devices, *device)
+	}
+	if len(devices) == 0 {
+		return nil, fmt.Errorf("group %q has no devices", name)
+	}
+	return devices, nil
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"gopkg.in/ini.v1"
+)
+
+// deviceStatus describes a configured or discovered device, as listed by
+// `picoleaf devices`.
+type deviceStatus struct {
+	Name       string `json:"name"`
+	Host       string `json:"host"`
+	Model      string `json:"model,omitempty"`
+	Firmware   string `json:"firmware,omitempty"`
+	Reachable  bool   `json:"reachable"`
+	Token      bool   `json:"token"`
+	Configured bool   `json:"configured"`
+}
+
+// configuredDeviceNames returns the names of the devices in the config file:
+// "default" if the top-level section has a host, then the rest in order.
+func configuredDeviceNames() []string {
+	var names []string
+	for _, section := range cfg.Sections() {
+		if name, ok := strings.CutPrefix(section.Name(), deviceSectionPrefix); ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	if cfg.Section("").HasKey("host") {
+		names = append([]string{defaultDeviceName}, names...)
+	}
+	return names
+}
+
+// configuredDeviceStatuses checks each configured device, in parallel. A
+// device that answers with an API error, e.g. for a missing token, is still
+// reachable.
+func configuredDeviceStatuses() []deviceStatus {
+	names := configuredDeviceNames()
+	statuses := make([]deviceStatus, len(names))
+	runParallel(len(names), *parallelism, func(i int) error {
+		status := deviceStatus{Name: names[i], Configured: true}
+		defer func() { statuses[i] = status }()
+
+		device, err := findDevice(names[i])
+		if err != nil {
+			return err
+		}
+		status.Host = device.Host
+		status.Token = device.Token != ""
+
+		client := device.Client()
+		client.client.Timeout = probeTimeout
+		defer client.Close()
+
+		info, err := client.GetPanelInfo()
+		var apiErr *APIError
+		status.Reachable = err == nil || errors.As(err, &apiErr)
+		if err == nil {
+			status.Model = info.Model
+			status.Firmware = info.FirmwareVersion
+		}
+		return err
+	})
+	return statuses
+}
+
+// doDevicesCommand lists the configured devices, and any others found on
+// the local network.
+func doDevicesCommand(args []string) {
+	flags := flag.NewFlagSet("devices", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "Print the devices as JSON")
+	discover := flags.Duration("discover", 2*time.Second, "How long to search the network for other devices (0 to skip)")
+	flags.Usage = func() {
+		fmt.Println("usage: picoleaf devices [--json] [--discover <duration>]")
+		exit(1)
+	}
+	flags.Parse(args)
+
+	if flags.NArg() > 0 || *discover < 0 {
+		flags.Usage()
+	}
+
+	statuses := configuredDeviceStatuses()
+	if *discover > 0 {
+		discovered, err := discoverDevices(*discover)
+		if err != nil {
+			fmt.Println("warning: failed to search the network:", err)
+		}
+		configured := make(map[string]bool)
+		for _, s := range statuses {
+			configured[s.Host] = true
+		}
+		for _, d := range discovered {
+			if !configured[d.Host] {
+				statuses = append(statuses, deviceStatus{Name: d.Name, Host: d.Host, Model: d.Model, Reachable: true})
+			}
+		}
+	}
+
+	if *asJSON {
+		if statuses == nil {
+			statuses = []deviceStatus{}
+		}
+		data, err := json.MarshalIndent(statuses, "", "  ")
+		if err != nil {
+			fmt.Println("error:", err)
+			exit(1)
+		}
+		fmt.Println(string(data))
+		return
+	}
+
+	yesNo := map[bool]string{true: "yes", false: "no"}
+	orDash := func(s string) string {
+		if s == "" {
+			return "-"
+		}
+		return s
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tHOST\tMODEL\tFIRMWARE\tREACHABLE\tTOKEN\tSOURCE")
+	for _, s := range statuses {
+		source := "config"
+		if !s.Configured {
+			source = "discovered"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", orDash(s.Name), orDash(s.Host), orDash(s.Model), orDash(s.Firmware), yesNo[s.Reachable], yesNo[s.Token], source)
+	}
+	w.Flush()
+}
+
+// pairInterval is how often `device add` retries pairing while waiting for
+// the power button to be held.
+const pairInterval = 2 * time.Second
+
+// deviceKeys are the settings that belong to a device, which `device remove`
+// deletes from the top-level section for the default device. Zone and panel
+// name keys are removed too.
+var deviceKeys = []string{
+	"host", "port", "access_token", "ca_file", "insecure", "proxy", "cache_ttl", "gamma", "white_point",
+	"max_brightness", "default_transition", "default_brightness", "default_color_mode", "udp_retransmits",
+	"rate_limit",
+}
+
+// pairDevice requests an access token until the Nanoleaf grants one, or the
+// timeout elapses.
+func pairDevice(client Client, timeout time.Duration) (string, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		token, err := client.Pair()
+		if !errors.Is(err, errNotPairing) {
+			return token, err
+		}
+		if time.Now().Add(pairInterval).After(deadline) {
+			return "", fmt.Errorf("timed out after %s: %v", timeout, err)
+		}
+		if !sleepOrCancel(pairInterval) {
+			return "", errors.New("cancelled")
+		}
+	}
+}
+
+// addDeviceConfig adds a device to the config file. The default device's
+// settings go in the top-level section.
+func addDeviceConfig(name, host, token string) error {
+	section := cfg.Section("")
+	if name != defaultDeviceName {
+		var err error
+		section, err = cfg.NewSection(deviceSectionPrefix + name)
+		if err != nil {
+			return err
+		}
+	}
+	section.Key("host").SetValue(host)
+	section.Key("access_token").SetValue(token)
+	return cfg.SaveTo(configFilePath)
+}
+
+// removeDeviceConfig removes a device from the config file, and from any
+// groups listing it.
+func removeDeviceConfig(name string) error {
+	if name == defaultDeviceName {
+		section := cfg.Section("")
+		for _, key := range section.KeyStrings() {
+			if slices.Contains(deviceKeys, key) || strings.HasPrefix(key, zoneKeyPrefix) || strings.HasPrefix(key, panelKeyPrefix) {
+				section.DeleteKey(key)
+			}
+		}
+	} else {
+		cfg.DeleteSection(deviceSectionPrefix + name)
+	}
+
+	for _, section := range cfg.Sections() {
+		if !strings.HasPrefix(section.Name(), groupSectionPrefix) {
+			continue
+		}
+		members := section.Key("devices").Strings(",")
+		if i := slices.Index(members, name); i >= 0 {
+			section.Key("devices").SetValue(strings.Join(slices.Delete(members, i, i+1), ","))
+		}
+	}
+	return cfg.SaveTo(configFilePath)
+}
+
+// deviceExists reports whether a device is configured under name.
+func deviceExists(name string) bool {
+	return slices.Contains(configuredDeviceNames(), name)
+}
+
+// doDeviceCommand adds devices to the config file, pairing with them to get
+// an access token, or removes them.
+func doDeviceCommand(args []string) {
+	usage := func() {
+		fmt.Println("usage: picoleaf device add <name> <host> [--timeout <duration>] [--encrypt]")
+		fmt.Println("       picoleaf device remove <name>")
+		exit(1)
+	}
+	if len(args) < 2 {
+		usage()
+	}
+
+	command, name := args[0], args[1]
+	switch command {
+	case "add":
+		if len(args) < 3 || strings.HasPrefix(args[2], "-") {
+			usage()
+		}
+		host := args[2]
+
+		flags := flag.NewFlagSet("device add", flag.ExitOnError)
+		timeout := flags.Duration("timeout", time.Minute, "How long to wait for the power button to be held")
+		encrypt := flags.Bool("encrypt", false, "Store the access token encrypted, as with picoleaf encrypt")
+		flags.Usage = usage
+		flags.Parse(args[3:])
+
+		if flags.NArg() > 0 || *timeout <= 0 || strings.ContainsAny(name, " \t[]") {
+			flags.Usage()
+		}
+		if deviceExists(name) || isGroup(name) {
+			fmt.Printf("error: %q is already configured; remove it first\n", name)
+			exit(1)
+		}
+
+		section := ini.Empty().Section("")
+		section.Key("host").SetValue(host)
+		address, https, err := deviceHost(section)
+		if err != nil {
+			fmt.Println("error:", err)
+			exit(1)
+		}
+		tlsConfig, err := deviceTLSConfig(section)
+		if err != nil {
+			fmt.Println("error:", err)
+			exit(1)
+		}
+		client := Device{Name: name, Host: address, HTTPS: https, TLSConfig: tlsConfig}.Client()
+		defer client.Close()
+
+		fmt.Println("Hold the Nanoleaf's power button for 5-7 seconds, until its lights flash...")
+		token, err := pairDevice(client, *timeout)
+		if err != nil {
+			fmt.Println("error: failed to pair:", err)
+			exit(1)
+		}
+		if *encrypt {
+			key, err := loadSecretKey(true)
+			if err == nil {
+				token, err = encryptSecret(key, token)
+			}
+			if err != nil {
+				fmt.Println("error:", err)
+				exit(1)
+			}
+		}
+
+		err = addDeviceConfig(name, host, token)
+		if err != nil {
+			fmt.Println("error: failed to save config:", err)
+			exit(1)
+		}
+		fmt.Printf("Added %s; use it with -d %s\n", name, name)
+	case "remove":
+		if len(args) != 2 {
+			usage()
+		}
+		if !deviceExists(name) {
+			fmt.Printf("error: no device named %q\n", name)
+			exit(1)
+		}
+		err := removeDeviceConfig(name)
+		if err != nil {
+			fmt.Println("error: failed to save config:", err)
+			exit(1)
+		}
+		if last, err := loadLastDevice(); err == nil && last == name {
+			saveLastDevice(defaultDeviceName)
+		}
+	default:
+		usage()
+	}
+}
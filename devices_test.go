@@ -0,0 +1,104 @@
+package main
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestParseSSDPResponse(t *testing.T) {
+	res := "HTTP/1.1 200 OK\r\n" +
+		"Cache-Control: max-age=60\r\n" +
+		"Location: http://192.168.1.20:16021\r\n" +
+		"ST: nanoleaf:nl29\r\n" +
+		"nl-deviceid: 5E:2E:EA:XX:XX:XX\r\n" +
+		"nl-devicename: Canvas 4A3B\r\n\r\n"
+	got, ok := parseSSDPResponse([]byte(res))
+	want := discoveredDevice{Name: "Canvas 4A3B", Host: "192.168.1.20:16021", Model: "NL29"}
+	if !ok || got != want {
+		t.Errorf("parseSSDPResponse() = %+v, %v, want %+v", got, ok, want)
+	}
+
+	other := "HTTP/1.1 200 OK\r\nLocation: http://192.168.1.1:1900/desc.xml\r\nST: upnp:rootdevice\r\n\r\n"
+	if _, ok := parseSSDPResponse([]byte(other)); ok {
+		t.Error("parseSSDPResponse() accepted a non-Nanoleaf response")
+	}
+	if model := modelFromSearchTarget("nanoleaf_aurora:light"); model != "NL22" {
+		t.Errorf("modelFromSearchTarget(aurora) = %q, want NL22", model)
+	}
+}
+
+func TestConfiguredDeviceStatuses(t *testing.T) {
+	_, server := newTestClient(t)
+	setTestConfig(t, "host="+server.Host()+"\naccess_token="+server.Token+"\n"+
+		"[device.notoken]\nhost="+server.Host()+"\n"+
+		"[device.gone]\nhost=127.0.0.1:1\naccess_token=abc\n")
+
+	statuses := configuredDeviceStatuses()
+	want := []deviceStatus{
+		{Name: "default", Host: server.Host(), Model: "NL22", Firmware: server.Device().FirmwareVersion, Reachable: true, Token: true, Configured: true},
+		{Name: "gone", Host: "127.0.0.1:1", Token: true, Configured: true},
+		{Name: "notoken", Host: server.Host(), Reachable: true, Configured: true},
+	}
+	if len(statuses) != len(want) {
+		t.Fatalf("statuses = %+v, want %+v", statuses, want)
+	}
+	for i := range want {
+		if statuses[i] != want[i] {
+			t.Errorf("statuses[%d] = %+v, want %+v", i, statuses[i], want[i])
+		}
+	}
+}
+
+func TestAddRemoveDeviceConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "picoleafrc")
+	prev := configFilePath
+	configFilePath = path
+	t.Cleanup(func() { configFilePath = prev })
+
+	setTestConfig(t, "[group.all]\ndevices=office,desk\n")
+	if err := addDeviceConfig("default", "192.168.1.10", "abc"); err != nil {
+		t.Fatal(err)
+	}
+	if err := addDeviceConfig("office", "https://192.168.1.20", "def"); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := loadINI(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg = f
+	if names := configuredDeviceNames(); !slices.Equal(names, []string{"default", "office"}) {
+		t.Fatalf("devices = %q, want default and office", names)
+	}
+	device, err := findDevice("office")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if device.Host != "192.168.1.20:443" || !device.HTTPS || device.Token != "def" {
+		t.Errorf("office = %+v, want the added host and token", device)
+	}
+
+	cfg.Section("").Key("zone.left").SetValue("1,2")
+	if err := removeDeviceConfig("default"); err != nil {
+		t.Fatal(err)
+	}
+	if err := removeDeviceConfig("office"); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err = loadINI(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys := f.Section("").KeyStrings(); len(keys) > 0 {
+		t.Errorf("top-level keys = %q, want none", keys)
+	}
+	if _, err := f.GetSection("device.office"); err == nil {
+		t.Error("device.office section wasn't removed")
+	}
+	if devices := f.Section("group.all").Key("devices").String(); devices != "desk" {
+		t.Errorf("group.all devices = %q, want desk", devices)
+	}
+}
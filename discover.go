@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ssdpAddress is the SSDP multicast group and port.
+const ssdpAddress = "239.255.255.250:1900"
+
+// ssdpTargets are the SSDP search targets Nanoleaf devices answer to.
+var ssdpTargets = []string{
+	"nanoleaf_aurora:light",
+	"nanoleaf:nl29",
+	"nanoleaf:nl42",
+	"nanoleaf:nl52",
+	"nanoleaf:nl59",
+}
+
+// discoveredDevice is a Nanoleaf that answered an SSDP search.
+type discoveredDevice struct {
+	Name  string
+	Host  string // host:port of its API
+	Model string
+}
+
+// modelFromSearchTarget returns the model for an SSDP search target, e.g.
+// NL29 for `nanoleaf:nl29`.
+func modelFromSearchTarget(st string) string {
+	if st == "nanoleaf_aurora:light" {
+		return "NL22"
+	}
+	if model, ok := strings.CutPrefix(st, "nanoleaf:"); ok {
+		return strings.ToUpper(model)
+	}
+	return ""
+}
+
+// parseSSDPResponse parses a response to an SSDP search, reporting whether
+// it came from a Nanoleaf.
+func parseSSDPResponse(data []byte) (discoveredDevice, bool) {
+	res, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(data)), nil)
+	if err != nil {
+		return discoveredDevice{}, false
+	}
+	res.Body.Close()
+
+	st := res.Header.Get("ST")
+	if !strings.HasPrefix(st, "nanoleaf") {
+		return discoveredDevice{}, false
+	}
+	location, err := url.Parse(res.Header.Get("Location"))
+	if err != nil || location.Host == "" {
+		return discoveredDevice{}, false
+	}
+	return discoveredDevice{
+		Name:  res.Header.Get("Nl-Devicename"),
+		Host:  location.Host,
+		Model: modelFromSearchTarget(st),
+	}, true
+}
+
+// discoverDevices searches the local network for Nanoleaf devices with
+// SSDP, collecting answers for the given time.
+func discoverDevices(timeout time.Duration) ([]discoveredDevice, error) {
+	group, err := net.ResolveUDPAddr("udp4", ssdpAddress)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	for _, st := range ssdpTargets {
+		search := fmt.Sprintf("M-SEARCH * HTTP/1.1\r\nHOST: %s\r\nMAN: \"ssdp:discover\"\r\nMX: 1\r\nST: %s\r\n\r\n", ssdpAddress, st)
+		_, err = conn.WriteTo([]byte(search), group)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var devices []discoveredDevice
+	seen := make(map[string]bool)
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	buf := make([]byte, 2048)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			// The read deadline ends the search.
+			return devices, nil
+		}
+		device, ok := parseSSDPResponse(buf[:n])
+		if ok && !seen[device.Host] {
+			seen[device.Host] = true
+			devices = append(devices, device)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// doctorTimeout bounds each network check.
+const doctorTimeout = 5 * time.Second
+
+// doctor prints the outcome of a series of checks.
+type doctor struct {
+	failed bool
+}
+
+func (d *doctor) ok(format string, args ...interface{}) {
+	fmt.Printf("[ok]   "+format+"\n", args...)
+}
+
+func (d *doctor) warn(format string, args ...interface{}) {
+	fmt.Printf("[warn] "+format+"\n", args...)
+}
+
+func (d *doctor) fail(format string, args ...interface{}) {
+	d.failed = true
+	fmt.Printf("[fail] "+format+"\n", args...)
+}
+
+func (d *doctor) hint(format string, args ...interface{}) {
+	fmt.Printf("       "+format+"\n", args...)
+}
+
+// doDoctorCommand diagnoses common setup problems. It runs before the config
+// file is loaded, so it can report problems with the config file itself.
+func doDoctorCommand(args []string) {
+	if len(args) > 0 {
+		fmt.Println("usage: picoleaf doctor")
+		exit(1)
+	}
+
+	d := &doctor{}
+	d.run()
+	if d.failed {
+		exit(1)
+	}
+}
+
+func (d *doctor) run() {
+	// Config
+	if _, err := os.Stat(configFilePath); err != nil {
+		d.fail("config file %s not found", configFilePath)
+		d.hint("create it with `host` and `access_token` settings (see README)")
+		return
+	}
+
+	var err error
+	cfg, err = loadINI(configFilePath)
+	if err != nil {
+		d.fail("config file %s could not be parsed: %v", configFilePath, err)
+		return
+	}
+	d.ok("config file %s", configFilePath)
+
+	device, err := findDevice(*deviceName)
+	if err != nil {
+		d.fail("%v", err)
+		d.hint("add a [device.%s] section, or drop the -d flag", *deviceName)
+		return
+	}
+	if device.Host == "" {
+		d.fail("no host configured for device %q", device.Name)
+		d.hint("set `host=<hostname or ip address>:<port>`")
+		return
+	}
+	if device.Token == "" {
+		d.fail("no access token configured for device %q", device.Name)
+		d.hint("hold the power button for 5-7 seconds, then run:")
+		d.hint("curl -iLX POST http://%s/api/v1/new", device.Host)
+		return
+	}
+
+	// DNS
+	hostname, port, err := net.SplitHostPort(device.Host)
+	if err != nil {
+		d.fail("invalid host %q: %v", device.Host, err)
+		return
+	}
+
+	addrs, err := net.LookupHost(hostname)
+	if err != nil {
+		d.fail("could not resolve %s: %v", hostname, err)
+		if strings.HasSuffix(hostname, ".local") {
+			d.hint("mDNS names need a resolver that supports them; try the IP address instead")
+		} else {
+			d.hint("check the hostname, or use the IP address from your router console")
+		}
+		return
+	}
+	d.ok("%s resolves to %s", hostname, strings.Join(addrs, ", "))
+
+	// TCP
+	address := net.JoinHostPort(hostname, port)
+	conn, err := net.DialTimeout("tcp", address, doctorTimeout)
+	if err != nil {
+		d.fail("could not connect to %s: %v", address, err)
+		d.hint("check that the Nanoleaf is powered on and on the same network")
+		if port != strconv.Itoa(DefaultAPIPort) {
+			d.hint("the Nanoleaf API usually listens on port %d", DefaultAPIPort)
+		}
+		return
+	}
+	conn.Close()
+	d.ok("connected to %s", address)
+
+	// Token
+	client := device.Client()
+	req, err := http.NewRequest(http.MethodGet, client.Endpoint(""), nil)
+	if err != nil {
+		d.fail("could not build request: %v", err)
+		return
+	}
+	httpClient := http.Client{Timeout: doctorTimeout, Transport: client.transport()}
+	res, err := httpClient.Do(req)
+	if err != nil {
+		d.fail("API request failed: %v", client.redactError(err))
+		return
+	}
+	res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+		d.ok("access token accepted")
+	case http.StatusUnauthorized, http.StatusForbidden:
+		d.fail("access token rejected (%s)", res.Status)
+		d.hint("generate a new token: hold the power button for 5-7 seconds, then run:")
+		d.hint("curl -iLX POST http://%s/api/v1/new", address)
+		return
+	default:
+		d.fail("unexpected API response: %s", res.Status)
+		return
+	}
+
+	// Firmware
+	panelInfo, err := client.GetPanelInfo()
+	if err != nil {
+		d.fail("could not read panel info: %v", err)
+		return
+	}
+	d.ok("%s (%s) running firmware %s", panelInfo.Name, panelInfo.Model, panelInfo.FirmwareVersion)
+	caps := DetectCapabilities(panelInfo)
+	if caps.ExtControlVersion < 2 {
+		d.warn("firmware %s predates external control v2, so custom effects are limited to 255 panels and 25.5s transitions", panelInfo.FirmwareVersion)
+		d.hint("update the firmware from the Nanoleaf app")
+	}
+
+	// UDP
+	err = checkUDP(addrs[0])
+	if err != nil {
+		d.fail("external control port %d/udp unreachable: %v", ExternalControlPort, err)
+		d.hint("check that your network (or firewall) doesn't block UDP to the Nanoleaf")
+		return
+	}
+	d.ok("external control port %d/udp reachable", ExternalControlPort)
+}
+
+// checkUDP sends an empty external control frame to the Nanoleaf, which is
+// ignored outside external control mode. UDP has no handshake, so the best we
+// can do is watch for an ICMP port unreachable error.
+func checkUDP(ip string) error {
+	conn, err := net.DialTimeout("udp", net.JoinHostPort(ip, strconv.Itoa(ExternalControlPort)), doctorTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Write([]byte{0, 0})
+	if err != nil {
+		return err
+	}
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, err = conn.Read(make([]byte, 1))
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return nil
+	}
+	return err
+}
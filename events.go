@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Event types, as numbered by the Nanoleaf events API.
+const (
+	StateEvent   = 1
+	LayoutEvent  = 2
+	EffectsEvent = 3
+	TouchEvent   = 4
+)
+
+// State event attributes.
+const (
+	AttrOn               = 1
+	AttrBrightness       = 2
+	AttrHue              = 3
+	AttrSaturation       = 4
+	AttrColorTemperature = 5
+	AttrColorMode        = 6
+)
+
+// Event is a single change reported by the Nanoleaf's event stream. State
+// and layout events set Attr and Value; touch events set PanelID and Gesture.
+type Event struct {
+	Type    int             `json:"-"`
+	Attr    int             `json:"attr"`
+	Value   json.RawMessage `json:"value"`
+	PanelID int             `json:"panelId"`
+	Gesture int             `json:"gesture"`
+}
+
+// IntValue returns the event's value as an integer.
+func (e Event) IntValue() (int, error) {
+	var v int
+	err := json.Unmarshal(e.Value, &v)
+	return v, err
+}
+
+// Subscribe streams events of the given types from the Nanoleaf, calling
+// handle for each one. It blocks until ctx is done or the stream fails.
+func (c Client) Subscribe(ctx context.Context, types []int, handle func(Event)) error {
+	ids := make([]string, len(types))
+	for i, t := range types {
+		ids[i] = strconv.Itoa(t)
+	}
+	path := "events?id=" + strings.Join(ids, ",")
+
+	c.logger().Debug("request", "method", http.MethodGet, "path", c.redact(path))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.Endpoint(path), nil)
+	if err != nil {
+		return c.redactError(err)
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return c.redactError(err)
+	}
+	defer res.Body.Close()
+
+	c.logger().Debug("response", "status", res.Status)
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected response: %s", res.Status)
+	}
+
+	// The stream is a series of `id: <type>` and `data: <json>` lines,
+	// separated by blank lines.
+	eventType := 0
+	scanner := bufio.NewScanner(res.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "id:"):
+			eventType, _ = strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "id:")))
+		case strings.HasPrefix(line, "data:"):
+			data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+			c.logger().Debug("event", "type", eventType, "data", data)
+
+			var payload struct {
+				Events []Event `json:"events"`
+			}
+			err := json.Unmarshal([]byte(data), &payload)
+			if err != nil {
+				return fmt.Errorf("invalid event: %v", err)
+			}
+			for _, event := range payload.Events {
+				event.Type = eventType
+				handle(event)
+			}
+		}
+	}
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err := scanner.Err(); err != nil {
+		return c.redactError(err)
+	}
+	return fmt.Errorf("event stream closed")
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+)
+
+// externalCommandPrefix names executables that add picoleaf commands, e.g.
+// `picoleaf-hello` for `picoleaf hello`.
+const externalCommandPrefix = "picoleaf-"
+
+// findExternalCommand returns the path of the executable implementing an
+// external command, if there's one on the PATH.
+func findExternalCommand(name string) (string, bool) {
+	path, err := exec.LookPath(externalCommandPrefix + name)
+	return path, err == nil
+}
+
+// externalCommandEnv returns the environment for an external command: ours,
+// plus the device to control and the options picoleaf was run with.
+func externalCommandEnv(client Client) []string {
+	return append(os.Environ(),
+		"PICOLEAF_HOST="+client.Host,
+		"PICOLEAF_TOKEN="+client.Token,
+		"PICOLEAF_HTTPS="+strconv.FormatBool(client.HTTPS),
+		"PICOLEAF_DEVICE="+currentDeviceName(),
+		"PICOLEAF_CONFIG="+configFilePath,
+	)
+}
+
+// runExternalCommand runs an external command with the remaining arguments,
+// exiting with its exit code if it fails.
+func runExternalCommand(client Client, path string, args []string) {
+	cmd := exec.Command(path, args[1:]...)
+	cmd.Env = externalCommandEnv(client)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		exit(max(exitErr.ExitCode(), 1))
+	} else if err != nil {
+		fmt.Printf("error: %s: %v\n", args[0], err)
+		exit(1)
+	}
+}
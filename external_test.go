@@ -0,0 +1,39 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestExternalCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script")
+	}
+	client, _ := newTestClient(t)
+	setTestConfig(t, "")
+
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out")
+	script := "#!/bin/sh\necho \"$PICOLEAF_HOST $PICOLEAF_TOKEN $*\" > " + out + "\nexit 4\n"
+	err := os.WriteFile(filepath.Join(dir, "picoleaf-hello"), []byte(script), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	code := runCommandInProcess(client, []string{"hello", "world"})
+	if code != 4 {
+		t.Errorf("exit code = %d, want the command's 4", code)
+	}
+
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := client.Host + " " + client.Token + " world"; strings.TrimSpace(string(got)) != want {
+		t.Errorf("command saw %q, want %q", got, want)
+	}
+}
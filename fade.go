@@ -0,0 +1,99 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+)
+
+// fade interpolates between two colors over a fixed duration.
+type fade struct {
+	Duration time.Duration
+	Steps    int    // 0 for a smooth fade
+	Ease     Easing // applied to each leg of the fade
+	Then     string // hold, reverse, or loop
+}
+
+// fadeEndings are the values accepted by fade --then.
+var fadeEndings = []string{"hold", "reverse", "loop"}
+
+// progress returns how far the fade is from the first color to the second,
+// from 0 to 1, at time t, and whether it has finished.
+func (f fade) progress(t time.Duration) (float64, bool) {
+	legs := t / f.Duration
+	x := float64(t%f.Duration) / float64(f.Duration)
+	switch {
+	case f.Then == "hold" && legs >= 1:
+		return 1, true
+	case f.Then == "reverse" && legs >= 2:
+		return 0, true
+	}
+
+	if f.Steps > 0 {
+		x = math.Floor(x*float64(f.Steps)) / float64(f.Steps)
+	}
+	x = f.Ease(x)
+	if legs%2 == 1 {
+		x = 1 - x
+	}
+	return x, false
+}
+
+// lerpRGB interpolates between two colors, channel by channel.
+func lerpRGB(a, b RGB, progress float64) RGB {
+	return RGB{
+		Red:   lerpChannel(a.Red, b.Red, progress),
+		Green: lerpChannel(a.Green, b.Green, progress),
+		Blue:  lerpChannel(a.Blue, b.Blue, progress),
+	}
+}
+
+// doFadeCommand fades the panels from one color to another.
+func doFadeCommand(client Client, args []string) {
+	usage := func() {
+		fmt.Println("usage: picoleaf fade <from> <to> [--duration <duration>] [--steps <n>] [--ease <easing>] [--then hold|reverse|loop] [--panels <selection>]")
+		exit(1)
+	}
+	if len(args) < 2 || strings.HasPrefix(args[0], "-") || strings.HasPrefix(args[1], "-") {
+		usage()
+	}
+
+	flags := flag.NewFlagSet("fade", flag.ExitOnError)
+	duration := flags.Duration("duration", 10*time.Second, "How long the fade takes")
+	steps := flags.Int("steps", 0, "Number of discrete steps (default smooth)")
+	ease := flags.String("ease", "linear", "Easing: linear, ease, ease-in, or ease-out")
+	then := flags.String("then", "hold", "What to do at the end: hold the second color, reverse back to the first, or loop")
+	selection := flags.String("panels", "", "Panels to drive, as for sacn --panels (default all)")
+	flags.Usage = usage
+	flags.Parse(args[2:])
+
+	easing, ok := easings[*ease]
+	if flags.NArg() > 0 || *duration <= 0 || *steps < 0 || !ok || !slices.Contains(fadeEndings, *then) {
+		flags.Usage()
+	}
+
+	from, err := parseColor(args[0])
+	if err != nil {
+		fmt.Println("error:", err)
+		exit(1)
+	}
+	to, err := parseColor(args[1])
+	if err != nil {
+		fmt.Println("error:", err)
+		exit(1)
+	}
+
+	f := fade{Duration: *duration, Steps: *steps, Ease: easing, Then: *then}
+	finished := false
+	runFx(client, *selection, func(t time.Duration, panels []PanelPosition) []SetPanelColor {
+		if finished {
+			return nil
+		}
+		progress, done := f.progress(t)
+		finished = done
+		return solidFrames(panels, lerpRGB(from, to, progress))
+	})
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestFadeProgress(t *testing.T) {
+	linear := easings["linear"]
+	tests := []struct {
+		name     string
+		fade     fade
+		t        time.Duration
+		want     float64
+		wantDone bool
+	}{
+		{"start", fade{Duration: 10 * time.Second, Ease: linear, Then: "hold"}, 0, 0, false},
+		{"midway", fade{Duration: 10 * time.Second, Ease: linear, Then: "hold"}, 5 * time.Second, 0.5, false},
+		{"hold", fade{Duration: 10 * time.Second, Ease: linear, Then: "hold"}, 12 * time.Second, 1, true},
+		{"steps", fade{Duration: 10 * time.Second, Steps: 4, Ease: linear, Then: "hold"}, 6 * time.Second, 0.5, false},
+		{"reversing", fade{Duration: 10 * time.Second, Ease: linear, Then: "reverse"}, 12 * time.Second, 0.8, false},
+		{"reversed", fade{Duration: 10 * time.Second, Ease: linear, Then: "reverse"}, 20 * time.Second, 0, true},
+		{"loop", fade{Duration: 10 * time.Second, Ease: linear, Then: "loop"}, 25 * time.Second, 0.5, false},
+	}
+	for _, tt := range tests {
+		got, done := tt.fade.progress(tt.t)
+		if math.Abs(got-tt.want) > 1e-9 || done != tt.wantDone {
+			t.Errorf("%s: progress(%s) = %v, %v, want %v, %v", tt.name, tt.t, got, done, tt.want, tt.wantDone)
+		}
+	}
+}
+
+func TestLerpRGB(t *testing.T) {
+	got := lerpRGB(RGB{255, 0, 0}, RGB{0, 0, 255}, 0.5)
+	want := RGB{128, 0, 128}
+	if got != want {
+		t.Errorf("lerpRGB = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+)
+
+// PanelColor is one panel's color in a Stream frame.
+type PanelColor struct {
+	PanelID int
+	Color   RGB
+}
+
+// Stream sends frames of panel colors to a Nanoleaf over external control,
+// letting integrations treat it like a framebuffer. Frames are paced to the
+// rate the model can keep up with: when they arrive faster, only the latest
+// pending frame is sent. A Stream is not safe for concurrent use.
+type Stream struct {
+	sink   *frameSink
+	panels []PanelPosition
+}
+
+// OpenStream starts external control and returns a Stream to the Nanoleaf.
+// Close it to stop streaming; the Nanoleaf keeps showing the last frame.
+func (c Client) OpenStream() (*Stream, error) {
+	info, err := c.CachedPanelInfo()
+	if err != nil {
+		return nil, err
+	}
+	sink, err := openFrameSink(c, info)
+	if err != nil {
+		return nil, err
+	}
+
+	var panels []PanelPosition
+	for _, p := range info.PanelLayout.Layout.PositionData {
+		if p.ShapeType != shapeShapesController {
+			panels = append(panels, p)
+		}
+	}
+	return &Stream{sink: sink, panels: panels}, nil
+}
+
+// Panels returns the panels the stream drives, in layout order.
+func (s *Stream) Panels() []PanelPosition {
+	return append([]PanelPosition(nil), s.panels...)
+}
+
+// WriteFrame sends a frame. Panels missing from it keep their colors.
+func (s *Stream) WriteFrame(colors []PanelColor) error {
+	frames := make([]SetPanelColor, len(colors))
+	for i, c := range colors {
+		frames[i] = SetPanelColor{PanelID: uint16(c.PanelID), Red: c.Color.Red, Green: c.Color.Green, Blue: c.Color.Blue}
+	}
+	return s.sink.Send(frames)
+}
+
+// Close sends any pending frame and stops streaming.
+func (s *Stream) Close() error {
+	return s.sink.Close()
+}
+
+// FrameWriter adapts a Stream to io.Writer, for raw RGB data: three bytes
+// per panel, in the order of Stream.Panels. Frames may be split across
+// writes, or several written at once; each complete frame is sent as soon as
+// it's written.
+type FrameWriter struct {
+	stream *Stream
+	buf    []byte
+}
+
+// NewFrameWriter returns a FrameWriter that sends frames to s.
+func NewFrameWriter(s *Stream) *FrameWriter {
+	return &FrameWriter{stream: s}
+}
+
+// FrameSize returns the size of a frame in bytes.
+func (w *FrameWriter) FrameSize() int {
+	return 3 * len(w.stream.panels)
+}
+
+// Write buffers p, sending each frame it completes.
+func (w *FrameWriter) Write(p []byte) (int, error) {
+	size := w.FrameSize()
+	if size == 0 {
+		return 0, fmt.Errorf("the Nanoleaf has no panels to stream to")
+	}
+
+	w.buf = append(w.buf, p...)
+	for len(w.buf) >= size {
+		err := w.stream.sink.Send(dmxToFrames(w.buf[:size], 1, w.stream.panels))
+		w.buf = append(w.buf[:0], w.buf[size:]...)
+		if err != nil {
+			return len(p), err
+		}
+	}
+	return len(p), nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFrameWriter(t *testing.T) {
+	client, server := newTestClient(t)
+	caps, err := client.Capabilities()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stream, err := client.OpenStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := NewFrameWriter(stream)
+	if w.FrameSize() != 9 {
+		t.Fatalf("frame size = %d, want 3 bytes for each of 3 panels", w.FrameSize())
+	}
+
+	// One frame split across writes, then another in a single write.
+	data := []byte{255, 0, 0, 0, 255, 0, 0, 0, 255, 10, 20, 30, 40, 50, 60, 70, 80, 90}
+	for _, chunk := range [][]byte{data[:4], data[4:9], data[9:]} {
+		if n, err := w.Write(chunk); err != nil || n != len(chunk) {
+			t.Fatalf("Write(%v) = %d, %v", chunk, n, err)
+		}
+	}
+	if err := stream.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	frames, err := server.WaitForFrames(2, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := encodeControlFrame(caps.ExtControlVersion, []SetPanelColor{
+		{PanelID: 101, Red: 10, Green: 20, Blue: 30},
+		{PanelID: 102, Red: 40, Green: 50, Blue: 60},
+		{PanelID: 103, Red: 70, Green: 80, Blue: 90},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last := frames[len(frames)-1]; !reflect.DeepEqual(last, want) {
+		t.Errorf("last frame = %v, want %v", last, want)
+	}
+}
+
+func TestStreamWriteFrame(t *testing.T) {
+	client, server := newTestClient(t)
+	caps, err := client.Capabilities()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stream, err := client.OpenStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+
+	panels := stream.Panels()
+	if len(panels) != 3 || panels[0].PanelID != 101 {
+		t.Fatalf("panels = %+v, want the layout's 3 panels", panels)
+	}
+
+	err = stream.WriteFrame([]PanelColor{{PanelID: 102, Color: RGB{1, 2, 3}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	frames, err := server.WaitForFrames(1, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := encodeControlFrame(caps.ExtControlVersion, []SetPanelColor{{PanelID: 102, Red: 1, Green: 2, Blue: 3}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(frames[0], want) {
+		t.Errorf("frame = %v, want %v", frames[0], want)
+	}
+}
@@ -0,0 +1,424 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// fxRenderer returns the panel colors for time t since an effect started, or
+// nil once the effect has finished.
+type fxRenderer func(t time.Duration, panels []PanelPosition) []SetPanelColor
+
+// runFx streams a locally rendered effect to the selected panels over
+// external control, at the model's safe frame rate, until it finishes or is
+// interrupted. If interrupted, it restores the previous state.
+func runFx(client Client, selection string, render fxRenderer) {
+	panelInfo, err := client.CachedPanelInfo()
+	if err != nil {
+		fmt.Println("error: failed to get Nanoleaf info:", err)
+		exit(1)
+	}
+	panels, err := selectPanels(panelInfo.PanelLayout.Layout.PositionData, selection)
+	if err != nil {
+		fmt.Println("error:", err)
+		exit(1)
+	}
+
+	ctx, restore := restoreOnInterrupt(client)
+	defer restore()
+
+	sink, err := openFrameSink(client, panelInfo)
+	if err != nil {
+		fmt.Println("error: failed to start external control:", err)
+		exit(1)
+	}
+	defer sink.Close()
+
+	fps := MaxFrameRate(panelInfo.Model)
+	ticker := time.NewTicker(time.Second / time.Duration(fps))
+	defer ticker.Stop()
+
+	slog.Info("running effect", "panels", len(panels), "fps", fps)
+	start := time.Now()
+	for {
+		frames := render(time.Since(start), panels)
+		if frames == nil {
+			return
+		}
+		err := sink.Send(frames)
+		if err != nil {
+			fmt.Println("error: failed to send frame:", err)
+			exit(1)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// scaleColor scales a color's channels by brightness, from 0 to 1.
+func scaleColor(c RGB, brightness float64) RGB {
+	return RGB{
+		Red:   uint8(math.Round(float64(c.Red) * brightness)),
+		Green: uint8(math.Round(float64(c.Green) * brightness)),
+		Blue:  uint8(math.Round(float64(c.Blue) * brightness)),
+	}
+}
+
+// solidFrames sets every panel to the same color.
+func solidFrames(panels []PanelPosition, c RGB) []SetPanelColor {
+	frames := make([]SetPanelColor, len(panels))
+	for i, p := range panels {
+		frames[i] = SetPanelColor{PanelID: uint16(p.PanelID), Red: c.Red, Green: c.Green, Blue: c.Blue}
+	}
+	return frames
+}
+
+// breatheLevel returns the brightness, from min to max percent, at time t
+// in a breathing cycle: a cosine wave that starts and ends at min.
+func breatheLevel(t, period time.Duration, min, max int) float64 {
+	phase := 2 * math.Pi * float64(t%period) / float64(period)
+	level := float64(min) + float64(max-min)*(1-math.Cos(phase))/2
+	return level / 100
+}
+
+func doFxCommand(client Client, args []string) {
+	usage := func() {
+		fmt.Println("usage: picoleaf fx breathe [--color <color>] [--period <duration>] [--min <n>] [--max <n>] [--panels <selection>]")
+		fmt.Println("       picoleaf fx candle [--color <color>] [--intensity <n>] [--wind <n>] [--panels <selection>]")
+		fmt.Println("       picoleaf fx clock [--12h] [--color <color>] [--background <color>] [--panels <selection>]")
+		fmt.Println("       picoleaf fx meteor [--color <color>] [--tail <n>] [--speed <n>] [--order <order>] [--bounce] [--loop] [--panels <selection>]")
+		fmt.Println("       picoleaf fx sysmon [--metric cpu|mem|net] [--interval <duration>] [--net-max <mbps>] [--color <color>] [--order <order>] [--panels <selection>]")
+		fmt.Println("       picoleaf fx twinkle [--color <color>] [--density <n>] [--speed <n>] [--panels <selection>]")
+		exit(1)
+	}
+
+	if len(args) < 1 {
+		usage()
+	}
+
+	switch args[0] {
+	case "breathe":
+		doFxBreatheCommand(client, args[1:])
+	case "candle":
+		doFxCandleCommand(client, args[1:])
+	case "clock":
+		doFxClockCommand(client, args[1:])
+	case "meteor":
+		doFxMeteorCommand(client, args[1:])
+	case "sysmon":
+		doFxSysmonCommand(client, args[1:])
+	case "twinkle":
+		doFxTwinkleCommand(client, args[1:])
+	default:
+		usage()
+	}
+}
+
+// doFxBreatheCommand slowly ramps the panels' brightness up and down.
+func doFxBreatheCommand(client Client, args []string) {
+	flags := flag.NewFlagSet("breathe", flag.ExitOnError)
+	colorArg := flags.String("color", "warmwhite", "Color, as a name or #rrggbb")
+	period := flags.Duration("period", 6*time.Second, "Length of one breath")
+	min := flags.Int("min", 10, "Lowest brightness (0-100)")
+	max := flags.Int("max", 70, "Highest brightness (0-100)")
+	selection := flags.String("panels", "", "Panels to drive, as for sacn --panels (default all)")
+	flags.Usage = func() {
+		fmt.Println("usage: picoleaf fx breathe [--color <color>] [--period <duration>] [--min <n>] [--max <n>] [--panels <selection>]")
+		exit(1)
+	}
+	flags.Parse(args)
+
+	if flags.NArg() > 0 || *period <= 0 || *min < 0 || *max > 100 || *min > *max {
+		flags.Usage()
+	}
+
+	color, err := parseColor(*colorArg)
+	if err != nil {
+		fmt.Println("error:", err)
+		exit(1)
+	}
+
+	runFx(client, *selection, func(t time.Duration, panels []PanelPosition) []SetPanelColor {
+		return solidFrames(panels, scaleColor(color, breatheLevel(t, *period, *min, *max)))
+	})
+}
+
+// candleFlame simulates one flickering flame per panel. Each flame's level
+// eases towards a target that changes at random; wind makes the target
+// change more often, and adds gusts that dip the flame further.
+type candleFlame struct {
+	rng       *rand.Rand
+	intensity float64 // 0-1, how deep ordinary flickers go
+	wind      float64 // 0-1
+
+	last    time.Duration
+	levels  map[int]float64
+	targets map[int]float64
+}
+
+func newCandleFlame(rng *rand.Rand, intensity, wind float64) *candleFlame {
+	return &candleFlame{
+		rng:       rng,
+		intensity: intensity,
+		wind:      wind,
+		levels:    make(map[int]float64),
+		targets:   make(map[int]float64),
+	}
+}
+
+// level advances the flame on a panel by dt, and returns its brightness,
+// from 0 to 1.
+func (f *candleFlame) level(panelID int, dt time.Duration) float64 {
+	level, ok := f.levels[panelID]
+	if !ok {
+		level = 1
+		f.targets[panelID] = 1
+	}
+
+	// A new target about 4 times a second in still air, and up to 14 in
+	// wind.
+	seconds := dt.Seconds()
+	if f.rng.Float64() < seconds*(4+10*f.wind) {
+		target := 1 - f.intensity*0.5*f.rng.Float64()
+		if f.rng.Float64() < seconds*f.wind*2 {
+			target -= f.wind * 0.4 * f.rng.Float64() // gust
+		}
+		f.targets[panelID] = math.Max(target, 0.05)
+	}
+
+	level += (f.targets[panelID] - level) * math.Min(1, seconds*8)
+	f.levels[panelID] = level
+	return level
+}
+
+// render is an fxRenderer for the given flame color.
+func (f *candleFlame) render(color RGB) fxRenderer {
+	return func(t time.Duration, panels []PanelPosition) []SetPanelColor {
+		dt := t - f.last
+		f.last = t
+
+		frames := make([]SetPanelColor, len(panels))
+		for i, p := range panels {
+			c := scaleColor(color, f.level(p.PanelID, dt))
+			frames[i] = SetPanelColor{PanelID: uint16(p.PanelID), Red: c.Red, Green: c.Green, Blue: c.Blue}
+		}
+		return frames
+	}
+}
+
+// doFxCandleCommand makes each panel flicker like a candle.
+func doFxCandleCommand(client Client, args []string) {
+	flags := flag.NewFlagSet("candle", flag.ExitOnError)
+	colorArg := flags.String("color", "#ff9329", "Flame color, as a name or #rrggbb")
+	intensity := flags.Int("intensity", 50, "How strongly the flames flicker (0-100)")
+	wind := flags.Int("wind", 20, "How drafty it is, making flickers faster and deeper (0-100)")
+	selection := flags.String("panels", "", "Panels to drive, as for sacn --panels (default all)")
+	flags.Usage = func() {
+		fmt.Println("usage: picoleaf fx candle [--color <color>] [--intensity <n>] [--wind <n>] [--panels <selection>]")
+		exit(1)
+	}
+	flags.Parse(args)
+
+	if flags.NArg() > 0 || *intensity < 0 || *intensity > 100 || *wind < 0 || *wind > 100 {
+		flags.Usage()
+	}
+
+	color, err := parseColor(*colorArg)
+	if err != nil {
+		fmt.Println("error:", err)
+		exit(1)
+	}
+
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	flame := newCandleFlame(rng, float64(*intensity)/100, float64(*wind)/100)
+	runFx(client, *selection, flame.render(color))
+}
+
+// meteor is a bright head with a fading tail, moving along a path of panels.
+type meteor struct {
+	Tail   int     // panels behind the head
+	Speed  float64 // panels per second
+	Bounce bool    // reverse at the end of the path, instead of flying off
+	Loop   bool
+}
+
+// levels returns the brightness of each panel on a path of n panels, from 0
+// to 1, at time t. It returns nil once a non-looping meteor has finished.
+func (m meteor) levels(n int, t time.Duration) []float64 {
+	pos := t.Seconds() * m.Speed
+
+	// The head runs from the first panel until the tail has left the last,
+	// or there and back when bouncing.
+	length := float64(n + m.Tail)
+	if m.Bounce {
+		length = float64(2 * max(n-1, 1))
+	}
+	if pos >= length {
+		if !m.Loop {
+			return nil
+		}
+		pos = math.Mod(pos, length)
+	}
+
+	head, forward := pos, true
+	if m.Bounce && pos > float64(n-1) {
+		head, forward = length-pos, false
+	}
+
+	levels := make([]float64, n)
+	for i := range levels {
+		behind := head - float64(i)
+		if !forward {
+			behind = -behind
+		}
+		if behind > -1 && behind <= float64(m.Tail)+1 {
+			// The head fades in as it arrives, and the tail fades out
+			// linearly behind it.
+			levels[i] = math.Min(behind+1, 1-math.Max(behind, 0)/float64(m.Tail+1))
+		}
+	}
+	return levels
+}
+
+// doFxMeteorCommand moves a meteor across the panels.
+func doFxMeteorCommand(client Client, args []string) {
+	flags := flag.NewFlagSet("meteor", flag.ExitOnError)
+	colorArg := flags.String("color", "white", "Color, as a name or #rrggbb")
+	tail := flags.Int("tail", 3, "Length of the tail, in panels")
+	speed := flags.Float64("speed", 1.5, "Speed, in panels per second")
+	order := flags.String("order", "chain", panelOrderUsage)
+	bounce := flags.Bool("bounce", false, "Reverse at the end of the path")
+	loop := flags.Bool("loop", false, "Repeat until interrupted")
+	selection := flags.String("panels", "", "Panels to drive, as for sacn --panels (default all)")
+	flags.Usage = func() {
+		fmt.Println("usage: picoleaf fx meteor [--color <color>] [--tail <n>] [--speed <n>] [--order <order>] [--bounce] [--loop] [--panels <selection>]")
+		exit(1)
+	}
+	flags.Parse(args)
+
+	if flags.NArg() > 0 || *tail < 0 || *speed <= 0 {
+		flags.Usage()
+	}
+
+	color, err := parseColor(*colorArg)
+	if err != nil {
+		fmt.Println("error:", err)
+		exit(1)
+	}
+	if _, err := orderPanels(nil, *order); err != nil {
+		fmt.Println("error:", err)
+		exit(1)
+	}
+
+	m := meteor{Tail: *tail, Speed: *speed, Bounce: *bounce, Loop: *loop}
+	runFx(client, *selection, func(t time.Duration, panels []PanelPosition) []SetPanelColor {
+		path, _ := orderPanels(panels, *order)
+		levels := m.levels(len(path), t)
+		if levels == nil {
+			return nil
+		}
+
+		frames := make([]SetPanelColor, len(path))
+		for i, p := range path {
+			c := scaleColor(color, levels[i])
+			frames[i] = SetPanelColor{PanelID: uint16(p.PanelID), Red: c.Red, Green: c.Green, Blue: c.Blue}
+		}
+		return frames
+	})
+}
+
+// twinkleDuration is how long one twinkle lasts at speed 1.
+const twinkleDuration = 2 * time.Second
+
+// starfield fades random panels in and out over a dark background.
+type starfield struct {
+	rng      *rand.Rand
+	density  float64 // 0-1, the fraction of panels lit on average
+	duration time.Duration
+
+	last   time.Duration
+	starts map[int]time.Duration // when each lit panel started twinkling
+}
+
+func newStarfield(rng *rand.Rand, density, speed float64) *starfield {
+	return &starfield{
+		rng:      rng,
+		density:  density,
+		duration: time.Duration(float64(twinkleDuration) / speed),
+		starts:   make(map[int]time.Duration),
+	}
+}
+
+// level returns a panel's brightness at time t, from 0 to 1, starting a new
+// twinkle on dark panels at random.
+func (s *starfield) level(panelID int, t, dt time.Duration) float64 {
+	start, lit := s.starts[panelID]
+	if lit && t-start >= s.duration {
+		delete(s.starts, panelID)
+		lit = false
+	}
+
+	if !lit {
+		// Panels start twinkling at a rate that keeps, on average, density
+		// of them lit.
+		rate := s.density / ((1 - s.density) * s.duration.Seconds())
+		if s.density < 1 && s.rng.Float64() >= rate*dt.Seconds() {
+			return 0
+		}
+		start = t
+		s.starts[panelID] = t
+	}
+
+	progress := float64(t-start) / float64(s.duration)
+	return math.Sin(math.Pi * progress)
+}
+
+// render is an fxRenderer for the given star color.
+func (s *starfield) render(color RGB) fxRenderer {
+	return func(t time.Duration, panels []PanelPosition) []SetPanelColor {
+		dt := t - s.last
+		s.last = t
+
+		frames := make([]SetPanelColor, len(panels))
+		for i, p := range panels {
+			c := scaleColor(color, s.level(p.PanelID, t, dt))
+			frames[i] = SetPanelColor{PanelID: uint16(p.PanelID), Red: c.Red, Green: c.Green, Blue: c.Blue}
+		}
+		return frames
+	}
+}
+
+// doFxTwinkleCommand twinkles random panels like stars.
+func doFxTwinkleCommand(client Client, args []string) {
+	flags := flag.NewFlagSet("twinkle", flag.ExitOnError)
+	colorArg := flags.String("color", "warmwhite", "Star color, as a name or #rrggbb")
+	density := flags.Float64("density", 0.2, "Fraction of panels lit at once, on average (0-1)")
+	speed := flags.Float64("speed", 1, "Twinkle speed; 1 fades each star in and out over 2s")
+	selection := flags.String("panels", "", "Panels to drive, as for sacn --panels (default all)")
+	flags.Usage = func() {
+		fmt.Println("usage: picoleaf fx twinkle [--color <color>] [--density <n>] [--speed <n>] [--panels <selection>]")
+		exit(1)
+	}
+	flags.Parse(args)
+
+	if flags.NArg() > 0 || *density <= 0 || *density > 1 || *speed <= 0 {
+		flags.Usage()
+	}
+
+	color, err := parseColor(*colorArg)
+	if err != nil {
+		fmt.Println("error:", err)
+		exit(1)
+	}
+
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	runFx(client, *selection, newStarfield(rng, *density, *speed).render(color))
+}
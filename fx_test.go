@@ -0,0 +1,131 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestBreatheLevel(t *testing.T) {
+	period := 6 * time.Second
+	tests := []struct {
+		t    time.Duration
+		want float64
+	}{
+		{0, 0.10},
+		{1500 * time.Millisecond, 0.40},
+		{3 * time.Second, 0.70},
+		{6 * time.Second, 0.10},
+		{7500 * time.Millisecond, 0.40},
+	}
+	for _, tt := range tests {
+		got := breatheLevel(tt.t, period, 10, 70)
+		if math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("breatheLevel(%s) = %.3f, want %.3f", tt.t, got, tt.want)
+		}
+	}
+}
+
+func TestScaleColor(t *testing.T) {
+	got := scaleColor(RGB{0, 128, 255}, 0.5)
+	if want := (RGB{0, 64, 128}); got != want {
+		t.Errorf("scaleColor = %v, want %v", got, want)
+	}
+}
+
+func TestCandleFlame(t *testing.T) {
+	panels := []PanelPosition{{PanelID: 1}, {PanelID: 2}}
+	color := RGB{255, 147, 41}
+
+	still := newCandleFlame(rand.New(rand.NewSource(1)), 0, 0)
+	render := still.render(color)
+	for i := 0; i < 30; i++ {
+		frames := render(time.Duration(i)*33*time.Millisecond, panels)
+		if frames[0].Red != 255 || frames[1].Blue != 41 {
+			t.Fatalf("frame %d = %+v, want a steady flame with no intensity or wind", i, frames)
+		}
+	}
+
+	windy := newCandleFlame(rand.New(rand.NewSource(1)), 1, 1)
+	render = windy.render(color)
+	lowest, flickered := 255, false
+	for i := 0; i < 300; i++ {
+		frames := render(time.Duration(i)*33*time.Millisecond, panels)
+		lowest = min(lowest, int(frames[0].Red))
+		flickered = flickered || frames[0] != frames[1]
+	}
+	if lowest > 200 {
+		t.Errorf("lowest red = %d, want the flame to dip in wind", lowest)
+	}
+	if !flickered {
+		t.Error("panels flickered in unison, want independent flames")
+	}
+}
+
+func TestMeteorLevels(t *testing.T) {
+	m := meteor{Tail: 1, Speed: 1}
+
+	tests := []struct {
+		t    time.Duration
+		want []float64
+	}{
+		{0, []float64{1, 0, 0}},
+		{2 * time.Second, []float64{0, 0.5, 1}},
+		{2500 * time.Millisecond, []float64{0, 0.25, 0.75}},
+		{3500 * time.Millisecond, []float64{0, 0, 0.25}},
+		{4 * time.Second, nil},
+	}
+	for _, tt := range tests {
+		got := m.levels(3, tt.t)
+		if len(got) != len(tt.want) || (got == nil) != (tt.want == nil) {
+			t.Errorf("levels(%s) = %v, want %v", tt.t, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if math.Abs(got[i]-tt.want[i]) > 1e-9 {
+				t.Errorf("levels(%s) = %v, want %v", tt.t, got, tt.want)
+				break
+			}
+		}
+	}
+
+	// Bouncing, the head turns at the end and the tail follows it back.
+	m = meteor{Tail: 1, Speed: 1, Bounce: true, Loop: true}
+	if got := m.levels(3, 3*time.Second); got[1] != 1 || got[2] != 0.5 {
+		t.Errorf("bouncing levels at 3s = %v, want head on 1 and tail on 2", got)
+	}
+	if got := m.levels(3, 5*time.Second); got == nil || got[1] != 1 {
+		t.Errorf("looping levels at 5s = %v, want the second lap", got)
+	}
+}
+
+func TestStarfield(t *testing.T) {
+	var panels []PanelPosition
+	for id := 1; id <= 50; id++ {
+		panels = append(panels, PanelPosition{PanelID: id})
+	}
+
+	field := newStarfield(rand.New(rand.NewSource(1)), 0.2, 1)
+	render := field.render(RGB{255, 255, 255})
+	frame := 33 * time.Millisecond
+
+	// Once the field has settled, about a fifth of the panels should be
+	// twinkling at any time.
+	var lit, samples int
+	for i := 0; i < 900; i++ {
+		frames := render(time.Duration(i)*frame, panels)
+		if i < 300 {
+			continue
+		}
+		for _, f := range frames {
+			if f.Red > 0 {
+				lit++
+			}
+		}
+		samples += len(frames)
+	}
+	if density := float64(lit) / float64(samples); density < 0.1 || density > 0.3 {
+		t.Errorf("average density = %.2f, want about 0.2", density)
+	}
+}
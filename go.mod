@@ -2,6 +2,10 @@ module github.com/paulrosania/picoleaf
 
 go 1.21
 
-require gopkg.in/ini.v1 v1.62.0
+require (
+	go.starlark.net v0.0.0-20240725214946-42030a7cedce
+	golang.org/x/sys v0.6.0
+	gopkg.in/ini.v1 v1.62.0
+)
 
 require github.com/smartystreets/goconvey v1.8.1 // indirect
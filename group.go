@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sync"
+	"text/tabwriter"
+)
+
+// runParallel calls fn for each index in [0, n), running at most limit calls
+// at once, and returns their errors in order.
+func runParallel(n int, limit int, fn func(i int) error) []error {
+	if limit < 1 {
+		limit = 1
+	}
+
+	errs := make([]error, n)
+	sem := make(chan struct{}, limit)
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			errs[i] = fn(i)
+		}(i)
+	}
+	wg.Wait()
+
+	return errs
+}
+
+// runGroupCommand runs a picoleaf command against every device in a group,
+// in parallel. Each device's output is printed once it's done, followed by
+// a summary of which devices succeeded. One failing device doesn't stop the
+// others.
+func runGroupCommand(devices []Device, args []string) {
+	outputs := make([][]byte, len(devices))
+	errs := runParallel(len(devices), *parallelism, func(i int) error {
+		cmd, err := subcommand(devices[i].Name, args)
+		if err != nil {
+			return err
+		}
+
+		var output bytes.Buffer
+		cmd.Stdout = &output
+		cmd.Stderr = &output
+		err = cmd.Run()
+		outputs[i] = output.Bytes()
+		return err
+	})
+
+	for i, device := range devices {
+		if len(outputs[i]) == 0 {
+			continue
+		}
+		fmt.Printf("==> %s <==\n", device.Name)
+		os.Stdout.Write(outputs[i])
+		fmt.Println()
+	}
+
+	failed := 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "DEVICE\tRESULT")
+	for i, device := range devices {
+		result := "ok"
+		if errs[i] != nil {
+			result = "failed: " + errs[i].Error()
+			failed++
+		}
+		fmt.Fprintf(w, "%s\t%s\n", device.Name, result)
+	}
+	w.Flush()
+
+	if failed > 0 {
+		fmt.Printf("error: %d of %d devices failed\n", failed, len(devices))
+		exit(1)
+	}
+}
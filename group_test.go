@@ -0,0 +1,37 @@
+package main
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunParallel(t *testing.T) {
+	var running, peak atomic.Int32
+	errs := runParallel(10, 3, func(i int) error {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+
+		time.Sleep(10 * time.Millisecond)
+		if i%4 == 0 {
+			return errors.New("failed")
+		}
+		return nil
+	})
+
+	if p := peak.Load(); p > 3 {
+		t.Errorf("peak parallelism = %d, want at most 3", p)
+	}
+	for i, err := range errs {
+		if (err != nil) != (i%4 == 0) {
+			t.Errorf("errs[%d] = %v", i, err)
+		}
+	}
+}
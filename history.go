@@ -0,0 +1,235 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// historyFile is the name of the undo history file in the state directory.
+const historyFile = "history.json"
+
+// maxHistoryEntries bounds the size of the undo history.
+const maxHistoryEntries = 50
+
+// HistoryEntry records a device's state before a mutating command ran.
+type HistoryEntry struct {
+	Device   string    `json:"device"`
+	Time     time.Time `json:"time"`
+	Command  string    `json:"command"`
+	Snapshot Snapshot  `json:"snapshot"`
+}
+
+// stateDir returns the directory picoleaf keeps local state in, creating it
+// if necessary.
+func stateDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	dir := filepath.Join(home, ".picoleaf")
+	err = os.MkdirAll(dir, 0700)
+	return dir, err
+}
+
+// writeFileAtomic replaces the named file without leaving it half-written if
+// picoleaf is interrupted. Each write uses its own temporary file, so
+// concurrent writers can't rename each other's half-written data into place.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// lockStateFile locks the named file in the state directory against other
+// picoleaf processes, e.g. group members running in parallel, until the
+// returned function is called. Hold it across loading, changing, and saving
+// the file, so no process's changes are lost.
+func lockStateFile(name string) (func(), error) {
+	dir, err := stateDir()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, name+".lock"), os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	err = lockFile(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return func() {
+		unlockFile(f)
+		f.Close()
+	}, nil
+}
+
+func historyPath() (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, historyFile), nil
+}
+
+func loadHistory() ([]HistoryEntry, error) {
+	path, err := historyPath()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var entries []HistoryEntry
+	err = json.Unmarshal(data, &entries)
+	return entries, err
+}
+
+func saveHistory(entries []HistoryEntry) error {
+	path, err := historyPath()
+	if err != nil {
+		return err
+	}
+
+	if len(entries) > maxHistoryEntries {
+		entries = entries[len(entries)-maxHistoryEntries:]
+	}
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+// isMutatingCommand reports whether the command changes the Nanoleaf's state,
+// and so should be recorded for undo.
+func isMutatingCommand(args []string) bool {
+	switch args[0] {
+	case "brightness", "fade", "fx", "hsl", "off", "on", "paint", "pick", "rgb", "sequence", "sleep", "temp":
+		return true
+	case "api":
+		return len(args) > 1 && !strings.EqualFold(args[1], "GET")
+	case "effect":
+		return len(args) > 1 && (args[1] == "select" || args[1] == "custom" || args[1] == "stream")
+	case "mode":
+		return len(args) > 1 && args[1] == "set"
+	case "nightlight":
+		return len(args) > 1 && (args[1] == "on" || args[1] == "schedule")
+	case "preset":
+		return len(args) > 1 && args[1] != "list"
+	case "scene":
+		return len(args) > 1 && args[1] != "list" && args[1] != "save" && args[1] != "apply"
+	case "script":
+		return len(args) > 1 && args[1] == "run"
+	case "palette":
+		for _, arg := range args {
+			if strings.HasPrefix(arg, "--apply") || strings.HasPrefix(arg, "-apply") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// appendHistory adds an entry to the undo history.
+func appendHistory(entry HistoryEntry) error {
+	unlock, err := lockStateFile(historyFile)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	entries, err := loadHistory()
+	if err != nil {
+		return err
+	}
+	return saveHistory(append(entries, entry))
+}
+
+// recordHistory saves the device's current state before a mutating command.
+// Failures are logged but otherwise ignored, since undo is a convenience and
+// shouldn't block the command itself.
+func recordHistory(client Client, args []string) {
+	snapshot, err := client.Snapshot()
+	if err == nil {
+		err = appendHistory(HistoryEntry{
+			Device:   currentDeviceName(),
+			Time:     time.Now(),
+			Command:  strings.Join(args, " "),
+			Snapshot: *snapshot,
+		})
+	}
+
+	if err != nil {
+		slog.Debug("failed to record undo history", "err", err)
+	}
+}
+
+func doUndoCommand(client Client, args []string) {
+	if len(args) > 0 {
+		fmt.Println("usage: picoleaf undo")
+		exit(1)
+	}
+
+	unlock, err := lockStateFile(historyFile)
+	if err != nil {
+		fmt.Println("error: failed to lock undo history:", err)
+		exit(1)
+	}
+	defer unlock()
+
+	entries, err := loadHistory()
+	if err != nil {
+		fmt.Println("error: failed to read undo history:", err)
+		exit(1)
+	}
+
+	device := currentDeviceName()
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		if entry.Device != device {
+			continue
+		}
+
+		err = client.Restore(entry.Snapshot)
+		if err != nil {
+			fmt.Println("error: failed to restore Nanoleaf state:", err)
+			exit(1)
+		}
+
+		err = saveHistory(append(entries[:i], entries[i+1:]...))
+		if err != nil {
+			fmt.Println("error: failed to update undo history:", err)
+			exit(1)
+		}
+
+		fmt.Printf("Undid `%s` from %s\n", entry.Command, entry.Time.Format(time.Stamp))
+		return
+	}
+
+	fmt.Println("Nothing to undo")
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/paulrosania/picoleaf/internal/nltest"
+)
+
+func TestUndoRestoresRecordedState(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	client, server := newTestClient(t)
+	server.Update(func(d *nltest.Device) { d.State.Brightness = 10 })
+
+	if code := runCommandInProcess(client, []string{"brightness", "80"}); code != 0 {
+		t.Fatalf("brightness exited %d", code)
+	}
+	entries, err := loadHistory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Command != "brightness 80" || entries[0].Snapshot.Brightness != 10 {
+		t.Fatalf("history = %+v, want brightness 80 recorded over brightness 10", entries)
+	}
+
+	if code := runCommandInProcess(client, []string{"undo"}); code != 0 {
+		t.Fatalf("undo exited %d", code)
+	}
+	if got := server.Device().State.Brightness; got != 10 {
+		t.Errorf("brightness after undo = %d, want 10", got)
+	}
+	entries, err = loadHistory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("history after undo = %+v, want it empty", entries)
+	}
+}
+
+func TestHistoryLimit(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	client, _ := newTestClient(t)
+
+	for i := 0; i < maxHistoryEntries+5; i++ {
+		recordHistory(client, []string{"brightness", fmt.Sprint(i)})
+	}
+	entries, err := loadHistory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != maxHistoryEntries {
+		t.Fatalf("history has %d entries, want %d", len(entries), maxHistoryEntries)
+	}
+	if first, last := entries[0].Command, entries[len(entries)-1].Command; first != "brightness 5" || last != fmt.Sprintf("brightness %d", maxHistoryEntries+4) {
+		t.Errorf("history runs from %q to %q, want the latest %d", first, last, maxHistoryEntries)
+	}
+}
+
+func TestAppendHistoryConcurrently(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	// As group members do, from separate processes.
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := appendHistory(HistoryEntry{Device: defaultDeviceName, Command: fmt.Sprint("brightness ", i)})
+			if err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	entries, err := loadHistory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != maxHistoryEntries {
+		t.Errorf("history has %d entries, want all %d", len(entries), maxHistoryEntries)
+	}
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HyperionPort is the default Hyperion JSON API port.
+const HyperionPort = 19444
+
+// hyperionRetryInterval is how long to wait before reconnecting to Hyperion
+// after the connection fails.
+const hyperionRetryInterval = 5 * time.Second
+
+// hyperionMessage is a message from the Hyperion JSON API.
+type hyperionMessage struct {
+	Command string `json:"command"`
+	Success *bool  `json:"success"`
+	Error   string `json:"error"`
+	Result  struct {
+		LEDs []int `json:"leds"`
+	} `json:"result"`
+}
+
+// spreadColors maps LED colors onto n panels, averaging the run of LEDs
+// that falls on each panel. leds is a flat list of red, green, and blue
+// values.
+func spreadColors(leds []int, n int) []RGB {
+	numLEDs := len(leds) / 3
+	if numLEDs == 0 || n == 0 {
+		return nil
+	}
+
+	colors := make([]RGB, n)
+	for i := range colors {
+		first := i * numLEDs / n
+		last := (i + 1) * numLEDs / n
+		if last <= first {
+			last = first + 1
+		}
+
+		var r, g, b int
+		for j := first; j < last; j++ {
+			r += leds[3*j]
+			g += leds[3*j+1]
+			b += leds[3*j+2]
+		}
+		count := last - first
+		colors[i] = RGB{Red: uint8(r / count), Green: uint8(g / count), Blue: uint8(b / count)}
+	}
+	return colors
+}
+
+// hyperionRegion drives some panels from a range of Hyperion's LEDs, e.g. so
+// that only the LEDs along one edge of a TV light the panels beside it.
+type hyperionRegion struct {
+	First, Last int // LED indices, inclusive
+	Panels      []PanelPosition
+}
+
+// parseLEDRange parses an inclusive range of LED indices, e.g. `0-29`.
+func parseLEDRange(s string) (int, int, error) {
+	first, last, ok := strings.Cut(strings.TrimSpace(s), "-")
+	if ok {
+		a, errA := strconv.Atoi(first)
+		b, errB := strconv.Atoi(last)
+		if errA == nil && errB == nil && a >= 0 && b >= a {
+			return a, b, nil
+		}
+	}
+	return 0, 0, fmt.Errorf("invalid LED range %q, expected e.g. 0-29", s)
+}
+
+// loadHyperionRegions reads the regions configured in the [hyperion]
+// section, as `zone.<name> = <first LED>-<last LED>`, ordering each zone's
+// panels. It returns nil if none are configured.
+func loadHyperionRegions(panels []PanelPosition, order string) ([]hyperionRegion, error) {
+	var regions []hyperionRegion
+	for _, key := range cfg.Section("hyperion").Keys() {
+		if !strings.HasPrefix(key.Name(), zoneKeyPrefix) {
+			continue
+		}
+		first, last, err := parseLEDRange(key.String())
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", key.Name(), err)
+		}
+		zone, err := selectPanels(panels, key.Name())
+		if err != nil {
+			return nil, err
+		}
+		zone, err = orderPanels(zone, order)
+		if err != nil {
+			return nil, err
+		}
+		regions = append(regions, hyperionRegion{First: first, Last: last, Panels: zone})
+	}
+	return regions, nil
+}
+
+// regionFrames maps each region's run of LEDs onto its panels. LEDs past the
+// end of the update are ignored.
+func regionFrames(leds []int, regions []hyperionRegion) []SetPanelColor {
+	numLEDs := len(leds) / 3
+	var frames []SetPanelColor
+	for _, r := range regions {
+		// Clamp before scaling to channels, which could overflow a 32-bit
+		// int for the default region's Last.
+		first, last := 3*min(r.First, numLEDs), 3*min(r.Last, numLEDs-1)+3
+		if first >= last {
+			continue
+		}
+		colors := spreadColors(leds[first:last], len(r.Panels))
+		for i, c := range colors {
+			frames = append(frames, SetPanelColor{PanelID: uint16(r.Panels[i].PanelID), Red: c.Red, Green: c.Green, Blue: c.Blue})
+		}
+	}
+	return frames
+}
+
+// streamHyperion subscribes to Hyperion's LED colors and calls handle with
+// each update, until the connection fails. A non-zero instance selects
+// another Hyperion instance, e.g. one capturing a different monitor.
+func streamHyperion(conn io.ReadWriter, token string, instance int, handle func(leds []int)) error {
+	enc := json.NewEncoder(conn)
+	if token != "" {
+		err := enc.Encode(map[string]string{"command": "authorize", "subcommand": "login", "token": token})
+		if err != nil {
+			return err
+		}
+	}
+	if instance != 0 {
+		err := enc.Encode(map[string]interface{}{"command": "instance", "subcommand": "switchTo", "instance": instance})
+		if err != nil {
+			return err
+		}
+	}
+	err := enc.Encode(map[string]string{"command": "ledcolors", "subcommand": "ledstream-start"})
+	if err != nil {
+		return err
+	}
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var msg hyperionMessage
+		err := json.Unmarshal(scanner.Bytes(), &msg)
+		if err != nil {
+			return fmt.Errorf("invalid message: %v", err)
+		}
+		if msg.Success != nil && !*msg.Success {
+			return fmt.Errorf("%s failed: %s", msg.Command, msg.Error)
+		}
+		if msg.Command == "ledcolors-ledstream-update" {
+			handle(msg.Result.LEDs)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return io.EOF
+}
+
+// doHyperionCommand forwards the LED colors computed by a Hyperion instance
+// to the Nanoleaf, until interrupted.
+func doHyperionCommand(client Client, args []string) {
+	flags := flag.NewFlagSet("hyperion", flag.ExitOnError)
+	addr := flags.String("addr", fmt.Sprintf("localhost:%d", HyperionPort), "Hyperion JSON API address")
+	token := flags.String("token", "", "Hyperion API token, if authorization is required")
+	instance := flags.Int("instance", cfg.Section("hyperion").Key("instance").MustInt(0), "Hyperion instance, e.g. the one capturing the TV's monitor")
+	order := flags.String("order", "x", panelOrderUsage)
+	selection := flags.String("panels", "", "Panels to drive, e.g. x<300, row:2, nearest:250,400, or a zone (default all, or the configured regions)")
+	flags.Usage = func() {
+		fmt.Println("usage: picoleaf hyperion [--addr <host:port>] [--token <token>] [--instance <n>] [--order <order>] [--panels <selection>]")
+		exit(1)
+	}
+	flags.Parse(args)
+	addLogSecret(*token)
+
+	if flags.NArg() > 0 || *instance < 0 {
+		flags.Usage()
+	}
+
+	panelInfo, err := client.CachedPanelInfo()
+	if err != nil {
+		fmt.Println("error: failed to get Nanoleaf info:", err)
+		exit(1)
+	}
+	panels, err := selectPanels(panelInfo.PanelLayout.Layout.PositionData, *selection)
+	if err != nil {
+		fmt.Println("error:", err)
+		exit(1)
+	}
+	var regions []hyperionRegion
+	if *selection == "" {
+		regions, err = loadHyperionRegions(panels, *order)
+		if err != nil {
+			fmt.Println("error:", err)
+			exit(1)
+		}
+	}
+	if regions == nil {
+		panels, err = orderPanels(panels, *order)
+		if err != nil {
+			fmt.Println("error:", err)
+			exit(1)
+		}
+		regions = []hyperionRegion{{First: 0, Last: math.MaxInt32, Panels: panels}}
+	}
+
+	ctx, restore := restoreOnInterrupt(client)
+	defer restore()
+
+	sink, err := openFrameSink(client, panelInfo)
+	if err != nil {
+		fmt.Println("error: failed to start external control:", err)
+		exit(1)
+	}
+	defer sink.Close()
+
+	handle := func(leds []int) {
+		err := sink.Send(regionFrames(leds, regions))
+		if err != nil {
+			slog.Error("failed to send frame", "err", err)
+		}
+	}
+
+	for {
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "tcp", *addr)
+		if err == nil {
+			slog.Info("connected to Hyperion", "addr", *addr, "instance", *instance, "regions", len(regions))
+
+			// Close the connection on Ctrl-C, so the read loop exits.
+			stopClose := context.AfterFunc(ctx, func() { conn.Close() })
+			err = streamHyperion(conn, *token, *instance, handle)
+			stopClose()
+			conn.Close()
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		slog.Error("lost connection to Hyperion", "addr", *addr, "err", err)
+
+		if !sleepOrCancel(hyperionRetryInterval) {
+			return
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bufio"
+	"math"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSpreadColors(t *testing.T) {
+	leds := []int{
+		100, 0, 0,
+		200, 0, 0,
+		0, 50, 0,
+		0, 150, 0,
+	}
+
+	got := spreadColors(leds, 2)
+	want := []RGB{{Red: 150}, {Green: 100}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("spreadColors() = %v, want %v", got, want)
+	}
+
+	// More panels than LEDs: LEDs are repeated.
+	got = spreadColors(leds[:6], 4)
+	want = []RGB{{Red: 100}, {Red: 100}, {Red: 200}, {Red: 200}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("spreadColors() = %v, want %v", got, want)
+	}
+}
+
+func TestStreamHyperion(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+
+	go func() {
+		defer server.Close()
+		r := bufio.NewReader(server)
+		line, _ := r.ReadString('\n')
+		if !strings.Contains(line, "ledstream-start") {
+			return
+		}
+		server.Write([]byte(`{"command":"ledcolors","success":true}` + "\n"))
+		server.Write([]byte(`{"command":"ledcolors-ledstream-update","result":{"leds":[1,2,3,4,5,6]}}` + "\n"))
+	}()
+
+	var updates [][]int
+	streamHyperion(client, "", 0, func(leds []int) {
+		updates = append(updates, leds)
+	})
+
+	want := [][]int{{1, 2, 3, 4, 5, 6}}
+	if !reflect.DeepEqual(updates, want) {
+		t.Errorf("updates = %v, want %v", updates, want)
+	}
+}
+
+func TestParseLEDRange(t *testing.T) {
+	first, last, err := parseLEDRange("10-29")
+	if err != nil || first != 10 || last != 29 {
+		t.Errorf("parseLEDRange(10-29) = %d, %d, %v, want 10, 29", first, last, err)
+	}
+	for _, s := range []string{"", "10", "29-10", "-1-5", "a-b"} {
+		if _, _, err := parseLEDRange(s); err == nil {
+			t.Errorf("parseLEDRange(%q) succeeded, want error", s)
+		}
+	}
+}
+
+func TestRegionFrames(t *testing.T) {
+	leds := []int{
+		100, 0, 0,
+		200, 0, 0,
+		0, 50, 0,
+		0, 150, 0,
+	}
+	regions := []hyperionRegion{
+		{First: 0, Last: 1, Panels: []PanelPosition{{PanelID: 101}}},
+		{First: 2, Last: 9, Panels: []PanelPosition{{PanelID: 102}, {PanelID: 103}}},
+		{First: 10, Last: 12, Panels: []PanelPosition{{PanelID: 104}}},
+	}
+
+	got := regionFrames(leds, regions)
+	want := []SetPanelColor{
+		{PanelID: 101, Red: 150},
+		{PanelID: 102, Green: 50},
+		{PanelID: 103, Green: 150},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("regionFrames() = %+v, want %+v", got, want)
+	}
+}
+
+func TestRegionFramesDefaultRegion(t *testing.T) {
+	leds := []int{
+		255, 0, 0,
+		0, 255, 0,
+		0, 0, 255,
+		0, 0, 255,
+	}
+	// As doHyperionCommand sets up when no regions are configured.
+	regions := []hyperionRegion{{First: 0, Last: math.MaxInt32, Panels: []PanelPosition{{PanelID: 101}, {PanelID: 102}}}}
+
+	got := regionFrames(leds, regions)
+	want := []SetPanelColor{
+		{PanelID: 101, Red: 127, Green: 127},
+		{PanelID: 102, Blue: 255},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("regionFrames() = %+v, want %+v", got, want)
+	}
+
+	if got := regionFrames(nil, regions); got != nil {
+		t.Errorf("regionFrames() with no LEDs = %+v, want nil", got)
+	}
+}
+
+func TestLoadHyperionRegions(t *testing.T) {
+	setTestConfig(t, "zone.left = 101,102\n\n[hyperion]\ninstance = 1\nzone.left = 0-9\n")
+
+	panels := []PanelPosition{{PanelID: 101, X: 100}, {PanelID: 102, X: 0}, {PanelID: 103, X: 200}}
+	regions, err := loadHyperionRegions(panels, "x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []hyperionRegion{{First: 0, Last: 9, Panels: []PanelPosition{{PanelID: 102, X: 0}, {PanelID: 101, X: 100}}}}
+	if !reflect.DeepEqual(regions, want) {
+		t.Errorf("loadHyperionRegions() = %+v, want %+v", regions, want)
+	}
+}
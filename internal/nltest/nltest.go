@@ -0,0 +1,539 @@
+// Package nltest provides a fake Nanoleaf for testing. It serves the REST API
+// over httptest, and listens for external control frames over UDP.
+package nltest
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultToken is the access token accepted by a new Server.
+const DefaultToken = "nltest-token"
+
+// Panel is a panel in the fake Nanoleaf's layout.
+type Panel struct {
+	ID        int
+	X         int
+	Y         int
+	O         int
+	ShapeType int
+}
+
+// State is the fake Nanoleaf's light state.
+type State struct {
+	On               bool
+	Brightness       int
+	Hue              int
+	Saturation       int
+	ColorTemperature int
+	ColorMode        string
+}
+
+// Device is the fake Nanoleaf's complete state.
+type Device struct {
+	Name            string
+	SerialNo        string
+	Model           string
+	FirmwareVersion string
+
+	State      State
+	Effect     string
+	Effects    []string
+	Panels     []Panel
+	SideLength int
+
+	// ExtControl is set once a client enables external control mode.
+	ExtControl bool
+}
+
+// Request records an API request received by the Server.
+type Request struct {
+	Method string
+	Path   string // relative to /api/v1/<token>/
+	Body   string
+}
+
+// Server is a fake Nanoleaf.
+type Server struct {
+	Token string
+
+	HTTP *httptest.Server
+	UDP  *net.UDPConn
+
+	mu          sync.Mutex
+	device      Device
+	requests    []Request
+	frames      [][]byte
+	frameCh     chan struct{}
+	subscribers map[chan string]bool
+	closed      chan struct{}
+	closeOnce   sync.Once
+}
+
+// NewServer starts a fake Nanoleaf with three panels and a couple of effects.
+// Callers should Close it when done.
+func NewServer() *Server {
+	s := newServer()
+	s.HTTP = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// NewTLSServer is like NewServer, but serves the API over HTTPS with a
+// self-signed certificate, as a reverse proxy might. HTTP.Certificate returns
+// the certificate.
+func NewTLSServer() *Server {
+	s := newServer()
+	s.HTTP = httptest.NewUnstartedServer(http.HandlerFunc(s.serveHTTP))
+	// Clients that reject the certificate are expected, not worth logging.
+	s.HTTP.Config.ErrorLog = log.New(io.Discard, "", 0)
+	s.HTTP.StartTLS()
+	return s
+}
+
+func newServer() *Server {
+	s := &Server{
+		Token: DefaultToken,
+		device: Device{
+			Name:            "Fake Nanoleaf",
+			SerialNo:        "S00000000",
+			Model:           "NL22",
+			FirmwareVersion: "3.3.4",
+			State: State{
+				On:               true,
+				Brightness:       50,
+				Hue:              30,
+				Saturation:       90,
+				ColorTemperature: 4000,
+				ColorMode:        "hs",
+			},
+			Effect:  "*Solid*",
+			Effects: []string{"Flames", "Northern Lights"},
+			Panels: []Panel{
+				{ID: 101, X: 0, Y: 0, O: 0},
+				{ID: 102, X: 150, Y: 0, O: 60},
+				{ID: 103, X: 75, Y: 130, O: 0},
+			},
+			SideLength: 150,
+		},
+		frameCh:     make(chan struct{}, 1),
+		subscribers: make(map[chan string]bool),
+		closed:      make(chan struct{}),
+	}
+
+	udp, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		panic("nltest: failed to listen on UDP: " + err.Error())
+	}
+	s.UDP = udp
+	go s.serveUDP()
+
+	return s
+}
+
+// Close shuts down the server.
+func (s *Server) Close() {
+	s.closeOnce.Do(func() { close(s.closed) })
+	s.HTTP.Close()
+	s.UDP.Close()
+}
+
+// Host returns the server's host:port, suitable for a client's Host.
+func (s *Server) Host() string {
+	return s.HTTP.Listener.Addr().String()
+}
+
+// UDPPort returns the port the server receives external control frames on.
+func (s *Server) UDPPort() int {
+	return s.UDP.LocalAddr().(*net.UDPAddr).Port
+}
+
+// Device returns a copy of the fake Nanoleaf's current state.
+func (s *Server) Device() Device {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d := s.device
+	d.Effects = append([]string(nil), s.device.Effects...)
+	d.Panels = append([]Panel(nil), s.device.Panels...)
+	return d
+}
+
+// Update modifies the fake Nanoleaf's state.
+func (s *Server) Update(fn func(*Device)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(&s.device)
+}
+
+// Requests returns the API requests received so far.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// Frames returns the external control frames received so far.
+func (s *Server) Frames() [][]byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([][]byte(nil), s.frames...)
+}
+
+// WaitForFrames waits until at least n external control frames have arrived.
+func (s *Server) WaitForFrames(n int, timeout time.Duration) ([][]byte, error) {
+	deadline := time.After(timeout)
+	for {
+		frames := s.Frames()
+		if len(frames) >= n {
+			return frames, nil
+		}
+
+		select {
+		case <-s.frameCh:
+		case <-deadline:
+			return frames, errors.New("nltest: timed out waiting for frames")
+		}
+	}
+}
+
+// SendEvent sends events of the given type to every client subscribed to
+// it. Each event is a JSON object, e.g. `{"attr":2,"value":40}`. State
+// changes made through the API send state events automatically.
+func (s *Server) SendEvent(id int, events ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sendEventLocked(id, events...)
+}
+
+func (s *Server) sendEventLocked(id int, events ...string) {
+	msg := "id: " + strconv.Itoa(id) + "\ndata: {\"events\":[" + strings.Join(events, ",") + "]}\n\n"
+	for ch := range s.subscribers {
+		select {
+		case ch <- msg:
+		default:
+		}
+	}
+}
+
+// WaitForSubscribers waits until at least n clients are subscribed to the
+// event stream.
+func (s *Server) WaitForSubscribers(n int, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		s.mu.Lock()
+		count := len(s.subscribers)
+		s.mu.Unlock()
+		if count >= n {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return errors.New("nltest: timed out waiting for subscribers")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// serveEvents streams server-sent events until the client or server goes
+// away. Events are sent to all subscribers regardless of the requested ids.
+func (s *Server) serveEvents(w http.ResponseWriter, r *http.Request) {
+	ch := make(chan string, 16)
+	s.mu.Lock()
+	s.requests = append(s.requests, Request{Method: r.Method, Path: "events"})
+	s.subscribers[ch] = true
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.subscribers, ch)
+		s.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	if flusher != nil {
+		flusher.Flush()
+	}
+
+	for {
+		select {
+		case msg := <-ch:
+			io.WriteString(w, msg)
+			if flusher != nil {
+				flusher.Flush()
+			}
+		case <-r.Context().Done():
+			return
+		case <-s.closed:
+			return
+		}
+	}
+}
+
+func (s *Server) serveUDP() {
+	buf := make([]byte, 65536)
+	for {
+		n, _, err := s.UDP.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		s.frames = append(s.frames, append([]byte(nil), buf[:n]...))
+		s.mu.Unlock()
+
+		select {
+		case s.frameCh <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	prefix := "/api/v1/" + s.Token + "/"
+	if !strings.HasPrefix(r.URL.Path+"/", prefix) {
+		http.Error(w, "", http.StatusUnauthorized)
+		return
+	}
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path+"/", prefix), "/")
+
+	if r.Method == http.MethodGet && path == "events" {
+		s.serveEvents(w, r)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, Request{Method: r.Method, Path: path, Body: string(body)})
+
+	switch r.Method {
+	case http.MethodGet:
+		s.handleGet(w, path)
+	case http.MethodPut:
+		s.handlePut(w, path, body)
+	default:
+		http.Error(w, "", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleGet serves any path into the panel info document, e.g. `state/on`.
+func (s *Server) handleGet(w http.ResponseWriter, path string) {
+	var v interface{} = s.panelInfo()
+	if path != "" {
+		for _, segment := range strings.Split(path, "/") {
+			m, ok := v.(map[string]interface{})
+			if !ok {
+				http.NotFound(w, nil)
+				return
+			}
+			v, ok = m[segment]
+			if !ok {
+				http.NotFound(w, nil)
+				return
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func (s *Server) handlePut(w http.ResponseWriter, path string, body []byte) {
+	var req map[string]json.RawMessage
+	err := json.Unmarshal(body, &req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch path {
+	case "state":
+		err = s.putState(req)
+	case "effects", "effects/select":
+		err = s.putEffects(req)
+	default:
+		http.NotFound(w, nil)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) putState(req map[string]json.RawMessage) error {
+	state := &s.device.State
+	for key, raw := range req {
+		var prop struct {
+			Value     json.RawMessage `json:"value"`
+			Increment *int            `json:"increment"`
+		}
+		err := json.Unmarshal(raw, &prop)
+		if err != nil {
+			return err
+		}
+
+		if key == "on" {
+			err = json.Unmarshal(prop.Value, &state.On)
+			if err != nil {
+				return err
+			}
+			s.sendEventLocked(1, `{"attr":1,"value":`+strconv.FormatBool(state.On)+`}`)
+			continue
+		}
+
+		var target *int
+		var min, max, attr int
+		switch key {
+		case "brightness":
+			target, min, max, attr = &state.Brightness, 0, 100, 2
+		case "hue":
+			target, min, max, attr = &state.Hue, 0, 360, 3
+			state.ColorMode = "hs"
+		case "sat":
+			target, min, max, attr = &state.Saturation, 0, 100, 4
+			state.ColorMode = "hs"
+		case "ct":
+			target, min, max, attr = &state.ColorTemperature, 1200, 6500, 5
+			state.ColorMode = "ct"
+		default:
+			return errors.New("unknown state property " + key)
+		}
+
+		v := *target
+		if prop.Increment != nil {
+			v += *prop.Increment
+		} else {
+			err = json.Unmarshal(prop.Value, &v)
+			if err != nil {
+				return err
+			}
+		}
+		if v < min || v > max {
+			return errors.New(key + " out of range " + strconv.Itoa(min) + "-" + strconv.Itoa(max))
+		}
+		*target = v
+		s.sendEventLocked(1, `{"attr":`+strconv.Itoa(attr)+`,"value":`+strconv.Itoa(v)+`}`)
+
+		if key == "hue" || key == "sat" || key == "ct" {
+			s.device.Effect = "*Solid*"
+			s.device.ExtControl = false
+		}
+	}
+	return nil
+}
+
+func (s *Server) putEffects(req map[string]json.RawMessage) error {
+	if raw, ok := req["select"]; ok {
+		var name string
+		err := json.Unmarshal(raw, &name)
+		if err != nil {
+			return err
+		}
+		for _, effect := range s.device.Effects {
+			if effect == name {
+				s.device.Effect = name
+				s.device.State.ColorMode = "effect"
+				s.device.ExtControl = false
+				return nil
+			}
+		}
+		return errors.New("unknown effect " + name)
+	}
+
+	if raw, ok := req["write"]; ok {
+		var write struct {
+			Command  string `json:"command"`
+			AnimName string `json:"animName"`
+			AnimType string `json:"animType"`
+		}
+		err := json.Unmarshal(raw, &write)
+		if err != nil {
+			return err
+		}
+		switch {
+		case write.Command == "display" && write.AnimType == "extControl":
+			s.device.Effect = "*ExtControl*"
+			s.device.State.ColorMode = "effect"
+			s.device.ExtControl = true
+		case write.Command == "add" && write.AnimName != "":
+			for _, effect := range s.device.Effects {
+				if effect == write.AnimName {
+					return nil
+				}
+			}
+			s.device.Effects = append(s.device.Effects, write.AnimName)
+		}
+		return nil
+	}
+
+	return errors.New("unsupported effects request")
+}
+
+// panelInfo renders the device as the `GET /` panel info document.
+func (s *Server) panelInfo() map[string]interface{} {
+	d := s.device
+
+	rangeProp := func(v, min, max int) map[string]interface{} {
+		return map[string]interface{}{"value": v, "min": min, "max": max}
+	}
+
+	positions := []interface{}{}
+	for _, p := range d.Panels {
+		positions = append(positions, map[string]interface{}{
+			"panelId": p.ID, "x": p.X, "y": p.Y, "o": p.O, "shapeType": p.ShapeType,
+		})
+	}
+
+	effects := []interface{}{}
+	for _, e := range d.Effects {
+		effects = append(effects, e)
+	}
+
+	return map[string]interface{}{
+		"name":            d.Name,
+		"serialNo":        d.SerialNo,
+		"manufacturer":    "Nanoleaf",
+		"firmwareVersion": d.FirmwareVersion,
+		"model":           d.Model,
+		"state": map[string]interface{}{
+			"on":         map[string]interface{}{"value": d.State.On},
+			"brightness": rangeProp(d.State.Brightness, 0, 100),
+			"hue":        rangeProp(d.State.Hue, 0, 360),
+			"sat":        rangeProp(d.State.Saturation, 0, 100),
+			"ct":         rangeProp(d.State.ColorTemperature, 1200, 6500),
+			"colorMode":  d.State.ColorMode,
+		},
+		"effects": map[string]interface{}{
+			"select":      d.Effect,
+			"effectsList": effects,
+		},
+		"panelLayout": map[string]interface{}{
+			"layout": map[string]interface{}{
+				"numPanels":    len(d.Panels),
+				"sideLength":   d.SideLength,
+				"positionData": positions,
+			},
+			"globalOrientation": rangeProp(0, 0, 360),
+		},
+		"rhythm": map[string]interface{}{
+			"rhythmConnected": false,
+		},
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// linkRetryInterval is how long to wait before reconnecting to a source
+// device's event stream after it fails.
+const linkRetryInterval = 5 * time.Second
+
+// linkBrightness copies the source's brightness to the targets, then keeps
+// copying it as it changes, until ctx is done or the event stream fails.
+func linkBrightness(ctx context.Context, source Client, targets []Client) error {
+	mirror := func(brightness int) {
+		errs := runParallel(len(targets), *parallelism, func(i int) error {
+			return targets[i].SetBrightness(brightness)
+		})
+		for i, err := range errs {
+			if err != nil {
+				slog.Error("failed to set brightness", "host", targets[i].Host, "err", err)
+			}
+		}
+	}
+
+	snapshot, err := source.Snapshot()
+	if err != nil {
+		return err
+	}
+	mirror(snapshot.Brightness)
+
+	return source.Subscribe(ctx, []int{StateEvent}, func(event Event) {
+		if event.Type != StateEvent || event.Attr != AttrBrightness {
+			return
+		}
+
+		brightness, err := event.IntValue()
+		if err != nil {
+			slog.Error("invalid brightness event", "err", err)
+			return
+		}
+		slog.Info("brightness changed", "brightness", brightness)
+		mirror(brightness)
+	})
+}
+
+// doLinkCommand keeps a property of several devices in sync with a source
+// device, until interrupted.
+func doLinkCommand(client Client, args []string) {
+	usage := func() {
+		fmt.Println("usage: picoleaf link brightness <from> <to>...")
+		exit(1)
+	}
+
+	if len(args) < 3 || args[0] != "brightness" {
+		usage()
+	}
+
+	device, err := findDevice(args[1])
+	if err != nil {
+		fmt.Println("error:", err)
+		exit(1)
+	}
+	source := device.Client()
+
+	var targets []Client
+	for _, name := range args[2:] {
+		devices, err := resolveDevices(name)
+		if err != nil {
+			fmt.Println("error:", err)
+			exit(1)
+		}
+		for _, d := range devices {
+			if d.Name != device.Name {
+				targets = append(targets, d.Client())
+			}
+		}
+	}
+	if len(targets) == 0 {
+		fmt.Println("error: no devices to link to")
+		exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	slog.Info("linking brightness", "from", device.Name, "to", len(targets))
+	for {
+		err := linkBrightness(ctx, source, targets)
+		if ctx.Err() != nil {
+			return
+		}
+		slog.Error("lost connection to source device", "device", device.Name, "err", err)
+		if !sleepOrCancel(linkRetryInterval) {
+			return
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/paulrosania/picoleaf/internal/nltest"
+)
+
+func TestLinkBrightness(t *testing.T) {
+	source, sourceServer := newTestClient(t)
+	target, targetServer := newTestClient(t)
+
+	sourceServer.Update(func(d *nltest.Device) {
+		d.State.Brightness = 20
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- linkBrightness(ctx, source, []Client{target})
+	}()
+
+	err := sourceServer.WaitForSubscribers(1, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := targetServer.Device().State.Brightness; got != 20 {
+		t.Errorf("initial brightness = %d, want 20", got)
+	}
+
+	err = source.SetBrightness(65)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for targetServer.Device().State.Brightness != 65 {
+		if time.Now().After(deadline) {
+			t.Fatalf("brightness = %d, want 65", targetServer.Device().State.Brightness)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("linkBrightness() didn't stop when cancelled")
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// setupLogging configures the default slog logger from the command line
+// flags. Secrets from the config file and environment are redacted from all
+// output. The returned closer closes the log file, if any.
+func setupLogging() (io.Closer, error) {
+	level := slog.LevelInfo
+	if *verbose || *logFilePath != "" {
+		level = slog.LevelDebug
+	}
+	if *logLevel != "" {
+		err := level.UnmarshalText([]byte(*logLevel))
+		if err != nil {
+			return nil, fmt.Errorf("invalid log level %q", *logLevel)
+		}
+	}
+
+	var w io.Writer = os.Stderr
+	var closer io.Closer = io.NopCloser(nil)
+	if *logFilePath != "" {
+		f, err := os.OpenFile(*logFilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			return nil, err
+		}
+		w = f
+		closer = f
+	}
+
+	logRedactor.Add(configSecrets()...)
+	opts := &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: logRedactor.ReplaceAttr,
+	}
+
+	var handler slog.Handler
+	switch *logFormat {
+	case "text":
+		handler = slog.NewTextHandler(w, opts)
+	case "json":
+		handler = slog.NewJSONHandler(w, opts)
+	default:
+		closer.Close()
+		return nil, fmt.Errorf("invalid log format %q, expected text or json", *logFormat)
+	}
+
+	slog.SetDefault(slog.New(handler))
+	return closer, nil
+}
+
+// secretKeys are the config keys whose values are secrets, in any section.
+// Encrypted values are treated as secrets too, whatever their key.
+var secretKeys = []string{"access_token", "api_key", "bot_token", "password", "secret", "token"}
+
+// secretEnvVars are the environment variables commands read secrets from.
+var secretEnvVars = []string{"GITHUB_TOKEN", "SLACK_TOKEN", "TELEGRAM_BOT_TOKEN"}
+
+// configSecrets returns every secret in the config file, decrypted, and in
+// the environment.
+func configSecrets() []string {
+	var secrets []string
+	for _, section := range cfg.Sections() {
+		for _, key := range section.Keys() {
+			if slices.Contains(secretKeys, key.Name()) || strings.HasPrefix(key.Value(), encryptedPrefix) {
+				secrets = append(secrets, key.String())
+			}
+		}
+	}
+	for _, name := range secretEnvVars {
+		secrets = append(secrets, os.Getenv(name))
+	}
+	return secrets
+}
+
+// redactor masks secrets in log output.
+type redactor struct {
+	mu      sync.RWMutex
+	secrets []string
+}
+
+// logRedactor masks secrets in the default logger's output.
+var logRedactor = &redactor{}
+
+// addLogSecret masks a secret in log output, e.g. a token given with a flag.
+func addLogSecret(secret string) {
+	logRedactor.Add(secret)
+}
+
+// Add adds secrets to mask. Empty ones are ignored.
+func (r *redactor) Add(secrets ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, secret := range secrets {
+		if secret != "" && !slices.Contains(r.secrets, secret) {
+			r.secrets = append(r.secrets, secret)
+		}
+	}
+	// Mask longer secrets first, in case one contains another.
+	sort.Slice(r.secrets, func(i, j int) bool { return len(r.secrets[i]) > len(r.secrets[j]) })
+}
+
+// Redact masks any secrets in s.
+func (r *redactor) Redact(s string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, secret := range r.secrets {
+		s = strings.ReplaceAll(s, secret, "<redacted>")
+	}
+	return s
+}
+
+// ReplaceAttr is a slog ReplaceAttr function that masks secrets in string
+// values, including the message, and in errors and other values logged as
+// text, such as URLs.
+func (r *redactor) ReplaceAttr(groups []string, a slog.Attr) slog.Attr {
+	switch a.Value.Kind() {
+	case slog.KindString:
+		a.Value = slog.StringValue(r.Redact(a.Value.String()))
+	case slog.KindAny:
+		switch v := a.Value.Any().(type) {
+		case error:
+			a.Value = slog.StringValue(r.Redact(v.Error()))
+		case fmt.Stringer:
+			a.Value = slog.StringValue(r.Redact(v.String()))
+		}
+	}
+	return a
+}
@@ -1,27 +1,47 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
+	"log/slog"
 	"math"
 	"os"
 	"os/user"
 	"path/filepath"
+	"slices"
 	"strconv"
+	"strings"
 
 	"gopkg.in/ini.v1"
 )
 
 const defaultConfigFile = ".picoleafrc"
 
+// exit terminates the process. The REPL replaces it so that a failing
+// command returns to the prompt instead.
+var exit = os.Exit
+
+var cfg *ini.File
 var configFilePath string
-var verbose = flag.Bool("v", false, "Verbose")
+var deviceName = flag.String("d", "", "Device or group name, remembered for later commands (default the last one used)")
+var logFilePath = flag.String("log", "", "Log file path (defaults to stderr)")
+var logLevel = flag.String("log-level", "", "Log level (debug, info, warn, or error)")
+var logFormat = flag.String("log-format", "text", "Log format (text or json)")
+var verbose = flag.Bool("v", false, "Verbose (same as -log-level debug)")
+var insecureTLS = flag.Bool("insecure", false, "Skip HTTPS certificate verification")
+var parallelism = flag.Int("parallel", 4, "Maximum number of devices in a group to control at once")
+var recordPath = flag.String("record", "", "Record API interactions to a session file")
+var replayPath = flag.String("replay", "", "Replay API interactions from a session file")
+var transition = flag.Duration("transition", 0, "Fade brightness, color, temperature, and scene changes over this long")
+var refreshCache = flag.Bool("refresh", false, "Refetch panel info instead of using the cache")
+var queueOffline = flag.Bool("queue", false, "Queue changes while the Nanoleaf is unreachable, and replay them once it's back")
 
 func init() {
 	usr, err := user.Current()
 	if err != nil {
 		fmt.Println("error: failed to fetch current user:", err)
-		os.Exit(1)
+		exit(1)
 	}
 	dir := usr.HomeDir
 	defaultConfigFilePath := filepath.Join(dir, defaultConfigFile)
@@ -30,117 +50,437 @@ func init() {
 }
 
 func usage() {
-	fmt.Println("usage: picoleaf [-f <path>] [-v] <command>")
+	fmt.Println("usage: picoleaf [-f <path>] [-d <device|group>] [-parallel <n>] [-v] [-log <path>] [-log-level <level>] [-log-format text|json]")
+	fmt.Println("                [-insecure] [-record <path> | -replay <path>] [-transition <duration>] [-refresh] [-queue] <command>")
 	fmt.Println()
 	fmt.Println("Commands:")
 	fmt.Println()
 	fmt.Println("   on           Turn on Nanoleaf")
 	fmt.Println("   off          Turn off Nanoleaf")
+	fmt.Println("   sleep        Slowly dim and warm Nanoleaf, then turn it off")
 	fmt.Println()
 	fmt.Println("   effect       Control Nanoleaf effects")
+	fmt.Println("   scene        Apply and save named scenes")
 	fmt.Println("   panel        Control Nanoleaf panel")
 	fmt.Println()
 	fmt.Println("   hsl          Set Nanoleaf to the provided HSL")
 	fmt.Println("   rgb          Set Nanoleaf to the provided RGB")
 	fmt.Println("   temp         Set Nanoleaf to the provided color temperature")
 	fmt.Println("   brightness   Set Nanoleaf to the provided brightness")
+	fmt.Println("   mode         Get or switch the color mode: hs, ct, or effect")
+	fmt.Println("   pick         Choose a color interactively, previewing it live")
+	fmt.Println("   paint        Paint individual panels interactively")
+	fmt.Println("   palette      Extract color palettes from images")
+	fmt.Println("   preset       Show a built-in holiday or seasonal preset")
+	fmt.Println("   fx           Run a locally animated effect, e.g. breathe")
+	fmt.Println("   fade         Fade from one color to another over time")
+	fmt.Println("   sequence     Step through a list of colors, fading between them")
+	fmt.Println()
+	fmt.Println("   notify       Flash Nanoleaf, then restore its previous state")
+	fmt.Println("   undo         Revert the most recent change")
+	fmt.Println("   queue        List, replay, or clear changes queued with -queue")
+	fmt.Println()
+	fmt.Println("   get          Print one state field, e.g. brightness, or send a GET request")
+	fmt.Println("   api          Send any request to the Nanoleaf, pretty-printing the response")
+	fmt.Println("   repl         Run commands interactively over one connection")
+	fmt.Println("   run          Run a script of commands, pauses, and loops")
+	fmt.Println("   run-cmd      Run a command, showing its progress and result on Nanoleaf")
+	fmt.Println("   script       Run a Starlark animation script")
+	fmt.Println("   config       Check the config file for typos and missing settings")
+	fmt.Println("   device       Add (pairing with it) or remove a device in the config file")
+	fmt.Println("   devices      List configured devices, and others on the network")
+	fmt.Println("   use          Switch the device commands run on without -d")
+	fmt.Println("   doctor       Diagnose connection and configuration problems")
+	fmt.Println("   encrypt      Encrypt a config value, like access_token")
+	fmt.Println("   bench        Measure REST and UDP latency to the Nanoleaf")
+	fmt.Println("   wait         Wait until the Nanoleaf is reachable")
+	fmt.Println("   power        Estimate the Nanoleaf's power draw")
 	fmt.Println()
-	fmt.Println("   get          Send a GET request to the Nanoleaf")
+	fmt.Println("   at           Run a command at the given time")
+	fmt.Println("   in           Run a command after the given delay")
+	fmt.Println("   cron         Run the commands scheduled in the config file")
+	fmt.Println("   weather      Set Nanoleaf to match the current weather")
+	fmt.Println("   ci           Set Nanoleaf to match a CI pipeline's status")
+	fmt.Println("   autooff      Turn Nanoleaf off after a period with no changes")
+	fmt.Println("   nightlight   Cap brightness and keep colors warm, e.g. overnight")
+	fmt.Println("   adapt        Match Nanoleaf's brightness to the room's light")
+	fmt.Println("   busy         Show when you're in a meeting, red or green")
+	fmt.Println("   slack        Set Nanoleaf to match your Slack status")
+	fmt.Println("   daemon       Serve an HTTP API for triggering scenes and flashes")
+	fmt.Println("   telegram     Control Nanoleaf from a Telegram bot")
+	fmt.Println("   link         Keep other devices' brightness in sync with one device")
+	fmt.Println("   mirror-device  Continuously copy one device's colors to another")
+	fmt.Println("   sacn         Drive Nanoleaf from E1.31 (sACN) lighting data")
+	fmt.Println("   artnet       Drive Nanoleaf from Art-Net lighting data")
+	fmt.Println("   ddp          Drive Nanoleaf from DDP pixel data, e.g. from LedFx")
+	fmt.Println("   openrgb      Serve the OpenRGB SDK, with one LED per panel")
+	fmt.Println("   hyperion     Forward a Hyperion instance's ambient colors to Nanoleaf")
 	fmt.Println()
-	os.Exit(1)
+	fmt.Println("Aliases from the config file, and picoleaf-<command> executables on your")
+	fmt.Println("PATH, can be run as commands too.")
+	fmt.Println()
+	exit(1)
+}
+
+// isFlagSet reports whether the named global flag was given on the command
+// line.
+func isFlagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// iniOptions are the options config and scene files are loaded with. # and ;
+// only start an inline comment after a space, so values like `color=#ff8000`
+// work unquoted, and only = separates keys from values, so keys like
+// `07:00` work too.
+var iniOptions = ini.LoadOptions{SpaceBeforeInlineComment: true, KeyValueDelimiters: "="}
+
+// loadINI loads a config or scene file. Encrypted values read as plaintext.
+func loadINI(source interface{}) (*ini.File, error) {
+	f, err := ini.LoadSources(iniOptions, source)
+	if err != nil {
+		return nil, err
+	}
+	return f, decryptConfig(f)
 }
 
 func main() {
 	flag.Parse()
 
-	cfg, err := ini.Load(configFilePath)
+	if flag.Arg(0) == "doctor" {
+		doDoctorCommand(flag.Args()[1:])
+		return
+	}
+	if flag.Arg(0) == "config" {
+		doConfigCommand(flag.Args()[1:])
+		return
+	}
+
+	var err error
+	cfg, err = loadINI(configFilePath)
+	if errors.Is(err, os.ErrNotExist) && flag.Arg(0) == "device" {
+		// device add creates the config file.
+		cfg, err = ini.Empty(iniOptions), nil
+	}
 	if err != nil {
 		fmt.Println("error: failed to read file:", err)
-		os.Exit(1)
+		exit(1)
 	}
 
-	client := Client{
-		Host:    cfg.Section("").Key("host").String(),
-		Token:   cfg.Section("").Key("access_token").String(),
-		Verbose: *verbose,
+	logCloser, err := setupLogging()
+	if err != nil {
+		fmt.Println("error: failed to set up logging:", err)
+		exit(1)
+	}
+	defer logCloser.Close()
+
+	switch flag.Arg(0) {
+	case "device":
+		doDeviceCommand(flag.Args()[1:])
+		return
+	case "use":
+		doUseCommand(flag.Args()[1:])
+		return
 	}
 
-	if *verbose {
-		fmt.Printf("Host: %s\n\n", client.Host)
+	selectDevice()
+	if isGroup(*deviceName) && flag.NArg() > 0 {
+		devices, err := resolveDevices(*deviceName)
+		if err != nil {
+			fmt.Println("error:", err)
+			exit(1)
+		}
+		runGroupCommand(devices, flag.Args())
+		return
 	}
 
-	if flag.NArg() > 0 {
-		cmd := flag.Arg(0)
-		switch cmd {
-		case "brightness":
-			doBrightnessCommand(client, flag.Args()[1:])
-		case "effect":
-			doEffectCommand(client, flag.Args()[1:])
-		case "get":
-			doGetCommand(client, flag.Args()[1:])
-		case "hsl":
-			doHSLCommand(client, flag.Args()[1:])
-		case "off":
-			err = client.Off()
-			if err != nil {
-				fmt.Println("error: failed to turn off Nanoleaf:", err)
-				os.Exit(1)
-			}
-		case "on":
-			err = client.On()
-			if err != nil {
-				fmt.Println("error: failed to turn on Nanoleaf:", err)
-				os.Exit(1)
-			}
-		case "panel":
-			doPanelCommand(client, flag.Args()[1:])
-		case "rgb":
-			doRGBCommand(client, flag.Args()[1:])
-		case "temp":
-			doColorTemperatureCommand(client, flag.Args()[1:])
-		default:
-			usage()
+	device, err := findDevice(*deviceName)
+	if err != nil {
+		fmt.Println("error:", err)
+		exit(1)
+	}
+	client := device.Client()
+	defer client.Close()
+
+	switch {
+	case *recordPath != "" && *replayPath != "":
+		fmt.Println("error: -record and -replay can't be used together")
+		exit(1)
+	case *recordPath != "":
+		client.client.Transport = newRecordingTransport(*recordPath, client.Token, client.client.Transport)
+	case *replayPath != "":
+		transport, err := newReplayingTransport(*replayPath, client.Token)
+		if err != nil {
+			fmt.Println("error: failed to load session:", err)
+			exit(1)
 		}
-	} else {
+		client.client.Transport = transport
+	}
+
+	slog.Debug("using device", "name", device.Name, "host", device.Host)
+
+	if *refreshCache && client.CacheTTL > 0 {
+		err = clearPanelInfoCache(client.Host)
+		if err != nil {
+			fmt.Println("error: failed to clear panel info cache:", err)
+			exit(1)
+		}
+	}
+
+	switch {
+	case flag.NArg() == 0:
 		usage()
+	case *queueOffline && isMutatingCommand(flag.Args()):
+		runOrQueueCommand(client, flag.Args())
+	default:
+		runCommand(client, flag.Args())
 	}
 }
 
+// runCommand dispatches a picoleaf subcommand. args[0] is the command name.
+func runCommand(client Client, args []string) {
+	if isMutatingCommand(args) {
+		recordHistory(client, args)
+	}
+
+	cmd := args[0]
+	if transitionCommands[cmd] {
+		client.Transition = *transition
+		if !isFlagSet("transition") {
+			client.Transition = client.Defaults.Transition
+		}
+	}
+	switch cmd {
+	case "adapt":
+		doAdaptCommand(client, args[1:])
+	case "api":
+		doAPICommand(client, args[1:])
+	case "artnet":
+		doArtNetCommand(client, args[1:])
+	case "at":
+		doAtCommand(client, args[1:])
+	case "autooff":
+		doAutooffCommand(client, args[1:])
+	case "bench":
+		doBenchCommand(client, args[1:])
+	case "brightness":
+		doBrightnessCommand(client, args[1:])
+	case "busy":
+		doBusyCommand(client, args[1:])
+	case "ci":
+		doCICommand(client, args[1:])
+	case "cron":
+		doCronCommand(client, args[1:])
+	case "daemon":
+		doDaemonCommand(client, args[1:])
+	case "ddp":
+		doDDPCommand(client, args[1:])
+	case "device":
+		doDeviceCommand(args[1:])
+	case "devices":
+		doDevicesCommand(args[1:])
+	case "effect":
+		doEffectCommand(client, args[1:])
+	case "encrypt":
+		doEncryptCommand(client, args[1:])
+	case "fade":
+		doFadeCommand(client, args[1:])
+	case "fx":
+		doFxCommand(client, args[1:])
+	case "get":
+		doGetCommand(client, args[1:])
+	case "hsl":
+		doHSLCommand(client, args[1:])
+	case "hyperion":
+		doHyperionCommand(client, args[1:])
+	case "in":
+		doInCommand(client, args[1:])
+	case "link":
+		doLinkCommand(client, args[1:])
+	case "mirror-device":
+		doMirrorDeviceCommand(client, args[1:])
+	case "mode":
+		doModeCommand(client, args[1:])
+	case "nightlight":
+		doNightlightCommand(client, args[1:])
+	case "notify":
+		doNotifyCommand(client, args[1:])
+	case "off":
+		doOffCommand(client, args[1:])
+	case "on":
+		doOnCommand(client, args[1:])
+	case "openrgb":
+		doOpenRGBCommand(client, args[1:])
+	case "paint":
+		doPaintCommand(client, args[1:])
+	case "palette":
+		doPaletteCommand(client, args[1:])
+	case "panel":
+		doPanelCommand(client, args[1:])
+	case "pick":
+		doPickCommand(client, args[1:])
+	case "power":
+		doPowerCommand(client, args[1:])
+	case "preset":
+		doPresetCommand(client, args[1:])
+	case "repl":
+		doReplCommand(client, args[1:])
+	case "queue":
+		doQueueCommand(client, args[1:])
+	case "rgb":
+		doRGBCommand(client, args[1:])
+	case "run":
+		doRunCommand(client, args[1:])
+	case "run-cmd":
+		doRunCmdCommand(client, args[1:])
+	case "sacn":
+		doSACNCommand(client, args[1:])
+	case "scene":
+		doSceneCommand(client, args[1:])
+	case "script":
+		doScriptCommand(client, args[1:])
+	case "sequence":
+		doSequenceCommand(client, args[1:])
+	case "slack":
+		doSlackCommand(client, args[1:])
+	case "sleep":
+		doSleepCommand(client, args[1:])
+	case "telegram":
+		doTelegramCommand(client, args[1:])
+	case "temp":
+		doColorTemperatureCommand(client, args[1:])
+	case "undo":
+		doUndoCommand(client, args[1:])
+	case "wait":
+		doWaitCommand(client, args[1:])
+	case "weather":
+		doWeatherCommand(client, args[1:])
+	default:
+		if isAlias(cmd) {
+			runAlias(client, args)
+		} else if path, ok := findExternalCommand(cmd); ok {
+			runExternalCommand(client, path, args)
+		} else {
+			usage()
+		}
+	}
+
+	if isMutatingCommand(args) {
+		applyNightlight(client)
+	}
+}
+
+// requireCapabilities returns the Nanoleaf's capabilities, for checking a
+// command's arguments against them, or exits if they can't be read.
+func requireCapabilities(client Client) *Capabilities {
+	caps, err := client.Capabilities()
+	if err != nil {
+		fmt.Println("error: failed to get Nanoleaf info:", err)
+		exit(1)
+	}
+	return caps
+}
+
+// requireFullColor is like requireCapabilities, but also exits if the
+// Nanoleaf only shows shades of white.
+func requireFullColor(client Client) *Capabilities {
+	caps := requireCapabilities(client)
+	if !caps.FullColor {
+		fmt.Printf("error: %s only shows shades of white; use temp instead\n", caps.ProductName)
+		exit(1)
+	}
+	return caps
+}
+
 func doBrightnessCommand(client Client, args []string) {
 	if len(args) < 1 {
 		fmt.Println("usage: picoleaf brightness <brightness>")
-		os.Exit(1)
+		exit(1)
 	}
 
 	brightness, err := strconv.Atoi(args[0])
-	if err != nil || brightness < 0 || brightness > 100 {
-		fmt.Println("error: temperature must be an integer 0-100")
-		os.Exit(1)
+	if err != nil {
+		fmt.Println("error: brightness must be an integer")
+		exit(1)
+	}
+	caps := requireCapabilities(client)
+	if err := caps.Brightness.check("brightness", brightness); err != nil {
+		fmt.Println("error:", err)
+		exit(1)
+	}
+
+	if client.MaxBrightness > 0 && brightness > client.MaxBrightness {
+		fmt.Printf("Limiting brightness to %d, this device's max_brightness\n", client.MaxBrightness)
 	}
 
 	err = client.SetBrightness(brightness)
 	if err != nil {
 		fmt.Println("error: failed to set brightness:", err)
-		os.Exit(1)
+		exit(1)
 	}
 }
 
+// emulatedTemperatureRange is the range of color temperatures, in kelvin,
+// `temp --emulate` accepts.
+var emulatedTemperatureRange = valueRange{1000, 40000}
+
 func doColorTemperatureCommand(client Client, args []string) {
-	if len(args) < 1 {
-		fmt.Println("usage: picoleaf temp <temperature>")
-		os.Exit(1)
+	usage := func() {
+		fmt.Println("usage: picoleaf temp <temperature> [--emulate[=false]]")
+		exit(1)
+	}
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		usage()
+	}
+
+	flags := flag.NewFlagSet("temp", flag.ExitOnError)
+	emulate := flags.Bool("emulate", client.Defaults.ColorMode == "hs", "Approximate the temperature with hue and saturation, for values outside the ct range (default true if the device's default_color_mode is hs)")
+	flags.Usage = usage
+	flags.Parse(args[1:])
+
+	if flags.NArg() > 0 {
+		flags.Usage()
 	}
 
 	temp, err := strconv.Atoi(args[0])
-	if err != nil || temp < 1200 || temp > 6500 {
-		fmt.Println("error: temperature must be an integer 1200-6500")
-		os.Exit(1)
+	if err != nil {
+		fmt.Println("error: temperature must be an integer")
+		exit(1)
+	}
+
+	if *emulate {
+		requireFullColor(client)
+		if err := emulatedTemperatureRange.check("temperature", temp); err != nil {
+			fmt.Println("error:", err)
+			exit(1)
+		}
+		err = client.EmulateColorTemperature(temp)
+		if err != nil {
+			fmt.Println("error: failed to set color:", err)
+			exit(1)
+		}
+		return
+	}
+
+	caps := requireCapabilities(client)
+	if err := caps.ColorTemperature.check("temperature", temp); err != nil {
+		if caps.FullColor {
+			fmt.Printf("error: %v; use --emulate to approximate it\n", err)
+		} else {
+			fmt.Println("error:", err)
+		}
+		exit(1)
 	}
 
 	err = client.SetColorTemperature(temp)
 	if err != nil {
 		fmt.Println("error: failed to set color temperature:", err)
-		os.Exit(1)
+		exit(1)
 	}
 }
 
@@ -148,8 +488,11 @@ func doEffectCommand(client Client, args []string) {
 	usage := func() {
 		fmt.Println("usage: picoleaf effect list")
 		fmt.Println("       picoleaf effect select <name>")
-		fmt.Println("       picoleaf effect custom [<panel> <red> <green> <blue> <transition time>] ...")
-		os.Exit(1)
+		fmt.Println("       picoleaf effect create --name <name> --plugin <plugin> --palette <colors> [<options>]")
+		fmt.Println("       picoleaf effect custom [--rgbw] [<panel> <red> <green> <blue>|<color> [<white>] <transition time>] ...")
+		fmt.Println("       picoleaf effect dump-custom [--frames]")
+		fmt.Println("       picoleaf effect stream [--fps <n>] [--rgbw] < frames")
+		exit(1)
 	}
 
 	if len(args) < 1 {
@@ -158,109 +501,302 @@ func doEffectCommand(client Client, args []string) {
 
 	command := args[0]
 	switch command {
+	case "create":
+		doEffectCreateCommand(client, args[1:])
 	case "custom":
-		customArgs := args[1:]
-		numFrameArgs := 5
-		if len(customArgs)%numFrameArgs != 0 {
+		flags := flag.NewFlagSet("custom", flag.ExitOnError)
+		rgbw := flags.Bool("rgbw", false, "Include a white value in each frame, for devices with a white channel")
+		flags.Usage = func() {
 			fmt.Println("usage: picoleaf effect custom [<panel> <red> <green> <blue> <transition time>] ...")
+			fmt.Println("       picoleaf effect custom [<panel> <color> <transition time>] ...")
+			fmt.Println("       picoleaf effect custom --rgbw [<panel> <red> <green> <blue>|<color> <white> <transition time>] ...")
+			exit(1)
 		}
-
-		numFrames := len(customArgs) / numFrameArgs
-		frames := make([]SetPanelColor, numFrames)
-		for i := 0; i < numFrames; i++ {
-			offset := numFrameArgs * i
-			panelID, err := strconv.ParseUint(customArgs[offset], 10, 16)
-			if err != nil {
-				fmt.Printf("error: expected panel ID between 0-%d, got %s", math.MaxUint16, customArgs[offset])
-				os.Exit(1)
-			}
-
-			red, err := strconv.ParseUint(customArgs[offset+1], 10, 8)
-			if err != nil {
-				fmt.Printf("error: expected red value between 0-%d, got %s", math.MaxUint8, customArgs[offset+1])
-				os.Exit(1)
-			}
-
-			green, err := strconv.ParseUint(customArgs[offset+2], 10, 8)
-			if err != nil {
-				fmt.Printf("error: expected green value between 0-%d, got %s", math.MaxUint8, customArgs[offset+2])
-				os.Exit(1)
-			}
-
-			blue, err := strconv.ParseUint(customArgs[offset+3], 10, 8)
-			if err != nil {
-				fmt.Printf("error: expected blue value between 0-%d, got %s", math.MaxUint8, customArgs[offset+3])
-				os.Exit(1)
-			}
-
-			transitionTime, err := strconv.ParseUint(customArgs[offset+4], 10, 16)
-			if err != nil {
-				fmt.Printf("error: expected transition time between 0-%d, got %s", math.MaxUint16, customArgs[offset+4])
-				os.Exit(1)
-			}
-
-			frames[i].PanelID = uint16(panelID)
-			frames[i].Red = uint8(red)
-			frames[i].Green = uint8(green)
-			frames[i].Blue = uint8(blue)
-			frames[i].TransitionTime = uint16(transitionTime)
+		flags.Parse(args[1:])
+
+		frames, err := parseCustomFrames(flags.Args(), *rgbw)
+		if err == errCustomFrameArgs {
+			flags.Usage()
+		} else if err != nil {
+			fmt.Println("error:", err)
+			exit(1)
 		}
 
-		err := client.SetCustomColors(frames)
+		err = client.SetCustomColors(frames)
 		if err != nil {
 			fmt.Println("error: failed to start external control:", err)
-			os.Exit(1)
+			exit(1)
 		}
+	case "dump-custom":
+		doEffectDumpCustomCommand(client, args[1:])
 	case "list":
 		list, err := client.ListEffects()
 		if err != nil {
 			fmt.Println("error: failed retrieve effects list:", err)
-			os.Exit(1)
+			exit(1)
 		}
 		for _, name := range list {
 			fmt.Println(name)
 		}
+	case "stream":
+		doEffectStreamCommand(client, args[1:])
 	case "select":
 		if len(args) != 2 {
 			fmt.Println("usage: picoleaf effect select <name>")
-			os.Exit(1)
+			exit(1)
 		}
 
 		name := args[1]
 		err := client.SelectEffect(name)
 		if err != nil {
 			fmt.Println("error: failed to select effect:", err)
-			os.Exit(1)
+			exit(1)
 		}
 	default:
 		usage()
 	}
 }
 
+// errCustomFrameArgs indicates custom effect arguments that don't divide
+// into complete frames.
+var errCustomFrameArgs = errors.New("wrong number of custom frame arguments")
+
+// parseCustomFrames parses `<panel> <red> <green> <blue> <transition time>`
+// tuples into panel colors, or `<panel> <red> <green> <blue> <white>
+// <transition time>` tuples if rgbw is set. <panel> may also be a zone name,
+// or a comma-separated list of IDs and zones, which sets each panel in it.
+// The red, green, and blue values may instead be given as one color: a name,
+// #rrggbb, or r,g,b, e.g. `left #ff8000 10`.
+func parseCustomFrames(customArgs []string, rgbw bool) ([]SetPanelColor, error) {
+	var frames []SetPanelColor
+	for offset := 0; offset < len(customArgs); {
+		if len(customArgs)-offset < 3 {
+			return nil, errCustomFrameArgs
+		}
+		panelIDs, err := parsePanels(customArgs[offset])
+		if err != nil {
+			return nil, err
+		}
+		offset++
+
+		color, n, err := parseFrameColor(customArgs[offset:])
+		if err != nil {
+			return nil, err
+		}
+		offset += n
+
+		rest := 1
+		if rgbw {
+			rest = 2
+		}
+		if len(customArgs)-offset < rest {
+			return nil, errCustomFrameArgs
+		}
+
+		var white uint64
+		if rgbw {
+			white, err = strconv.ParseUint(customArgs[offset], 10, 8)
+			if err != nil {
+				return nil, fmt.Errorf("expected white value between 0-%d, got %s", math.MaxUint8, customArgs[offset])
+			}
+			offset++
+		}
+
+		transitionTime, err := strconv.ParseUint(customArgs[offset], 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("expected transition time between 0-%d, got %s", math.MaxUint16, customArgs[offset])
+		}
+		offset++
+
+		for _, panelID := range panelIDs {
+			frames = append(frames, SetPanelColor{
+				PanelID:        panelID,
+				Red:            color.Red,
+				Green:          color.Green,
+				Blue:           color.Blue,
+				White:          uint8(white),
+				TransitionTime: uint16(transitionTime),
+			})
+		}
+	}
+	return frames, nil
+}
+
+// parseFrameColor parses the color at the start of a custom frame's
+// remaining arguments: separate red, green, and blue values, or one color
+// name, #rrggbb, or r,g,b. It returns the number of arguments used.
+func parseFrameColor(args []string) (RGB, int, error) {
+	if _, err := strconv.ParseUint(args[0], 10, 8); err == nil {
+		if len(args) < 3 {
+			return RGB{}, 0, errCustomFrameArgs
+		}
+		var channels [3]uint8
+		for i, name := range []string{"red", "green", "blue"} {
+			v, err := strconv.ParseUint(args[i], 10, 8)
+			if err != nil {
+				return RGB{}, 0, fmt.Errorf("expected %s value between 0-%d, got %s", name, math.MaxUint8, args[i])
+			}
+			channels[i] = uint8(v)
+		}
+		return RGB{channels[0], channels[1], channels[2]}, 3, nil
+	}
+
+	if parts := strings.Split(args[0], ","); len(parts) == 3 {
+		var channels [3]uint8
+		for i, part := range parts {
+			v, err := strconv.ParseUint(strings.TrimSpace(part), 10, 8)
+			if err != nil {
+				return RGB{}, 0, fmt.Errorf("expected r,g,b values between 0-%d, got %s", math.MaxUint8, args[0])
+			}
+			channels[i] = uint8(v)
+		}
+		return RGB{channels[0], channels[1], channels[2]}, 1, nil
+	}
+
+	c, err := parseColor(args[0])
+	if err != nil {
+		return RGB{}, 0, fmt.Errorf("expected a red value between 0-%d or a color, got %s", math.MaxUint8, args[0])
+	}
+	return c, 1, nil
+}
+
+// stateFields are the fields `get` prints as bare values.
+var stateFields = []string{"brightness", "hue", "sat", "ct", "effect", "on"}
+
+// stateField returns a single state field as a bare value, for scripts.
+func stateField(info *PanelInfo, field string) (string, bool) {
+	state := info.State
+	switch field {
+	case "brightness":
+		if state.Brightness != nil {
+			return strconv.Itoa(state.Brightness.Value), true
+		}
+	case "hue":
+		if state.Hue != nil {
+			return strconv.Itoa(state.Hue.Value), true
+		}
+	case "sat":
+		if state.Saturation != nil {
+			return strconv.Itoa(state.Saturation.Value), true
+		}
+	case "ct":
+		if state.ColorTemperature != nil {
+			return strconv.Itoa(state.ColorTemperature.Value), true
+		}
+	case "effect":
+		return info.Effects.Selected, true
+	case "on":
+		if state.On != nil {
+			return strconv.FormatBool(state.On.Value), true
+		}
+	}
+	return "", false
+}
+
 func doGetCommand(client Client, args []string) {
-	if len(args) < 1 {
-		fmt.Println("usage: picoleaf get <path>")
-		os.Exit(1)
+	if len(args) != 1 {
+		fmt.Printf("usage: picoleaf get %s\n", strings.Join(stateFields, "|"))
+		fmt.Println("       picoleaf get <path>")
+		exit(1)
+	}
+
+	if slices.Contains(stateFields, args[0]) {
+		panelInfo, err := client.GetPanelInfo()
+		if err != nil {
+			fmt.Println("error: failed to get Nanoleaf state:", err)
+			exit(1)
+		}
+		value, ok := stateField(panelInfo, args[0])
+		if !ok {
+			fmt.Println("error: Nanoleaf did not report", args[0])
+			exit(1)
+		}
+		fmt.Println(value)
+		return
 	}
 
 	res, err := client.Get(args[0])
 	if err != nil {
-		fmt.Println("error: failed to set color temperature:", err)
-		os.Exit(1)
+		fmt.Println("error: failed to get", args[0]+":", err)
+		exit(1)
 	}
 
 	fmt.Println(res)
 }
 
+// doOnCommand turns the Nanoleaf on, at the device's default brightness if
+// one is configured.
+func doOnCommand(client Client, args []string) {
+	flags := flag.NewFlagSet("on", flag.ExitOnError)
+	brightness := flags.Int("brightness", client.Defaults.Brightness, "Brightness to turn on at (default the device's default_brightness, or the last brightness)")
+	flags.Usage = func() {
+		fmt.Println("usage: picoleaf on [--brightness <brightness>]")
+		exit(1)
+	}
+	flags.Parse(args)
+
+	if flags.NArg() > 0 || *brightness < 0 || *brightness > 100 {
+		flags.Usage()
+	}
+
+	var err error
+	if *brightness > 0 {
+		err = client.PutState(State{
+			On:         &OnProperty{true},
+			Brightness: &BrightnessProperty{Value: *brightness},
+		})
+	} else {
+		err = client.On()
+	}
+	if err != nil {
+		fmt.Println("error: failed to turn on Nanoleaf:", err)
+		exit(1)
+	}
+}
+
+func doOffCommand(client Client, args []string) {
+	flags := flag.NewFlagSet("off", flag.ExitOnError)
+	delay := flags.Duration("in", 0, "Delay before turning off")
+	flags.Usage = func() {
+		fmt.Println("usage: picoleaf off [--in <duration>]")
+		exit(1)
+	}
+	flags.Parse(args)
+
+	if *delay < 0 || flags.NArg() > 0 {
+		flags.Usage()
+	}
+
+	if *delay > 0 {
+		fmt.Printf("Turning off in %s (Ctrl-C to cancel)\n", *delay)
+		if !sleepOrCancel(*delay) {
+			fmt.Println("Timer cancelled")
+			return
+		}
+	}
+
+	err := client.Off()
+	if err != nil {
+		fmt.Println("error: failed to turn off Nanoleaf:", err)
+		exit(1)
+	}
+}
+
 func doPanelCommand(client Client, args []string) {
 	usage := func() {
-		fmt.Println("usage: picoleaf panel info")
+		fmt.Println("usage: picoleaf panel capabilities")
+		fmt.Println("       picoleaf panel colors [--json]")
+		fmt.Println("       picoleaf panel info")
 		fmt.Println("       picoleaf panel model")
 		fmt.Println("       picoleaf panel name")
 		fmt.Println("       picoleaf panel version")
-		os.Exit(1)
+		exit(1)
 	}
 
+	if len(args) > 0 && args[0] == "colors" {
+		doPanelColorsCommand(client, args[1:])
+		return
+	}
 	if len(args) != 1 {
 		usage()
 	}
@@ -268,11 +804,22 @@ func doPanelCommand(client Client, args []string) {
 	panelInfo, err := client.GetPanelInfo()
 	if err != nil {
 		fmt.Println("error: failed to get Nanoleaf state:", err)
-		os.Exit(1)
+		exit(1)
 	}
 
 	command := args[0]
 	switch command {
+	case "capabilities":
+		caps := DetectCapabilities(panelInfo)
+		fmt.Printf("Model:            %s (%s)\n", caps.ProductName, caps.Model)
+		fmt.Println("Firmware Version:", caps.FirmwareVersion)
+		fmt.Println()
+		fmt.Printf("External Control: v%d\n", caps.ExtControlVersion)
+		fmt.Println("Touch Events:    ", caps.TouchEvents)
+		fmt.Println("Full Color:      ", caps.FullColor)
+		fmt.Println()
+		fmt.Printf("Brightness:        %d-%d\n", caps.Brightness.Min, caps.Brightness.Max)
+		fmt.Printf("Color Temperature: %dK-%dK\n", caps.ColorTemperature.Min, caps.ColorTemperature.Max)
 	case "info":
 		fmt.Println("Name:", panelInfo.Name)
 		fmt.Println()
@@ -361,61 +908,64 @@ func doPanelCommand(client Client, args []string) {
 func doHSLCommand(client Client, args []string) {
 	if len(args) != 3 {
 		fmt.Println("usage: picoleaf hsl <hue> <saturation> <lightness>")
-		os.Exit(1)
+		exit(1)
 	}
 
-	hue, err := strconv.Atoi(args[0])
-	if err != nil || hue < 0 || hue > 360 {
-		fmt.Println("error: hue must be an integer 0-100")
-		os.Exit(1)
-	}
-
-	sat, err := strconv.Atoi(args[1])
-	if err != nil || sat < 0 || sat > 100 {
-		fmt.Println("error: saturation must be an integer 0-360")
-		os.Exit(1)
+	names := []string{"hue", "saturation", "lightness"}
+	values := make([]int, len(args))
+	for i, name := range names {
+		v, err := strconv.Atoi(args[i])
+		if err != nil {
+			fmt.Printf("error: %s must be an integer\n", name)
+			exit(1)
+		}
+		values[i] = v
 	}
 
-	lightness, err := strconv.Atoi(args[2])
-	if err != nil || lightness < 0 || lightness > 100 {
-		fmt.Println("error: lightness must be an integer 0-100")
-		os.Exit(1)
+	caps := requireFullColor(client)
+	for i, r := range []valueRange{caps.Hue, caps.Saturation, caps.Brightness} {
+		if err := r.check(names[i], values[i]); err != nil {
+			fmt.Println("error:", err)
+			exit(1)
+		}
 	}
+	hue, sat, lightness := values[0], values[1], values[2]
 
-	err = client.SetHSL(hue, sat, lightness)
+	err := client.SetHSL(hue, sat, lightness)
 	if err != nil {
 		fmt.Println("error: failed to set HSL:", err)
-		os.Exit(1)
+		exit(1)
 	}
 }
 
 func doRGBCommand(client Client, args []string) {
 	if len(args) != 3 {
 		fmt.Println("usage: picoleaf rgb <red> <green> <blue>")
-		os.Exit(1)
+		exit(1)
 	}
 
 	red, err := strconv.Atoi(args[0])
 	if err != nil || red < 0 || red > 255 {
 		fmt.Println("error: red must be an integer 0-255")
-		os.Exit(1)
+		exit(1)
 	}
 
 	green, err := strconv.Atoi(args[1])
 	if err != nil || green < 0 || green > 255 {
 		fmt.Println("error: green must be an integer 0-255")
-		os.Exit(1)
+		exit(1)
 	}
 
 	blue, err := strconv.Atoi(args[2])
 	if err != nil || blue < 0 || blue > 255 {
 		fmt.Println("error: blue must be an integer 0-255")
-		os.Exit(1)
+		exit(1)
 	}
+	requireFullColor(client)
 
 	err = client.SetRGB(red, green, blue)
 	if err != nil {
 		fmt.Println("error: failed to set RGB:", err)
-		os.Exit(1)
+		exit(1)
 	}
 }
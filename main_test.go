@@ -0,0 +1,86 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCustomFramesRGBW(t *testing.T) {
+	frames, err := parseCustomFrames([]string{"101", "255", "0", "0", "128", "10"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []SetPanelColor{{PanelID: 101, Red: 255, White: 128, TransitionTime: 10}}
+	if !reflect.DeepEqual(frames, want) {
+		t.Errorf("parseCustomFrames = %+v, want %+v", frames, want)
+	}
+
+	_, err = parseCustomFrames([]string{"101", "255", "0", "0", "10"}, true)
+	if err != errCustomFrameArgs {
+		t.Errorf("parseCustomFrames with RGB frames and rgbw = %v, want errCustomFrameArgs", err)
+	}
+
+	_, err = parseCustomFrames([]string{"101", "255", "0", "0", "256", "10"}, true)
+	if err == nil {
+		t.Error("parseCustomFrames accepted white value 256")
+	}
+}
+
+func TestStateField(t *testing.T) {
+	info := &PanelInfo{
+		State: State{
+			On:         &OnProperty{Value: true},
+			Brightness: &BrightnessProperty{Value: 40},
+		},
+		Effects: Effects{Selected: "*Solid*"},
+	}
+
+	tests := []struct {
+		field string
+		want  string
+		ok    bool
+	}{
+		{"on", "true", true},
+		{"brightness", "40", true},
+		{"effect", "*Solid*", true},
+		{"hue", "", false},
+		{"state", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := stateField(info, tt.field)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("stateField(%q) = %q, %v, want %q, %v", tt.field, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestParseCustomFramesColors(t *testing.T) {
+	frames, err := parseCustomFrames([]string{"101", "#ff8000", "10", "102", "0,0,255", "0", "103", "red", "5", "104", "1", "2", "3", "0"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []SetPanelColor{
+		{PanelID: 101, Red: 255, Green: 128, TransitionTime: 10},
+		{PanelID: 102, Blue: 255},
+		{PanelID: 103, Red: 255, TransitionTime: 5},
+		{PanelID: 104, Red: 1, Green: 2, Blue: 3},
+	}
+	if !reflect.DeepEqual(frames, want) {
+		t.Errorf("parseCustomFrames = %+v, want %+v", frames, want)
+	}
+
+	frames, err = parseCustomFrames([]string{"101", "blue", "128", "10"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []SetPanelColor{{PanelID: 101, Blue: 255, White: 128, TransitionTime: 10}}
+	if !reflect.DeepEqual(frames, want) {
+		t.Errorf("parseCustomFrames with rgbw = %+v, want %+v", frames, want)
+	}
+
+	for _, args := range [][]string{{"101", "#ff8000"}, {"101", "nope", "10"}, {"101", "0,0,256", "10"}} {
+		if _, err := parseCustomFrames(args, false); err == nil {
+			t.Errorf("parseCustomFrames(%q) succeeded, want error", args)
+		}
+	}
+}
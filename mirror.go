@@ -0,0 +1,196 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"math"
+	"strings"
+	"time"
+)
+
+// mapPanels maps each target panel to the source panel in the same relative
+// position, after scaling both layouts to fill the same square.
+func mapPanels(from []PanelPosition, to []PanelPosition) map[int]int {
+	if len(from) == 0 {
+		return nil
+	}
+
+	normalize := func(panels []PanelPosition) [][2]float64 {
+		minX, minY := math.Inf(1), math.Inf(1)
+		maxX, maxY := math.Inf(-1), math.Inf(-1)
+		for _, p := range panels {
+			minX, maxX = math.Min(minX, float64(p.X)), math.Max(maxX, float64(p.X))
+			minY, maxY = math.Min(minY, float64(p.Y)), math.Max(maxY, float64(p.Y))
+		}
+
+		points := make([][2]float64, len(panels))
+		for i, p := range panels {
+			if maxX > minX {
+				points[i][0] = (float64(p.X) - minX) / (maxX - minX)
+			}
+			if maxY > minY {
+				points[i][1] = (float64(p.Y) - minY) / (maxY - minY)
+			}
+		}
+		return points
+	}
+
+	fromPoints := normalize(from)
+	toPoints := normalize(to)
+
+	mapping := make(map[int]int, len(to))
+	for i, p := range toPoints {
+		best, bestDist := 0, math.Inf(1)
+		for j, q := range fromPoints {
+			dist := math.Hypot(p[0]-q[0], p[1]-q[1])
+			if dist < bestDist {
+				best, bestDist = j, dist
+			}
+		}
+		mapping[to[i].PanelID] = from[best].PanelID
+	}
+	return mapping
+}
+
+// deviceMirror copies one device's state to another.
+type deviceMirror struct {
+	source Client
+	target Client
+
+	// mapping maps target panel IDs to source panel IDs.
+	mapping map[int]int
+
+	last *Snapshot
+}
+
+// sync copies the source's state to the target if it has changed since the
+// last call. Static effects are copied panel by panel over external
+// control; anything else is copied as a whole.
+func (m *deviceMirror) sync() error {
+	snapshot, err := m.source.Snapshot()
+	if err != nil {
+		return err
+	}
+	if m.last != nil && *snapshot == *m.last {
+		return nil
+	}
+
+	if !snapshot.On {
+		err = m.target.Off()
+		if err == nil {
+			m.last = snapshot
+		}
+		return err
+	}
+
+	var colors map[int]RGB
+	if snapshot.ColorMode == "effect" && !strings.HasPrefix(snapshot.Effect, "*") {
+		colors, err = m.source.EffectColors(snapshot.Effect)
+		if err != nil && err != errDynamicEffect {
+			return err
+		}
+	}
+
+	if colors == nil {
+		slog.Info("mirroring state", "mode", snapshot.ColorMode, "effect", snapshot.Effect)
+		err = m.target.Restore(*snapshot)
+		if err == nil {
+			m.last = snapshot
+		}
+		return err
+	}
+
+	slog.Info("mirroring panel colors", "effect", snapshot.Effect, "panels", len(colors))
+	err = m.target.SetBrightness(snapshot.Brightness)
+	if err != nil {
+		return err
+	}
+	err = m.target.On()
+	if err != nil {
+		return err
+	}
+
+	var frames []SetPanelColor
+	for targetID, sourceID := range m.mapping {
+		c := colors[sourceID]
+		frames = append(frames, SetPanelColor{
+			PanelID: uint16(targetID),
+			Red:     c.Red,
+			Green:   c.Green,
+			Blue:    c.Blue,
+		})
+	}
+	err = m.target.SetCustomColors(frames)
+	if err != nil {
+		return err
+	}
+
+	m.last = snapshot
+	return nil
+}
+
+// doMirrorDeviceCommand continuously copies one device's state and panel
+// colors to another, until interrupted, then restores the other device's
+// previous state.
+func doMirrorDeviceCommand(client Client, args []string) {
+	flags := flag.NewFlagSet("mirror-device", flag.ExitOnError)
+	from := flags.String("from", "", "Device to copy from")
+	to := flags.String("to", "", "Device to copy to")
+	interval := flags.Duration("interval", time.Second, "How often to check the source device for changes")
+	flags.Usage = func() {
+		fmt.Println("usage: picoleaf mirror-device --from <device> --to <device> [--interval <duration>]")
+		exit(1)
+	}
+	flags.Parse(args)
+
+	if flags.NArg() > 0 || *from == "" || *to == "" || *interval <= 0 {
+		flags.Usage()
+	}
+	if *from == *to {
+		fmt.Println("error: can't mirror a device to itself")
+		exit(1)
+	}
+
+	var clients []Client
+	var layouts [][]PanelPosition
+	for _, name := range []string{*from, *to} {
+		device, err := findDevice(name)
+		if err != nil {
+			fmt.Println("error:", err)
+			exit(1)
+		}
+		c := device.Client()
+		defer c.Close()
+
+		panelInfo, err := c.GetPanelInfo()
+		if err != nil {
+			fmt.Printf("error: failed to get %s layout: %v\n", name, err)
+			exit(1)
+		}
+		clients = append(clients, c)
+		layouts = append(layouts, panelInfo.PanelLayout.Layout.PositionData)
+	}
+
+	m := &deviceMirror{
+		source:  clients[0],
+		target:  clients[1],
+		mapping: mapPanels(layouts[0], layouts[1]),
+	}
+
+	ctx, restore := restoreOnInterrupt(m.target)
+	defer restore()
+
+	slog.Info("mirroring device", "from", *from, "to", *to)
+	for {
+		err := m.sync()
+		if err != nil {
+			slog.Error("failed to mirror device", "err", err)
+		}
+		select {
+		case <-time.After(*interval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseAnimData(t *testing.T) {
+	colors, err := parseAnimData("2 101 1 255 0 0 0 10 102 2 0 255 0 0 10 0 0 255 0 10")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[int]RGB{
+		101: {Red: 255},
+		102: {Green: 255},
+	}
+	if !reflect.DeepEqual(colors, want) {
+		t.Errorf("parseAnimData() = %v, want %v", colors, want)
+	}
+
+	_, err = parseAnimData("2 101 1 255 0 0 0 10")
+	if err == nil {
+		t.Error("parseAnimData() accepted truncated data")
+	}
+}
+
+func TestMapPanels(t *testing.T) {
+	// A wide source layout and a small, tall target: corners should map to
+	// corners regardless of scale.
+	from := []PanelPosition{
+		{PanelID: 1, X: 0, Y: 0},
+		{PanelID: 2, X: 1000, Y: 0},
+		{PanelID: 3, X: 0, Y: 200},
+		{PanelID: 4, X: 1000, Y: 200},
+	}
+	to := []PanelPosition{
+		{PanelID: 11, X: 0, Y: 0},
+		{PanelID: 12, X: 50, Y: 0},
+		{PanelID: 13, X: 0, Y: 300},
+		{PanelID: 14, X: 50, Y: 300},
+	}
+
+	got := mapPanels(from, to)
+	want := map[int]int{11: 1, 12: 2, 13: 3, 14: 4}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("mapPanels() = %v, want %v", got, want)
+	}
+}
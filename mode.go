@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// colorModes are the Nanoleaf's color modes: hue and saturation, color
+// temperature, or an effect.
+var colorModes = []string{"hs", "ct", "effect"}
+
+// isPlaceholderEffect reports whether an effect name is a placeholder for a
+// transient mode, like *Solid* after a color change, rather than a real
+// effect.
+func isPlaceholderEffect(name string) bool {
+	return name == "" || strings.HasPrefix(name, "*")
+}
+
+// lastEffect returns the most recent real effect in the device's undo
+// history, if any.
+func lastEffect(device string) (string, bool) {
+	entries, err := loadHistory()
+	if err != nil {
+		return "", false
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].Device == device && !isPlaceholderEffect(entries[i].Snapshot.Effect) {
+			return entries[i].Snapshot.Effect, true
+		}
+	}
+	return "", false
+}
+
+// setColorMode switches the Nanoleaf into a color mode, keeping the hue and
+// saturation or color temperature it last had. For effect mode, that's the
+// selected effect if it's still running, or else the last one in the undo
+// history.
+func setColorMode(client Client, mode string) error {
+	snapshot, err := client.Snapshot()
+	if err != nil {
+		return err
+	}
+
+	switch mode {
+	case "hs":
+		return client.PutState(State{
+			Hue:        &HueProperty{Value: snapshot.Hue},
+			Saturation: &SaturationProperty{Value: snapshot.Saturation},
+		})
+	case "ct":
+		return client.PutState(State{
+			ColorTemperature: &ColorTemperatureProperty{Value: snapshot.ColorTemperature},
+		})
+	case "effect":
+		effect := snapshot.Effect
+		if isPlaceholderEffect(effect) {
+			var ok bool
+			effect, ok = lastEffect(currentDeviceName())
+			if !ok {
+				return fmt.Errorf("no effect to switch back to, use `effect select`")
+			}
+		}
+		return client.SelectEffect(effect)
+	}
+	return fmt.Errorf("unknown mode %q, expected one of: %s", mode, strings.Join(colorModes, ", "))
+}
+
+// doModeCommand prints or switches the Nanoleaf's color mode.
+func doModeCommand(client Client, args []string) {
+	usage := func() {
+		fmt.Println("usage: picoleaf mode get")
+		fmt.Println("       picoleaf mode set hs|ct|effect")
+		exit(1)
+	}
+
+	switch {
+	case len(args) == 1 && args[0] == "get":
+		snapshot, err := client.Snapshot()
+		if err != nil {
+			fmt.Println("error: failed to get Nanoleaf state:", err)
+			exit(1)
+		}
+		fmt.Println(snapshot.ColorMode)
+	case len(args) == 2 && args[0] == "set":
+		err := setColorMode(client, args[1])
+		if err != nil {
+			fmt.Println("error: failed to set mode:", err)
+			exit(1)
+		}
+	default:
+		usage()
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/paulrosania/picoleaf/internal/nltest"
+)
+
+func TestModeCommand(t *testing.T) {
+	client, server := newTestClient(t)
+	t.Setenv("HOME", t.TempDir()) // mode set records undo history
+	setTestConfig(t, "")
+	server.Update(func(d *nltest.Device) {
+		d.State.ColorMode = "effect"
+		d.State.ColorTemperature = 2700
+		d.Effect = "Northern Lights"
+		d.Effects = []string{"Northern Lights"}
+	})
+
+	if code := runCommandInProcess(client, []string{"mode", "set", "ct"}); code != 0 {
+		t.Fatalf("mode set ct: exit code = %d", code)
+	}
+	if d := server.Device(); d.State.ColorMode != "ct" || d.State.ColorTemperature != 2700 {
+		t.Errorf("after mode set ct: mode %q, ct %d", d.State.ColorMode, d.State.ColorTemperature)
+	}
+
+	// The effect is gone from the device, but still in the undo history.
+	if code := runCommandInProcess(client, []string{"mode", "set", "effect"}); code != 0 {
+		t.Fatalf("mode set effect: exit code = %d", code)
+	}
+	if effect := server.Device().Effect; effect != "Northern Lights" {
+		t.Errorf("after mode set effect: effect %q, want Northern Lights", effect)
+	}
+
+	if code := runCommandInProcess(client, []string{"mode", "set", "rgb"}); code != 1 {
+		t.Errorf("mode set rgb: exit code = %d, want 1", code)
+	}
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// nightlightFile is the name of the nightlight settings file in the state
+// directory.
+const nightlightFile = "nightlight.json"
+
+// nightlightCheckInterval is how often nightlight watch checks the state,
+// to catch scheduled windows starting.
+const nightlightCheckInterval = time.Minute
+
+// nightlightSetting is a device's nightlight mode: on, off, or schedule.
+type nightlightSetting struct {
+	Mode  string `json:"mode"`
+	Hours string `json:"hours,omitempty"` // for schedule
+}
+
+// activeAt reports whether the nightlight applies at time t.
+func (s nightlightSetting) activeAt(t time.Time) bool {
+	switch s.Mode {
+	case "on":
+		return true
+	case "schedule":
+		hours, err := parseHourRange(s.Hours)
+		return err == nil && hours.Contains(t)
+	}
+	return false
+}
+
+func (s nightlightSetting) String() string {
+	if s.Mode == "schedule" {
+		return "schedule " + s.Hours
+	}
+	if s.Mode == "" {
+		return "off"
+	}
+	return s.Mode
+}
+
+// nightlightLimits are what the nightlight allows: brightness up to
+// MaxBrightness, and colors no cooler than Temperature, in kelvin.
+type nightlightLimits struct {
+	MaxBrightness int
+	Temperature   int
+}
+
+// loadNightlightLimits reads the limits from the `[nightlight]` section.
+func loadNightlightLimits() nightlightLimits {
+	section := cfg.Section("nightlight")
+	return nightlightLimits{
+		MaxBrightness: section.Key("max_brightness").MustInt(30),
+		Temperature:   section.Key("temperature").MustInt(2200),
+	}
+}
+
+func nightlightPath() (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, nightlightFile), nil
+}
+
+// loadNightlight returns the nightlight settings, keyed by device name.
+func loadNightlight() (map[string]nightlightSetting, error) {
+	path, err := nightlightPath()
+	if err != nil {
+		return nil, err
+	}
+
+	settings := make(map[string]nightlightSetting)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return settings, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(data, &settings)
+	return settings, err
+}
+
+func saveNightlight(settings map[string]nightlightSetting) error {
+	path, err := nightlightPath()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+// isWarmHue reports whether a hue is a red, orange, or amber the nightlight
+// allows.
+func isWarmHue(hue int) bool {
+	return hue <= 45 || hue >= 330
+}
+
+// enforceNightlight dims the Nanoleaf and warms its color if they're past
+// the nightlight's limits. Effects are replaced with warm white.
+func enforceNightlight(client Client, limits nightlightLimits) error {
+	snapshot, err := client.Snapshot()
+	if err != nil || !snapshot.On {
+		return err
+	}
+
+	warm := false
+	switch snapshot.ColorMode {
+	case "ct":
+		warm = snapshot.ColorTemperature <= limits.Temperature
+	case "hs":
+		warm = isWarmHue(snapshot.Hue)
+	}
+	if !warm {
+		caps, err := client.Capabilities()
+		if err != nil {
+			return err
+		}
+		switch {
+		case caps.ColorTemperature.check("temperature", limits.Temperature) == nil:
+			err = client.SetColorTemperature(limits.Temperature)
+		case caps.FullColor:
+			err = client.EmulateColorTemperature(limits.Temperature)
+		default:
+			err = client.SetColorTemperature(caps.ColorTemperature.Min)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if snapshot.Brightness > limits.MaxBrightness {
+		return client.SetBrightness(limits.MaxBrightness)
+	}
+	return nil
+}
+
+// applyNightlight enforces the current device's nightlight, if it's active.
+// runCommand calls it after every change, so later color commands can't
+// escape it. Failures are logged, since they shouldn't fail the command.
+func applyNightlight(client Client) {
+	settings, err := loadNightlight()
+	if err == nil && settings[currentDeviceName()].activeAt(time.Now()) {
+		err = enforceNightlight(client, loadNightlightLimits())
+	}
+	if err != nil {
+		slog.Warn("failed to apply nightlight", "err", err)
+	}
+}
+
+// doNightlightCommand turns the nightlight on or off, schedules it, or
+// enforces it against changes from other apps until interrupted.
+func doNightlightCommand(client Client, args []string) {
+	usage := func() {
+		fmt.Println("usage: picoleaf nightlight [status]")
+		fmt.Println("       picoleaf nightlight on|off")
+		fmt.Println("       picoleaf nightlight schedule <HH:MM-HH:MM>")
+		fmt.Println("       picoleaf nightlight watch")
+		exit(1)
+	}
+
+	command := "status"
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
+	}
+
+	settings, err := loadNightlight()
+	if err != nil {
+		fmt.Println("error: failed to read nightlight settings:", err)
+		exit(1)
+	}
+	device := currentDeviceName()
+
+	switch command {
+	case "status":
+		if len(args) > 0 {
+			usage()
+		}
+		setting := settings[device]
+		if setting.activeAt(time.Now()) {
+			fmt.Println(setting, "(active)")
+		} else {
+			fmt.Println(setting)
+		}
+		return
+	case "on", "off":
+		if len(args) > 0 {
+			usage()
+		}
+		settings[device] = nightlightSetting{Mode: command}
+	case "schedule":
+		if len(args) != 1 {
+			usage()
+		}
+		if _, err := parseHourRange(args[0]); err != nil {
+			fmt.Println("error:", err)
+			exit(1)
+		}
+		settings[device] = nightlightSetting{Mode: command, Hours: args[0]}
+	case "watch":
+		if len(args) > 0 {
+			usage()
+		}
+		watchNightlight(client)
+		return
+	default:
+		usage()
+	}
+
+	// runCommand applies the nightlight after this, as after any change.
+	err = saveNightlight(settings)
+	if err != nil {
+		fmt.Println("error: failed to save nightlight settings:", err)
+		exit(1)
+	}
+}
+
+// watchNightlight enforces the nightlight whenever the Nanoleaf changes, and
+// when a scheduled window starts, until interrupted.
+func watchNightlight(client Client) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	changed := make(chan struct{}, 1)
+	go func() {
+		for {
+			err := client.Subscribe(ctx, []int{StateEvent, EffectsEvent}, func(Event) {
+				select {
+				case changed <- struct{}{}:
+				default:
+				}
+			})
+			if ctx.Err() != nil {
+				return
+			}
+			slog.Error("lost connection to event stream", "err", err)
+			if !sleepOrCancel(linkRetryInterval) {
+				return
+			}
+		}
+	}()
+
+	slog.Info("enforcing nightlight")
+	ticker := time.NewTicker(nightlightCheckInterval)
+	defer ticker.Stop()
+	for {
+		applyNightlight(client)
+		select {
+		case <-changed:
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
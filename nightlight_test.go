@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNightlightActiveAt(t *testing.T) {
+	night := time.Date(2024, 1, 1, 23, 30, 0, 0, time.Local)
+	day := time.Date(2024, 1, 1, 12, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		setting   nightlightSetting
+		night     bool
+		day       bool
+		formatted string
+	}{
+		{nightlightSetting{}, false, false, "off"},
+		{nightlightSetting{Mode: "on"}, true, true, "on"},
+		{nightlightSetting{Mode: "schedule", Hours: "22:00-07:00"}, true, false, "schedule 22:00-07:00"},
+	}
+	for _, tt := range tests {
+		if got := tt.setting.activeAt(night); got != tt.night {
+			t.Errorf("%v.activeAt(23:30) = %v, want %v", tt.setting, got, tt.night)
+		}
+		if got := tt.setting.activeAt(day); got != tt.day {
+			t.Errorf("%v.activeAt(12:00) = %v, want %v", tt.setting, got, tt.day)
+		}
+		if got := tt.setting.String(); got != tt.formatted {
+			t.Errorf("String() = %q, want %q", got, tt.formatted)
+		}
+	}
+}
+
+func TestNightlightCorrectsLaterCommands(t *testing.T) {
+	client, server := newTestClient(t)
+	t.Setenv("HOME", t.TempDir())
+	setTestConfig(t, "[nightlight]\nmax_brightness = 20\ntemperature = 2000\n")
+
+	for _, args := range [][]string{{"on"}, {"nightlight", "on"}, {"rgb", "0", "0", "255"}} {
+		if code := runCommandInProcess(client, args); code != 0 {
+			t.Fatalf("%v: exit code = %d", args, code)
+		}
+	}
+	state := server.Device().State
+	if state.ColorMode != "ct" || state.ColorTemperature != 2000 || state.Brightness != 20 {
+		t.Errorf("state = %s mode, ct %d, brightness %d, want ct 2000 at brightness 20", state.ColorMode, state.ColorTemperature, state.Brightness)
+	}
+
+	for _, args := range [][]string{{"nightlight", "off"}, {"rgb", "0", "0", "255"}} {
+		if code := runCommandInProcess(client, args); code != 0 {
+			t.Fatalf("%v: exit code = %d", args, code)
+		}
+	}
+	if state := server.Device().State; state.ColorMode != "hs" || state.Hue != 240 {
+		t.Errorf("state = %s mode, hue %d, want blue once the nightlight is off", state.ColorMode, state.Hue)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+)
+
+func doNotifyCommand(client Client, args []string) {
+	flags := flag.NewFlagSet("notify", flag.ExitOnError)
+	colorName := flags.String("color", "red", "Flash color (name or #rrggbb)")
+	times := flags.Int("times", 3, "Number of flashes")
+	interval := flags.Duration("interval", 500*time.Millisecond, "Time the light stays on (and off) per flash")
+	brightness := flags.Int("brightness", 100, "Flash brightness")
+	flags.Usage = func() {
+		fmt.Println("usage: picoleaf notify [--color <color>] [--times <n>] [--interval <duration>] [--brightness <brightness>]")
+		exit(1)
+	}
+	flags.Parse(args)
+
+	if flags.NArg() > 0 || *times < 1 || *interval <= 0 {
+		flags.Usage()
+	}
+	if *brightness < 0 || *brightness > 100 {
+		fmt.Println("error: brightness must be an integer 0-100")
+		exit(1)
+	}
+
+	color, err := parseColor(*colorName)
+	if err != nil {
+		fmt.Println("error:", err)
+		exit(1)
+	}
+
+	snapshot, err := client.Snapshot()
+	if err != nil {
+		fmt.Println("error: failed to get Nanoleaf state:", err)
+		exit(1)
+	}
+
+	flashErr := flash(client, color, *brightness, *times, *interval)
+	if flashErr != nil {
+		fmt.Println("error: failed to flash Nanoleaf:", flashErr)
+	}
+
+	err = client.Restore(*snapshot)
+	if err != nil {
+		fmt.Println("error: failed to restore Nanoleaf state:", err)
+		exit(1)
+	}
+	if flashErr != nil {
+		exit(1)
+	}
+}
+
+// flash blinks the Nanoleaf in the given color. It leaves the light off.
+func flash(client Client, color RGB, brightness int, times int, interval time.Duration) error {
+	hue, sat, _ := RGBToHSL(color)
+	for i := 0; i < times; i++ {
+		err := client.SetHSL(hue, sat, brightness)
+		if err != nil {
+			return err
+		}
+		err = client.On()
+		if err != nil {
+			return err
+		}
+		time.Sleep(interval)
+
+		err = client.Off()
+		if err != nil {
+			return err
+		}
+		time.Sleep(interval)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestNotifyCommand(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	client, server := newTestClient(t)
+	before := server.Device().State
+
+	code := runCommandInProcess(client, []string{"notify", "--color", "blue", "--times", "2", "--interval", "1ms", "--brightness", "80"})
+	if code != 0 {
+		t.Fatalf("notify exited with %d", code)
+	}
+
+	var flashes int
+	for _, req := range server.Requests() {
+		if req.Method == http.MethodPut && strings.Contains(req.Body, `"hue":{"value":240}`) {
+			flashes++
+		}
+	}
+	if flashes != 2 {
+		t.Errorf("flashed blue %d times, want 2", flashes)
+	}
+
+	after := server.Device().State
+	if after.On != before.On || after.Brightness != before.Brightness || after.Hue != before.Hue || after.Saturation != before.Saturation {
+		t.Errorf("state after notify = %+v, want it restored to %+v", after, before)
+	}
+}
+
+func TestNotifyCommandUsage(t *testing.T) {
+	client, _ := newTestClient(t)
+	for _, args := range [][]string{
+		{"notify", "--times", "0"},
+		{"notify", "--interval", "0s"},
+		{"notify", "--brightness", "101"},
+		{"notify", "--color", "nope"},
+		{"notify", "extra"},
+	} {
+		if code := runCommandInProcess(client, args); code != 1 {
+			t.Errorf("%q exited with %d, want 1", args, code)
+		}
+	}
+}
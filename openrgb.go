@@ -0,0 +1,339 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"strconv"
+	"sync"
+)
+
+// OpenRGBPort is the default OpenRGB SDK server port.
+const OpenRGBPort = 6742
+
+// OpenRGB SDK packet IDs.
+const (
+	openRGBRequestControllerCount = 0
+	openRGBRequestControllerData  = 1
+	openRGBRequestProtocolVersion = 40
+	openRGBSetClientName          = 50
+	openRGBResizeZone             = 1000
+	openRGBUpdateLEDs             = 1050
+	openRGBUpdateZoneLEDs         = 1051
+	openRGBUpdateSingleLED        = 1052
+	openRGBSetCustomMode          = 1100
+	openRGBUpdateMode             = 1101
+)
+
+// openRGBProtocolVersion is the SDK protocol version this server speaks.
+// Version 0 is the oldest, which every client supports.
+const openRGBProtocolVersion = 0
+
+// OpenRGB controller description constants.
+const (
+	openRGBDeviceTypeLight     = 11
+	openRGBZoneTypeLinear      = 1
+	openRGBModeFlagPerLEDColor = 1 << 5
+	openRGBModeColorModePerLED = 1
+)
+
+// OpenRGB packet sizes.
+const (
+	openRGBHeaderLen           = 16
+	openRGBMaxPacketLen        = 1 << 20
+	openRGBBytesPerColor       = 4
+	openRGBUpdateLEDsHeaderLen = 6
+	openRGBUpdateZoneHeaderLen = 10
+	openRGBUpdateSingleLEDLen  = 8
+)
+
+// openRGBMagic starts every OpenRGB SDK packet.
+var openRGBMagic = []byte("ORGB")
+
+// openRGBServer exposes a Nanoleaf as a single OpenRGB controller, with one
+// LED per panel.
+type openRGBServer struct {
+	name   string
+	panels []PanelPosition
+	send   func([]SetPanelColor) error
+
+	mu     sync.Mutex
+	colors []RGB
+}
+
+func newOpenRGBServer(name string, panels []PanelPosition, send func([]SetPanelColor) error) *openRGBServer {
+	return &openRGBServer{
+		name:   name,
+		panels: panels,
+		send:   send,
+		colors: make([]RGB, len(panels)),
+	}
+}
+
+// serve handles one client connection until it closes.
+func (s *openRGBServer) serve(conn io.ReadWriter) error {
+	header := make([]byte, openRGBHeaderLen)
+	for {
+		_, err := io.ReadFull(conn, header)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(header[:4], openRGBMagic) {
+			return errors.New("invalid packet magic")
+		}
+
+		deviceIndex := binary.LittleEndian.Uint32(header[4:])
+		packetID := binary.LittleEndian.Uint32(header[8:])
+		size := binary.LittleEndian.Uint32(header[12:])
+		if size > openRGBMaxPacketLen {
+			return fmt.Errorf("packet too large (%d bytes)", size)
+		}
+
+		payload := make([]byte, size)
+		_, err = io.ReadFull(conn, payload)
+		if err != nil {
+			return err
+		}
+
+		var reply []byte
+		switch packetID {
+		case openRGBRequestControllerCount:
+			reply = binary.LittleEndian.AppendUint32(nil, 1)
+		case openRGBRequestControllerData:
+			if deviceIndex != 0 {
+				return fmt.Errorf("no controller %d", deviceIndex)
+			}
+			reply = s.controllerData()
+		case openRGBRequestProtocolVersion:
+			reply = binary.LittleEndian.AppendUint32(nil, openRGBProtocolVersion)
+		case openRGBUpdateLEDs:
+			err = s.updateLEDs(payload, openRGBUpdateLEDsHeaderLen)
+		case openRGBUpdateZoneLEDs:
+			err = s.updateLEDs(payload, openRGBUpdateZoneHeaderLen)
+		case openRGBUpdateSingleLED:
+			err = s.updateSingleLED(payload)
+		case openRGBSetClientName, openRGBResizeZone, openRGBSetCustomMode, openRGBUpdateMode:
+			// Nothing to do: there's only one zone and one mode.
+		default:
+			slog.Debug("ignoring OpenRGB packet", "id", packetID)
+		}
+		if err != nil {
+			return err
+		}
+
+		if reply != nil {
+			out := append([]byte(nil), openRGBMagic...)
+			out = binary.LittleEndian.AppendUint32(out, deviceIndex)
+			out = binary.LittleEndian.AppendUint32(out, packetID)
+			out = binary.LittleEndian.AppendUint32(out, uint32(len(reply)))
+			out = append(out, reply...)
+			_, err = conn.Write(out)
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// updateLEDs handles UPDATELEDS and UPDATEZONELEDS, whose payloads are a
+// header ending in a color count, then that many colors. There's only one
+// zone, so both start at the first LED.
+func (s *openRGBServer) updateLEDs(payload []byte, headerLen int) error {
+	if len(payload) < headerLen {
+		return errors.New("LED update too short")
+	}
+	count := int(binary.LittleEndian.Uint16(payload[headerLen-2:]))
+	if len(payload) < headerLen+count*openRGBBytesPerColor {
+		return errors.New("LED update too short")
+	}
+
+	s.mu.Lock()
+	for i := 0; i < count && i < len(s.colors); i++ {
+		c := payload[headerLen+i*openRGBBytesPerColor:]
+		s.colors[i] = RGB{Red: c[0], Green: c[1], Blue: c[2]}
+	}
+	s.mu.Unlock()
+
+	return s.flush()
+}
+
+// updateSingleLED handles UPDATESINGLELED.
+func (s *openRGBServer) updateSingleLED(payload []byte) error {
+	if len(payload) < openRGBUpdateSingleLEDLen {
+		return errors.New("LED update too short")
+	}
+	i := int(int32(binary.LittleEndian.Uint32(payload)))
+
+	s.mu.Lock()
+	if i >= 0 && i < len(s.colors) {
+		s.colors[i] = RGB{Red: payload[4], Green: payload[5], Blue: payload[6]}
+	}
+	s.mu.Unlock()
+
+	return s.flush()
+}
+
+// flush sends the current colors to the Nanoleaf.
+func (s *openRGBServer) flush() error {
+	s.mu.Lock()
+	frames := make([]SetPanelColor, len(s.panels))
+	for i, panel := range s.panels {
+		c := s.colors[i]
+		frames[i] = SetPanelColor{PanelID: uint16(panel.PanelID), Red: c.Red, Green: c.Green, Blue: c.Blue}
+	}
+	s.mu.Unlock()
+
+	return s.send(frames)
+}
+
+// controllerData describes the Nanoleaf as an OpenRGB controller with a
+// single "Direct" mode and a single zone, in protocol version 0 format.
+func (s *openRGBServer) controllerData() []byte {
+	appendString := func(b []byte, str string) []byte {
+		b = binary.LittleEndian.AppendUint16(b, uint16(len(str)+1))
+		b = append(b, str...)
+		return append(b, 0)
+	}
+	appendColors := func(b []byte, colors []RGB) []byte {
+		b = binary.LittleEndian.AppendUint16(b, uint16(len(colors)))
+		for _, c := range colors {
+			b = append(b, c.Red, c.Green, c.Blue, 0)
+		}
+		return b
+	}
+
+	s.mu.Lock()
+	colors := append([]RGB(nil), s.colors...)
+	s.mu.Unlock()
+
+	var b []byte
+	b = binary.LittleEndian.AppendUint32(b, openRGBDeviceTypeLight)
+	b = appendString(b, s.name)
+	b = appendString(b, "Nanoleaf controlled by picoleaf")
+	b = appendString(b, "")
+	b = appendString(b, "")
+	b = appendString(b, "picoleaf")
+
+	// Modes
+	b = binary.LittleEndian.AppendUint16(b, 1)
+	b = binary.LittleEndian.AppendUint32(b, 0) // active mode
+	b = appendString(b, "Direct")
+	b = binary.LittleEndian.AppendUint32(b, 0) // value
+	b = binary.LittleEndian.AppendUint32(b, openRGBModeFlagPerLEDColor)
+	for i := 0; i < 6; i++ {
+		b = binary.LittleEndian.AppendUint32(b, 0) // speed, color, and direction settings
+	}
+	b = binary.LittleEndian.AppendUint32(b, openRGBModeColorModePerLED)
+	b = appendColors(b, nil)
+
+	// Zones
+	b = binary.LittleEndian.AppendUint16(b, 1)
+	b = appendString(b, "Panels")
+	b = binary.LittleEndian.AppendUint32(b, openRGBZoneTypeLinear)
+	for i := 0; i < 3; i++ {
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(s.panels))) // min, max, and count
+	}
+	b = binary.LittleEndian.AppendUint16(b, 0) // no matrix map
+
+	// LEDs
+	b = binary.LittleEndian.AppendUint16(b, uint16(len(s.panels)))
+	for _, panel := range s.panels {
+		b = appendString(b, "Panel "+strconv.Itoa(panel.PanelID))
+		b = binary.LittleEndian.AppendUint32(b, uint32(panel.PanelID))
+	}
+
+	b = appendColors(b, colors)
+
+	// The data starts with its own size, including the size field.
+	return append(binary.LittleEndian.AppendUint32(nil, uint32(len(b)+4)), b...)
+}
+
+// doOpenRGBCommand runs an OpenRGB SDK server for the Nanoleaf, until
+// interrupted.
+func doOpenRGBCommand(client Client, args []string) {
+	flags := flag.NewFlagSet("openrgb", flag.ExitOnError)
+	listen := flags.String("listen", fmt.Sprintf("127.0.0.1:%d", OpenRGBPort), "Address to serve the OpenRGB SDK on")
+	order := flags.String("order", "layout", panelOrderUsage)
+	selection := flags.String("panels", "", "Panels to drive, e.g. x<300, row:2, nearest:250,400, or a zone (default all)")
+	flags.Usage = func() {
+		fmt.Println("usage: picoleaf openrgb [--listen <addr>] [--order <order>] [--panels <selection>]")
+		exit(1)
+	}
+	flags.Parse(args)
+
+	if flags.NArg() > 0 {
+		flags.Usage()
+	}
+
+	panelInfo, err := client.CachedPanelInfo()
+	if err != nil {
+		fmt.Println("error: failed to get Nanoleaf info:", err)
+		exit(1)
+	}
+	panels, err := selectPanels(panelInfo.PanelLayout.Layout.PositionData, *selection)
+	if err != nil {
+		fmt.Println("error:", err)
+		exit(1)
+	}
+	panels, err = orderPanels(panels, *order)
+	if err != nil {
+		fmt.Println("error:", err)
+		exit(1)
+	}
+
+	ln, err := net.Listen("tcp", *listen)
+	if err != nil {
+		fmt.Println("error: failed to listen:", err)
+		exit(1)
+	}
+	defer ln.Close()
+
+	ctx, restore := restoreOnInterrupt(client)
+	defer restore()
+
+	sink, err := openFrameSink(client, panelInfo)
+	if err != nil {
+		fmt.Println("error: failed to start external control:", err)
+		exit(1)
+	}
+	defer sink.Close()
+
+	name := panelInfo.Name
+	if name == "" {
+		name = "Nanoleaf"
+	}
+	server := newOpenRGBServer(name, panels, sink.Send)
+
+	// Close the listener on Ctrl-C, so the accept loop exits.
+	context.AfterFunc(ctx, func() { ln.Close() })
+
+	slog.Info("serving OpenRGB SDK", "addr", ln.Addr(), "leds", len(panels))
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			slog.Error("failed to accept connection", "err", err)
+			continue
+		}
+
+		go func() {
+			defer conn.Close()
+			slog.Info("OpenRGB client connected", "addr", conn.RemoteAddr())
+			err := server.serve(conn)
+			if err != nil {
+				slog.Error("OpenRGB client failed", "addr", conn.RemoteAddr(), "err", err)
+			}
+		}()
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+)
+
+// openRGBRequest builds an OpenRGB SDK packet.
+func openRGBRequest(packetID uint32, payload []byte) []byte {
+	b := append([]byte(nil), openRGBMagic...)
+	b = binary.LittleEndian.AppendUint32(b, 0)
+	b = binary.LittleEndian.AppendUint32(b, packetID)
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(payload)))
+	return append(b, payload...)
+}
+
+// readOpenRGBReply reads a reply packet, returning its ID and payload.
+func readOpenRGBReply(t *testing.T, r io.Reader) (uint32, []byte) {
+	t.Helper()
+
+	header := make([]byte, openRGBHeaderLen)
+	_, err := io.ReadFull(r, header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := make([]byte, binary.LittleEndian.Uint32(header[12:]))
+	_, err = io.ReadFull(r, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return binary.LittleEndian.Uint32(header[8:]), payload
+}
+
+func TestOpenRGBServer(t *testing.T) {
+	panels := []PanelPosition{{PanelID: 101}, {PanelID: 102}}
+	sent := make(chan []SetPanelColor, 1)
+	server := newOpenRGBServer("Test", panels, func(frames []SetPanelColor) error {
+		sent <- frames
+		return nil
+	})
+
+	client, conn := net.Pipe()
+	defer client.Close()
+	go func() {
+		defer conn.Close()
+		server.serve(conn)
+	}()
+
+	client.Write(openRGBRequest(openRGBRequestControllerCount, nil))
+	id, payload := readOpenRGBReply(t, client)
+	if id != openRGBRequestControllerCount || binary.LittleEndian.Uint32(payload) != 1 {
+		t.Errorf("controller count reply = %d %v, want 1", id, payload)
+	}
+
+	client.Write(openRGBRequest(openRGBRequestControllerData, nil))
+	_, payload = readOpenRGBReply(t, client)
+	if size := binary.LittleEndian.Uint32(payload); int(size) != len(payload) {
+		t.Errorf("controller data size = %d, want %d", size, len(payload))
+	}
+	if binary.LittleEndian.Uint32(payload[4:]) != openRGBDeviceTypeLight {
+		t.Errorf("device type = %d, want %d", binary.LittleEndian.Uint32(payload[4:]), openRGBDeviceTypeLight)
+	}
+
+	update := binary.LittleEndian.AppendUint32(nil, 0)
+	update = binary.LittleEndian.AppendUint16(update, 2)
+	update = append(update, 255, 0, 0, 0, 0, 0, 255, 0)
+	client.Write(openRGBRequest(openRGBUpdateLEDs, update))
+
+	want := []SetPanelColor{
+		{PanelID: 101, Red: 255},
+		{PanelID: 102, Blue: 255},
+	}
+	if got := <-sent; !reflect.DeepEqual(got, want) {
+		t.Errorf("frames = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// panelOrderUsage describes the --order flag shared by commands that map
+// pixels or channels onto panels.
+const panelOrderUsage = "Panel order: layout, x (left to right), y (bottom to top), chain (nearest neighbor), or angle (around the center); prefix with - to reverse"
+
+// orderPanels returns the panels in the order pixels should be assigned to
+// them:
+//
+//	layout  the Nanoleaf's own order
+//	x       left to right, then bottom to top
+//	y       bottom to top, then left to right
+//	chain   from the leftmost panel, each panel followed by its nearest
+//	        unvisited neighbor
+//	angle   counterclockwise around the layout's center, from 3 o'clock
+//
+// Any order can be reversed with a - prefix, e.g. "-y" is top to bottom.
+// Ties are broken by layout order, so the result is deterministic.
+func orderPanels(panels []PanelPosition, order string) ([]PanelPosition, error) {
+	reverse := strings.HasPrefix(order, "-")
+	order = strings.TrimPrefix(order, "-")
+
+	ordered := append([]PanelPosition(nil), panels...)
+	switch order {
+	case "layout":
+	case "x":
+		sort.SliceStable(ordered, func(i, j int) bool {
+			if ordered[i].X != ordered[j].X {
+				return ordered[i].X < ordered[j].X
+			}
+			return ordered[i].Y < ordered[j].Y
+		})
+	case "y":
+		sort.SliceStable(ordered, func(i, j int) bool {
+			if ordered[i].Y != ordered[j].Y {
+				return ordered[i].Y < ordered[j].Y
+			}
+			return ordered[i].X < ordered[j].X
+		})
+	case "chain":
+		ordered = chainPanels(ordered)
+	case "angle":
+		ordered = anglePanels(ordered)
+	default:
+		return nil, fmt.Errorf("unknown panel order %q", order)
+	}
+
+	if reverse {
+		for i, j := 0, len(ordered)-1; i < j; i, j = i+1, j-1 {
+			ordered[i], ordered[j] = ordered[j], ordered[i]
+		}
+	}
+	return ordered, nil
+}
+
+// chainPanels orders panels as a path, starting from the leftmost panel and
+// stepping to the nearest unvisited panel each time.
+func chainPanels(panels []PanelPosition) []PanelPosition {
+	if len(panels) == 0 {
+		return panels
+	}
+
+	remaining, _ := orderPanels(panels, "x")
+	chain := []PanelPosition{remaining[0]}
+	remaining = remaining[1:]
+	for len(remaining) > 0 {
+		last := chain[len(chain)-1]
+		nearest := 0
+		for i, p := range remaining {
+			if panelDistance(last, p) < panelDistance(last, remaining[nearest]) {
+				nearest = i
+			}
+		}
+		chain = append(chain, remaining[nearest])
+		remaining = append(remaining[:nearest], remaining[nearest+1:]...)
+	}
+	return chain
+}
+
+// anglePanels orders panels counterclockwise around their centroid,
+// starting from 3 o'clock. Panels at the same angle are ordered from the
+// center outwards.
+func anglePanels(panels []PanelPosition) []PanelPosition {
+	var center PanelPosition
+	for _, p := range panels {
+		center.X += p.X
+		center.Y += p.Y
+	}
+	if len(panels) > 0 {
+		center.X /= len(panels)
+		center.Y /= len(panels)
+	}
+
+	angle := func(p PanelPosition) float64 {
+		a := math.Atan2(float64(p.Y-center.Y), float64(p.X-center.X))
+		if a < 0 {
+			a += 2 * math.Pi
+		}
+		return a
+	}
+	sort.SliceStable(panels, func(i, j int) bool {
+		ai, aj := angle(panels[i]), angle(panels[j])
+		if ai != aj {
+			return ai < aj
+		}
+		return panelDistance(center, panels[i]) < panelDistance(center, panels[j])
+	})
+	return panels
+}
+
+// panelDistance returns the squared distance between two panels.
+func panelDistance(a, b PanelPosition) int {
+	dx, dy := a.X-b.X, a.Y-b.Y
+	return dx*dx + dy*dy
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOrderPanels(t *testing.T) {
+	panels := []PanelPosition{
+		{PanelID: 1, X: 200, Y: 0},
+		{PanelID: 2, X: 0, Y: 100},
+		{PanelID: 3, X: 100, Y: 50},
+	}
+
+	tests := []struct {
+		order string
+		want  []int
+	}{
+		{"layout", []int{1, 2, 3}},
+		{"x", []int{2, 3, 1}},
+		{"y", []int{1, 3, 2}},
+		{"-y", []int{2, 3, 1}},
+		{"-layout", []int{3, 2, 1}},
+	}
+	for _, tt := range tests {
+		ordered, err := orderPanels(panels, tt.order)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []int
+		for _, p := range ordered {
+			ids = append(ids, p.PanelID)
+		}
+		if !reflect.DeepEqual(ids, tt.want) {
+			t.Errorf("orderPanels(%q) = %v, want %v", tt.order, ids, tt.want)
+		}
+	}
+
+	if _, err := orderPanels(panels, "spiral"); err == nil {
+		t.Error("orderPanels(spiral) succeeded, want error")
+	}
+}
+
+func TestOrderPanelsSpatial(t *testing.T) {
+	// A 3x2 grid, numbered in an arbitrary layout order:
+	//
+	//	4 6 5
+	//	1 3 2
+	panels := []PanelPosition{
+		{PanelID: 1, X: 0, Y: 0},
+		{PanelID: 2, X: 200, Y: 0},
+		{PanelID: 3, X: 100, Y: 0},
+		{PanelID: 4, X: 0, Y: 100},
+		{PanelID: 5, X: 200, Y: 100},
+		{PanelID: 6, X: 100, Y: 100},
+	}
+
+	tests := []struct {
+		order string
+		want  []int
+	}{
+		// From 1, ties between equally near panels go to the leftmost.
+		{"chain", []int{1, 4, 6, 3, 2, 5}},
+		// Around (100, 50), starting at 3 o'clock.
+		{"angle", []int{5, 6, 4, 1, 3, 2}},
+	}
+	for _, tt := range tests {
+		ordered, err := orderPanels(panels, tt.order)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []int
+		for _, p := range ordered {
+			ids = append(ids, p.PanelID)
+		}
+		if !reflect.DeepEqual(ids, tt.want) {
+			t.Errorf("orderPanels(%q) = %v, want %v", tt.order, ids, tt.want)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"sync"
+	"time"
+)
+
+// defaultMaxFrameRate is used for models without a known safe frame rate.
+const defaultMaxFrameRate = 10
+
+// maxFrameRates are conservative external control frame rates, by model.
+// The original Light Panels (Aurora) controller drops packets above ~10 fps;
+// newer controllers keep up with much faster streams.
+var maxFrameRates = map[string]int{
+	"NL22": 10, // Light Panels (Aurora)
+	"NL29": 30, // Canvas
+	"NL42": 30, // Shapes Hexagons
+	"NL45": 30, // Shapes Triangles
+	"NL47": 30, // Shapes Mini Triangles
+	"NL48": 30, // Shapes Controller
+	"NL52": 30, // Elements
+	"NL59": 30, // Lines
+}
+
+// MaxFrameRate returns the safe external control frame rate for a model.
+func MaxFrameRate(model string) int {
+	if fps, ok := maxFrameRates[model]; ok {
+		return fps
+	}
+	return defaultMaxFrameRate
+}
+
+// Pacer limits the rate of external control frames. Frames submitted faster
+// than the rate are coalesced, so only the most recent is sent, and frames
+// identical to the last one sent are dropped.
+type Pacer struct {
+	interval time.Duration
+	write    func([]byte) error
+
+	mu       sync.Mutex
+	last     []byte
+	lastSent time.Time
+	pending  []byte
+	timer    *time.Timer
+	err      error
+}
+
+// NewPacer returns a pacer that passes at most fps frames per second to
+// write.
+func NewPacer(fps int, write func([]byte) error) *Pacer {
+	return &Pacer{
+		interval: time.Second / time.Duration(fps),
+		write:    write,
+	}
+}
+
+// Send submits a frame. It returns the first error from an earlier write,
+// since delayed frames are written in the background.
+func (p *Pacer) Send(frame []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.err != nil {
+		return p.err
+	}
+
+	if p.timer != nil {
+		p.pending = frame
+		return nil
+	}
+	if bytes.Equal(frame, p.last) {
+		return nil
+	}
+
+	wait := p.interval - time.Since(p.lastSent)
+	if wait <= 0 {
+		p.sendLocked(frame)
+		return p.err
+	}
+
+	p.pending = frame
+	p.timer = time.AfterFunc(wait, p.flush)
+	return nil
+}
+
+// Close sends any pending frame immediately, and returns the first write
+// error, if any.
+func (p *Pacer) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.timer != nil {
+		p.timer.Stop()
+		p.timer = nil
+	}
+	if p.pending != nil && p.err == nil && !bytes.Equal(p.pending, p.last) {
+		p.sendLocked(p.pending)
+	}
+	p.pending = nil
+	return p.err
+}
+
+func (p *Pacer) flush() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.timer == nil {
+		return // closed
+	}
+	p.timer = nil
+
+	frame := p.pending
+	p.pending = nil
+	if frame != nil && p.err == nil && !bytes.Equal(frame, p.last) {
+		p.sendLocked(frame)
+	}
+}
+
+func (p *Pacer) sendLocked(frame []byte) {
+	p.err = p.write(frame)
+	p.last = frame
+	p.lastSent = time.Now()
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+type frameRecorder struct {
+	mu     sync.Mutex
+	frames []string
+}
+
+func (r *frameRecorder) write(frame []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.frames = append(r.frames, string(frame))
+	return nil
+}
+
+func (r *frameRecorder) Frames() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.frames...)
+}
+
+func TestPacerCoalescesFrames(t *testing.T) {
+	var r frameRecorder
+	pacer := NewPacer(10, r.write)
+
+	for _, frame := range []string{"a", "b", "c", "d"} {
+		err := pacer.Send([]byte(frame))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The first frame goes out immediately; the rest are coalesced into the
+	// most recent one, which is sent after the frame interval.
+	if got := r.Frames(); len(got) != 1 || got[0] != "a" {
+		t.Fatalf("frames = %v, want [a]", got)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	if got := r.Frames(); len(got) != 2 || got[1] != "d" {
+		t.Fatalf("frames = %v, want [a d]", got)
+	}
+
+	err := pacer.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPacerDropsDuplicateFrames(t *testing.T) {
+	var r frameRecorder
+	pacer := NewPacer(1000, r.write)
+
+	for i := 0; i < 3; i++ {
+		pacer.Send([]byte("same"))
+		time.Sleep(5 * time.Millisecond)
+	}
+	pacer.Close()
+
+	if got := r.Frames(); len(got) != 1 {
+		t.Errorf("frames = %v, want a single frame", got)
+	}
+}
+
+func TestPacerCloseFlushesPending(t *testing.T) {
+	var r frameRecorder
+	pacer := NewPacer(1, r.write)
+
+	pacer.Send([]byte("a"))
+	pacer.Send([]byte("b"))
+	err := pacer.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := r.Frames(); len(got) != 2 || got[1] != "b" {
+		t.Errorf("frames = %v, want [a b]", got)
+	}
+}
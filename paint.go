@@ -0,0 +1,266 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Size of the grid the layout is drawn on.
+const (
+	paintCols = 64
+	paintRows = 20
+)
+
+// nextPanel returns the index of the panel nearest to panels[current] in the
+// direction (dx, dy), or current if there's nothing that way. Panels off to
+// the side count as further away than panels straight ahead.
+func nextPanel(panels []PanelPosition, current int, dx, dy int) int {
+	from := panels[current]
+	best, bestScore := current, math.Inf(1)
+	for i, p := range panels {
+		x := float64(p.X - from.X)
+		y := float64(p.Y - from.Y)
+		ahead := x*float64(dx) + y*float64(dy)
+		if i == current || ahead <= 0 {
+			continue
+		}
+
+		side := math.Abs(x*float64(dy) - y*float64(dx))
+		score := ahead + 2*side
+		if score < bestScore {
+			best, bestScore = i, score
+		}
+	}
+	return best
+}
+
+// painter is the state of the layout editor.
+type painter struct {
+	panels  []PanelPosition
+	colors  map[int]RGB
+	cursor  int
+	palette []string
+	color   int
+	status  string
+}
+
+// newPainter returns an editor for the given layout, with every panel
+// black.
+func newPainter(panels []PanelPosition) *painter {
+	var palette []string
+	for name := range namedColors {
+		palette = append(palette, name)
+	}
+	sort.Strings(palette)
+
+	p := &painter{
+		panels:  panels,
+		colors:  make(map[int]RGB),
+		palette: palette,
+	}
+	for i, name := range palette {
+		if name == "white" {
+			p.color = i
+		}
+	}
+	for _, panel := range panels {
+		p.colors[panel.PanelID] = RGB{}
+	}
+	return p
+}
+
+// paint sets the panel under the cursor to c.
+func (p *painter) paint(c RGB) {
+	p.colors[p.panels[p.cursor].PanelID] = c
+}
+
+// frames returns the painted colors as external control frames.
+func (p *painter) frames() []SetPanelColor {
+	frames := make([]SetPanelColor, len(p.panels))
+	for i, panel := range p.panels {
+		c := p.colors[panel.PanelID]
+		frames[i] = SetPanelColor{PanelID: uint16(panel.PanelID), Red: c.Red, Green: c.Green, Blue: c.Blue, TransitionTime: 1}
+	}
+	return frames
+}
+
+// render draws the layout, with each panel as a colored block and the cursor
+// in brackets, followed by the current color and key help.
+func (p *painter) render() string {
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, panel := range p.panels {
+		minX, maxX = math.Min(minX, float64(panel.X)), math.Max(maxX, float64(panel.X))
+		minY, maxY = math.Min(minY, float64(panel.Y)), math.Max(maxY, float64(panel.Y))
+	}
+	scale := func(v, min, max float64, size int) int {
+		if max == min {
+			return size / 2
+		}
+		return int(math.Round((v - min) / (max - min) * float64(size-1)))
+	}
+
+	grid := make([][]string, paintRows)
+	for i := range grid {
+		grid[i] = make([]string, paintCols+4)
+		for j := range grid[i] {
+			grid[i][j] = " "
+		}
+	}
+	for i, panel := range p.panels {
+		col := scale(float64(panel.X), minX, maxX, paintCols)
+		// Nanoleaf y coordinates grow upwards.
+		row := paintRows - 1 - scale(float64(panel.Y), minY, maxY, paintRows)
+		c := p.colors[panel.PanelID]
+
+		left, right := " ", " "
+		if i == p.cursor {
+			left, right = "[", "]"
+		}
+		grid[row][col] = left
+		grid[row][col+1] = fmt.Sprintf("\x1b[38;2;%d;%d;%dm██\x1b[0m", c.Red, c.Green, c.Blue)
+		grid[row][col+2] = ""
+		grid[row][col+3] = right
+	}
+
+	var b strings.Builder
+	b.WriteString("\x1b[H\x1b[2J")
+	for _, row := range grid {
+		b.WriteString(strings.TrimRight(strings.Join(row, ""), " "))
+		b.WriteString("\r\n")
+	}
+
+	name := p.palette[p.color]
+	c := namedColors[name]
+	fmt.Fprintf(&b, "\r\nColor: \x1b[38;2;%d;%d;%dm██\x1b[0m %s   Panel: %d\r\n", c.Red, c.Green, c.Blue, name, p.panels[p.cursor].PanelID)
+	b.WriteString("arrows move  [/] color  space paint  x clear  s save effect  w write file  q quit\r\n")
+	if p.status != "" {
+		b.WriteString(p.status + "\r\n")
+	}
+	return b.String()
+}
+
+// writeAnimation writes the painted colors as a single frame in the format
+// read by `effect stream`.
+func (p *painter) writeAnimation(path string) error {
+	var fields []string
+	for _, frame := range p.frames() {
+		fields = append(fields, fmt.Sprintf("%d %d %d %d %d", frame.PanelID, frame.Red, frame.Green, frame.Blue, frame.TransitionTime))
+	}
+	return os.WriteFile(path, []byte(strings.Join(fields, " ")+"\n"), 0644)
+}
+
+// doPaintCommand runs an interactive layout editor for painting individual
+// panels, previewing the result on the Nanoleaf as it changes.
+func doPaintCommand(client Client, args []string) {
+	flags := flag.NewFlagSet("paint", flag.ExitOnError)
+	name := flags.String("name", "Painted", "Effect name to save as")
+	out := flags.String("out", "painted.frames", "File to write the animation to")
+	flags.Usage = func() {
+		fmt.Println("usage: picoleaf paint [--name <effect name>] [--out <file>]")
+		exit(1)
+	}
+	flags.Parse(args)
+
+	if flags.NArg() > 0 {
+		flags.Usage()
+	}
+
+	panelInfo, err := client.GetPanelInfo()
+	if err != nil {
+		fmt.Println("error: failed to get Nanoleaf info:", err)
+		exit(1)
+	}
+	panels := panelInfo.PanelLayout.Layout.PositionData
+	if len(panels) == 0 {
+		fmt.Println("error: Nanoleaf has no panels")
+		exit(1)
+	}
+
+	snapshot, err := client.Snapshot()
+	if err != nil {
+		fmt.Println("error: failed to get Nanoleaf state:", err)
+		exit(1)
+	}
+
+	restoreTerminal, err := makeRaw()
+	if err != nil {
+		fmt.Println("error: failed to set up terminal:", err)
+		exit(1)
+	}
+
+	p := newPainter(panels)
+	saved := false
+	for {
+		fmt.Print(p.render())
+
+		key, err := readKey(os.Stdin)
+		if err != nil || key == keyEsc || key == keyCtrlC || key == "q" {
+			break
+		}
+
+		p.status = ""
+		changed := false
+		switch key {
+		case keyLeft:
+			p.cursor = nextPanel(panels, p.cursor, -1, 0)
+		case keyRight:
+			p.cursor = nextPanel(panels, p.cursor, 1, 0)
+		case keyUp:
+			p.cursor = nextPanel(panels, p.cursor, 0, 1)
+		case keyDown:
+			p.cursor = nextPanel(panels, p.cursor, 0, -1)
+		case "[":
+			p.color = (p.color + len(p.palette) - 1) % len(p.palette)
+		case "]":
+			p.color = (p.color + 1) % len(p.palette)
+		case " ", keyEnter:
+			p.paint(namedColors[p.palette[p.color]])
+			changed = true
+		case "x":
+			p.paint(RGB{})
+			changed = true
+		case "s":
+			err = client.AddStaticEffect(*name, p.colors)
+			if err != nil {
+				p.status = "error: failed to save effect: " + err.Error()
+			} else {
+				p.status = fmt.Sprintf("saved effect %q", *name)
+				saved = true
+			}
+		case "w":
+			err = p.writeAnimation(*out)
+			if err != nil {
+				p.status = "error: failed to write file: " + err.Error()
+			} else {
+				p.status = "wrote " + *out
+			}
+		}
+
+		if changed {
+			err = client.SetCustomColors(p.frames())
+			if err != nil {
+				p.status = "error: failed to preview: " + err.Error()
+			}
+		}
+	}
+
+	restoreTerminal()
+	fmt.Println()
+
+	// A saved effect is selected as the result; otherwise the preview is
+	// discarded.
+	if saved {
+		err = client.SelectEffect(*name)
+	} else {
+		err = client.Restore(*snapshot)
+	}
+	if err != nil {
+		fmt.Println("error: failed to restore Nanoleaf state:", err)
+		exit(1)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNextPanel(t *testing.T) {
+	//   3
+	// 0 1 2
+	panels := []PanelPosition{
+		{PanelID: 10, X: 0, Y: 0},
+		{PanelID: 11, X: 100, Y: 0},
+		{PanelID: 12, X: 200, Y: 10},
+		{PanelID: 13, X: 110, Y: 100},
+	}
+
+	tests := []struct {
+		current int
+		dx, dy  int
+		want    int
+	}{
+		{0, 1, 0, 1},
+		{1, 1, 0, 2},
+		{2, 1, 0, 2},
+		{1, 0, 1, 3},
+		{3, 0, -1, 1},
+		{1, -1, 0, 0},
+	}
+	for _, tt := range tests {
+		got := nextPanel(panels, tt.current, tt.dx, tt.dy)
+		if got != tt.want {
+			t.Errorf("nextPanel(%d, %d, %d) = %d, want %d", tt.current, tt.dx, tt.dy, got, tt.want)
+		}
+	}
+}
+
+func TestFormatAnimData(t *testing.T) {
+	colors := map[int]RGB{
+		102: {Red: 1, Green: 2, Blue: 3},
+		101: {Red: 255},
+	}
+
+	data := formatAnimData(colors)
+	if want := "2 101 1 255 0 0 0 1 102 1 1 2 3 0 1"; data != want {
+		t.Errorf("formatAnimData() = %q, want %q", data, want)
+	}
+
+	parsed, err := parseAnimData(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(parsed, colors) {
+		t.Errorf("parseAnimData(formatAnimData()) = %v, want %v", parsed, colors)
+	}
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"math"
+	"os"
+	"sort"
+	"strings"
+)
+
+// paletteSection holds named palettes in the config file, e.g.
+//
+//	[palette]
+//	beach = #f4d35e,#0d3b66,#faf0ca
+const paletteSection = "palette"
+
+// builtinPalettes are available by name unless the config file defines a
+// palette with the same name.
+var builtinPalettes = map[string]string{
+	"fire":    "#ff0000,#ff4500,#ff8c00,#ffd700",
+	"forest":  "#013220,#228b22,#6b8e23,#daa520",
+	"ice":     "#ffffff,#d6f4ff,#87cefa,#4682b4",
+	"ocean":   "#000080,#0060ff,#00bfff,#40e0d0",
+	"pastel":  "#ffb3ba,#ffdfba,#ffffba,#baffc9,#bae1ff",
+	"rainbow": "#ff0000,#ff8000,#ffff00,#00ff00,#0000ff,#8000ff",
+	"sunset":  "#ff5e3a,#ff9500,#ffcc00,#c644fc",
+}
+
+// lookupPalette returns the named palette's colors, as a comma-separated
+// list, from the config file or the built-in palettes.
+func lookupPalette(name string) (string, bool) {
+	if cfg.Section(paletteSection).HasKey(name) {
+		return cfg.Section(paletteSection).Key(name).String(), true
+	}
+	colors, ok := builtinPalettes[name]
+	return colors, ok
+}
+
+// parsePalette parses a comma-separated list of colors, each a name or
+// #rrggbb, or the name of a palette.
+func parsePalette(s string) ([]RGB, error) {
+	if !strings.Contains(s, ",") {
+		if _, err := parseColor(s); err != nil {
+			colors, ok := lookupPalette(s)
+			if !ok {
+				return nil, fmt.Errorf("unknown palette or color %q", s)
+			}
+			s = colors
+		}
+	}
+
+	var palette []RGB
+	for _, item := range strings.Split(s, ",") {
+		c, err := parseColor(strings.TrimSpace(item))
+		if err != nil {
+			return nil, err
+		}
+		palette = append(palette, c)
+	}
+	return palette, nil
+}
+
+// paletteNames returns the names of all palettes, built-in and configured,
+// sorted.
+func paletteNames() []string {
+	names := cfg.Section(paletteSection).KeyStrings()
+	for name := range builtinPalettes {
+		if !cfg.Section(paletteSection).HasKey(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// paletteSampleSize bounds the number of pixels sampled from an image.
+const paletteSampleSize = 10000
+
+// extractPalette returns up to n dominant colors in img, most common first.
+// It uses median cut: the sampled pixels are repeatedly split at the median
+// of the box with the widest channel range, and each box is averaged.
+func extractPalette(img image.Image, n int) []RGB {
+	bounds := img.Bounds()
+	step := int(math.Max(1, math.Sqrt(float64(bounds.Dx()*bounds.Dy())/paletteSampleSize)))
+
+	var pixels []RGB
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += step {
+		for x := bounds.Min.X; x < bounds.Max.X; x += step {
+			r, g, b, a := img.At(x, y).RGBA()
+			if a < 0x8000 {
+				continue // mostly transparent
+			}
+			pixels = append(pixels, RGB{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8)})
+		}
+	}
+	if len(pixels) == 0 {
+		return nil
+	}
+
+	boxes := [][]RGB{pixels}
+	for len(boxes) < n {
+		widest, channel, widestRange := -1, 0, 0
+		for i, box := range boxes {
+			if len(box) < 2 {
+				continue
+			}
+			c, r := widestChannel(box)
+			if r > widestRange {
+				widest, channel, widestRange = i, c, r
+			}
+		}
+		if widest < 0 {
+			break // every box is a single color
+		}
+
+		box := boxes[widest]
+		value := func(i int) uint8 { return rgbChannel(box[i], channel) }
+		sort.Slice(box, func(i, j int) bool { return value(i) < value(j) })
+
+		// Split at the median, moved to the nearest change in value so
+		// identical pixels stay together.
+		mid := len(box) / 2
+		lo, hi := mid, mid
+		for value(lo-1) == value(lo) && value(hi-1) == value(hi) {
+			if lo > 1 {
+				lo--
+			}
+			if hi < len(box)-1 {
+				hi++
+			}
+		}
+		if value(lo-1) != value(lo) {
+			mid = lo
+		} else {
+			mid = hi
+		}
+		boxes[widest] = box[:mid]
+		boxes = append(boxes, box[mid:])
+	}
+
+	sort.SliceStable(boxes, func(i, j int) bool { return len(boxes[i]) > len(boxes[j]) })
+	palette := make([]RGB, len(boxes))
+	for i, box := range boxes {
+		palette[i] = averageColor(box)
+	}
+	return palette
+}
+
+// widestChannel returns the channel (0-2 for red, green, blue) with the
+// largest range of values in pixels, and the range.
+func widestChannel(pixels []RGB) (int, int) {
+	widest, widestRange := 0, -1
+	for c := 0; c < 3; c++ {
+		lo, hi := 255, 0
+		for _, p := range pixels {
+			v := int(rgbChannel(p, c))
+			lo, hi = min(lo, v), max(hi, v)
+		}
+		if hi-lo > widestRange {
+			widest, widestRange = c, hi-lo
+		}
+	}
+	return widest, widestRange
+}
+
+func rgbChannel(c RGB, channel int) uint8 {
+	switch channel {
+	case 0:
+		return c.Red
+	case 1:
+		return c.Green
+	}
+	return c.Blue
+}
+
+func averageColor(pixels []RGB) RGB {
+	var r, g, b int
+	for _, p := range pixels {
+		r += int(p.Red)
+		g += int(p.Green)
+		b += int(p.Blue)
+	}
+	n := len(pixels)
+	return RGB{uint8((r + n/2) / n), uint8((g + n/2) / n), uint8((b + n/2) / n)}
+}
+
+// formatPalette formats colors as a comma-separated #rrggbb list, the format
+// --palette accepts.
+func formatPalette(palette []RGB) string {
+	colors := make([]string, len(palette))
+	for i, c := range palette {
+		colors[i] = fmt.Sprintf("#%02x%02x%02x", c.Red, c.Green, c.Blue)
+	}
+	return strings.Join(colors, ",")
+}
+
+func doPaletteCommand(client Client, args []string) {
+	usage := func() {
+		fmt.Println("usage: picoleaf palette from-image <file> [--colors <n>] [--save <name>] [--apply <plugin>] [--name <effect>]")
+		fmt.Println("       picoleaf palette list")
+		exit(1)
+	}
+
+	if len(args) < 1 {
+		usage()
+	}
+
+	switch args[0] {
+	case "from-image":
+		doPaletteFromImageCommand(client, args[1:])
+	case "list":
+		for _, name := range paletteNames() {
+			colors, _ := lookupPalette(name)
+			fmt.Printf("%s = %s\n", name, colors)
+		}
+	default:
+		usage()
+	}
+}
+
+// doPaletteFromImageCommand extracts a palette from an image, then prints,
+// saves, or applies it.
+func doPaletteFromImageCommand(client Client, args []string) {
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		fmt.Println("usage: picoleaf palette from-image <file> [--colors <n>] [--save <name>] [--apply <plugin>] [--name <effect>]")
+		exit(1)
+	}
+	path := args[0]
+
+	flags := flag.NewFlagSet("from-image", flag.ExitOnError)
+	colors := flags.Int("colors", 5, "Number of colors to extract (1-16)")
+	save := flags.String("save", "", "Save the palette in the config file under this name")
+	apply := flags.String("apply", "", "Create and select an effect from the palette with this plugin, e.g. flow or wheel")
+	name := flags.String("name", "Palette", "Name of the effect created by --apply")
+	flags.Usage = func() {
+		fmt.Println("usage: picoleaf palette from-image <file> [--colors <n>] [--save <name>] [--apply <plugin>] [--name <effect>]")
+		exit(1)
+	}
+	flags.Parse(args[1:])
+
+	if flags.NArg() > 0 || *colors < 1 || *colors > 16 {
+		flags.Usage()
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		fmt.Println("error: failed to open image:", err)
+		exit(1)
+	}
+	img, _, err := image.Decode(f)
+	f.Close()
+	if err != nil {
+		fmt.Println("error: failed to decode image:", err)
+		exit(1)
+	}
+
+	palette := extractPalette(img, *colors)
+	if len(palette) == 0 {
+		fmt.Println("error: image has no opaque pixels")
+		exit(1)
+	}
+	fmt.Println(formatPalette(palette))
+
+	if *save != "" {
+		cfg.Section(paletteSection).Key(*save).SetValue(formatPalette(palette))
+		err := cfg.SaveTo(configFilePath)
+		if err != nil {
+			fmt.Println("error: failed to save palette:", err)
+			exit(1)
+		}
+	}
+
+	if *apply != "" {
+		err := client.AddPluginEffect(PluginEffect{Name: *name, Plugin: *apply, Palette: palette})
+		if err != nil {
+			fmt.Println("error: failed to create effect:", err)
+			exit(1)
+		}
+		err = client.SelectEffect(*name)
+		if err != nil {
+			fmt.Println("error: failed to select effect:", err)
+			exit(1)
+		}
+	}
+}
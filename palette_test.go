@@ -0,0 +1,73 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"reflect"
+	"testing"
+)
+
+func TestExtractPalette(t *testing.T) {
+	// Half red, a third blue, and the rest green.
+	img := image.NewRGBA(image.Rect(0, 0, 60, 10))
+	for x := 0; x < 60; x++ {
+		c := color.RGBA{0, 200, 0, 255}
+		switch {
+		case x < 30:
+			c = color.RGBA{255, 0, 0, 255}
+		case x < 50:
+			c = color.RGBA{0, 0, 255, 255}
+		}
+		for y := 0; y < 10; y++ {
+			img.Set(x, y, c)
+		}
+	}
+
+	got := extractPalette(img, 3)
+	want := []RGB{{255, 0, 0}, {0, 0, 255}, {0, 200, 0}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("extractPalette = %v, want %v", got, want)
+	}
+	if s := formatPalette(got); s != "#ff0000,#0000ff,#00c800" {
+		t.Errorf("formatPalette = %q", s)
+	}
+
+	// Asking for more colors than the image has stops at the distinct ones.
+	if got := extractPalette(img, 8); len(got) != 3 {
+		t.Errorf("extractPalette(8) returned %d colors, want 3", len(got))
+	}
+}
+
+func TestParseNamedPalette(t *testing.T) {
+	setTestConfig(t, "[palette]\nsunset = #ff5e3a,#ffcc00 ; warm\n")
+
+	got, err := parsePalette("sunset")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []RGB{{0xff, 0x5e, 0x3a}, {0xff, 0xcc, 0x00}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("parsePalette(sunset) = %v, want %v", got, want)
+	}
+
+	got, err = parsePalette("fire")
+	if err != nil || len(got) != 4 {
+		t.Errorf("parsePalette(fire) = %v, %v, want the built-in palette", got, err)
+	}
+
+	if _, err := parsePalette("sunrise"); err == nil {
+		t.Error("parsePalette accepted an unknown palette")
+	}
+
+	names := paletteNames()
+	if len(names) != len(builtinPalettes) || names[len(names)-1] != "sunset" {
+		t.Errorf("paletteNames() = %v, want the built-ins with sunset once", names)
+	}
+}
+
+func TestBuiltinPalettes(t *testing.T) {
+	for name, colors := range builtinPalettes {
+		if _, err := parsePalette(colors); err != nil {
+			t.Errorf("palette %s: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// currentPanelColors returns the colors the panels are showing, keyed by
+// panel ID, before global brightness is applied. Streamed colors are only
+// known if they were sent through this client, e.g. earlier in a REPL.
+func currentPanelColors(client Client, info *PanelInfo) (map[int]RGB, error) {
+	state := info.State
+	var uniform RGB
+	switch state.ColorMode {
+	case "hs":
+		var hue, sat int
+		if state.Hue != nil {
+			hue = state.Hue.Value
+		}
+		if state.Saturation != nil {
+			sat = state.Saturation.Value
+		}
+		uniform = HSVToRGB(hue, sat, 100)
+	case "ct":
+		ct := 0
+		if state.ColorTemperature != nil {
+			ct = state.ColorTemperature.Value
+		}
+		uniform = KelvinToRGB(ct)
+	default:
+		effect := info.Effects.Selected
+		if effect == "*ExtControl*" {
+			if colors := client.StreamedColors(); colors != nil {
+				return colors, nil
+			}
+			return nil, errors.New("panels are being streamed to by another program")
+		}
+		if isPlaceholderEffect(effect) {
+			return nil, fmt.Errorf("unknown panel colors for %s", effect)
+		}
+		colors, err := client.EffectColors(effect)
+		if err == errDynamicEffect {
+			return nil, fmt.Errorf("effect %q is animated, so its colors change", effect)
+		}
+		return colors, err
+	}
+
+	colors := make(map[int]RGB)
+	for _, p := range info.PanelLayout.Layout.PositionData {
+		if p.ShapeType != shapeShapesController {
+			colors[p.PanelID] = uniform
+		}
+	}
+	return colors, nil
+}
+
+// panelColor is a panel's color, as printed by `panel colors --json`.
+type panelColor struct {
+	PanelID int   `json:"panelId"`
+	Red     uint8 `json:"red"`
+	Green   uint8 `json:"green"`
+	Blue    uint8 `json:"blue"`
+}
+
+// sortedPanelColors orders panel colors by panel ID.
+func sortedPanelColors(colors map[int]RGB) []panelColor {
+	sorted := make([]panelColor, 0, len(colors))
+	for id, c := range colors {
+		sorted = append(sorted, panelColor{PanelID: id, Red: c.Red, Green: c.Green, Blue: c.Blue})
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].PanelID < sorted[j].PanelID })
+	return sorted
+}
+
+// doPanelColorsCommand prints the color of each panel.
+func doPanelColorsCommand(client Client, args []string) {
+	flags := flag.NewFlagSet("colors", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "Print the colors as JSON")
+	flags.Usage = func() {
+		fmt.Println("usage: picoleaf panel colors [--json]")
+		exit(1)
+	}
+	flags.Parse(args)
+
+	if flags.NArg() > 0 {
+		flags.Usage()
+	}
+
+	panelInfo, err := client.GetPanelInfo()
+	if err != nil {
+		fmt.Println("error: failed to get Nanoleaf state:", err)
+		exit(1)
+	}
+	colors, err := currentPanelColors(client, panelInfo)
+	if err != nil {
+		fmt.Println("error:", err)
+		exit(1)
+	}
+
+	sorted := sortedPanelColors(colors)
+	if *asJSON {
+		data, err := json.MarshalIndent(sorted, "", "  ")
+		if err != nil {
+			fmt.Println("error:", err)
+			exit(1)
+		}
+		fmt.Println(string(data))
+		return
+	}
+	for _, c := range sorted {
+		fmt.Printf("%5d: #%02x%02x%02x (%d, %d, %d)\n", c.PanelID, c.Red, c.Green, c.Blue, c.Red, c.Green, c.Blue)
+	}
+}
+
+// formatCustomFrame formats panel colors as `effect custom` arguments, with
+// no transition time.
+func formatCustomFrame(colors []panelColor) string {
+	fields := make([]string, 0, 5*len(colors))
+	for _, c := range colors {
+		fields = append(fields, fmt.Sprintf("%d %d %d %d 0", c.PanelID, c.Red, c.Green, c.Blue))
+	}
+	return strings.Join(fields, " ")
+}
+
+// doEffectDumpCustomCommand prints an `effect custom` command that
+// reproduces the current panel colors.
+func doEffectDumpCustomCommand(client Client, args []string) {
+	flags := flag.NewFlagSet("dump-custom", flag.ExitOnError)
+	frames := flags.Bool("frames", false, "Print just the frame, for a frames file or effect stream")
+	flags.Usage = func() {
+		fmt.Println("usage: picoleaf effect dump-custom [--frames]")
+		exit(1)
+	}
+	flags.Parse(args)
+
+	if flags.NArg() > 0 {
+		flags.Usage()
+	}
+
+	panelInfo, err := client.GetPanelInfo()
+	if err != nil {
+		fmt.Println("error: failed to get Nanoleaf state:", err)
+		exit(1)
+	}
+	colors, err := currentPanelColors(client, panelInfo)
+	if err != nil {
+		fmt.Println("error:", err)
+		exit(1)
+	}
+
+	frame := formatCustomFrame(sortedPanelColors(colors))
+	if *frames {
+		fmt.Println(frame)
+		return
+	}
+	fmt.Println("picoleaf effect custom", frame)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/paulrosania/picoleaf/internal/nltest"
+)
+
+func TestCurrentPanelColorsSolid(t *testing.T) {
+	client, server := newTestClient(t)
+	server.Update(func(d *nltest.Device) {
+		d.State.Hue = 0
+		d.State.Saturation = 100
+	})
+
+	info, err := client.GetPanelInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	colors, err := currentPanelColors(client, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	red := RGB{255, 0, 0}
+	want := map[int]RGB{101: red, 102: red, 103: red}
+	if !reflect.DeepEqual(colors, want) {
+		t.Errorf("currentPanelColors = %v, want %v", colors, want)
+	}
+}
+
+func TestCurrentPanelColorsStreamed(t *testing.T) {
+	server := nltest.NewServer()
+	t.Cleanup(server.Close)
+
+	client := NewClient(server.Host(), server.Token)
+	client.UDPPort = server.UDPPort()
+	defer client.Close()
+
+	err := client.SetCustomColors([]SetPanelColor{{PanelID: 101, Red: 10}, {PanelID: 102, Blue: 20}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := client.GetPanelInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	colors, err := currentPanelColors(client, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[int]RGB{101: {Red: 10}, 102: {Blue: 20}}
+	if !reflect.DeepEqual(colors, want) {
+		t.Errorf("currentPanelColors = %v, want %v", colors, want)
+	}
+
+	// Another program's stream can't be read back.
+	other := Client{Host: server.Host(), Token: server.Token}
+	if _, err := currentPanelColors(other, info); err == nil {
+		t.Error("currentPanelColors without a session succeeded, want error")
+	}
+}
+
+func TestColorTemperatureToRGB(t *testing.T) {
+	if c := KelvinToRGB(6600); c != (RGB{255, 255, 255}) {
+		t.Errorf("KelvinToRGB(6600) = %+v, want white", c)
+	}
+	warm := KelvinToRGB(2700)
+	if warm.Red != 255 || warm.Blue >= warm.Green {
+		t.Errorf("KelvinToRGB(2700) = %+v, want a warm white", warm)
+	}
+}
+
+func TestFormatCustomFrame(t *testing.T) {
+	colors := sortedPanelColors(map[int]RGB{102: {0, 0, 255}, 101: {255, 128, 0}})
+	got := formatCustomFrame(colors)
+	want := "101 255 128 0 0 102 0 0 255 0"
+	if got != want {
+		t.Errorf("formatCustomFrame = %q, want %q", got, want)
+	}
+
+	frames, err := parseCustomFrames(strings.Fields(got), false)
+	if err != nil || len(frames) != 2 {
+		t.Errorf("parseCustomFrames(%q) = %v, %v, want 2 frames", got, frames, err)
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// pickStep is how far each arrow key press moves the selection.
+const pickStep = 5
+
+// colorPicker is an HSV color selection.
+type colorPicker struct {
+	Hue        int
+	Saturation int
+	Brightness int
+}
+
+// handleKey updates the selection for a key press, returning whether it
+// changed.
+func (p *colorPicker) handleKey(key string) bool {
+	old := *p
+	switch key {
+	case keyLeft:
+		p.Hue = (p.Hue + 360 - pickStep) % 360
+	case keyRight:
+		p.Hue = (p.Hue + pickStep) % 360
+	case keyUp:
+		p.Saturation = clamp(p.Saturation+pickStep, 0, 100)
+	case keyDown:
+		p.Saturation = clamp(p.Saturation-pickStep, 0, 100)
+	case "+", "=":
+		p.Brightness = clamp(p.Brightness+pickStep, 0, 100)
+	case "-", "_":
+		p.Brightness = clamp(p.Brightness-pickStep, 0, 100)
+	}
+	return *p != old
+}
+
+// render draws the selection as a single terminal line, with a swatch in
+// the selected color.
+func (p colorPicker) render() string {
+	c := HSVToRGB(p.Hue, p.Saturation, p.Brightness)
+	return fmt.Sprintf("\r\x1b[K\x1b[48;2;%d;%d;%dm        \x1b[0m  H %3d°  S %3d  B %3d   ←/→ hue  ↑/↓ saturation  +/- brightness  Enter keep  Esc revert",
+		c.Red, c.Green, c.Blue, p.Hue, p.Saturation, p.Brightness)
+}
+
+func clamp(v, min, max int) int {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
+
+// doPickCommand runs an interactive color picker, previewing the selection
+// on the Nanoleaf as it moves. Enter keeps the color; Esc restores the
+// previous state.
+func doPickCommand(client Client, args []string) {
+	if len(args) != 0 {
+		fmt.Println("usage: picoleaf pick")
+		exit(1)
+	}
+
+	snapshot, err := client.Snapshot()
+	if err != nil {
+		fmt.Println("error: failed to get Nanoleaf state:", err)
+		exit(1)
+	}
+
+	restoreTerminal, err := makeRaw()
+	if err != nil {
+		fmt.Println("error: failed to set up terminal:", err)
+		exit(1)
+	}
+
+	picker := colorPicker{
+		Hue:        snapshot.Hue,
+		Saturation: snapshot.Saturation,
+		Brightness: snapshot.Brightness,
+	}
+	fmt.Print(picker.render())
+
+	keep := false
+	for {
+		key, err := readKey(os.Stdin)
+		if err != nil || key == keyEsc || key == keyCtrlC || key == "q" {
+			break
+		}
+		if key == keyEnter {
+			keep = true
+			break
+		}
+
+		if picker.handleKey(key) {
+			fmt.Print(picker.render())
+			err = client.SetHSL(picker.Hue, picker.Saturation, picker.Brightness)
+			if err != nil {
+				restoreTerminal()
+				fmt.Println("\nerror: failed to set color:", err)
+				exit(1)
+			}
+		}
+	}
+
+	restoreTerminal()
+	fmt.Println()
+
+	if keep {
+		fmt.Printf("hsl %d %d %d\n", picker.Hue, picker.Saturation, picker.Brightness)
+		return
+	}
+
+	err = client.Restore(*snapshot)
+	if err != nil {
+		fmt.Println("error: failed to restore Nanoleaf state:", err)
+		exit(1)
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// effectPlugins maps the names accepted by `effect create --plugin` to the
+// UUIDs of Nanoleaf's built-in color plugins.
+var effectPlugins = map[string]string{
+	"explode":   "713518c1-d560-47db-8991-de780af71d1e",
+	"fade":      "b3fd723a-aae8-4c99-bf2b-087159e0ef53",
+	"flow":      "027842e4-e1d6-4a4c-a731-be74a1ebd4cf",
+	"highlight": "70b7c636-6bf8-491f-89c1-f4103508d642",
+	"random":    "ba632d3e-9c2b-4413-a965-510c839b3f71",
+	"wheel":     "6970681a-20b5-4c5e-8813-bdaebc4ee4fa",
+}
+
+// PaletteColor is a color in an effect palette.
+type PaletteColor struct {
+	Hue        int `json:"hue"`
+	Saturation int `json:"saturation"`
+	Brightness int `json:"brightness"`
+}
+
+// PluginOption is a setting for an effect plugin, e.g. transTime.
+type PluginOption struct {
+	Name  string      `json:"name"`
+	Value interface{} `json:"value"`
+}
+
+// PluginEffect describes a plugin-based effect.
+type PluginEffect struct {
+	Name    string
+	Plugin  string // a key of effectPlugins
+	Palette []RGB
+	Options []PluginOption
+}
+
+// pluginEffectWrite returns the effects write request that adds the effect.
+func pluginEffectWrite(effect PluginEffect) ([]byte, error) {
+	uuid, ok := effectPlugins[effect.Plugin]
+	if !ok {
+		return nil, fmt.Errorf("unknown plugin %q, expected one of %s", effect.Plugin, strings.Join(pluginNames(), ", "))
+	}
+
+	palette := make([]PaletteColor, len(effect.Palette))
+	for i, c := range effect.Palette {
+		h, s, v := RGBToHSV(c)
+		palette[i] = PaletteColor{Hue: h, Saturation: s, Brightness: v}
+	}
+
+	options := effect.Options
+	if options == nil {
+		options = []PluginOption{}
+	}
+
+	return json.Marshal(map[string]interface{}{
+		"write": map[string]interface{}{
+			"command":       "add",
+			"version":       "2.0",
+			"animName":      effect.Name,
+			"animType":      "plugin",
+			"colorType":     "HSB",
+			"pluginType":    "color",
+			"pluginUuid":    uuid,
+			"pluginOptions": options,
+			"palette":       palette,
+		},
+	})
+}
+
+// AddPluginEffect saves a plugin-based effect on the Nanoleaf.
+func (c Client) AddPluginEffect(effect PluginEffect) error {
+	req, err := pluginEffectWrite(effect)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.Put("effects", req)
+	return err
+}
+
+// pluginNames returns the names of the supported plugins, sorted.
+func pluginNames() []string {
+	names := make([]string, 0, len(effectPlugins))
+	for name := range effectPlugins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// doEffectCreateCommand saves a new effect built from one of the Nanoleaf's
+// color plugins.
+func doEffectCreateCommand(client Client, args []string) {
+	flags := flag.NewFlagSet("create", flag.ExitOnError)
+	name := flags.String("name", "", "Name of the new effect")
+	plugin := flags.String("plugin", "", "Plugin: "+strings.Join(pluginNames(), ", "))
+	paletteArg := flags.String("palette", "", "Comma-separated colors, each a name or #rrggbb, or a palette name")
+	transTime := flags.Int("trans-time", -1, "Transition time, in tenths of a second")
+	delayTime := flags.Int("delay-time", -1, "Delay between transitions, in tenths of a second")
+	direction := flags.String("direction", "", "Direction for flow and wheel: left, right, up, or down")
+	flags.Usage = func() {
+		fmt.Println("usage: picoleaf effect create --name <name> --plugin <plugin> --palette <colors> [--trans-time <n>] [--delay-time <n>] [--direction <dir>]")
+		exit(1)
+	}
+	flags.Parse(args)
+
+	if flags.NArg() > 0 || *name == "" || *plugin == "" || *paletteArg == "" {
+		flags.Usage()
+	}
+
+	palette, err := parsePalette(*paletteArg)
+	if err != nil {
+		fmt.Println("error:", err)
+		exit(1)
+	}
+
+	effect := PluginEffect{Name: *name, Plugin: *plugin, Palette: palette}
+	if *transTime >= 0 {
+		effect.Options = append(effect.Options, PluginOption{Name: "transTime", Value: *transTime})
+	}
+	if *delayTime >= 0 {
+		effect.Options = append(effect.Options, PluginOption{Name: "delayTime", Value: *delayTime})
+	}
+	switch *direction {
+	case "":
+	case "left", "right", "up", "down":
+		effect.Options = append(effect.Options, PluginOption{Name: "linDirection", Value: *direction})
+	default:
+		fmt.Println("error: direction must be left, right, up, or down")
+		exit(1)
+	}
+
+	err = client.AddPluginEffect(effect)
+	if err != nil {
+		fmt.Println("error: failed to create effect:", err)
+		exit(1)
+	}
+}
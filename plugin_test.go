@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestAddPluginEffect(t *testing.T) {
+	client, server := newTestClient(t)
+
+	palette, err := parsePalette("#ff0000, blue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = client.AddPluginEffect(PluginEffect{
+		Name:    "MyWheel",
+		Plugin:  "wheel",
+		Palette: palette,
+		Options: []PluginOption{{Name: "transTime", Value: 10}, {Name: "linDirection", Value: "left"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requests := server.Requests()
+	var req struct {
+		Write map[string]interface{} `json:"write"`
+	}
+	err = json.Unmarshal([]byte(requests[len(requests)-1].Body), &req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]interface{}{
+		"command":    "add",
+		"version":    "2.0",
+		"animName":   "MyWheel",
+		"animType":   "plugin",
+		"colorType":  "HSB",
+		"pluginType": "color",
+		"pluginUuid": "6970681a-20b5-4c5e-8813-bdaebc4ee4fa",
+		"pluginOptions": []interface{}{
+			map[string]interface{}{"name": "transTime", "value": 10.0},
+			map[string]interface{}{"name": "linDirection", "value": "left"},
+		},
+		"palette": []interface{}{
+			map[string]interface{}{"hue": 0.0, "saturation": 100.0, "brightness": 100.0},
+			map[string]interface{}{"hue": 240.0, "saturation": 100.0, "brightness": 100.0},
+		},
+	}
+	if !reflect.DeepEqual(req.Write, want) {
+		t.Errorf("write = %v, want %v", req.Write, want)
+	}
+
+	err = client.AddPluginEffect(PluginEffect{Name: "Nope", Plugin: "spiral"})
+	if err == nil {
+		t.Error("AddPluginEffect accepted an unknown plugin")
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+// powerCurve is a rough power model for a Nanoleaf model. Each panel's LEDs
+// draw up to PanelMax watts at full brightness showing white, scaling
+// linearly with brightness and with how much of each LED channel a color
+// uses. The controller draws Standby watts even when the panels are off.
+type powerCurve struct {
+	Standby  float64
+	PanelMax float64
+}
+
+// powerCurves are estimated from Nanoleaf's published per-panel ratings.
+var powerCurves = map[string]powerCurve{
+	"NL22": {Standby: 1, PanelMax: 2},   // Light Panels
+	"NL29": {Standby: 1, PanelMax: 1.6}, // Canvas
+	"NL42": {Standby: 1, PanelMax: 2},   // Shapes Hexagons
+	"NL45": {Standby: 1, PanelMax: 1.4}, // Shapes Triangles
+	"NL47": {Standby: 1, PanelMax: 0.5}, // Shapes Mini Triangles
+	"NL48": {Standby: 1, PanelMax: 2},   // Shapes Controller
+	"NL52": {Standby: 1, PanelMax: 2},   // Elements
+	"NL59": {Standby: 1, PanelMax: 1.4}, // Lines
+}
+
+// defaultPowerCurve is used for unknown models.
+var defaultPowerCurve = powerCurve{Standby: 1, PanelMax: 2}
+
+// shapeShapesController is the shape type of a Shapes controller, which is
+// listed in the layout but has no LEDs of its own.
+const shapeShapesController = 12
+
+// effectColorLoad is the assumed color load while an effect is running,
+// since its colors can't be read back.
+const effectColorLoad = 0.5
+
+// colorLoad returns how much of the LEDs' maximum draw a color uses, from 0
+// to 1.
+func colorLoad(c RGB) float64 {
+	return (float64(c.Red) + float64(c.Green) + float64(c.Blue)) / (3 * 255)
+}
+
+// stateColorLoad returns the color load of a Nanoleaf's current state.
+func stateColorLoad(state State) float64 {
+	switch state.ColorMode {
+	case "ct":
+		return 1
+	case "hs":
+		var hue, sat int
+		if state.Hue != nil {
+			hue = state.Hue.Value
+		}
+		if state.Saturation != nil {
+			sat = state.Saturation.Value
+		}
+		return colorLoad(HSVToRGB(hue, sat, 100))
+	}
+	return effectColorLoad
+}
+
+// estimatePower estimates a Nanoleaf's current power draw, in watts.
+func estimatePower(info *PanelInfo) float64 {
+	curve, ok := powerCurves[info.Model]
+	if !ok {
+		curve = defaultPowerCurve
+	}
+	if info.State.On == nil || !info.State.On.Value {
+		return curve.Standby
+	}
+
+	panels := 0
+	for _, p := range info.PanelLayout.Layout.PositionData {
+		if p.ShapeType != shapeShapesController {
+			panels++
+		}
+	}
+
+	brightness := 100
+	if info.State.Brightness != nil {
+		brightness = info.State.Brightness.Value
+	}
+	load := float64(brightness) / 100 * stateColorLoad(info.State)
+	return curve.Standby + float64(panels)*curve.PanelMax*load
+}
+
+// doPowerCommand prints the Nanoleaf's estimated power draw, once or at an
+// interval.
+func doPowerCommand(client Client, args []string) {
+	flags := flag.NewFlagSet("power", flag.ExitOnError)
+	every := flags.Duration("every", 0, "Estimate repeatedly at this interval, until interrupted")
+	logPath := flags.String("log", "", "Append each estimate to a CSV file")
+	flags.Usage = func() {
+		fmt.Println("usage: picoleaf power [--every <duration>] [--log <path>]")
+		exit(1)
+	}
+	flags.Parse(args)
+
+	if flags.NArg() > 0 || *every < 0 {
+		flags.Usage()
+	}
+
+	var log *os.File
+	if *logPath != "" {
+		f, err := os.OpenFile(*logPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			fmt.Println("error: failed to open power log:", err)
+			exit(1)
+		}
+		defer f.Close()
+		log = f
+	}
+
+	var energy float64 // watt-hours
+	for {
+		panelInfo, err := client.GetPanelInfo()
+		if err != nil {
+			fmt.Println("error: failed to get Nanoleaf info:", err)
+			exit(1)
+		}
+		watts := estimatePower(panelInfo)
+		now := time.Now()
+
+		if *every == 0 {
+			fmt.Printf("%.1f W\n", watts)
+		} else {
+			energy += watts * every.Hours()
+			fmt.Printf("%s  %5.1f W  %.2f Wh\n", now.Format(time.TimeOnly), watts, energy)
+		}
+		if log != nil {
+			_, err := fmt.Fprintf(log, "%s,%.2f\n", now.Format(time.RFC3339), watts)
+			if err != nil {
+				fmt.Println("error: failed to write power log:", err)
+				exit(1)
+			}
+		}
+
+		if *every == 0 || !sleepOrCancel(*every) {
+			return
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestEstimatePower(t *testing.T) {
+	info := &PanelInfo{Model: "NL29"}
+	for i := 0; i < 10; i++ {
+		info.PanelLayout.Layout.PositionData = append(info.PanelLayout.Layout.PositionData, PanelPosition{PanelID: i + 1})
+	}
+
+	if w := estimatePower(info); w != 1 {
+		t.Errorf("off: estimatePower = %v, want the 1W standby", w)
+	}
+
+	info.State = State{
+		On:         &OnProperty{Value: true},
+		Brightness: &BrightnessProperty{Value: 50},
+		ColorMode:  "ct",
+	}
+	if w := estimatePower(info); math.Abs(w-9) > 1e-9 {
+		t.Errorf("white at 50%%: estimatePower = %v, want 9", w)
+	}
+
+	// Pure red lights one of three channels.
+	info.State.ColorMode = "hs"
+	info.State.Hue = &HueProperty{Value: 0}
+	info.State.Saturation = &SaturationProperty{Value: 100}
+	if w := estimatePower(info); math.Abs(w-(1+8.0/3)) > 1e-9 {
+		t.Errorf("red at 50%%: estimatePower = %v, want %v", w, 1+8.0/3)
+	}
+}
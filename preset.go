@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+)
+
+// Preset is a built-in seasonal look, written to the device as a plugin
+// effect or streamed as a local animation.
+type Preset struct {
+	Palette string // colors, as for --palette
+	Plugin  string // a key of effectPlugins
+	Options []PluginOption
+
+	// Step is how long each color holds on a panel when streamed.
+	Step time.Duration
+}
+
+// presets are the built-in presets, by name.
+var presets = map[string]Preset{
+	"christmas": {
+		Palette: "#ff0000,#00a000,#ffffff,#ffb000",
+		Plugin:  "random",
+		Options: []PluginOption{{Name: "transTime", Value: 10}, {Name: "delayTime", Value: 20}},
+		Step:    2 * time.Second,
+	},
+	"halloween": {
+		Palette: "#ff4500,#8000ff,#30ff00,#ff8c00",
+		Plugin:  "flow",
+		Options: []PluginOption{{Name: "transTime", Value: 20}, {Name: "delayTime", Value: 10}, {Name: "linDirection", Value: "right"}},
+		Step:    3 * time.Second,
+	},
+	"hanukkah": {
+		Palette: "#0038b8,#ffffff,#4f86f7,#c0c0c0",
+		Plugin:  "fade",
+		Options: []PluginOption{{Name: "transTime", Value: 30}, {Name: "delayTime", Value: 20}},
+		Step:    4 * time.Second,
+	},
+	"pride": {
+		Palette: "rainbow",
+		Plugin:  "wheel",
+		Options: []PluginOption{{Name: "transTime", Value: 10}, {Name: "linDirection", Value: "right"}},
+		Step:    time.Second,
+	},
+	"valentine": {
+		Palette: "#ff0000,#ff69b4,#ffffff,#c71585",
+		Plugin:  "fade",
+		Options: []PluginOption{{Name: "transTime", Value: 20}, {Name: "delayTime", Value: 10}},
+		Step:    3 * time.Second,
+	},
+}
+
+// presetEffectName is the name presets are saved under on the device, e.g.
+// "Picoleaf Christmas".
+func presetEffectName(name string) string {
+	return "Picoleaf " + string(name[0]-'a'+'A') + name[1:]
+}
+
+// presetKeyframes returns one cycle of a streamed preset: each panel steps
+// through the palette, offset by its position from left to right, and the
+// last keyframe matches the first so the cycle loops smoothly.
+func presetKeyframes(panels []PanelPosition, palette []RGB, step time.Duration) []Keyframe {
+	ordered, _ := orderPanels(panels, "x")
+
+	keyframes := make([]Keyframe, len(palette)+1)
+	for k := range keyframes {
+		frames := make([]SetPanelColor, len(ordered))
+		for i, p := range ordered {
+			c := palette[(i+k)%len(palette)]
+			frames[i] = SetPanelColor{PanelID: uint16(p.PanelID), Red: c.Red, Green: c.Green, Blue: c.Blue}
+		}
+		keyframes[k] = Keyframe{At: time.Duration(k) * step, Frames: frames}
+	}
+	return keyframes
+}
+
+// presetNames returns the names of the built-in presets, sorted.
+func presetNames() []string {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func doPresetCommand(client Client, args []string) {
+	flags := flag.NewFlagSet("preset", flag.ExitOnError)
+	stream := flags.Bool("stream", false, "Animate locally until interrupted, instead of writing an effect to the device")
+	flags.Usage = func() {
+		fmt.Println("usage: picoleaf preset [--stream] <name>")
+		fmt.Println("       picoleaf preset list")
+		exit(1)
+	}
+
+	if len(args) == 1 && args[0] == "list" {
+		for _, name := range presetNames() {
+			fmt.Println(name)
+		}
+		return
+	}
+
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+	}
+
+	name := flags.Arg(0)
+	preset, ok := presets[name]
+	if !ok {
+		fmt.Printf("error: no preset named %q\n", name)
+		exit(1)
+	}
+	palette, err := parsePalette(preset.Palette)
+	if err != nil {
+		fmt.Println("error:", err)
+		exit(1)
+	}
+
+	if !*stream {
+		effect := PluginEffect{Name: presetEffectName(name), Plugin: preset.Plugin, Palette: palette, Options: preset.Options}
+		err = client.AddPluginEffect(effect)
+		if err == nil {
+			err = client.SelectEffect(effect.Name)
+		}
+		if err == nil {
+			return
+		}
+		// The device may be out of effect storage, so fall back to
+		// streaming.
+		fmt.Println("warning: failed to write effect, streaming instead:", err)
+	}
+
+	streamPreset(client, palette, preset.Step)
+}
+
+// streamPreset animates a palette across the panels until interrupted, then
+// restores the previous state.
+func streamPreset(client Client, palette []RGB, step time.Duration) {
+	panelInfo, err := client.CachedPanelInfo()
+	if err != nil {
+		fmt.Println("error: failed to get Nanoleaf info:", err)
+		exit(1)
+	}
+
+	ctx, restore := restoreOnInterrupt(client)
+	defer restore()
+
+	sink, err := openFrameSink(client, panelInfo)
+	if err != nil {
+		fmt.Println("error: failed to start external control:", err)
+		exit(1)
+	}
+	defer sink.Close()
+
+	keyframes := presetKeyframes(panelInfo.PanelLayout.Layout.PositionData, palette, step)
+	fps := MaxFrameRate(panelInfo.Model)
+	slog.Info("streaming preset", "panels", len(panelInfo.PanelLayout.Layout.PositionData), "fps", fps)
+	for {
+		_, err := animate(ctx, keyframes, fps, easings["ease"], 0, sink.Send)
+		if errors.Is(err, context.Canceled) {
+			return
+		}
+		if err != nil {
+			fmt.Println("error: failed to send frame:", err)
+			exit(1)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPresets(t *testing.T) {
+	setTestConfig(t, "")
+
+	for name, preset := range presets {
+		palette, err := parsePalette(preset.Palette)
+		if err != nil {
+			t.Errorf("preset %s: %v", name, err)
+			continue
+		}
+		_, err = pluginEffectWrite(PluginEffect{Name: presetEffectName(name), Plugin: preset.Plugin, Palette: palette, Options: preset.Options})
+		if err != nil {
+			t.Errorf("preset %s: %v", name, err)
+		}
+	}
+
+	if got := presetEffectName("christmas"); got != "Picoleaf Christmas" {
+		t.Errorf("presetEffectName(christmas) = %q", got)
+	}
+}
+
+func TestPresetKeyframes(t *testing.T) {
+	panels := []PanelPosition{{PanelID: 2, X: 100}, {PanelID: 1, X: 0}}
+	palette := []RGB{{255, 0, 0}, {0, 255, 0}}
+
+	keyframes := presetKeyframes(panels, palette, time.Second)
+	if len(keyframes) != 3 {
+		t.Fatalf("got %d keyframes, want 3", len(keyframes))
+	}
+
+	// Panel 1 is leftmost, so it starts on the first color.
+	first := keyframes[0].Frames
+	if first[0].PanelID != 1 || first[0].Red != 255 || first[1].PanelID != 2 || first[1].Green != 255 {
+		t.Errorf("first keyframe = %+v", first)
+	}
+	if second := keyframes[1]; second.At != time.Second || second.Frames[0].Green != 255 {
+		t.Errorf("second keyframe = %+v", second)
+	}
+	if last := keyframes[2].Frames; last[0] != first[0] || last[1] != first[1] {
+		t.Errorf("last keyframe = %+v, want it to match the first", last)
+	}
+}
+
+func TestPresetCommand(t *testing.T) {
+	client, server := newTestClient(t)
+	t.Setenv("HOME", t.TempDir()) // presets record undo history
+	setTestConfig(t, "")
+
+	code := runCommandInProcess(client, []string{"preset", "halloween"})
+	if code != 0 {
+		t.Fatalf("exit code = %d, want 0", code)
+	}
+	if effect := server.Device().Effect; effect != "Picoleaf Halloween" {
+		t.Errorf("effect = %q, want Picoleaf Halloween", effect)
+	}
+}
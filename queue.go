@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// queueFile is the name of the offline command queue in the state directory.
+const queueFile = "queue.json"
+
+// QueuedCommand is a mutating command that was run with -queue while its
+// device was unreachable.
+type QueuedCommand struct {
+	Device string    `json:"device"`
+	Time   time.Time `json:"time"`
+	Args   []string  `json:"args"`
+}
+
+func queuePath() (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, queueFile), nil
+}
+
+func loadQueue() ([]QueuedCommand, error) {
+	path, err := queuePath()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var queue []QueuedCommand
+	err = json.Unmarshal(data, &queue)
+	return queue, err
+}
+
+func saveQueue(queue []QueuedCommand) error {
+	path, err := queuePath()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(queue, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+// appendQueue adds a command to the queue.
+func appendQueue(cmd QueuedCommand) error {
+	unlock, err := lockStateFile(queueFile)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	queue, err := loadQueue()
+	if err != nil {
+		return err
+	}
+	return saveQueue(append(queue, cmd))
+}
+
+// runOrQueueCommand runs a mutating command if the Nanoleaf is reachable,
+// after replaying any commands queued for it. Otherwise, it queues the
+// command to be replayed later.
+func runOrQueueCommand(client Client, args []string) {
+	err := probe(client)
+	if err != nil {
+		err2 := appendQueue(QueuedCommand{Device: currentDeviceName(), Time: time.Now(), Args: args})
+		if err2 != nil {
+			fmt.Println("error: failed to queue command:", err2)
+			exit(1)
+		}
+		fmt.Printf("Nanoleaf not reachable (%v), queued `%s`\n", err, strings.Join(args, " "))
+		return
+	}
+
+	err = replayQueue(client)
+	if err != nil {
+		fmt.Println("error: failed to replay queued commands:", err)
+		exit(1)
+	}
+	runCommand(client, args)
+}
+
+// replayQueue runs the current device's queued commands in order. If the
+// device becomes unreachable partway through, the rest stay queued; commands
+// that fail for other reasons are dropped.
+func replayQueue(client Client) error {
+	unlock, err := lockStateFile(queueFile)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	queue, err := loadQueue()
+	if err != nil {
+		return err
+	}
+
+	device := currentDeviceName()
+	var rest []QueuedCommand
+	for i, cmd := range queue {
+		if cmd.Device != device {
+			rest = append(rest, cmd)
+			continue
+		}
+
+		command := strings.Join(cmd.Args, " ")
+		fmt.Printf("Replaying `%s` from %s\n", command, cmd.Time.Format(time.Stamp))
+		if runCommandInProcess(client, cmd.Args) == 0 {
+			continue
+		}
+		if probe(client) != nil {
+			fmt.Println("Nanoleaf not reachable, leaving the rest queued")
+			rest = append(rest, queue[i:]...)
+			break
+		}
+		fmt.Printf("warning: `%s` failed, removing it from the queue\n", command)
+	}
+	return saveQueue(rest)
+}
+
+// doQueueCommand lists, replays, or clears the current device's queued
+// commands.
+func doQueueCommand(client Client, args []string) {
+	usage := func() {
+		fmt.Println("usage: picoleaf queue [list]")
+		fmt.Println("       picoleaf queue run [--timeout <duration>] [--interval <duration>]")
+		fmt.Println("       picoleaf queue clear")
+		exit(1)
+	}
+
+	command := "list"
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
+	}
+
+	switch command {
+	case "list":
+		if len(args) > 0 {
+			usage()
+		}
+		queue, err := loadQueue()
+		if err != nil {
+			fmt.Println("error: failed to read queue:", err)
+			exit(1)
+		}
+		device := currentDeviceName()
+		for _, cmd := range queue {
+			if cmd.Device == device {
+				fmt.Printf("%s  %s\n", cmd.Time.Format(time.Stamp), strings.Join(cmd.Args, " "))
+			}
+		}
+	case "run":
+		flags := flag.NewFlagSet("run", flag.ExitOnError)
+		timeout := flags.Duration("timeout", 0, "Maximum time to wait for the Nanoleaf (0 waits forever)")
+		interval := flags.Duration("interval", 10*time.Second, "Time between attempts")
+		flags.Usage = usage
+		flags.Parse(args)
+
+		if flags.NArg() > 0 || *timeout < 0 || *interval <= 0 {
+			flags.Usage()
+		}
+
+		err := waitUntilReachable(client, *timeout, *interval)
+		if err != nil {
+			fmt.Println("error: Nanoleaf not reachable:", err)
+			exit(1)
+		}
+		err = replayQueue(client)
+		if err != nil {
+			fmt.Println("error: failed to replay queued commands:", err)
+			exit(1)
+		}
+	case "clear":
+		if len(args) > 0 {
+			usage()
+		}
+		unlock, err := lockStateFile(queueFile)
+		if err != nil {
+			fmt.Println("error: failed to lock queue:", err)
+			exit(1)
+		}
+		defer unlock()
+		queue, err := loadQueue()
+		if err != nil {
+			fmt.Println("error: failed to read queue:", err)
+			exit(1)
+		}
+		device := currentDeviceName()
+		var rest []QueuedCommand
+		for _, cmd := range queue {
+			if cmd.Device != device {
+				rest = append(rest, cmd)
+			}
+		}
+		err = saveQueue(rest)
+		if err != nil {
+			fmt.Println("error: failed to update queue:", err)
+			exit(1)
+		}
+	default:
+		usage()
+	}
+}
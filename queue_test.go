@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestQueueReplaysWhenReachable(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	offline, server := newTestClient(t)
+	server.Close()
+
+	runOrQueueCommand(offline, []string{"brightness", "12"})
+	queue, err := loadQueue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queue) != 1 || queue[0].Device != defaultDeviceName {
+		t.Fatalf("queue = %+v, want one command for %s", queue, defaultDeviceName)
+	}
+
+	client, server := newTestClient(t)
+	runOrQueueCommand(client, []string{"brightness", "34"})
+	if got := server.Device().State.Brightness; got != 34 {
+		t.Errorf("brightness = %d, want 34", got)
+	}
+
+	var puts []string
+	for _, req := range server.Requests() {
+		if req.Method == "PUT" {
+			puts = append(puts, req.Body)
+		}
+	}
+	if len(puts) != 2 {
+		t.Errorf("PUT requests = %q, want the queued brightness and then the new one", puts)
+	}
+
+	queue, err = loadQueue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queue) != 0 {
+		t.Errorf("queue = %+v, want it empty after replaying", queue)
+	}
+}
+
+func TestAppendQueueConcurrently(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	// As group members run with -queue do, from separate processes.
+	var wg sync.WaitGroup
+	for i := 0; i < 30; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := appendQueue(QueuedCommand{Device: fmt.Sprint("device", i), Args: []string{"off"}})
+			if err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	queue, err := loadQueue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queue) != 30 {
+		t.Errorf("queue has %d commands, want all 30", len(queue))
+	}
+
+	dir, err := stateDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmp, _ := filepath.Glob(filepath.Join(dir, "*.tmp"))
+	if len(tmp) > 0 {
+		t.Errorf("left temporary files behind: %q", tmp)
+	}
+}
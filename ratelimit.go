@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Default state write rate limits. Nanoleaf suggests staying around 10 REST
+// requests a second; faster than that, controllers start queuing requests
+// and answering slowly, or dropping them.
+const (
+	DefaultWriteRate  = 10
+	DefaultWriteBurst = 5
+)
+
+// writeLimiter is a token bucket limiting state writes. Writes that have to
+// wait for a token, or for the coalescing window, are coalesced: while one is
+// waiting, later writes merge their properties into it, so only the most
+// recent value of each property is sent.
+type writeLimiter struct {
+	rate   float64 // zero for no limit
+	burst  float64
+	window time.Duration
+
+	mu      sync.Mutex
+	tokens  float64
+	last    time.Time
+	pending *pendingWrite
+}
+
+// pendingWrite is a state write waiting for a token, and the result shared
+// by every write merged into it.
+type pendingWrite struct {
+	state map[string]json.RawMessage
+	done  chan struct{}
+	body  string
+	err   error
+}
+
+func newWriteLimiter(perSecond float64, burst int) *writeLimiter {
+	burst = max(burst, 1)
+	return &writeLimiter{rate: perSecond, burst: float64(burst), tokens: float64(burst)}
+}
+
+// reserve takes a token, and returns how long to wait before it may be
+// used. Must be called with mu held.
+func (l *writeLimiter) reserve() time.Duration {
+	if l.rate == 0 {
+		return 0
+	}
+	now := time.Now()
+	if !l.last.IsZero() {
+		l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	}
+	l.last = now
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+// SetRateLimit limits state writes to perSecond, with bursts of up to burst
+// writes. Zero disables the limit. Clients created with NewClient start with
+// DefaultWriteRate and DefaultWriteBurst; copies made after this share the
+// new limit.
+func (c *Client) SetRateLimit(perSecond float64, burst int) {
+	var window time.Duration
+	if c.limiter != nil {
+		window = c.limiter.window
+	}
+	c.limiter = nil
+	if perSecond > 0 {
+		c.limiter = newWriteLimiter(perSecond, burst)
+	} else if window > 0 {
+		c.limiter = &writeLimiter{}
+	}
+	if c.limiter != nil {
+		c.limiter.window = window
+	}
+}
+
+// SetCoalesceWindow holds each state write for at least d before sending it,
+// so writes made in the meantime, e.g. from dragging a slider, merge into it.
+// Zero sends writes as soon as the rate limit allows. Copies made after this
+// share the window.
+func (c *Client) SetCoalesceWindow(d time.Duration) {
+	var l *writeLimiter
+	switch {
+	case c.limiter != nil && c.limiter.rate > 0:
+		l = newWriteLimiter(c.limiter.rate, int(c.limiter.burst))
+	case d > 0:
+		l = &writeLimiter{}
+	}
+	if l != nil {
+		l.window = d
+	}
+	c.limiter = l
+}
+
+// putState PUTs a state change through the client's rate limit and
+// coalescing window.
+func (c Client) putState(body []byte) (string, error) {
+	l := c.limiter
+	if l == nil {
+		return c.checkedRequest(http.MethodPut, "state", body)
+	}
+
+	var state map[string]json.RawMessage
+	if json.Unmarshal(body, &state) != nil {
+		state = nil
+	}
+
+	l.mu.Lock()
+	if p := l.pending; p != nil && state != nil && canMergeState(p.state, state) {
+		mergeState(p.state, state)
+		l.mu.Unlock()
+		<-p.done
+		return p.body, p.err
+	}
+
+	wait := max(l.reserve(), l.window)
+	if wait == 0 || state == nil {
+		// Later writes mustn't be merged into one sent before this.
+		l.pending = nil
+		l.mu.Unlock()
+		time.Sleep(wait)
+		return c.checkedRequest(http.MethodPut, "state", body)
+	}
+
+	p := &pendingWrite{state: state, done: make(chan struct{})}
+	l.pending = p
+	l.mu.Unlock()
+	time.Sleep(wait)
+
+	l.mu.Lock()
+	if l.pending == p {
+		l.pending = nil
+	}
+	body, err := json.Marshal(p.state)
+	l.mu.Unlock()
+	if err == nil {
+		p.body, err = c.checkedRequest(http.MethodPut, "state", body)
+	}
+	p.err = err
+	close(p.done)
+	return p.body, p.err
+}
+
+// colorModeProperties are the state properties that set the color, of which
+// only the latest mode may be sent.
+var colorModeProperties = map[string][]string{
+	"hue": {"ct"},
+	"sat": {"ct"},
+	"ct":  {"hue", "sat"},
+}
+
+// canMergeState reports whether the properties in next can replace those in
+// pending. Increments can't, since they depend on the value before them.
+func canMergeState(pending, next map[string]json.RawMessage) bool {
+	for key, value := range next {
+		old, ok := pending[key]
+		if ok && (isIncrement(old) || isIncrement(value)) {
+			return false
+		}
+	}
+	return true
+}
+
+// mergeState merges next into pending, later properties replacing earlier
+// ones, and dropping earlier properties for a different color mode.
+func mergeState(pending, next map[string]json.RawMessage) {
+	for key, value := range next {
+		for _, other := range colorModeProperties[key] {
+			if _, ok := next[other]; !ok {
+				delete(pending, other)
+			}
+		}
+		pending[key] = value
+	}
+}
+
+func isIncrement(value json.RawMessage) bool {
+	var property map[string]json.RawMessage
+	if json.Unmarshal(value, &property) != nil {
+		return false
+	}
+	_, ok := property["increment"]
+	return ok
+}
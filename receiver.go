@@ -0,0 +1,49 @@
+package main
+
+import "net"
+
+// frameSink streams panel colors to a Nanoleaf over external control, at a
+// rate it can keep up with. Receiver modes (sACN, Art-Net, and so on) use it
+// to forward frames as fast as they arrive.
+type frameSink struct {
+	version     int
+	calibration *Calibration
+	conn        *net.UDPConn
+	pacer       *Pacer
+}
+
+// openFrameSink starts external control on the Nanoleaf described by
+// panelInfo.
+func openFrameSink(client Client, panelInfo *PanelInfo) (*frameSink, error) {
+	caps := DetectCapabilities(panelInfo)
+	port, err := client.startExternalControl(caps.ExtControlVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := client.dialExternalControl(port)
+	if err != nil {
+		return nil, err
+	}
+
+	pacer := NewPacer(MaxFrameRate(panelInfo.Model), func(frame []byte) error {
+		return writeControlFrame(conn, caps.ExtControlVersion, frame)
+	})
+	return &frameSink{version: caps.ExtControlVersion, calibration: client.Calibration, conn: conn, pacer: pacer}, nil
+}
+
+// Send queues panel colors to be sent.
+func (s *frameSink) Send(frames []SetPanelColor) error {
+	buf, err := encodeControlFrame(s.version, s.calibration.ApplyFrames(frames))
+	if err != nil {
+		return err
+	}
+	return s.pacer.Send(buf)
+}
+
+// Close sends any pending frame and closes the connection.
+func (s *frameSink) Close() error {
+	err := s.pacer.Close()
+	s.conn.Close()
+	return err
+}
@@ -0,0 +1,393 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// replCommands are the commands the REPL accepts and completes. Commands
+// that re-run picoleaf in the background, like `at --detach`, still work,
+// but run with a fresh client.
+var replCommands = []string{
+	"adapt", "api", "artnet", "at", "autooff", "bench", "brightness",
+	"busy", "ci", "cron", "daemon", "ddp", "device", "devices", "effect",
+	"encrypt", "fade", "fx", "get", "hsl", "hyperion", "in", "link",
+	"mirror-device", "mode", "nightlight", "notify", "off", "on", "openrgb",
+	"paint", "palette", "panel", "pick", "power", "preset", "queue", "rgb",
+	"run", "run-cmd", "sacn", "scene", "script", "sequence", "slack",
+	"sleep", "telegram", "temp", "undo", "wait", "weather",
+}
+
+// replHistoryLimit bounds the saved REPL history.
+const replHistoryLimit = 500
+
+// inProcessExit is panicked by exit while a command runs in-process.
+type inProcessExit struct {
+	code int
+}
+
+// splitArgs splits a command line into arguments, honoring single and double
+// quotes, e.g. `effect select "Northern Lights"`.
+func splitArgs(line string) ([]string, error) {
+	var args []string
+	var arg strings.Builder
+	inArg := false
+	var quote rune
+
+	for _, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				arg.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote = r
+			inArg = true
+		case unicode.IsSpace(r):
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, errors.New("unterminated quote")
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
+
+// quoteArg quotes an argument for the command line if it contains spaces.
+func quoteArg(s string) string {
+	if strings.ContainsAny(s, " \t") {
+		return `"` + s + `"`
+	}
+	return s
+}
+
+// replCompleter completes commands, effect names, and scene names.
+type replCompleter struct {
+	client  Client
+	effects []string
+}
+
+// candidates returns the completions for the last word of line, given the
+// words before it.
+func (c *replCompleter) candidates(words []string) []string {
+	switch {
+	case len(words) == 0:
+		return append(replCommands[:len(replCommands):len(replCommands)], aliasNames()...)
+	case len(words) == 1 && words[0] == "effect":
+		return []string{"create", "custom", "dump-custom", "list", "select", "stream"}
+	case len(words) == 2 && words[0] == "effect" && words[1] == "select":
+		if c.effects == nil {
+			c.effects, _ = c.client.ListEffects()
+		}
+		return c.effects
+	case len(words) == 1 && words[0] == "scene":
+		return append([]string{"apply", "list", "save"}, sceneNames()...)
+	case len(words) == 1 && words[0] == "panel":
+		return []string{"capabilities", "colors", "info", "layout", "model", "name", "version"}
+	}
+	return nil
+}
+
+// complete completes the last word of line. It returns the new line, and
+// the candidates if there's more than one.
+func (c *replCompleter) complete(line string) (string, []string) {
+	// The word being completed starts after the last unquoted space, or at
+	// an opening quote.
+	start := 0
+	quoted := false
+	for i, r := range line {
+		switch {
+		case r == '"':
+			if quoted {
+				quoted = false
+			} else {
+				quoted = true
+				start = i
+			}
+		case r == ' ' && !quoted:
+			start = i + 1
+		}
+	}
+
+	words, err := splitArgs(line[:start])
+	if err != nil {
+		return line, nil
+	}
+	prefix := strings.TrimPrefix(line[start:], `"`)
+
+	var matches []string
+	for _, candidate := range c.candidates(words) {
+		if strings.HasPrefix(strings.ToLower(candidate), strings.ToLower(prefix)) {
+			matches = append(matches, candidate)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return line, nil
+	case 1:
+		return line[:start] + quoteArg(matches[0]) + " ", nil
+	}
+
+	common := matches[0]
+	for _, m := range matches[1:] {
+		for !strings.HasPrefix(strings.ToLower(m), strings.ToLower(common)) {
+			common = common[:len(common)-1]
+		}
+	}
+	if len(common) > len(prefix) {
+		if strings.Contains(common, " ") || strings.HasPrefix(line[start:], `"`) {
+			return line[:start] + `"` + common, matches
+		}
+		return line[:start] + common, matches
+	}
+	return line, matches
+}
+
+// lineEditor reads lines from a terminal in raw mode, with history and tab
+// completion.
+type lineEditor struct {
+	in       io.Reader
+	out      io.Writer
+	history  []string
+	complete func(line string) (string, []string)
+}
+
+// errInterrupted is returned by readLine when the user presses Ctrl-C.
+var errInterrupted = errors.New("interrupted")
+
+// readLine reads a line, returning io.EOF on Ctrl-D at an empty prompt.
+func (e *lineEditor) readLine(prompt string) (string, error) {
+	line := ""
+	pos := len(e.history)
+	draft := ""
+
+	redraw := func() {
+		fmt.Fprintf(e.out, "\r\x1b[K%s%s", prompt, line)
+	}
+	redraw()
+
+	for {
+		key, err := readKey(e.in)
+		if err != nil {
+			return "", err
+		}
+
+		switch key {
+		case keyEnter:
+			fmt.Fprint(e.out, "\r\n")
+			return line, nil
+		case keyCtrlC:
+			fmt.Fprint(e.out, "^C\r\n")
+			return "", errInterrupted
+		case "\x04":
+			if line == "" {
+				fmt.Fprint(e.out, "\r\n")
+				return "", io.EOF
+			}
+		case "\x7f", "\b":
+			if line != "" {
+				r := []rune(line)
+				line = string(r[:len(r)-1])
+			}
+		case "\x15": // Ctrl-U
+			line = ""
+		case "\t":
+			if e.complete == nil {
+				break
+			}
+			completed, matches := e.complete(line)
+			if len(matches) > 1 {
+				fmt.Fprint(e.out, "\r\n"+strings.Join(matches, "  ")+"\r\n")
+			}
+			line = completed
+		case keyUp:
+			if pos > 0 {
+				if pos == len(e.history) {
+					draft = line
+				}
+				pos--
+				line = e.history[pos]
+			}
+		case keyDown:
+			if pos < len(e.history) {
+				pos++
+				if pos == len(e.history) {
+					line = draft
+				} else {
+					line = e.history[pos]
+				}
+			}
+		case keyLeft, keyRight, keyEsc:
+			// Only editing at the end of the line is supported.
+		default:
+			for _, r := range key {
+				if unicode.IsPrint(r) {
+					line += string(r)
+				}
+			}
+		}
+		redraw()
+	}
+}
+
+// add appends a line to the history, skipping repeats.
+func (e *lineEditor) add(line string) {
+	if line == "" || (len(e.history) > 0 && e.history[len(e.history)-1] == line) {
+		return
+	}
+	e.history = append(e.history, line)
+	if len(e.history) > replHistoryLimit {
+		e.history = e.history[len(e.history)-replHistoryLimit:]
+	}
+}
+
+func replHistoryPath() (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "repl_history"), nil
+}
+
+// loadReplHistory returns the saved REPL history, oldest first.
+func loadReplHistory() []string {
+	path, err := replHistoryPath()
+	if err != nil {
+		return nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines
+}
+
+// saveReplHistory saves the REPL history for the next session.
+func saveReplHistory(lines []string) error {
+	path, err := replHistoryPath()
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, []byte(strings.Join(lines, "\n")+"\n"))
+}
+
+// runCommandInProcess runs a command in-process, returning its exit code
+// instead of exiting. The REPL and scripts use this to share one client.
+func runCommandInProcess(client Client, args []string) (code int) {
+	prevExit := exit
+	exit = func(code int) {
+		panic(inProcessExit{code})
+	}
+	defer func() {
+		exit = prevExit
+		if r := recover(); r != nil {
+			e, ok := r.(inProcessExit)
+			if !ok {
+				panic(r)
+			}
+			code = e.code
+		}
+	}()
+
+	runCommand(client, args)
+	return 0
+}
+
+// runReplCommand runs a command entered at the REPL: a built-in command, an
+// alias, or an external command. Unlike on the command line, unknown
+// commands print an error rather than the usage.
+func runReplCommand(client Client, args []string) int {
+	i := sort.SearchStrings(replCommands, args[0])
+	builtin := i < len(replCommands) && replCommands[i] == args[0]
+	if !builtin && !isAlias(args[0]) {
+		if _, ok := findExternalCommand(args[0]); !ok {
+			fmt.Printf("error: unknown command %q\n", args[0])
+			return 1
+		}
+	}
+	return runCommandInProcess(client, args)
+}
+
+// doReplCommand reads and runs commands interactively, sharing one client
+// (and its connections) between them.
+func doReplCommand(client Client, args []string) {
+	if len(args) != 0 {
+		fmt.Println("usage: picoleaf repl")
+		exit(1)
+	}
+
+	completer := &replCompleter{client: client}
+	editor := &lineEditor{
+		in:       os.Stdin,
+		out:      os.Stdout,
+		history:  loadReplHistory(),
+		complete: completer.complete,
+	}
+	sort.Strings(replCommands)
+
+	fmt.Println("picoleaf repl: Tab completes, Ctrl-D exits")
+	for {
+		restoreTerminal, err := makeRaw()
+		if err != nil {
+			fmt.Println("error: failed to set up terminal:", err)
+			exit(1)
+		}
+		line, err := editor.readLine("picoleaf> ")
+		restoreTerminal()
+
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			continue
+		}
+
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		editor.add(line)
+		if line == "exit" || line == "quit" {
+			break
+		}
+
+		args, err := splitArgs(line)
+		if err != nil {
+			fmt.Println("error:", err)
+			continue
+		}
+		runReplCommand(client, args)
+	}
+
+	err := saveReplHistory(editor.history)
+	if err != nil {
+		fmt.Println("warning: failed to save history:", err)
+	}
+}
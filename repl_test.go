@@ -0,0 +1,113 @@
+package main
+
+import (
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestSplitArgs(t *testing.T) {
+	got, err := splitArgs(`effect select "Northern Lights"  'a b'c`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"effect", "select", "Northern Lights", "a bc"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("splitArgs() = %q, want %q", got, want)
+	}
+
+	_, err = splitArgs(`effect select "Northern`)
+	if err == nil {
+		t.Error("splitArgs() accepted an unterminated quote")
+	}
+}
+
+func TestReplComplete(t *testing.T) {
+	client, _ := newTestClient(t)
+	completer := &replCompleter{client: client}
+	setTestConfig(t, "[aliases]\nmovie = scene movie-night && brightness 15\n")
+
+	tests := []struct {
+		line    string
+		want    string
+		matches int
+	}{
+		{"bri", "brightness ", 0},
+		{"effect sel", "effect select ", 0},
+		{"effect select n", `effect select "Northern Lights" `, 0},
+		{"effect select ", "effect select ", 2},
+		{"o", "o", 3},
+		{"mo", "mo", 2},
+		{"mov", "movie ", 0},
+	}
+	for _, tt := range tests {
+		got, matches := completer.complete(tt.line)
+		if got != tt.want || len(matches) != tt.matches {
+			t.Errorf("complete(%q) = %q, %d matches, want %q, %d matches", tt.line, got, len(matches), tt.want, tt.matches)
+		}
+	}
+}
+
+// keyReader returns one key per Read, like a terminal.
+type keyReader struct {
+	keys []string
+}
+
+func (r *keyReader) Read(p []byte) (int, error) {
+	if len(r.keys) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, r.keys[0])
+	r.keys = r.keys[1:]
+	return n, nil
+}
+
+func TestLineEditor(t *testing.T) {
+	editor := &lineEditor{
+		in:      &keyReader{keys: []string{"\x1b[A", "\x7f", "9", "\r"}},
+		out:     io.Discard,
+		history: []string{"on", "brightness 50"},
+	}
+
+	line, err := editor.readLine("> ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line != "brightness 59" {
+		t.Errorf("readLine() = %q, want %q", line, "brightness 59")
+	}
+}
+
+func TestRunCommandInProcess(t *testing.T) {
+	client, _ := newTestClient(t)
+	t.Setenv("HOME", t.TempDir()) // brightness records undo history
+
+	code := runCommandInProcess(client, []string{"brightness", "nope"})
+	if code != 1 {
+		t.Errorf("exit code = %d, want 1", code)
+	}
+
+	code = runCommandInProcess(client, []string{"brightness", "40"})
+	if code != 0 {
+		t.Errorf("exit code = %d, want 0", code)
+	}
+}
+
+func TestRunReplCommand(t *testing.T) {
+	client, server := newTestClient(t)
+	t.Setenv("HOME", t.TempDir()) // brightness records undo history
+	t.Setenv("PATH", t.TempDir())
+	setTestConfig(t, "[aliases]\ndim = brightness 7\n")
+
+	// Aliases are offered by tab completion, so they must run too.
+	if code := runReplCommand(client, []string{"dim"}); code != 0 {
+		t.Fatalf("alias exit code = %d, want 0", code)
+	}
+	if got := server.Device().State.Brightness; got != 7 {
+		t.Errorf("brightness = %d, want 7", got)
+	}
+
+	if code := runReplCommand(client, []string{"nope"}); code != 1 {
+		t.Errorf("unknown command exit code = %d, want 1", code)
+	}
+}
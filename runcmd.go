@@ -0,0 +1,137 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"os/signal"
+	"time"
+)
+
+// runCmdPulse is how long each rise or fall of run-cmd's pulse takes.
+const runCmdPulse = time.Second
+
+// pulseUntil slowly pulses the panels' brightness in a color until done
+// receives, and returns what it received. Failures to update the light are
+// logged, so they don't interrupt whatever is being waited on.
+func pulseUntil(client Client, color RGB, done <-chan error) error {
+	hue, sat, _ := RGBToHSL(color)
+	err := client.SetHSL(hue, sat, 80)
+	if err == nil {
+		err = client.On()
+	}
+	if err != nil {
+		slog.Warn("failed to set Nanoleaf color", "err", err)
+	}
+
+	ticker := time.NewTicker(runCmdPulse)
+	defer ticker.Stop()
+	high := false
+	for {
+		select {
+		case err := <-done:
+			return err
+		case <-ticker.C:
+			level := 20
+			if high {
+				level = 80
+			}
+			high = !high
+
+			err := client.PutState(State{Brightness: &BrightnessProperty{Value: level, Duration: int(runCmdPulse.Seconds())}})
+			if err != nil {
+				slog.Warn("failed to set Nanoleaf brightness", "err", err)
+			}
+		}
+	}
+}
+
+// doRunCmdCommand runs a command, pulsing the panels while it runs and
+// flashing its result afterwards, then restores their previous state. It
+// exits with the command's exit code.
+func doRunCmdCommand(client Client, args []string) {
+	flags := flag.NewFlagSet("run-cmd", flag.ExitOnError)
+	runningName := flags.String("running", "yellow", "Pulse color while the command runs")
+	successName := flags.String("success", "green", "Flash color when the command succeeds")
+	failureName := flags.String("failure", "red", "Flash color when the command fails")
+	flags.Usage = func() {
+		fmt.Println("usage: picoleaf run-cmd [--running <color>] [--success <color>] [--failure <color>] -- <command> [<args>]")
+		exit(1)
+	}
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+	}
+
+	var colors []RGB
+	for _, name := range []string{*runningName, *successName, *failureName} {
+		c, err := parseColor(name)
+		if err != nil {
+			fmt.Println("error:", err)
+			exit(1)
+		}
+		colors = append(colors, c)
+	}
+	running, success, failure := colors[0], colors[1], colors[2]
+
+	snapshot, err := client.Snapshot()
+	if err != nil {
+		fmt.Println("error: failed to get Nanoleaf state:", err)
+		exit(1)
+	}
+
+	cmd := exec.Command(flags.Arg(0), flags.Args()[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	err = cmd.Start()
+	if err != nil {
+		fmt.Println("error:", err)
+		exit(1)
+	}
+
+	// Ctrl-C reaches the command too. Let it decide whether to stop, so the
+	// result is still shown and the panels restored.
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt)
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	err = pulseUntil(client, running, done)
+	signal.Stop(sigs)
+
+	code := 0
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		code = exitErr.ExitCode()
+		if code < 0 {
+			code = 1 // killed by a signal
+		}
+	} else if err != nil {
+		fmt.Println("error:", err)
+		code = 1
+	}
+
+	result := success
+	if code != 0 {
+		result = failure
+	}
+	err = flash(client, result, 100, 2, 300*time.Millisecond)
+	if err != nil {
+		fmt.Println("error: failed to flash Nanoleaf:", err)
+	}
+
+	err = client.Restore(*snapshot)
+	if err != nil {
+		fmt.Println("error: failed to restore Nanoleaf state:", err)
+		if code == 0 {
+			code = 1
+		}
+	}
+	if code != 0 {
+		exit(code)
+	}
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/paulrosania/picoleaf/internal/nltest"
+)
+
+func TestRunCmdCommand(t *testing.T) {
+	client, server := newTestClient(t)
+	server.Update(func(d *nltest.Device) {
+		d.State.On = true
+		d.State.Brightness = 42
+	})
+
+	code := runCommandInProcess(client, []string{"run-cmd", "--", "sh", "-c", "exit 3"})
+	if code != 3 {
+		t.Errorf("exit code = %d, want the command's 3", code)
+	}
+
+	state := server.Device().State
+	if !state.On || state.Brightness != 42 {
+		t.Errorf("device on=%v brightness=%d, want the previous state restored", state.On, state.Brightness)
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+)
+
+// SACNPort is the UDP port E1.31 (sACN) data is sent to.
+const SACNPort = 5568
+
+// E1.31 packet layout. Offsets are from the start of the packet.
+const (
+	sacnVectorRootData    = 0x00000004
+	sacnVectorFramingData = 0x00000002
+	sacnVectorDMPSetProp  = 0x02
+
+	sacnRootVectorOffset    = 18
+	sacnFramingVectorOffset = 40
+	sacnOptionsOffset       = 112
+	sacnUniverseOffset      = 113
+	sacnDMPVectorOffset     = 117
+	sacnPropCountOffset     = 123
+	sacnStartCodeOffset     = 125
+	sacnDataOffset          = 126
+
+	// sacnOptionStreamTerminated is set when a source stops sending.
+	sacnOptionStreamTerminated = 0x40
+)
+
+// sacnPacketIdentifier identifies ACN packets.
+var sacnPacketIdentifier = []byte("ASC-E1.17\x00\x00\x00")
+
+// errSACNStreamTerminated is returned for packets announcing that a source
+// has stopped sending.
+var errSACNStreamTerminated = errors.New("stream terminated")
+
+// parseSACN parses an E1.31 data packet, returning its universe and DMX
+// channel values. Channel 1 is data[0].
+func parseSACN(packet []byte) (int, []byte, error) {
+	if len(packet) < sacnDataOffset {
+		return 0, nil, errors.New("packet too short")
+	}
+	if !bytes.Equal(packet[4:16], sacnPacketIdentifier) {
+		return 0, nil, errors.New("not an ACN packet")
+	}
+	if binary.BigEndian.Uint32(packet[sacnRootVectorOffset:]) != sacnVectorRootData ||
+		binary.BigEndian.Uint32(packet[sacnFramingVectorOffset:]) != sacnVectorFramingData ||
+		packet[sacnDMPVectorOffset] != sacnVectorDMPSetProp {
+		return 0, nil, errors.New("not an E1.31 data packet")
+	}
+
+	universe := int(binary.BigEndian.Uint16(packet[sacnUniverseOffset:]))
+	if packet[sacnOptionsOffset]&sacnOptionStreamTerminated != 0 {
+		return universe, nil, errSACNStreamTerminated
+	}
+	if packet[sacnStartCodeOffset] != 0 {
+		return universe, nil, errors.New("not DMX data")
+	}
+
+	// The property count includes the start code.
+	count := int(binary.BigEndian.Uint16(packet[sacnPropCountOffset:])) - 1
+	if count < 0 || sacnDataOffset+count > len(packet) {
+		return universe, nil, errors.New("invalid property count")
+	}
+	return universe, packet[sacnDataOffset : sacnDataOffset+count], nil
+}
+
+// sacnMulticastGroup returns the multicast address E1.31 data for a universe
+// is sent to.
+func sacnMulticastGroup(universe int) *net.UDPAddr {
+	return &net.UDPAddr{
+		IP:   net.IPv4(239, 255, byte(universe>>8), byte(universe)),
+		Port: SACNPort,
+	}
+}
+
+// dmxToFrames maps DMX channels to panels, three channels (red, green, blue)
+// per panel in layout order, starting at the given 1-based channel. Panels
+// past the end of the data are left out.
+func dmxToFrames(data []byte, start int, panels []PanelPosition) []SetPanelColor {
+	var frames []SetPanelColor
+	for i, panel := range panels {
+		offset := start - 1 + 3*i
+		if offset+3 > len(data) {
+			break
+		}
+		frames = append(frames, SetPanelColor{
+			PanelID: uint16(panel.PanelID),
+			Red:     data[offset],
+			Green:   data[offset+1],
+			Blue:    data[offset+2],
+		})
+	}
+	return frames
+}
+
+// doSACNCommand drives the Nanoleaf from E1.31 (sACN) data, until
+// interrupted.
+func doSACNCommand(client Client, args []string) {
+	flags := flag.NewFlagSet("sacn", flag.ExitOnError)
+	universe := flags.Int("universe", 1, "DMX universe to listen to (1-63999)")
+	start := flags.Int("start", 1, "First DMX channel (1-512)")
+	listen := flags.String("listen", "", "Address to receive unicast sACN on, e.g. :5568 (defaults to multicast)")
+	order := flags.String("order", "layout", panelOrderUsage)
+	selection := flags.String("panels", "", "Panels to drive, e.g. x<300, row:2, nearest:250,400, or a zone (default all)")
+	flags.Usage = func() {
+		fmt.Println("usage: picoleaf sacn [--universe <n>] [--start <channel>] [--listen <addr>] [--order <order>] [--panels <selection>]")
+		exit(1)
+	}
+	flags.Parse(args)
+
+	if flags.NArg() > 0 || *universe < 1 || *universe > 63999 || *start < 1 || *start > 512 {
+		flags.Usage()
+	}
+
+	panelInfo, err := client.CachedPanelInfo()
+	if err != nil {
+		fmt.Println("error: failed to get Nanoleaf info:", err)
+		exit(1)
+	}
+	panels, err := selectPanels(panelInfo.PanelLayout.Layout.PositionData, *selection)
+	if err != nil {
+		fmt.Println("error:", err)
+		exit(1)
+	}
+	panels, err = orderPanels(panels, *order)
+	if err != nil {
+		fmt.Println("error:", err)
+		exit(1)
+	}
+
+	var conn *net.UDPConn
+	if *listen != "" {
+		addr, err := net.ResolveUDPAddr("udp", *listen)
+		if err != nil {
+			fmt.Println("error: invalid listen address:", err)
+			exit(1)
+		}
+		conn, err = net.ListenUDP("udp", addr)
+	} else {
+		conn, err = net.ListenMulticastUDP("udp4", nil, sacnMulticastGroup(*universe))
+	}
+	if err != nil {
+		fmt.Println("error: failed to listen for sACN:", err)
+		exit(1)
+	}
+	defer conn.Close()
+
+	ctx, restore := restoreOnInterrupt(client)
+	defer restore()
+
+	sink, err := openFrameSink(client, panelInfo)
+	if err != nil {
+		fmt.Println("error: failed to start external control:", err)
+		exit(1)
+	}
+	defer sink.Close()
+
+	// Close the socket on Ctrl-C, so the read loop exits.
+	context.AfterFunc(ctx, func() { conn.Close() })
+
+	slog.Info("listening for sACN", "universe", *universe, "start", *start, "panels", len(panels))
+	buf := make([]byte, 1500)
+	for {
+		n, _, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			slog.Error("failed to receive sACN", "err", err)
+			continue
+		}
+
+		u, data, err := parseSACN(buf[:n])
+		if u != *universe {
+			continue
+		}
+		if err == errSACNStreamTerminated {
+			slog.Info("sACN source stopped sending")
+			continue
+		}
+		if err != nil {
+			slog.Debug("ignoring packet", "err", err)
+			continue
+		}
+
+		err = sink.Send(dmxToFrames(data, *start, panels))
+		if err != nil {
+			slog.Error("failed to send frame", "err", err)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+// sacnPacket builds an E1.31 data packet for a universe.
+func sacnPacket(universe int, options byte, data []byte) []byte {
+	packet := make([]byte, sacnDataOffset+len(data))
+	binary.BigEndian.PutUint16(packet[0:], 0x0010)
+	copy(packet[4:], sacnPacketIdentifier)
+	binary.BigEndian.PutUint32(packet[sacnRootVectorOffset:], sacnVectorRootData)
+	binary.BigEndian.PutUint32(packet[sacnFramingVectorOffset:], sacnVectorFramingData)
+	packet[sacnOptionsOffset] = options
+	binary.BigEndian.PutUint16(packet[sacnUniverseOffset:], uint16(universe))
+	packet[sacnDMPVectorOffset] = sacnVectorDMPSetProp
+	packet[118] = 0xa1
+	binary.BigEndian.PutUint16(packet[121:], 1)
+	binary.BigEndian.PutUint16(packet[sacnPropCountOffset:], uint16(len(data)+1))
+	copy(packet[sacnDataOffset:], data)
+	return packet
+}
+
+func TestParseSACN(t *testing.T) {
+	universe, data, err := parseSACN(sacnPacket(7, 0, []byte{1, 2, 3, 4}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if universe != 7 || !reflect.DeepEqual(data, []byte{1, 2, 3, 4}) {
+		t.Errorf("parseSACN() = %d, %v, want 7, [1 2 3 4]", universe, data)
+	}
+
+	_, _, err = parseSACN(sacnPacket(7, sacnOptionStreamTerminated, nil))
+	if err != errSACNStreamTerminated {
+		t.Errorf("terminated stream err = %v, want %v", err, errSACNStreamTerminated)
+	}
+
+	_, _, err = parseSACN([]byte("not sacn"))
+	if err == nil {
+		t.Error("parseSACN() accepted garbage")
+	}
+}
+
+func TestDMXToFrames(t *testing.T) {
+	panels := []PanelPosition{{PanelID: 101}, {PanelID: 102}, {PanelID: 103}}
+	data := []byte{9, 10, 20, 30, 40, 50, 60, 70}
+
+	got := dmxToFrames(data, 2, panels)
+	want := []SetPanelColor{
+		{PanelID: 101, Red: 10, Green: 20, Blue: 30},
+		{PanelID: 102, Red: 40, Green: 50, Blue: 60},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("dmxToFrames() = %v, want %v", got, want)
+	}
+}
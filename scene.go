@@ -0,0 +1,310 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+// sceneSectionPrefix prefixes scene section names in the config file, e.g.
+// `[scene.movie-night]`.
+const sceneSectionPrefix = "scene."
+
+// Scene is a named Nanoleaf state defined in the config file. Unset fields
+// are left unchanged when the scene is applied.
+type Scene struct {
+	Name string
+
+	On               *bool
+	Effect           string
+	Color            *RGB
+	Hue              *int
+	Saturation       *int
+	ColorTemperature *int
+	Brightness       *int
+}
+
+// parseScene reads a scene from a config section.
+func parseScene(name string, section *ini.Section) (*Scene, error) {
+	scene := Scene{Name: name}
+
+	intKey := func(key string, min, max int) (*int, error) {
+		if !section.HasKey(key) {
+			return nil, nil
+		}
+		v, err := section.Key(key).Int()
+		if err != nil || v < min || v > max {
+			return nil, fmt.Errorf("%s must be an integer %d-%d", key, min, max)
+		}
+		return &v, nil
+	}
+
+	var err error
+	if section.HasKey("on") {
+		on, err := section.Key("on").Bool()
+		if err != nil {
+			return nil, fmt.Errorf("on must be true or false")
+		}
+		scene.On = &on
+	}
+	scene.Effect = section.Key("effect").String()
+	if section.HasKey("color") {
+		color, err := parseColor(section.Key("color").String())
+		if err != nil {
+			return nil, err
+		}
+		scene.Color = &color
+	}
+	if scene.Hue, err = intKey("hue", 0, 360); err != nil {
+		return nil, err
+	}
+	if scene.Saturation, err = intKey("sat", 0, 100); err != nil {
+		return nil, err
+	}
+	if scene.ColorTemperature, err = intKey("ct", 1200, 6500); err != nil {
+		return nil, err
+	}
+	if scene.Brightness, err = intKey("brightness", 0, 100); err != nil {
+		return nil, err
+	}
+	return &scene, nil
+}
+
+// loadScene reads the named scene from the config file.
+func loadScene(name string) (*Scene, error) {
+	section, err := cfg.GetSection(sceneSectionPrefix + name)
+	if err != nil {
+		return nil, fmt.Errorf("no scene named %q", name)
+	}
+	return parseScene(name, section)
+}
+
+// sceneNames returns the names of all scenes defined in the config file.
+func sceneNames() []string {
+	var names []string
+	for _, section := range cfg.Sections() {
+		if strings.HasPrefix(section.Name(), sceneSectionPrefix) {
+			names = append(names, strings.TrimPrefix(section.Name(), sceneSectionPrefix))
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ApplyScene sets the Nanoleaf to the given scene. Power is only changed if
+// the scene sets `on`.
+func (c Client) ApplyScene(scene Scene) error {
+	if scene.On != nil && !*scene.On {
+		return c.Off()
+	}
+
+	var err error
+	switch {
+	case scene.Effect != "":
+		err = c.SelectEffect(scene.Effect)
+	case scene.Color != nil:
+		color := c.Calibration.Apply(*scene.Color)
+		hue, sat, lightness := RGBToHSL(color)
+		if scene.Brightness != nil {
+			lightness = *scene.Brightness
+		}
+		err = c.SetHSL(hue, sat, lightness)
+	case (scene.Hue != nil || scene.Saturation != nil) && c.Transition > 0:
+		err = c.rampHueSaturation(scene.Hue, scene.Saturation)
+	case scene.Hue != nil || scene.Saturation != nil:
+		state := State{}
+		if scene.Hue != nil {
+			state.Hue = &HueProperty{Value: *scene.Hue}
+		}
+		if scene.Saturation != nil {
+			state.Saturation = &SaturationProperty{Value: *scene.Saturation}
+		}
+		err = c.PutState(state)
+	case scene.ColorTemperature != nil:
+		err = c.SetColorTemperature(*scene.ColorTemperature)
+	}
+	if err != nil {
+		return err
+	}
+
+	if scene.Brightness != nil && scene.Color == nil {
+		err = c.SetBrightness(*scene.Brightness)
+		if err != nil {
+			return err
+		}
+	}
+
+	if scene.On != nil {
+		return c.On()
+	}
+	return nil
+}
+
+// saveScene writes the snapshot to the config file as a scene.
+func saveScene(name string, s Snapshot) error {
+	cfg.DeleteSection(sceneSectionPrefix + name)
+	section, err := cfg.NewSection(sceneSectionPrefix + name)
+	if err != nil {
+		return err
+	}
+
+	section.NewKey("on", fmt.Sprint(s.On))
+	switch {
+	case s.ColorMode == "effect" && !strings.HasPrefix(s.Effect, "*"):
+		section.NewKey("effect", s.Effect)
+	case s.ColorMode == "ct":
+		section.NewKey("ct", fmt.Sprint(s.ColorTemperature))
+	default:
+		section.NewKey("hue", fmt.Sprint(s.Hue))
+		section.NewKey("sat", fmt.Sprint(s.Saturation))
+	}
+	section.NewKey("brightness", fmt.Sprint(s.Brightness))
+
+	return cfg.SaveTo(configFilePath)
+}
+
+// sceneTarget pairs a device with the scene to apply to it.
+type sceneTarget struct {
+	Device Device
+	Scene  Scene
+}
+
+// loadSceneFile reads a multi-device scene file. Each section is named after
+// a device or group, and holds the same settings as a config file scene:
+//
+//	[office]
+//	ct = 2700
+//	brightness = 20
+//
+//	[livingroom]
+//	effect = Northern Lights
+//
+// The whole file is validated before anything is applied.
+func loadSceneFile(path string) ([]sceneTarget, error) {
+	file, err := loadINI(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var targets []sceneTarget
+	seen := map[string]string{}
+	for _, section := range file.Sections() {
+		if section.Name() == ini.DefaultSection {
+			if len(section.Keys()) > 0 {
+				return nil, fmt.Errorf("settings must be in a device or group section")
+			}
+			continue
+		}
+
+		devices, err := resolveDevices(section.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		scene, err := parseScene(section.Name(), section)
+		if err != nil {
+			return nil, fmt.Errorf("[%s]: %v", section.Name(), err)
+		}
+
+		for _, device := range devices {
+			if prev, ok := seen[device.Name]; ok {
+				return nil, fmt.Errorf("device %q is targeted by both [%s] and [%s]", device.Name, prev, section.Name())
+			}
+			seen[device.Name] = section.Name()
+			targets = append(targets, sceneTarget{Device: device, Scene: *scene})
+		}
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no devices found in %s", path)
+	}
+	return targets, nil
+}
+
+// applySceneTargets applies each scene to its device in parallel, returning
+// one error (or nil) per target.
+func applySceneTargets(targets []sceneTarget) []error {
+	return runParallel(len(targets), *parallelism, func(i int) error {
+		return targets[i].Device.Client().ApplyScene(targets[i].Scene)
+	})
+}
+
+func doSceneCommand(client Client, args []string) {
+	usage := func() {
+		fmt.Println("usage: picoleaf scene <name>")
+		fmt.Println("       picoleaf scene list")
+		fmt.Println("       picoleaf scene apply <file>")
+		fmt.Println("       picoleaf scene save <name>")
+		exit(1)
+	}
+
+	if len(args) < 1 {
+		usage()
+	}
+
+	command := args[0]
+	switch command {
+	case "apply":
+		if len(args) != 2 {
+			fmt.Println("usage: picoleaf scene apply <file>")
+			exit(1)
+		}
+
+		targets, err := loadSceneFile(args[1])
+		if err != nil {
+			fmt.Println("error: invalid scene file:", err)
+			exit(1)
+		}
+
+		failed := false
+		for i, err := range applySceneTargets(targets) {
+			if err != nil {
+				fmt.Printf("%s: error: %v\n", targets[i].Device.Name, err)
+				failed = true
+			} else {
+				fmt.Printf("%s: ok\n", targets[i].Device.Name)
+			}
+		}
+		if failed {
+			exit(1)
+		}
+	case "list":
+		for _, name := range sceneNames() {
+			fmt.Println(name)
+		}
+	case "save":
+		if len(args) != 2 {
+			fmt.Println("usage: picoleaf scene save <name>")
+			exit(1)
+		}
+
+		snapshot, err := client.Snapshot()
+		if err != nil {
+			fmt.Println("error: failed to get Nanoleaf state:", err)
+			exit(1)
+		}
+
+		err = saveScene(args[1], *snapshot)
+		if err != nil {
+			fmt.Println("error: failed to save scene:", err)
+			exit(1)
+		}
+	default:
+		if len(args) != 1 {
+			usage()
+		}
+
+		scene, err := loadScene(command)
+		if err != nil {
+			fmt.Println("error:", err)
+			exit(1)
+		}
+
+		err = client.ApplyScene(*scene)
+		if err != nil {
+			fmt.Println("error: failed to apply scene:", err)
+			exit(1)
+		}
+	}
+}
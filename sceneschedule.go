@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"gopkg.in/ini.v1"
+)
+
+// sceneScheduleSection maps times of day to the scenes the daemon applies
+// then, e.g. `07:00 = energize` or `sunset-30m = relax`.
+const sceneScheduleSection = "daemon.schedule"
+
+// ScheduledScene is a scene applied every day at a wall-clock or solar time.
+type ScheduledScene struct {
+	At    string // as configured, e.g. "07:00" or "sunset-30m"
+	Solar *SolarTime
+	Scene string
+}
+
+// Next returns the first time the scene is due after now.
+func (s ScheduledScene) Next(now time.Time, coords Coordinates) (time.Time, error) {
+	if s.Solar != nil {
+		return s.Solar.Next(now, coords)
+	}
+	return parseClockTime(s.At, now)
+}
+
+// parseScheduledScene parses a `[daemon.schedule]` entry.
+func parseScheduledScene(key *ini.Key) (ScheduledScene, error) {
+	entry := ScheduledScene{At: key.Name(), Scene: key.String()}
+	if isSolarTime(entry.At) {
+		st, err := ParseSolarTime(entry.At)
+		if err != nil {
+			return entry, err
+		}
+		entry.Solar = &st
+	} else if _, err := parseClockTime(entry.At, time.Now()); err != nil {
+		return entry, err
+	}
+
+	if _, err := cfg.GetSection(sceneSectionPrefix + entry.Scene); err != nil {
+		return entry, fmt.Errorf("%s: no scene named %q", entry.At, entry.Scene)
+	}
+	return entry, nil
+}
+
+// loadSceneSchedule reads the scenes scheduled in `[daemon.schedule]`.
+func loadSceneSchedule() ([]ScheduledScene, error) {
+	var entries []ScheduledScene
+	for _, key := range cfg.Section(sceneScheduleSection).Keys() {
+		entry, err := parseScheduledScene(key)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// dueScenes returns the entries due after from, up to and including to, in
+// the order they fell due.
+func dueScenes(entries []ScheduledScene, from, to time.Time, coords Coordinates) []ScheduledScene {
+	type dueScene struct {
+		entry ScheduledScene
+		at    time.Time
+	}
+	var due []dueScene
+	for _, entry := range entries {
+		t, err := entry.Next(from, coords)
+		if err != nil {
+			// E.g. no sunset during a polar day.
+			slog.Debug("scheduled scene isn't due", "at", entry.At, "scene", entry.Scene, "err", err)
+			continue
+		}
+		if !t.After(to) {
+			due = append(due, dueScene{entry, t})
+		}
+	}
+	sort.SliceStable(due, func(i, j int) bool { return due[i].at.Before(due[j].at) })
+
+	scenes := make([]ScheduledScene, len(due))
+	for i, d := range due {
+		scenes[i] = d.entry
+	}
+	return scenes
+}
+
+// runSceneSchedule applies scheduled scenes as they fall due, forever.
+// Scenes that fell due while the machine was asleep are applied when it
+// wakes, the latest last.
+func runSceneSchedule(client Client, entries []ScheduledScene, coords Coordinates) {
+	last := time.Now()
+	for {
+		time.Sleep(time.Until(last.Truncate(time.Minute).Add(time.Minute)))
+		now := time.Now()
+		for _, entry := range dueScenes(entries, last, now, coords) {
+			slog.Info("applying scheduled scene", "at", entry.At, "scene", entry.Scene)
+			scene, err := loadScene(entry.Scene)
+			if err == nil {
+				err = client.ApplyScene(*scene)
+			}
+			if err != nil {
+				slog.Error("scheduled scene failed", "at", entry.At, "scene", entry.Scene, "err", err)
+			}
+		}
+		last = now
+	}
+}
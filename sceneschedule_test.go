@@ -0,0 +1,65 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLoadSceneSchedule(t *testing.T) {
+	setTestConfig(t, "[scene.energize]\nct=6500\n[scene.relax]\nct=2700\n"+
+		"[daemon.schedule]\n07:00 = energize\nsunset-30m = relax\n")
+
+	got, err := loadSceneSchedule()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ScheduledScene{
+		{At: "07:00", Scene: "energize"},
+		{At: "sunset-30m", Solar: &SolarTime{Event: "sunset", Offset: -30 * time.Minute}, Scene: "relax"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("loadSceneSchedule() = %+v, want %+v", got, want)
+	}
+
+	for _, schedule := range []string{"7 o'clock = energize", "sunrise+soon = energize", "22:00 = sleepy"} {
+		setTestConfig(t, "[scene.energize]\nct=6500\n[daemon.schedule]\n"+schedule+"\n")
+		if _, err := loadSceneSchedule(); err == nil {
+			t.Errorf("loadSceneSchedule() with %q succeeded", schedule)
+		}
+	}
+}
+
+func TestDueScenes(t *testing.T) {
+	london := Coordinates{Latitude: 51.5, Longitude: -0.13}
+	sunset := SolarTime{Event: "sunset"}
+	entries := []ScheduledScene{
+		{At: "sunset", Solar: &sunset, Scene: "relax"},
+		{At: "07:00", Scene: "energize"},
+		{At: "06:30", Scene: "wake"},
+	}
+	names := func(scenes []ScheduledScene) []string {
+		var names []string
+		for _, s := range scenes {
+			names = append(names, s.Scene)
+		}
+		return names
+	}
+
+	day := time.Date(2024, 6, 21, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		from, to time.Time
+		want     []string
+	}{
+		{day.Add(6*time.Hour + 59*time.Minute), day.Add(7 * time.Hour), []string{"energize"}},
+		{day.Add(7 * time.Hour), day.Add(7*time.Hour + time.Minute), nil},
+		// After sleeping through the morning.
+		{day.Add(5 * time.Hour), day.Add(8 * time.Hour), []string{"wake", "energize"}},
+		{day.Add(20 * time.Hour), day.Add(21 * time.Hour), []string{"relax"}},
+	}
+	for _, tt := range tests {
+		if got := names(dueScenes(entries, tt.from, tt.to, london)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("dueScenes(%s, %s) = %q, want %q", tt.from.Format("15:04"), tt.to.Format("15:04"), got, tt.want)
+		}
+	}
+}
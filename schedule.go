@@ -0,0 +1,208 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// clockTimeLayouts are the accepted layouts for wall-clock times.
+var clockTimeLayouts = []string{"15:04", "15:04:05", "3:04pm", "3pm"}
+
+// parseClockTime returns the next occurrence of the wall-clock time s after now.
+func parseClockTime(s string, now time.Time) (time.Time, error) {
+	for _, layout := range clockTimeLayouts {
+		t, err := time.ParseInLocation(layout, strings.ToLower(s), now.Location())
+		if err != nil {
+			continue
+		}
+
+		next := time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), t.Second(), 0, now.Location())
+		if !next.After(now) {
+			next = next.AddDate(0, 0, 1)
+		}
+		return next, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q, expected HH:MM", s)
+}
+
+// parseAtTime parses a wall-clock time like `22:30` or a solar time like
+// `sunset-30m`, returning its next occurrence after now.
+func parseAtTime(s string, now time.Time) (time.Time, error) {
+	if !isSolarTime(s) {
+		return parseClockTime(s, now)
+	}
+
+	st, err := ParseSolarTime(s)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	coords, err := loadCoordinates()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to determine location: %v", err)
+	}
+	return st.Next(now, coords)
+}
+
+func doAtCommand(client Client, args []string) {
+	flags := flag.NewFlagSet("at", flag.ExitOnError)
+	detach := flags.Bool("detach", false, "Run in the background")
+	flags.Usage = func() {
+		fmt.Println("usage: picoleaf at [--detach] <time> -- <command> [<args>]")
+		exit(1)
+	}
+	flags.Parse(args)
+
+	if flags.NArg() < 2 {
+		flags.Usage()
+	}
+
+	when, err := parseAtTime(flags.Arg(0), time.Now())
+	if err != nil {
+		fmt.Println("error:", err)
+		exit(1)
+	}
+
+	runScheduledCommand(client, "at", flags.Arg(0), when, scheduledCommandArgs(flags.Args()[1:], flags.Usage), *detach)
+}
+
+func doInCommand(client Client, args []string) {
+	flags := flag.NewFlagSet("in", flag.ExitOnError)
+	detach := flags.Bool("detach", false, "Run in the background")
+	flags.Usage = func() {
+		fmt.Println("usage: picoleaf in [--detach] <duration> -- <command> [<args>]")
+		exit(1)
+	}
+	flags.Parse(args)
+
+	if flags.NArg() < 2 {
+		flags.Usage()
+	}
+
+	delay, err := time.ParseDuration(flags.Arg(0))
+	if err != nil || delay < 0 {
+		fmt.Println("error: delay must be a duration, e.g. 45m or 2h")
+		exit(1)
+	}
+
+	runScheduledCommand(client, "in", flags.Arg(0), time.Now().Add(delay), scheduledCommandArgs(flags.Args()[1:], flags.Usage), *detach)
+}
+
+// scheduledCommandArgs strips the optional `--` separator preceding a
+// wrapped subcommand.
+func scheduledCommandArgs(args []string, usage func()) []string {
+	if len(args) > 0 && args[0] == "--" {
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		usage()
+	}
+	return args
+}
+
+// runScheduledCommand waits until the given time, then runs the wrapped
+// subcommand. If detach is set, picoleaf runs `<command> <timeArg> -- <args>`
+// again in the background, and returns immediately.
+func runScheduledCommand(client Client, command, timeArg string, when time.Time, args []string, detach bool) {
+	if detach {
+		pid, err := detachSelf(command, timeArg, args)
+		if err != nil {
+			fmt.Println("error: failed to start background process:", err)
+			exit(1)
+		}
+		fmt.Printf("Scheduled `%s` for %s (pid %d)\n", strings.Join(args, " "), when.Format(time.Kitchen), pid)
+		return
+	}
+
+	fmt.Printf("Running `%s` at %s (Ctrl-C to cancel)\n", strings.Join(args, " "), when.Format(time.Kitchen))
+	if !sleepOrCancel(time.Until(when)) {
+		fmt.Println("Timer cancelled")
+		return
+	}
+
+	runCommand(client, args)
+}
+
+// detachedCommand returns the command detachSelf runs: the at or in
+// command again, without --detach, for the current device and global flags.
+func detachedCommand(command, timeArg string, args []string) (*exec.Cmd, error) {
+	return subcommand(*deviceName, append([]string{command, timeArg, "--"}, args...))
+}
+
+// detachSelf runs the at or in command in a background process, returning
+// its process ID.
+func detachSelf(command, timeArg string, args []string) (int, error) {
+	cmd, err := detachedCommand(command, timeArg, args)
+	if err != nil {
+		return 0, err
+	}
+	cmd.SysProcAttr = detachedProcAttr()
+	err = cmd.Start()
+	if err != nil {
+		return 0, err
+	}
+
+	pid := cmd.Process.Pid
+	return pid, cmd.Process.Release()
+}
+
+// runSubcommand runs a picoleaf command in a child process, with the current
+// config file, device, and logging options, so a failing command can't take
+// down the long-running mode that started it.
+func runSubcommand(args []string) error {
+	cmd, err := subcommand(*deviceName, args)
+	if err != nil {
+		return err
+	}
+
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// subcommand returns a command that runs picoleaf against the named device
+// (or the default device, if empty) with the current config file and
+// logging options.
+func subcommand(device string, args []string) (*exec.Cmd, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+
+	// Always pass -d, so children don't pick up a device remembered since.
+	if device == "" {
+		device = defaultDeviceName
+	}
+	childArgs := []string{"-f", configFilePath, "-d", device}
+	if *verbose {
+		childArgs = append(childArgs, "-v")
+	}
+	if *insecureTLS {
+		childArgs = append(childArgs, "-insecure")
+	}
+	if *logFilePath != "" {
+		childArgs = append(childArgs, "-log", *logFilePath)
+	}
+	if *logLevel != "" {
+		childArgs = append(childArgs, "-log-level", *logLevel)
+	}
+	childArgs = append(childArgs, "-log-format", *logFormat)
+	if *transition > 0 {
+		childArgs = append(childArgs, "-transition", transition.String())
+	}
+	if *refreshCache {
+		childArgs = append(childArgs, "-refresh")
+	}
+	if *queueOffline {
+		childArgs = append(childArgs, "-queue")
+	}
+	childArgs = append(childArgs, args...)
+
+	cmd := exec.Command(exe, childArgs...)
+	cmd.Env = append(os.Environ(), subcommandEnv+"=1")
+	return cmd, nil
+}
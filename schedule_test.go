@@ -0,0 +1,47 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDetachedCommand(t *testing.T) {
+	prevDevice, prevConfig := *deviceName, configFilePath
+	t.Cleanup(func() { *deviceName, configFilePath = prevDevice, prevConfig })
+	*deviceName, configFilePath = "office", "/tmp/picoleafrc"
+
+	// The wrapped command's own --detach is kept, and the child doesn't
+	// detach again.
+	cmd, err := detachedCommand("at", "22:00", []string{"run-cmd", "--detach", "make"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	args := cmd.Args[1:]
+	if got, want := args[:4], []string{"-f", "/tmp/picoleafrc", "-d", "office"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("child global flags = %q, want %q", got, want)
+	}
+	if got, want := args[len(args)-6:], []string{"at", "22:00", "--", "run-cmd", "--detach", "make"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("child command = %q, want %q", got, want)
+	}
+}
+
+func TestParseClockTime(t *testing.T) {
+	now := time.Date(2024, 3, 9, 21, 0, 0, 0, time.UTC)
+	tests := map[string]time.Time{
+		"22:30":    time.Date(2024, 3, 9, 22, 30, 0, 0, time.UTC),
+		"20:00":    time.Date(2024, 3, 10, 20, 0, 0, 0, time.UTC),
+		"21:00:30": time.Date(2024, 3, 9, 21, 0, 30, 0, time.UTC),
+		"7am":      time.Date(2024, 3, 10, 7, 0, 0, 0, time.UTC),
+		"9:15PM":   time.Date(2024, 3, 9, 21, 15, 0, 0, time.UTC),
+	}
+	for in, want := range tests {
+		got, err := parseClockTime(in, now)
+		if err != nil || !got.Equal(want) {
+			t.Errorf("parseClockTime(%q) = %s, %v, want %s", in, got, err, want)
+		}
+	}
+	if _, err := parseClockTime("25:00", now); err == nil {
+		t.Error("parseClockTime(25:00) succeeded")
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// scriptStep is a line of a picoleaf script: a command, a pause, or a loop.
+type scriptStep struct {
+	Line int
+
+	// Args is the command to run, e.g. ["effect", "select", "Flames"].
+	Args []string
+
+	// Pause is set for `sleep <duration>` lines.
+	Pause time.Duration
+
+	// Loop is set for `repeat [<count>]` blocks, which run Body Count times,
+	// or forever if Count is zero.
+	Loop  bool
+	Count int
+	Body  []scriptStep
+}
+
+// parseScript parses a picoleaf script. Each line is a picoleaf command, a
+// `sleep <duration>` pause, or a `repeat [<count>]` ... `end` loop. Blank
+// lines and lines starting with # are ignored.
+func parseScript(r io.Reader) ([]scriptStep, error) {
+	// stack holds the steps of each open block; the top level is first.
+	stack := [][]scriptStep{nil}
+	var loops []scriptStep
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		args, err := splitArgs(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+
+		step := scriptStep{Line: line}
+		switch {
+		case args[0] == "repeat":
+			if len(args) > 2 {
+				return nil, fmt.Errorf("line %d: expected repeat [<count>]", line)
+			}
+			step.Loop = true
+			if len(args) == 2 {
+				step.Count, err = strconv.Atoi(args[1])
+				if err != nil || step.Count < 1 {
+					return nil, fmt.Errorf("line %d: invalid repeat count %q", line, args[1])
+				}
+			}
+			loops = append(loops, step)
+			stack = append(stack, nil)
+			continue
+		case args[0] == "end":
+			if len(loops) == 0 {
+				return nil, fmt.Errorf("line %d: end without repeat", line)
+			}
+			step = loops[len(loops)-1]
+			step.Body = stack[len(stack)-1]
+			loops = loops[:len(loops)-1]
+			stack = stack[:len(stack)-1]
+		case args[0] == "sleep" && len(args) == 2 && !strings.HasPrefix(args[1], "-"):
+			// `sleep <duration>` pauses; `sleep --duration ...` is the
+			// sleep command.
+			step.Pause, err = time.ParseDuration(args[1])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid duration %q", line, args[1])
+			}
+		default:
+			step.Args = args
+		}
+		stack[len(stack)-1] = append(stack[len(stack)-1], step)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(loops) > 0 {
+		return nil, fmt.Errorf("line %d: repeat without end", loops[len(loops)-1].Line)
+	}
+	return stack[0], nil
+}
+
+// errScriptCancelled is returned by runScript when interrupted during a
+// pause.
+var errScriptCancelled = errors.New("cancelled")
+
+// runScript runs script steps in order, stopping at the first command that
+// fails.
+func runScript(steps []scriptStep, run func(args []string) int) error {
+	for _, step := range steps {
+		switch {
+		case step.Loop:
+			for i := 0; step.Count == 0 || i < step.Count; i++ {
+				err := runScript(step.Body, run)
+				if err != nil {
+					return err
+				}
+			}
+		case step.Pause > 0:
+			if !sleepOrCancel(step.Pause) {
+				return errScriptCancelled
+			}
+		default:
+			code := run(step.Args)
+			if code != 0 {
+				return fmt.Errorf("line %d: %s failed (exit status %d)", step.Line, step.Args[0], code)
+			}
+		}
+	}
+	return nil
+}
+
+// doRunCommand runs a picoleaf script, sharing one client (and its
+// connections) between all of its commands.
+func doRunCommand(client Client, args []string) {
+	if len(args) != 1 {
+		fmt.Println("usage: picoleaf run <script> | -")
+		exit(1)
+	}
+
+	in := os.Stdin
+	if args[0] != "-" {
+		f, err := os.Open(args[0])
+		if err != nil {
+			fmt.Println("error: failed to open script:", err)
+			exit(1)
+		}
+		defer f.Close()
+		in = f
+	}
+
+	steps, err := parseScript(in)
+	if err != nil {
+		fmt.Println("error:", err)
+		exit(1)
+	}
+
+	err = runScript(steps, func(args []string) int {
+		if args[0] == "run" || args[0] == "repl" {
+			fmt.Printf("error: %s can't be used in a script\n", args[0])
+			return 1
+		}
+		return runCommandInProcess(client, args)
+	})
+	if err == errScriptCancelled {
+		return
+	}
+	if err != nil {
+		fmt.Println("error:", err)
+		exit(1)
+	}
+}
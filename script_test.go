@@ -0,0 +1,95 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseScript(t *testing.T) {
+	script := `# warm up
+on
+sleep 1s
+repeat 2
+  rgb 255 0 0
+  repeat
+    effect select "Northern Lights"
+  end
+end
+sleep --duration 20m
+`
+	steps, err := parseScript(strings.NewReader(script))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []scriptStep{
+		{Line: 2, Args: []string{"on"}},
+		{Line: 3, Pause: time.Second},
+		{Line: 4, Loop: true, Count: 2, Body: []scriptStep{
+			{Line: 5, Args: []string{"rgb", "255", "0", "0"}},
+			{Line: 6, Loop: true, Body: []scriptStep{
+				{Line: 7, Args: []string{"effect", "select", "Northern Lights"}},
+			}},
+		}},
+		{Line: 10, Args: []string{"sleep", "--duration", "20m"}},
+	}
+	if !reflect.DeepEqual(steps, want) {
+		t.Errorf("parseScript = %+v, want %+v", steps, want)
+	}
+}
+
+func TestParseScriptErrors(t *testing.T) {
+	tests := []struct {
+		script string
+		want   string
+	}{
+		{"on\nend", "line 2: end without repeat"},
+		{"repeat 2\non", "line 1: repeat without end"},
+		{"repeat zero\nend", `line 1: invalid repeat count "zero"`},
+		{"sleep soon", `line 1: invalid duration "soon"`},
+		{`effect select "Nemo`, "line 1: unterminated quote"},
+	}
+	for _, tt := range tests {
+		_, err := parseScript(strings.NewReader(tt.script))
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("parseScript(%q) error = %v, want %q", tt.script, err, tt.want)
+		}
+	}
+}
+
+func TestRunScript(t *testing.T) {
+	client, server := newTestClient(t)
+	t.Setenv("HOME", t.TempDir()) // brightness records undo history
+
+	steps, err := parseScript(strings.NewReader("repeat 2\nbrightness 40\nend\noff\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ran []string
+	err = runScript(steps, func(args []string) int {
+		ran = append(ran, args[0])
+		return runCommandInProcess(client, args)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"brightness", "brightness", "off"}; !reflect.DeepEqual(ran, want) {
+		t.Errorf("ran %v, want %v", ran, want)
+	}
+	if state := server.Device().State; state.On || state.Brightness != 40 {
+		t.Errorf("state = %+v, want off at brightness 40", state)
+	}
+
+	steps, _ = parseScript(strings.NewReader("on\nbrightness nope\noff\n"))
+	err = runScript(steps, func(args []string) int {
+		return runCommandInProcess(client, args)
+	})
+	if err == nil || !strings.HasPrefix(err.Error(), "line 2: brightness failed") {
+		t.Errorf("runScript error = %v, want line 2 failure", err)
+	}
+	if !server.Device().State.On {
+		t.Error("script kept running after a failed command")
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+// encryptedPrefix marks an encrypted config value.
+const encryptedPrefix = "enc:"
+
+// secretKeyEnv may hold the base64 secret key, instead of the key file.
+const secretKeyEnv = "PICOLEAF_KEY"
+
+// secretKeyPath returns the path of the key file, in the state directory.
+func secretKeyPath() (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "key"), nil
+}
+
+// loadSecretKey returns the key for encrypted config values, from
+// PICOLEAF_KEY or the key file. If create is set and there's no key yet, a
+// new one is generated and saved.
+func loadSecretKey(create bool) ([]byte, error) {
+	encoded := os.Getenv(secretKeyEnv)
+	if encoded == "" {
+		path, err := secretKeyPath()
+		if err != nil {
+			return nil, err
+		}
+		data, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) && create {
+			key := make([]byte, 32)
+			if _, err := rand.Read(key); err != nil {
+				return nil, err
+			}
+			return key, writeFileAtomic(path, []byte(base64.StdEncoding.EncodeToString(key)+"\n"))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("no key for encrypted config values: %v", err)
+		}
+		encoded = string(data)
+	}
+
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil || len(key) != 32 {
+		return nil, errors.New("invalid key for encrypted config values, expected 32 bytes of base64")
+	}
+	return key, nil
+}
+
+// encryptSecret encrypts a config value with AES-256-GCM.
+func encryptSecret(key []byte, plaintext string) (string, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return encryptedPrefix + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// decryptSecret decrypts a value from encryptSecret.
+func decryptSecret(key []byte, value string) (string, error) {
+	sealed, err := base64.RawStdEncoding.DecodeString(strings.TrimPrefix(value, encryptedPrefix))
+	if err != nil {
+		return "", err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return "", errors.New("too short")
+	}
+	plaintext, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", errors.New("wrong key, or corrupted value")
+	}
+	return string(plaintext), nil
+}
+
+// decryptConfig decrypts a config file's encrypted values up front, so a
+// missing key fails early, and makes reading them return the plaintext.
+// Saving the file keeps them encrypted.
+func decryptConfig(f *ini.File) error {
+	var key []byte
+	plaintexts := make(map[string]string)
+	for _, section := range f.Sections() {
+		for _, k := range section.Keys() {
+			value := k.Value()
+			if !strings.HasPrefix(value, encryptedPrefix) {
+				continue
+			}
+
+			if key == nil {
+				var err error
+				key, err = loadSecretKey(false)
+				if err != nil {
+					return err
+				}
+			}
+			plaintext, err := decryptSecret(key, value)
+			if err != nil {
+				return fmt.Errorf("failed to decrypt %s: %v", k.Name(), err)
+			}
+			plaintexts[value] = plaintext
+		}
+	}
+
+	if len(plaintexts) > 0 {
+		f.ValueMapper = func(value string) string {
+			if plaintext, ok := plaintexts[value]; ok {
+				return plaintext
+			}
+			return value
+		}
+	}
+	return nil
+}
+
+// doEncryptCommand prints an encrypted config value, creating the key on
+// first use. The value is read from stdin if not given, to keep it out of
+// shell history.
+func doEncryptCommand(client Client, args []string) {
+	if len(args) > 1 {
+		fmt.Println("usage: picoleaf encrypt [<value>]")
+		exit(1)
+	}
+
+	var value string
+	if len(args) == 1 {
+		value = args[0]
+	} else {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			fmt.Println("error: failed to read value:", err)
+			exit(1)
+		}
+		value = strings.TrimRight(line, "\r\n")
+	}
+
+	key, err := loadSecretKey(true)
+	if err != nil {
+		fmt.Println("error:", err)
+		exit(1)
+	}
+	encrypted, err := encryptSecret(key, value)
+	if err != nil {
+		fmt.Println("error:", err)
+		exit(1)
+	}
+	fmt.Println(encrypted)
+}
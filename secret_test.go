@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestEncryptedConfig(t *testing.T) {
+	key := make([]byte, 32)
+	for i := range key {
+		key[i] = byte(i)
+	}
+	t.Setenv(secretKeyEnv, base64.StdEncoding.EncodeToString(key))
+
+	encrypted, err := encryptSecret(key, "s3cret-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(encrypted, encryptedPrefix) || strings.Contains(encrypted, "s3cret") {
+		t.Fatalf("encryptSecret = %q", encrypted)
+	}
+
+	setTestConfig(t, "host = nanoleaf.local\naccess_token = "+encrypted+"\n")
+	if token := cfg.Section("").Key("access_token").String(); token != "s3cret-token" {
+		t.Errorf("access_token = %q, want it decrypted", token)
+	}
+
+	// Saving keeps the value encrypted.
+	var saved strings.Builder
+	if _, err := cfg.WriteTo(&saved); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(saved.String(), encrypted) {
+		t.Errorf("saved config = %q, want the encrypted value", saved.String())
+	}
+
+	key[0] ^= 1
+	t.Setenv(secretKeyEnv, base64.StdEncoding.EncodeToString(key))
+	_, err = loadINI([]byte("access_token = " + encrypted + "\n"))
+	if err == nil || !strings.Contains(err.Error(), "wrong key") {
+		t.Errorf("loading with the wrong key: err = %v, want wrong key", err)
+	}
+}
+
+func TestLoadSecretKeyCreate(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv(secretKeyEnv, "")
+
+	if _, err := loadSecretKey(false); err == nil {
+		t.Error("loadSecretKey(false) succeeded with no key")
+	}
+	created, err := loadSecretKey(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := loadSecretKey(false)
+	if err != nil || string(loaded) != string(created) {
+		t.Errorf("reloaded key = %x, %v, want %x", loaded, err, created)
+	}
+}
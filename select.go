@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// selectPanels returns the panels matching a selection expression, in layout
+// order. An expression is one of:
+//
+//	x<300, y>=200    panels left of, above, etc. a coordinate
+//	nearest:250,400  the panel closest to a point
+//	row:2, col:3     a row (from the bottom) or column (from the left),
+//	                 numbered from 1, e.g. on Canvas
+//	r2c3             the panel in row 2 and column 3, numbered as above
+//	left,104         panel IDs and zone names, as for parsePanels
+//
+// An empty expression selects every panel. It's an error for an expression
+// to match no panels.
+func selectPanels(panels []PanelPosition, expr string) ([]PanelPosition, error) {
+	selected, err := matchPanels(panels, expr)
+	if err == nil && len(selected) == 0 {
+		err = fmt.Errorf("no panels match %q", expr)
+	}
+	return selected, err
+}
+
+// matchPanels returns the panels matching a selection expression.
+func matchPanels(panels []PanelPosition, expr string) ([]PanelPosition, error) {
+	switch {
+	case expr == "":
+		return panels, nil
+	case strings.HasPrefix(expr, "nearest:"):
+		return selectNearest(panels, strings.TrimPrefix(expr, "nearest:"))
+	case strings.HasPrefix(expr, "row:"):
+		return selectLine(panels, strings.TrimPrefix(expr, "row:"), func(p PanelPosition) int { return p.Y })
+	case strings.HasPrefix(expr, "col:"):
+		return selectLine(panels, strings.TrimPrefix(expr, "col:"), func(p PanelPosition) int { return p.X })
+	case strings.HasPrefix(expr, "x") || strings.HasPrefix(expr, "y"):
+		if strings.ContainsAny(expr, "<>") {
+			return selectComparison(panels, expr)
+		}
+	case strings.HasPrefix(expr, "r"):
+		if row, col, ok := parseGridCell(expr); ok {
+			return selectCell(panels, row, col)
+		}
+	}
+
+	ids, err := parsePanels(expr)
+	if err != nil {
+		return nil, err
+	}
+	wanted := make(map[int]bool)
+	for _, id := range ids {
+		wanted[int(id)] = true
+	}
+	return filterPanels(panels, func(p PanelPosition) bool { return wanted[p.PanelID] }), nil
+}
+
+// selectComparison selects panels with expressions like `x<300`.
+func selectComparison(panels []PanelPosition, expr string) ([]PanelPosition, error) {
+	coord := func(p PanelPosition) int { return p.X }
+	if expr[0] == 'y' {
+		coord = func(p PanelPosition) int { return p.Y }
+	}
+
+	op, rest := expr[1:2], expr[2:]
+	if strings.HasPrefix(rest, "=") {
+		op, rest = op+"=", rest[1:]
+	}
+	value, err := strconv.Atoi(rest)
+	if err != nil {
+		return nil, fmt.Errorf("invalid panel selection %q", expr)
+	}
+
+	var match func(int) bool
+	switch op {
+	case "<":
+		match = func(v int) bool { return v < value }
+	case "<=":
+		match = func(v int) bool { return v <= value }
+	case ">":
+		match = func(v int) bool { return v > value }
+	case ">=":
+		match = func(v int) bool { return v >= value }
+	default:
+		return nil, fmt.Errorf("invalid panel selection %q", expr)
+	}
+	return filterPanels(panels, func(p PanelPosition) bool { return match(coord(p)) }), nil
+}
+
+// selectNearest selects the panel closest to the point `<x>,<y>`.
+func selectNearest(panels []PanelPosition, point string) ([]PanelPosition, error) {
+	xs, ys, ok := strings.Cut(point, ",")
+	x, xErr := strconv.Atoi(xs)
+	y, yErr := strconv.Atoi(ys)
+	if !ok || xErr != nil || yErr != nil {
+		return nil, fmt.Errorf("expected nearest:<x>,<y>, got nearest:%s", point)
+	}
+
+	var nearest []PanelPosition
+	best := -1
+	for _, p := range panels {
+		dx, dy := p.X-x, p.Y-y
+		if d := dx*dx + dy*dy; best < 0 || d < best {
+			nearest, best = []PanelPosition{p}, d
+		}
+	}
+	return nearest, nil
+}
+
+// selectLine selects the panels in the nth distinct value of coord, counting
+// from 1 at the lowest value.
+func selectLine(panels []PanelPosition, n string, coord func(PanelPosition) int) ([]PanelPosition, error) {
+	values := distinctCoords(panels, coord)
+	i, err := strconv.Atoi(n)
+	if err != nil || i < 1 || i > len(values) {
+		return nil, fmt.Errorf("expected a row or column between 1-%d, got %s", len(values), n)
+	}
+	return filterPanels(panels, func(p PanelPosition) bool { return coord(p) == values[i-1] }), nil
+}
+
+// parseGridCell splits a grid cell like `r2c3` into its row and column.
+func parseGridCell(expr string) (string, string, bool) {
+	row, col, ok := strings.Cut(strings.TrimPrefix(expr, "r"), "c")
+	_, rowErr := strconv.Atoi(row)
+	_, colErr := strconv.Atoi(col)
+	return row, col, ok && rowErr == nil && colErr == nil
+}
+
+// selectCell selects the panels in both the given row and column, numbered
+// as by selectLine.
+func selectCell(panels []PanelPosition, row, col string) ([]PanelPosition, error) {
+	inRow, err := selectLine(panels, row, func(p PanelPosition) int { return p.Y })
+	if err != nil {
+		return nil, err
+	}
+	inCol, err := selectLine(panels, col, func(p PanelPosition) int { return p.X })
+	if err != nil {
+		return nil, err
+	}
+	x := inCol[0].X
+	return filterPanels(inRow, func(p PanelPosition) bool { return p.X == x }), nil
+}
+
+// distinctCoords returns the distinct values of coord among panels, in
+// ascending order.
+func distinctCoords(panels []PanelPosition, coord func(PanelPosition) int) []int {
+	seen := make(map[int]bool)
+	var values []int
+	for _, p := range panels {
+		if !seen[coord(p)] {
+			seen[coord(p)] = true
+			values = append(values, coord(p))
+		}
+	}
+	sort.Ints(values)
+	return values
+}
+
+// filterPanels returns the panels for which keep returns true.
+func filterPanels(panels []PanelPosition, keep func(PanelPosition) bool) []PanelPosition {
+	var kept []PanelPosition
+	for _, p := range panels {
+		if keep(p) {
+			kept = append(kept, p)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSelectPanels(t *testing.T) {
+	setTestConfig(t, "zone.top = 4,3\n")
+
+	// A 2x2 Canvas grid.
+	panels := []PanelPosition{
+		{PanelID: 1, X: 0, Y: 0},
+		{PanelID: 2, X: 100, Y: 0},
+		{PanelID: 3, X: 0, Y: 100},
+		{PanelID: 4, X: 100, Y: 100},
+	}
+
+	tests := []struct {
+		expr string
+		want []int
+	}{
+		{"", []int{1, 2, 3, 4}},
+		{"x<50", []int{1, 3}},
+		{"x>=100", []int{2, 4}},
+		{"y<=0", []int{1, 2}},
+		{"y>0", []int{3, 4}},
+		{"nearest:90,20", []int{2}},
+		{"row:2", []int{3, 4}},
+		{"col:1", []int{1, 3}},
+		{"r2c1", []int{3}},
+		{"r1c2", []int{2}},
+		{"top", []int{3, 4}},
+		{"2,1", []int{1, 2}},
+	}
+	for _, tt := range tests {
+		selected, err := selectPanels(panels, tt.expr)
+		if err != nil {
+			t.Errorf("selectPanels(%q): %v", tt.expr, err)
+			continue
+		}
+		var got []int
+		for _, p := range selected {
+			got = append(got, p.PanelID)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("selectPanels(%q) = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+
+	for _, expr := range []string{"x<", "x=5", "y<-10", "nearest:1", "row:3", "col:0", "r3c1", "r1c0", "rc", "bottom", "9"} {
+		if _, err := selectPanels(panels, expr); err == nil {
+			t.Errorf("selectPanels(%q) succeeded, want error", expr)
+		}
+	}
+}
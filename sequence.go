@@ -0,0 +1,80 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// colorSequence steps through a list of colors, holding each one and then
+// fading to the next.
+type colorSequence struct {
+	Colors []RGB
+	Hold   time.Duration
+	Fade   time.Duration
+	Loop   bool
+}
+
+// color returns the sequence's color at time t, and whether it has finished.
+// Without Loop, it finishes after holding the last color.
+func (s colorSequence) color(t time.Duration) (RGB, bool) {
+	n := len(s.Colors)
+	period := s.Hold + s.Fade
+	step := int(t / period)
+	if !s.Loop && step >= n-1 {
+		return s.Colors[n-1], t >= time.Duration(n-1)*period+s.Hold
+	}
+
+	offset := t % period
+	from := s.Colors[step%n]
+	if offset < s.Hold {
+		return from, false
+	}
+	to := s.Colors[(step+1)%n]
+	return lerpRGB(from, to, float64(offset-s.Hold)/float64(s.Fade)), false
+}
+
+// doSequenceCommand cycles the panels through a list of colors, or a named
+// palette.
+func doSequenceCommand(client Client, args []string) {
+	usage := func() {
+		fmt.Println("usage: picoleaf sequence <color>,<color>[,...]|<palette> [--hold <duration>] [--fade <duration>] [--loop] [--panels <selection>]")
+		exit(1)
+	}
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		usage()
+	}
+
+	flags := flag.NewFlagSet("sequence", flag.ExitOnError)
+	hold := flags.Duration("hold", 5*time.Second, "How long to show each color")
+	fade := flags.Duration("fade", 2*time.Second, "How long to fade between colors")
+	loop := flags.Bool("loop", false, "Return to the first color and repeat until interrupted")
+	selection := flags.String("panels", "", "Panels to drive, as for sacn --panels (default all)")
+	flags.Usage = usage
+	flags.Parse(args[1:])
+
+	if flags.NArg() > 0 || *hold < 0 || *fade < 0 || *hold+*fade <= 0 {
+		flags.Usage()
+	}
+
+	colors, err := parsePalette(args[0])
+	if err != nil {
+		fmt.Println("error:", err)
+		exit(1)
+	}
+	if len(colors) < 2 {
+		usage()
+	}
+
+	seq := colorSequence{Colors: colors, Hold: *hold, Fade: *fade, Loop: *loop}
+	finished := false
+	runFx(client, *selection, func(t time.Duration, panels []PanelPosition) []SetPanelColor {
+		if finished {
+			return nil
+		}
+		c, done := seq.color(t)
+		finished = done
+		return solidFrames(panels, c)
+	})
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestColorSequence(t *testing.T) {
+	red, green, blue := RGB{255, 0, 0}, RGB{0, 255, 0}, RGB{0, 0, 255}
+	seq := colorSequence{Colors: []RGB{red, green, blue}, Hold: 5 * time.Second, Fade: 2 * time.Second}
+
+	tests := []struct {
+		name     string
+		loop     bool
+		t        time.Duration
+		want     RGB
+		wantDone bool
+	}{
+		{"holding", false, 3 * time.Second, red, false},
+		{"fading", false, 6 * time.Second, RGB{128, 128, 0}, false},
+		{"next", false, 8 * time.Second, green, false},
+		{"last", false, 15 * time.Second, blue, false},
+		{"done", false, 19 * time.Second, blue, true},
+		{"wrapping", true, 20 * time.Second, RGB{128, 0, 128}, false},
+		{"looped", true, 22 * time.Second, red, false},
+	}
+	for _, tt := range tests {
+		seq.Loop = tt.loop
+		got, done := seq.color(tt.t)
+		if got != tt.want || done != tt.wantDone {
+			t.Errorf("%s: color(%s) = %+v, %v, want %+v, %v", tt.name, tt.t, got, done, tt.want, tt.wantDone)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Interaction is a recorded API request and its response. Access tokens are
+// redacted from paths, so sessions can be attached to bug reports.
+type Interaction struct {
+	Method       string `json:"method"`
+	Path         string `json:"path"`
+	RequestBody  string `json:"requestBody,omitempty"`
+	Status       int    `json:"status"`
+	ResponseBody string `json:"responseBody"`
+}
+
+// Session is a sequence of recorded API interactions.
+type Session struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// redactPath replaces the access token in an API URL path.
+func redactPath(path string, token string) string {
+	if token == "" {
+		return path
+	}
+	return strings.ReplaceAll(path, token, "<token>")
+}
+
+// readBody reads and replaces an HTTP body, so it can be read again.
+func readBody(body *io.ReadCloser) ([]byte, error) {
+	if *body == nil {
+		return nil, nil
+	}
+	data, err := io.ReadAll(*body)
+	(*body).Close()
+	*body = io.NopCloser(bytes.NewReader(data))
+	return data, err
+}
+
+// recordingTransport records API interactions to a session file. The file is
+// rewritten after every interaction, so nothing is lost if picoleaf exits
+// abruptly.
+type recordingTransport struct {
+	next  http.RoundTripper
+	path  string
+	token string
+
+	mu      sync.Mutex
+	session Session
+}
+
+// newRecordingTransport returns a transport that records interactions sent
+// through next, or http.DefaultTransport if next is nil.
+func newRecordingTransport(path string, token string, next http.RoundTripper) *recordingTransport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &recordingTransport{
+		next:  next,
+		path:  path,
+		token: token,
+	}
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := readBody(&req.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resBody, err := readBody(&res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.session.Interactions = append(t.session.Interactions, Interaction{
+		Method:       req.Method,
+		Path:         redactPath(req.URL.Path, t.token),
+		RequestBody:  string(reqBody),
+		Status:       res.StatusCode,
+		ResponseBody: string(resBody),
+	})
+
+	data, err := json.MarshalIndent(t.session, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	err = writeFileAtomic(t.path, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write session: %v", err)
+	}
+	return res, nil
+}
+
+// replayingTransport answers API requests from a recorded session, without
+// touching the network. Each request is matched to the first unused
+// interaction with the same method and path.
+type replayingTransport struct {
+	token string
+
+	mu      sync.Mutex
+	session Session
+	used    []bool
+}
+
+func newReplayingTransport(path string, token string) (*replayingTransport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var session Session
+	err = json.Unmarshal(data, &session)
+	if err != nil {
+		return nil, fmt.Errorf("invalid session file: %v", err)
+	}
+
+	return &replayingTransport{
+		token:   token,
+		session: session,
+		used:    make([]bool, len(session.Interactions)),
+	}, nil
+}
+
+func (t *replayingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	path := redactPath(req.URL.Path, t.token)
+	for i, interaction := range t.session.Interactions {
+		if t.used[i] || interaction.Method != req.Method || interaction.Path != path {
+			continue
+		}
+		t.used[i] = true
+
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", interaction.Status, http.StatusText(interaction.Status)),
+			StatusCode:    interaction.Status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        http.Header{"Content-Type": {"application/json"}},
+			Body:          io.NopCloser(strings.NewReader(interaction.ResponseBody)),
+			ContentLength: int64(len(interaction.ResponseBody)),
+			Request:       req,
+		}, nil
+	}
+	return nil, fmt.Errorf("no recorded response for %s %s", req.Method, path)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net/http"
+	"path/filepath"
+	"testing"
+)
+
+func TestRecordReplay(t *testing.T) {
+	client, server := newTestClient(t)
+	path := filepath.Join(t.TempDir(), "session.json")
+
+	client.client.Transport = newRecordingTransport(path, client.Token, nil)
+	err := client.SetBrightness(42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorded, err := client.GetPanelInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server.Close()
+
+	replayer, err := newReplayingTransport(path, client.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := replayer.session.Interactions[0].Path; got != "/api/v1/<token>/state" {
+		t.Errorf("recorded path = %q, want token redacted", got)
+	}
+
+	client.client = http.Client{Transport: replayer}
+	err = client.SetBrightness(42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayed, err := client.GetPanelInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replayed.State.Brightness.Value != recorded.State.Brightness.Value {
+		t.Errorf("replayed brightness = %d, want %d", replayed.State.Brightness.Value, recorded.State.Brightness.Value)
+	}
+
+	_, err = client.GetPanelInfo()
+	if err == nil {
+		t.Error("expected an error once the session is exhausted")
+	}
+}
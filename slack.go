@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// slackAPI is the base URL of the Slack Web API.
+var slackAPI = "https://slack.com/api/"
+
+// defaultSlackCommands are used for presences missing from the `[slack]`
+// section.
+var defaultSlackCommands = map[string][]string{
+	"presence.active": {"hsl", "120", "100", "50"},
+	"presence.away":   {"hsl", "30", "100", "30"},
+}
+
+// slackStatus is a Slack user's presence and status emoji.
+type slackStatus struct {
+	Presence string // active or away
+	Emoji    string // without colons, e.g. palm_tree
+}
+
+// slackGet calls a Slack Web API method, decoding the response into v.
+// Slack reports most failures in the response's `ok` and `error` fields,
+// rather than with an HTTP status.
+func slackGet(method, token string, v interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, slackAPI+method, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	client := http.Client{Timeout: 30 * time.Second}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("slack: %s", res.Status)
+	}
+
+	var body json.RawMessage
+	err = json.NewDecoder(res.Body).Decode(&body)
+	if err != nil {
+		return err
+	}
+	var result struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	err = json.Unmarshal(body, &result)
+	if err != nil {
+		return err
+	}
+	if !result.OK {
+		return fmt.Errorf("slack: %s: %s", method, result.Error)
+	}
+	return json.Unmarshal(body, v)
+}
+
+// fetchSlackStatus returns the token owner's presence and status emoji.
+func fetchSlackStatus(token string) (slackStatus, error) {
+	var presence struct {
+		Presence string `json:"presence"`
+	}
+	err := slackGet("users.getPresence", token, &presence)
+	if err != nil {
+		return slackStatus{}, err
+	}
+
+	var profile struct {
+		Profile struct {
+			StatusEmoji string `json:"status_emoji"`
+		} `json:"profile"`
+	}
+	err = slackGet("users.profile.get", token, &profile)
+	if err != nil {
+		return slackStatus{}, err
+	}
+
+	return slackStatus{
+		Presence: presence.Presence,
+		Emoji:    strings.Trim(profile.Profile.StatusEmoji, ":"),
+	}, nil
+}
+
+// slackLightStatus picks the `[slack]` key to apply for a status: the status
+// emoji's, if one is configured, or else the presence's.
+func slackLightStatus(s slackStatus) string {
+	if s.Emoji != "" && cfg.Section("slack").HasKey("emoji."+s.Emoji) {
+		return "emoji." + s.Emoji
+	}
+	return "presence." + s.Presence
+}
+
+// doSlackCommand sets Nanoleaf to match your Slack presence and status
+// emoji, until interrupted.
+func doSlackCommand(client Client, args []string) {
+	flags := flag.NewFlagSet("slack", flag.ExitOnError)
+	token := flags.String("token", os.Getenv("SLACK_TOKEN"), "Slack user token, with users:read and users.profile:read scopes")
+	every := flags.Duration("every", time.Minute, "Time between polls")
+	flags.Usage = func() {
+		fmt.Println("usage: picoleaf slack [--token <token>] [--every <duration>]")
+		exit(1)
+	}
+	flags.Parse(args)
+	addLogSecret(*token)
+
+	if flags.NArg() > 0 || *every <= 0 {
+		flags.Usage()
+	}
+	if *token == "" {
+		fmt.Println("error: a Slack token is required, via --token or SLACK_TOKEN")
+		exit(1)
+	}
+
+	light := &statusLight{section: "slack", defaults: defaultSlackCommands}
+	for {
+		status, err := fetchSlackStatus(*token)
+		if err != nil {
+			slog.Error("failed to fetch Slack status", "err", err)
+		} else {
+			key := slackLightStatus(status)
+			if light.Command(key) != nil {
+				light.Update(key)
+			}
+		}
+
+		if !sleepOrCancel(*every) {
+			return
+		}
+	}
+}
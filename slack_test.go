@@ -0,0 +1,60 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFetchSlackStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer xoxp-test" {
+			w.Write([]byte(`{"ok":false,"error":"invalid_auth"}`))
+			return
+		}
+		switch r.URL.Path {
+		case "/users.getPresence":
+			w.Write([]byte(`{"ok":true,"presence":"away"}`))
+		case "/users.profile.get":
+			w.Write([]byte(`{"ok":true,"profile":{"status_text":"In a meeting","status_emoji":":calendar:"}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	saved := slackAPI
+	slackAPI = server.URL + "/"
+	defer func() { slackAPI = saved }()
+
+	status, err := fetchSlackStatus("xoxp-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (slackStatus{Presence: "away", Emoji: "calendar"}); status != want {
+		t.Errorf("fetchSlackStatus = %+v, want %+v", status, want)
+	}
+
+	_, err = fetchSlackStatus("wrong")
+	if err == nil || err.Error() != "slack: users.getPresence: invalid_auth" {
+		t.Errorf("fetchSlackStatus with a bad token = %v, want invalid_auth", err)
+	}
+}
+
+func TestSlackLightStatus(t *testing.T) {
+	setTestConfig(t, "[slack]\nemoji.calendar = hsl 0 100 50\n")
+
+	tests := []struct {
+		status slackStatus
+		want   string
+	}{
+		{slackStatus{Presence: "active", Emoji: "calendar"}, "emoji.calendar"},
+		{slackStatus{Presence: "active", Emoji: "palm_tree"}, "presence.active"},
+		{slackStatus{Presence: "away"}, "presence.away"},
+	}
+	for _, tt := range tests {
+		if got := slackLightStatus(tt.status); got != tt.want {
+			t.Errorf("slackLightStatus(%+v) = %q, want %q", tt.status, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"time"
+)
+
+// sleepStepInterval is the time between state updates during a sleep fade.
+const sleepStepInterval = 10 * time.Second
+
+// defaultWarmestTemperature is used when the Nanoleaf doesn't report its
+// color temperature range.
+const defaultWarmestTemperature = 1200
+
+func doSleepCommand(client Client, args []string) {
+	flags := flag.NewFlagSet("sleep", flag.ExitOnError)
+	duration := flags.Duration("duration", 20*time.Minute, "Time to fade out over")
+	temp := flags.Int("temp", 0, "Final color temperature (defaults to the warmest supported)")
+	flags.Usage = func() {
+		fmt.Println("usage: picoleaf sleep [--duration <duration>] [--temp <temperature>]")
+		exit(1)
+	}
+	flags.Parse(args)
+
+	if *duration <= 0 || flags.NArg() > 0 {
+		flags.Usage()
+	}
+
+	panelInfo, err := client.GetPanelInfo()
+	if err != nil {
+		fmt.Println("error: failed to get Nanoleaf state:", err)
+		exit(1)
+	}
+
+	startBrightness := 100
+	if panelInfo.State.Brightness != nil {
+		startBrightness = panelInfo.State.Brightness.Value
+	}
+
+	startTemp := 2700
+	endTemp := defaultWarmestTemperature
+	if ct := panelInfo.State.ColorTemperature; ct != nil {
+		startTemp = ct.Value
+		if ct.Min != nil {
+			endTemp = *ct.Min
+		}
+	}
+	if *temp != 0 {
+		caps := DetectCapabilities(panelInfo)
+		if err := caps.ColorTemperature.check("temperature", *temp); err != nil {
+			fmt.Println("error:", err)
+			exit(1)
+		}
+		endTemp = *temp
+	}
+
+	steps := int(*duration / sleepStepInterval)
+	if steps < 1 {
+		steps = 1
+	}
+	interval := *duration / time.Duration(steps)
+
+	fmt.Printf("Fading out over %s (Ctrl-C to cancel)\n", *duration)
+	for i := 1; i <= steps; i++ {
+		if !sleepOrCancel(interval) {
+			fmt.Println("Sleep cancelled")
+			return
+		}
+
+		t := float64(i) / float64(steps)
+		brightness := int(math.Round(float64(startBrightness) * (1 - t)))
+		ct := int(math.Round(float64(startTemp) + float64(endTemp-startTemp)*t))
+
+		err = client.SetColorTemperature(ct)
+		if err != nil {
+			fmt.Println("error: failed to set color temperature:", err)
+			exit(1)
+		}
+
+		err = client.SetBrightness(brightness)
+		if err != nil {
+			fmt.Println("error: failed to set brightness:", err)
+			exit(1)
+		}
+	}
+
+	err = client.Off()
+	if err != nil {
+		fmt.Println("error: failed to turn off Nanoleaf:", err)
+		exit(1)
+	}
+}
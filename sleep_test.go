@@ -0,0 +1,46 @@
+package main
+
+import "testing"
+
+func TestSleepCommand(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	client, server := newTestClient(t)
+
+	// Shorter than a step, so it fades in one.
+	if code := runCommandInProcess(client, []string{"sleep", "--duration", "10ms"}); code != 0 {
+		t.Fatalf("sleep exited with %d", code)
+	}
+	state := server.Device().State
+	if state.On || state.Brightness != 0 || state.ColorTemperature != 1200 {
+		t.Errorf("state = %+v, want off at brightness 0 and the warmest ct, 1200", state)
+	}
+
+	if code := runCommandInProcess(client, []string{"sleep", "--duration", "0s"}); code != 1 {
+		t.Errorf("sleep with zero duration exited with %d, want 1", code)
+	}
+}
+
+func TestSleepCommandTemperature(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	client, server := newTestClient(t)
+
+	if code := runCommandInProcess(client, []string{"sleep", "--duration", "10ms", "--temp", "2200"}); code != 0 {
+		t.Fatalf("sleep exited with %d", code)
+	}
+	if ct := server.Device().State.ColorTemperature; ct != 2200 {
+		t.Errorf("ct = %d, want 2200", ct)
+	}
+}
+
+func TestSleepCommandTemperatureOutOfRange(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	client, server := newTestClient(t)
+	before := server.Device().State
+
+	if code := runCommandInProcess(client, []string{"sleep", "--duration", "10ms", "--temp", "100"}); code != 1 {
+		t.Fatalf("sleep with an unsupported temp exited with %d, want 1", code)
+	}
+	if after := server.Device().State; after != before {
+		t.Errorf("state = %+v, want it left at %+v", after, before)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+)
+
+// Snapshot captures the Nanoleaf state needed to restore it later.
+type Snapshot struct {
+	On               bool   `json:"on"`
+	ColorMode        string `json:"colorMode"`
+	Effect           string `json:"effect"`
+	Brightness       int    `json:"brightness"`
+	Hue              int    `json:"hue"`
+	Saturation       int    `json:"sat"`
+	ColorTemperature int    `json:"ct"`
+}
+
+// Snapshot captures the Nanoleaf's current state.
+func (c Client) Snapshot() (*Snapshot, error) {
+	panelInfo, err := c.GetPanelInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	state := panelInfo.State
+	snapshot := Snapshot{
+		ColorMode: state.ColorMode,
+		Effect:    panelInfo.Effects.Selected,
+	}
+	if state.On != nil {
+		snapshot.On = state.On.Value
+	}
+	if state.Brightness != nil {
+		snapshot.Brightness = state.Brightness.Value
+	}
+	if state.Hue != nil {
+		snapshot.Hue = state.Hue.Value
+	}
+	if state.Saturation != nil {
+		snapshot.Saturation = state.Saturation.Value
+	}
+	if state.ColorTemperature != nil {
+		snapshot.ColorTemperature = state.ColorTemperature.Value
+	}
+	return &snapshot, nil
+}
+
+// Restore returns the Nanoleaf to a previously captured state.
+func (c Client) Restore(s Snapshot) error {
+	var err error
+	switch {
+	// Effect names like *Solid* and *ExtControl* are placeholders for
+	// transient modes, which can't be selected.
+	case s.ColorMode == "effect" && !strings.HasPrefix(s.Effect, "*"):
+		err = c.SelectEffect(s.Effect)
+		if err == nil {
+			err = c.SetBrightness(s.Brightness)
+		}
+	case s.ColorMode == "ct":
+		err = c.SetColorTemperature(s.ColorTemperature)
+		if err == nil {
+			err = c.SetBrightness(s.Brightness)
+		}
+	default:
+		err = c.SetHSL(s.Hue, s.Saturation, s.Brightness)
+	}
+	if err != nil {
+		return err
+	}
+
+	if s.On {
+		return c.On()
+	}
+	return c.Off()
+}
+
+// restoreOnInterrupt saves the Nanoleaf's state before a long-running mode
+// takes over the panels, and returns a context that's cancelled by SIGINT or
+// SIGTERM. Call the returned function when the mode exits: if it was
+// interrupted, it restores the saved state, which also ends external control,
+// rather than leaving the panels frozen on the last frame.
+func restoreOnInterrupt(client Client) (context.Context, func()) {
+	snapshot, err := client.Snapshot()
+	if err != nil {
+		slog.Warn("failed to save state, so it won't be restored", "err", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	return ctx, func() {
+		// Check before stop, which also cancels ctx.
+		interrupted := ctx.Err() != nil
+		stop()
+		if !interrupted || snapshot == nil {
+			return
+		}
+
+		slog.Info("restoring previous state", "mode", snapshot.ColorMode, "effect", snapshot.Effect)
+		err := client.Restore(*snapshot)
+		if err != nil {
+			fmt.Println("error: failed to restore previous state:", err)
+		}
+	}
+}
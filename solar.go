@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// geolocationURL is queried for the current location when it isn't
+// configured.
+const geolocationURL = "http://ip-api.com/json/?fields=status,message,lat,lon"
+
+// Coordinates is a geographic location in decimal degrees. Longitude is
+// positive east of Greenwich.
+type Coordinates struct {
+	Latitude  float64
+	Longitude float64
+}
+
+// SolarTime is a time relative to sunrise or sunset, e.g. `sunset-30m`.
+type SolarTime struct {
+	Event  string // "sunrise" or "sunset"
+	Offset time.Duration
+}
+
+// ParseSolarTime parses expressions like `sunrise`, `sunrise+1h`, and
+// `sunset-30m`.
+func ParseSolarTime(s string) (SolarTime, error) {
+	s = strings.ToLower(s)
+	for _, event := range []string{"sunrise", "sunset"} {
+		if !strings.HasPrefix(s, event) {
+			continue
+		}
+
+		st := SolarTime{Event: event}
+		rest := s[len(event):]
+		if rest == "" {
+			return st, nil
+		}
+		if rest[0] != '+' && rest[0] != '-' {
+			break
+		}
+
+		offset, err := time.ParseDuration(rest)
+		if err != nil {
+			return st, fmt.Errorf("invalid offset in %q", s)
+		}
+		st.Offset = offset
+		return st, nil
+	}
+	return SolarTime{}, fmt.Errorf("invalid solar time %q, expected e.g. sunset-30m", s)
+}
+
+// isSolarTime reports whether s looks like a solar time expression.
+func isSolarTime(s string) bool {
+	s = strings.ToLower(s)
+	return strings.HasPrefix(s, "sunrise") || strings.HasPrefix(s, "sunset")
+}
+
+// On returns the solar time on the given day, in the day's location.
+func (st SolarTime) On(day time.Time, coords Coordinates) (time.Time, error) {
+	sunrise, sunset, err := sunTimes(day, coords)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if st.Event == "sunrise" {
+		return sunrise.Add(st.Offset), nil
+	}
+	return sunset.Add(st.Offset), nil
+}
+
+// Next returns the first occurrence of the solar time after now.
+func (st SolarTime) Next(now time.Time, coords Coordinates) (time.Time, error) {
+	for i := 0; i < 2; i++ {
+		t, err := st.On(now.AddDate(0, 0, i), coords)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if t.After(now) {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("no %s found after %s", st.Event, now.Format(time.RFC3339))
+}
+
+// sunTimes computes sunrise and sunset on the given local day, using the
+// sunrise equation with a standard -0.833° correction for refraction.
+func sunTimes(day time.Time, coords Coordinates) (time.Time, time.Time, error) {
+	rad := math.Pi / 180
+
+	noon := time.Date(day.Year(), day.Month(), day.Day(), 12, 0, 0, 0, day.Location())
+	julianNoon := float64(noon.Unix())/86400 + 2440587.5
+
+	// Count days from the longitude-adjusted noon, so the mean solar noon
+	// below falls on the local day, even far east or west of Greenwich.
+	n := math.Round(julianNoon - 2451545.0 + coords.Longitude/360)
+	meanSolarNoon := n - coords.Longitude/360
+	m := math.Mod(357.5291+0.98560028*meanSolarNoon, 360)
+	c := 1.9148*math.Sin(m*rad) + 0.02*math.Sin(2*m*rad) + 0.0003*math.Sin(3*m*rad)
+	lambda := math.Mod(m+c+180+102.9372, 360)
+	transit := 2451545.0 + meanSolarNoon + 0.0053*math.Sin(m*rad) - 0.0069*math.Sin(2*lambda*rad)
+
+	sinDecl := math.Sin(lambda*rad) * math.Sin(23.4397*rad)
+	cosDecl := math.Cos(math.Asin(sinDecl))
+	lat := coords.Latitude * rad
+	cosHourAngle := (math.Sin(-0.833*rad) - math.Sin(lat)*sinDecl) / (math.Cos(lat) * cosDecl)
+	if cosHourAngle < -1 || cosHourAngle > 1 {
+		return time.Time{}, time.Time{}, errors.New("the sun doesn't rise or set on this day at this latitude")
+	}
+	hourAngle := math.Acos(cosHourAngle) / rad
+
+	fromJulian := func(j float64) time.Time {
+		secs := (j - 2440587.5) * 86400
+		return time.Unix(int64(math.Round(secs)), 0).In(day.Location())
+	}
+	return fromJulian(transit - hourAngle/360), fromJulian(transit + hourAngle/360), nil
+}
+
+// loadCoordinates returns the `latitude` and `longitude` config settings,
+// falling back to IP geolocation if they aren't set.
+func loadCoordinates() (Coordinates, error) {
+	section := cfg.Section("")
+	if section.HasKey("latitude") || section.HasKey("longitude") {
+		lat, err := section.Key("latitude").Float64()
+		if err != nil {
+			return Coordinates{}, fmt.Errorf("invalid latitude: %v", err)
+		}
+		lon, err := section.Key("longitude").Float64()
+		if err != nil {
+			return Coordinates{}, fmt.Errorf("invalid longitude: %v", err)
+		}
+		return Coordinates{Latitude: lat, Longitude: lon}, nil
+	}
+
+	return geolocate()
+}
+
+// geolocate estimates the current location from this machine's public IP.
+func geolocate() (Coordinates, error) {
+	client := http.Client{Timeout: 10 * time.Second}
+	res, err := client.Get(geolocationURL)
+	if err != nil {
+		return Coordinates{}, err
+	}
+	defer res.Body.Close()
+
+	var body struct {
+		Status  string  `json:"status"`
+		Message string  `json:"message"`
+		Lat     float64 `json:"lat"`
+		Lon     float64 `json:"lon"`
+	}
+	err = json.NewDecoder(res.Body).Decode(&body)
+	if err != nil {
+		return Coordinates{}, err
+	}
+	if body.Status != "success" {
+		return Coordinates{}, fmt.Errorf("geolocation failed: %s", body.Message)
+	}
+	return Coordinates{Latitude: body.Lat, Longitude: body.Lon}, nil
+}
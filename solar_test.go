@@ -0,0 +1,99 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSunTimes(t *testing.T) {
+	tests := []struct {
+		name            string
+		coords          Coordinates
+		zone            *time.Location
+		day             string
+		sunrise, sunset string
+	}{
+		{"London", Coordinates{51.51, -0.13}, time.FixedZone("BST", 1*3600), "2024-06-21", "04:43", "21:21"},
+		{"Auckland", Coordinates{-36.85, 174.76}, time.FixedZone("NZDT", 13*3600), "2024-01-15", "06:16", "20:42"},
+		{"Tokyo", Coordinates{35.68, 139.69}, time.FixedZone("JST", 9*3600), "2024-03-20", "05:45", "17:53"},
+		{"Honolulu", Coordinates{21.31, -157.86}, time.FixedZone("HST", -10*3600), "2024-06-21", "05:50", "19:16"},
+		{"Anchorage", Coordinates{61.22, -149.90}, time.FixedZone("AKDT", -8*3600), "2024-06-21", "04:20", "23:42"},
+	}
+	for _, tt := range tests {
+		day, err := time.ParseInLocation("2006-01-02", tt.day, tt.zone)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sunrise, sunset, err := sunTimes(day, tt.coords)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		for _, got := range []struct {
+			event string
+			t     time.Time
+			want  string
+		}{{"sunrise", sunrise, tt.sunrise}, {"sunset", sunset, tt.sunset}} {
+			want, err := time.ParseInLocation("2006-01-02 15:04", tt.day+" "+got.want, tt.zone)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := got.t.Sub(want).Abs(); diff > 3*time.Minute {
+				t.Errorf("%s %s = %s, want %s", tt.name, got.event, got.t.Format("2006-01-02 15:04"), want.Format("2006-01-02 15:04"))
+			}
+		}
+	}
+}
+
+func TestSunTimesPolar(t *testing.T) {
+	tromso := Coordinates{69.65, 18.96}
+	zone := time.FixedZone("CET", 1*3600)
+	for _, day := range []time.Time{
+		time.Date(2024, 6, 21, 0, 0, 0, 0, zone),  // midnight sun
+		time.Date(2024, 12, 21, 0, 0, 0, 0, zone), // polar night
+	} {
+		if _, _, err := sunTimes(day, tromso); err == nil {
+			t.Errorf("sunTimes(%s) in Tromsø succeeded", day.Format("Jan 2"))
+		}
+	}
+	if _, _, err := sunTimes(time.Date(2024, 3, 20, 0, 0, 0, 0, zone), tromso); err != nil {
+		t.Errorf("sunTimes(Mar 20) in Tromsø: %v", err)
+	}
+}
+
+func TestSolarTimeNext(t *testing.T) {
+	auckland := Coordinates{-36.85, 174.76}
+	zone := time.FixedZone("NZDT", 13*3600)
+
+	// After dusk, the next sunset is tomorrow's.
+	now := time.Date(2024, 1, 15, 22, 0, 0, 0, zone)
+	got, err := SolarTime{Event: "sunset", Offset: -30 * time.Minute}.Next(now, auckland)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Day() != 16 || got.Hour() != 20 {
+		t.Errorf("Next(sunset-30m) = %s, want Jan 16 around 20:12", got.Format(time.RFC3339))
+	}
+}
+
+func TestParseSolarTime(t *testing.T) {
+	tests := []struct {
+		in   string
+		want SolarTime
+	}{
+		{"sunrise", SolarTime{"sunrise", 0}},
+		{"Sunset-30m", SolarTime{"sunset", -30 * time.Minute}},
+		{"sunrise+1h30m", SolarTime{"sunrise", 90 * time.Minute}},
+	}
+	for _, tt := range tests {
+		got, err := ParseSolarTime(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseSolarTime(%q) = %+v, %v, want %+v", tt.in, got, err, tt.want)
+		}
+	}
+	for _, in := range []string{"noon", "sunset30m", "sunrise+soon"} {
+		if _, err := ParseSolarTime(in); err == nil {
+			t.Errorf("ParseSolarTime(%q) succeeded", in)
+		}
+	}
+}
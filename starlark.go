@@ -0,0 +1,292 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"time"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+	"go.starlark.net/syntax"
+)
+
+// starlarkOptions allow while loops and statements at the top level, which
+// animations usually need.
+var starlarkOptions = &syntax.FileOptions{While: true, TopLevelControl: true, GlobalReassign: true}
+
+// animationScript runs a Starlark animation. Panel colors set by the script
+// are sent as one frame when it calls show or sleep, or when it ends.
+type animationScript struct {
+	panels []PanelPosition
+	send   func([]SetPanelColor) error
+
+	// sleep pauses the script, returning false if it was interrupted.
+	sleep func(time.Duration) bool
+
+	pending map[int]RGB
+	order   []int
+}
+
+// predeclared returns the script API:
+//
+//	panels                 the panels, with id, x, y, and o (orientation)
+//	set_panel(id, color)   set a panel's color, an (r, g, b) tuple
+//	set_all(color)         set every panel's color
+//	show()                 send the colors set since the last frame
+//	sleep(seconds)         show, then pause
+//	hsv(h, s, v)           convert hue 0-360, saturation and value 0-100
+//	rgb(color)             convert a color like "#ff8000" or "orange"
+func (a *animationScript) predeclared() starlark.StringDict {
+	panels := make([]starlark.Value, len(a.panels))
+	for i, p := range a.panels {
+		panels[i] = starlarkstruct.FromStringDict(starlarkstruct.Default, starlark.StringDict{
+			"id": starlark.MakeInt(p.PanelID),
+			"x":  starlark.MakeInt(p.X),
+			"y":  starlark.MakeInt(p.Y),
+			"o":  starlark.MakeInt(p.O),
+		})
+	}
+	list := starlark.NewList(panels)
+	list.Freeze()
+
+	return starlark.StringDict{
+		"panels":    list,
+		"set_panel": starlark.NewBuiltin("set_panel", a.setPanel),
+		"set_all":   starlark.NewBuiltin("set_all", a.setAll),
+		"show":      starlark.NewBuiltin("show", a.show),
+		"sleep":     starlark.NewBuiltin("sleep", a.sleepBuiltin),
+		"hsv":       starlark.NewBuiltin("hsv", hsvBuiltin),
+		"rgb":       starlark.NewBuiltin("rgb", rgbBuiltin),
+	}
+}
+
+// run runs the script in src, sending any colors it left unshown.
+func (a *animationScript) run(thread *starlark.Thread, filename string, src []byte) error {
+	_, err := starlark.ExecFileOptions(starlarkOptions, thread, filename, src, a.predeclared())
+	if err != nil {
+		return err
+	}
+	return a.flush()
+}
+
+func (a *animationScript) set(id int, color RGB) {
+	if a.pending == nil {
+		a.pending = make(map[int]RGB)
+	}
+	if _, ok := a.pending[id]; !ok {
+		a.order = append(a.order, id)
+	}
+	a.pending[id] = color
+}
+
+func (a *animationScript) flush() error {
+	if len(a.order) == 0 {
+		return nil
+	}
+	frame := make([]SetPanelColor, len(a.order))
+	for i, id := range a.order {
+		c := a.pending[id]
+		frame[i] = SetPanelColor{PanelID: uint16(id), Red: c.Red, Green: c.Green, Blue: c.Blue}
+	}
+	a.pending, a.order = nil, nil
+	return a.send(frame)
+}
+
+func (a *animationScript) setPanel(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var id int
+	var color starlark.Value
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "id", &id, "color", &color); err != nil {
+		return nil, err
+	}
+	found := false
+	for _, p := range a.panels {
+		found = found || p.PanelID == id
+	}
+	if !found {
+		return nil, fmt.Errorf("%s: no panel %d", b.Name(), id)
+	}
+	rgb, err := starlarkColor(b.Name(), color)
+	if err != nil {
+		return nil, err
+	}
+	a.set(id, rgb)
+	return starlark.None, nil
+}
+
+func (a *animationScript) setAll(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var color starlark.Value
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "color", &color); err != nil {
+		return nil, err
+	}
+	rgb, err := starlarkColor(b.Name(), color)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range a.panels {
+		a.set(p.PanelID, rgb)
+	}
+	return starlark.None, nil
+}
+
+func (a *animationScript) show(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs); err != nil {
+		return nil, err
+	}
+	return starlark.None, a.flush()
+}
+
+func (a *animationScript) sleepBuiltin(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var seconds number
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "seconds", &seconds); err != nil {
+		return nil, err
+	}
+	if seconds < 0 {
+		return nil, fmt.Errorf("%s: negative duration", b.Name())
+	}
+	if err := a.flush(); err != nil {
+		return nil, err
+	}
+	if !a.sleep(time.Duration(float64(seconds) * float64(time.Second))) {
+		return nil, errScriptCancelled
+	}
+	return starlark.None, nil
+}
+
+func hsvBuiltin(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var hArg, sArg, vArg number
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "h", &hArg, "s", &sArg, "v", &vArg); err != nil {
+		return nil, err
+	}
+	h, s, v := float64(hArg), float64(sArg), float64(vArg)
+	if s < 0 || s > 100 || v < 0 || v > 100 {
+		return nil, fmt.Errorf("%s: saturation and value must be 0-100", b.Name())
+	}
+	hue := math.Mod(h, 360)
+	if hue < 0 {
+		hue += 360
+	}
+	return rgbTuple(HSVToRGB(int(math.Round(hue))%360, int(math.Round(s)), int(math.Round(v)))), nil
+}
+
+func rgbBuiltin(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var s string
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "color", &s); err != nil {
+		return nil, err
+	}
+	color, err := parseColor(s)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", b.Name(), err)
+	}
+	return rgbTuple(color), nil
+}
+
+// number is an int or float argument.
+type number float64
+
+func (n *number) Unpack(v starlark.Value) error {
+	f, ok := starlark.AsFloat(v)
+	if !ok {
+		return fmt.Errorf("got %s, want number", v.Type())
+	}
+	*n = number(f)
+	return nil
+}
+
+func rgbTuple(c RGB) starlark.Tuple {
+	return starlark.Tuple{starlark.MakeInt(int(c.Red)), starlark.MakeInt(int(c.Green)), starlark.MakeInt(int(c.Blue))}
+}
+
+// starlarkColor converts an (r, g, b) tuple or list to a color.
+func starlarkColor(fn string, v starlark.Value) (RGB, error) {
+	seq, ok := v.(starlark.Indexable)
+	if !ok || seq.Len() != 3 {
+		return RGB{}, fmt.Errorf("%s: color must be an (r, g, b) tuple, not %s", fn, v.Type())
+	}
+	var channels [3]uint8
+	for i := range channels {
+		n, err := starlark.AsInt32(seq.Index(i))
+		if err != nil || n < 0 || n > 255 {
+			return RGB{}, fmt.Errorf("%s: color channels must be integers 0-255", fn)
+		}
+		channels[i] = uint8(n)
+	}
+	return RGB{Red: channels[0], Green: channels[1], Blue: channels[2]}, nil
+}
+
+// doScriptCommand runs Starlark animation scripts, which stream per-panel
+// colors over external control.
+func doScriptCommand(client Client, args []string) {
+	if len(args) != 2 || args[0] != "run" {
+		fmt.Println("usage: picoleaf script run <file.star>")
+		exit(1)
+	}
+	filename := args[1]
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		fmt.Println("error: failed to read script:", err)
+		exit(1)
+	}
+
+	panelInfo, err := client.GetPanelInfo()
+	if err != nil {
+		fmt.Println("error: failed to get Nanoleaf info:", err)
+		exit(1)
+	}
+
+	ctx, restore := restoreOnInterrupt(client)
+	defer restore()
+
+	sink, err := openFrameSink(client, panelInfo)
+	if err != nil {
+		fmt.Println("error: failed to start external control:", err)
+		exit(1)
+	}
+
+	script := &animationScript{
+		panels: panelInfo.PanelLayout.Layout.PositionData,
+		send:   sink.Send,
+		sleep: func(d time.Duration) bool {
+			return sleepContext(ctx, d)
+		},
+	}
+	thread := &starlark.Thread{
+		Name:  filename,
+		Print: func(_ *starlark.Thread, msg string) { fmt.Println(msg) },
+	}
+	go func() {
+		<-ctx.Done()
+		thread.Cancel("interrupted")
+	}()
+
+	err = script.run(thread, filename, src)
+	if closeErr := sink.Close(); err == nil {
+		err = closeErr
+	}
+	if ctx.Err() != nil || errors.Is(err, errScriptCancelled) {
+		return
+	}
+	var evalErr *starlark.EvalError
+	if errors.As(err, &evalErr) {
+		fmt.Println("error:", evalErr.Backtrace())
+		exit(1)
+	}
+	if err != nil {
+		fmt.Println("error:", err)
+		exit(1)
+	}
+}
+
+// sleepContext pauses for d, returning false if ctx is cancelled first.
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
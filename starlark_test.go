@@ -0,0 +1,101 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go.starlark.net/starlark"
+)
+
+func TestAnimationScript(t *testing.T) {
+	var frames [][]SetPanelColor
+	var slept []time.Duration
+	script := &animationScript{
+		panels: []PanelPosition{{PanelID: 101, X: 0}, {PanelID: 102, X: 150}},
+		send: func(frame []SetPanelColor) error {
+			frames = append(frames, frame)
+			return nil
+		},
+		sleep: func(d time.Duration) bool {
+			slept = append(slept, d)
+			return true
+		},
+	}
+
+	src := `
+i = 0
+while i < 2:
+    for p in panels:
+        set_panel(p.id, hsv(120 * i, 100, 100))
+    sleep(0.5)
+    i += 1
+set_all(rgb("#0000ff"))
+set_panel(102, (1, 2, 3))
+`
+	err := script.run(&starlark.Thread{}, "test.star", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := [][]SetPanelColor{
+		{{PanelID: 101, Red: 255}, {PanelID: 102, Red: 255}},
+		{{PanelID: 101, Green: 255}, {PanelID: 102, Green: 255}},
+		{{PanelID: 101, Blue: 255}, {PanelID: 102, Red: 1, Green: 2, Blue: 3}},
+	}
+	if !reflect.DeepEqual(frames, want) {
+		t.Errorf("frames = %v, want %v", frames, want)
+	}
+	if wantSlept := []time.Duration{500 * time.Millisecond, 500 * time.Millisecond}; !reflect.DeepEqual(slept, wantSlept) {
+		t.Errorf("slept %v, want %v", slept, wantSlept)
+	}
+}
+
+func TestAnimationScriptErrors(t *testing.T) {
+	tests := map[string]string{
+		"set_panel(999, (0, 0, 0))":   "no panel 999",
+		"set_panel(101, (0, 0))":      "(r, g, b) tuple",
+		"set_panel(101, (0, 0, 256))": "0-255",
+		"hsv(0, 120, 100)":            "0-100",
+		`rgb("blurple")`:              "rgb:",
+		"sleep(-1)":                   "negative duration",
+	}
+	for src, want := range tests {
+		script := &animationScript{
+			panels: []PanelPosition{{PanelID: 101}},
+			send:   func([]SetPanelColor) error { return nil },
+			sleep:  func(time.Duration) bool { return true },
+		}
+		err := script.run(&starlark.Thread{}, "test.star", []byte(src))
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: err = %v, want it to mention %q", src, err, want)
+		}
+	}
+
+	// An interrupted sleep stops the script.
+	script := &animationScript{
+		send:  func([]SetPanelColor) error { return nil },
+		sleep: func(time.Duration) bool { return false },
+	}
+	err := script.run(&starlark.Thread{}, "test.star", []byte("sleep(1)\nfail('kept running')"))
+	if err == nil || strings.Contains(err.Error(), "kept running") {
+		t.Errorf("interrupted script: err = %v", err)
+	}
+}
+
+func TestScriptCommand(t *testing.T) {
+	client, server := newTestClient(t)
+	path := t.TempDir() + "/anim.star"
+	if err := os.WriteFile(path, []byte("set_all((255, 0, 0))\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if code := runCommandInProcess(client, []string{"script", "run", path}); code != 0 {
+		t.Fatalf("script run exited %d", code)
+	}
+	if _, err := server.WaitForFrames(1, time.Second); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+)
+
+// doEffectStreamCommand reads custom frames from stdin, one per line, in the
+// same format as `effect custom`, and streams them to the Nanoleaf at a rate
+// it can keep up with. With --keyframes, each line starts with a time offset,
+// and picoleaf generates the frames in between.
+func doEffectStreamCommand(client Client, args []string) {
+	flags := flag.NewFlagSet("stream", flag.ExitOnError)
+	fps := flags.Int("fps", 0, "Maximum frame rate (defaults to the model's safe rate)")
+	rgbw := flags.Bool("rgbw", false, "Include a white value in each frame, as for effect custom --rgbw")
+	keyframes := flags.Bool("keyframes", false, "Read timed keyframes, and interpolate between them at --fps")
+	ease := flags.String("ease", "linear", "Keyframe easing: linear, ease, ease-in, or ease-out")
+	latency := flags.String("latency", "auto", "Keyframe latency compensation, as a duration, or auto to measure it")
+	flags.Usage = func() {
+		fmt.Println("usage: picoleaf effect stream [--fps <n>] [--rgbw] < frames")
+		fmt.Println("       picoleaf effect stream --keyframes [--ease <easing>] [--latency <duration>] [--fps <n>] [--rgbw] < keyframes")
+		exit(1)
+	}
+	flags.Parse(args)
+
+	easing, ok := easings[*ease]
+	if flags.NArg() > 0 || *fps < 0 || !ok {
+		flags.Usage()
+	}
+
+	panelInfo, err := client.CachedPanelInfo()
+	if err != nil {
+		fmt.Println("error: failed to get Nanoleaf info:", err)
+		exit(1)
+	}
+
+	maxFPS := MaxFrameRate(panelInfo.Model)
+	if *fps == 0 {
+		*fps = maxFPS
+	} else if *fps > maxFPS {
+		fmt.Printf("warning: %d fps exceeds the safe rate for %s, capping at %d\n", *fps, panelInfo.Model, maxFPS)
+		*fps = maxFPS
+	}
+
+	ctx, restore := restoreOnInterrupt(client)
+	defer restore()
+
+	caps := DetectCapabilities(panelInfo)
+	port, err := client.startExternalControl(caps.ExtControlVersion)
+	if err != nil {
+		fmt.Println("error: failed to start external control:", err)
+		exit(1)
+	}
+
+	conn, err := client.dialExternalControl(port)
+	if err != nil {
+		fmt.Println("error: failed to open UDP socket:", err)
+		exit(1)
+	}
+	defer conn.Close()
+
+	write := func(frame []byte) error {
+		return writeControlFrame(conn, caps.ExtControlVersion, frame)
+	}
+	encode := func(write func([]byte) error) func([]SetPanelColor) error {
+		return func(frames []SetPanelColor) error {
+			buf, err := encodeControlFrame(caps.ExtControlVersion, client.Calibration.ApplyFrames(frames))
+			if err != nil {
+				return err
+			}
+			return write(buf)
+		}
+	}
+
+	if *keyframes {
+		// Keyframe animations schedule their own frames, so they bypass the
+		// pacer to avoid its timer adding jitter.
+		animateKeyframes(ctx, client, *fps, easing, *latency, *rgbw, encode(write))
+		return
+	}
+
+	pacer := NewPacer(*fps, write)
+	streamFrames(ctx, *rgbw, encode(pacer.Send))
+
+	err = pacer.Close()
+	if err != nil {
+		fmt.Println("error: failed to send frame:", err)
+		exit(1)
+	}
+}
+
+// streamFrames sends frames from stdin as they're read, until stdin ends or
+// ctx is cancelled.
+func streamFrames(ctx context.Context, rgbw bool, send func([]SetPanelColor) error) {
+	// Read in the background, since reads from stdin can't be cancelled.
+	lines := make(chan string)
+	errc := make(chan error, 1)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			select {
+			case lines <- scanner.Text():
+			case <-ctx.Done():
+				return
+			}
+		}
+		errc <- scanner.Err()
+	}()
+
+	for line := 1; ; line++ {
+		var text string
+		select {
+		case t, ok := <-lines:
+			if !ok {
+				if err := <-errc; err != nil {
+					fmt.Println("error: failed to read frames:", err)
+					exit(1)
+				}
+				return
+			}
+			text = t
+		case <-ctx.Done():
+			return
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		frames, err := parseFrameLine(fields, rgbw)
+		if err != nil {
+			fmt.Printf("error: line %d: %v\n", line, err)
+			exit(1)
+		}
+
+		err = send(frames)
+		if err != nil {
+			fmt.Printf("error: line %d: failed to send frame: %v\n", line, err)
+			exit(1)
+		}
+	}
+}
+
+// animateKeyframes reads keyframes from stdin, then sends interpolated
+// frames until the last keyframe, or until interrupted.
+func animateKeyframes(ctx context.Context, client Client, fps int, easing Easing, latencyArg string, rgbw bool, send func([]SetPanelColor) error) {
+	keyframes, err := parseKeyframes(os.Stdin, rgbw)
+	if err != nil {
+		fmt.Println("error:", err)
+		exit(1)
+	}
+	if len(keyframes) == 0 {
+		fmt.Println("error: no keyframes")
+		exit(1)
+	}
+
+	var latency time.Duration
+	if latencyArg == "auto" {
+		latency, err = measureLatency(client)
+		if err != nil {
+			fmt.Println("error: failed to measure latency:", err)
+			exit(1)
+		}
+	} else {
+		latency, err = time.ParseDuration(latencyArg)
+		if err != nil || latency < 0 {
+			fmt.Println("error: latency must be a duration or auto, e.g. 20ms")
+			exit(1)
+		}
+	}
+	slog.Debug("animating keyframes", "keyframes", len(keyframes), "fps", fps, "latency", latency)
+
+	stats, err := animate(ctx, keyframes, fps, easing, latency, send)
+	slog.Debug("animation finished", "frames", stats.Frames, "dropped", stats.Dropped,
+		"mean_drift", stats.MeanDrift, "max_drift", stats.MaxDrift)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		fmt.Println("error: failed to send frame:", err)
+		exit(1)
+	}
+}
+
+// parseFrameLine parses one line of frames in the `effect custom` format.
+func parseFrameLine(fields []string, rgbw bool) ([]SetPanelColor, error) {
+	frames, err := parseCustomFrames(fields, rgbw)
+	if err == errCustomFrameArgs && rgbw {
+		err = fmt.Errorf("expected [<panel> <red> <green> <blue>|<color> <white> <transition time>] ...")
+	} else if err == errCustomFrameArgs {
+		err = fmt.Errorf("expected [<panel> <red> <green> <blue>|<color> <transition time>] ...")
+	}
+	return frames, err
+}
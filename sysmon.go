@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sysmonMetrics are the metrics fx sysmon can show.
+var sysmonMetrics = []string{"cpu", "mem", "net"}
+
+// cpuTimes are cumulative CPU times, in clock ticks, from /proc/stat.
+type cpuTimes struct {
+	Total uint64
+	Idle  uint64
+}
+
+// parseCPUTimes reads the aggregate CPU times from /proc/stat.
+func parseCPUTimes(r io.Reader) (cpuTimes, error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 || fields[0] != "cpu" {
+			continue
+		}
+
+		var times cpuTimes
+		for i, f := range fields[1:] {
+			n, err := strconv.ParseUint(f, 10, 64)
+			if err != nil {
+				return cpuTimes{}, fmt.Errorf("invalid cpu time %q", f)
+			}
+			times.Total += n
+			// idle and iowait
+			if i == 3 || i == 4 {
+				times.Idle += n
+			}
+		}
+		return times, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return cpuTimes{}, err
+	}
+	return cpuTimes{}, fmt.Errorf("no cpu line")
+}
+
+// cpuLoad returns the fraction of time the CPUs were busy between two
+// samples.
+func cpuLoad(prev, cur cpuTimes) float64 {
+	total := cur.Total - prev.Total
+	if total == 0 {
+		return 0
+	}
+	return 1 - float64(cur.Idle-prev.Idle)/float64(total)
+}
+
+// parseMemUsage reads the fraction of memory in use from /proc/meminfo.
+func parseMemUsage(r io.Reader) (float64, error) {
+	var total, available float64
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		n, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			continue
+		}
+		switch fields[0] {
+		case "MemTotal:":
+			total = n
+		case "MemAvailable:":
+			available = n
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	if total == 0 {
+		return 0, fmt.Errorf("no MemTotal")
+	}
+	return 1 - available/total, nil
+}
+
+// parseNetBytes reads the total bytes received and sent on all interfaces
+// but loopback from /proc/net/dev.
+func parseNetBytes(r io.Reader) (uint64, error) {
+	var total uint64
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		name, stats, ok := strings.Cut(scanner.Text(), ":")
+		if !ok || strings.TrimSpace(name) == "lo" {
+			continue
+		}
+
+		// Received bytes are the first field, and sent bytes the ninth.
+		fields := strings.Fields(stats)
+		if len(fields) < 9 {
+			continue
+		}
+		for _, f := range []string{fields[0], fields[8]} {
+			n, err := strconv.ParseUint(f, 10, 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid byte count %q", f)
+			}
+			total += n
+		}
+	}
+	return total, scanner.Err()
+}
+
+// loadColor shades from green at no load, through yellow, to red at full
+// load.
+func loadColor(load float64) RGB {
+	return HSVToRGB(int(math.Round(120*(1-load))), 100, 100)
+}
+
+// gaugeFrames fills a path of panels in proportion to load, from 0 to 1,
+// with the panel at the edge of the fill partly lit. A zero color shades
+// the gauge by load.
+func gaugeFrames(path []PanelPosition, load float64, color RGB) []SetPanelColor {
+	if color == (RGB{}) {
+		color = loadColor(load)
+	}
+
+	fill := load * float64(len(path))
+	frames := make([]SetPanelColor, len(path))
+	for i, p := range path {
+		c := scaleColor(color, math.Max(0, math.Min(1, fill-float64(i))))
+		frames[i] = SetPanelColor{PanelID: uint16(p.PanelID), Red: c.Red, Green: c.Green, Blue: c.Blue}
+	}
+	return frames
+}
+
+// doFxSysmonCommand shows a local system metric as a gauge.
+func doFxSysmonCommand(client Client, args []string) {
+	flags := flag.NewFlagSet("sysmon", flag.ExitOnError)
+	metric := flags.String("metric", "cpu", "Metric to show: "+strings.Join(sysmonMetrics, ", "))
+	interval := flags.Duration("interval", time.Second, "How often to sample the metric")
+	netMax := flags.Float64("net-max", 100, "Throughput that fills the gauge for the net metric, in Mbit/s")
+	colorArg := flags.String("color", "", "Gauge color, as a name or #rrggbb (default green to red by load)")
+	order := flags.String("order", "y", panelOrderUsage)
+	selection := flags.String("panels", "", "Panels to drive, as for sacn --panels (default all)")
+	flags.Usage = func() {
+		fmt.Println("usage: picoleaf fx sysmon [--metric cpu|mem|net] [--interval <duration>] [--net-max <mbps>] [--color <color>] [--order <order>] [--panels <selection>]")
+		exit(1)
+	}
+	flags.Parse(args)
+
+	if flags.NArg() > 0 || *interval <= 0 || *netMax <= 0 {
+		flags.Usage()
+	}
+
+	var color RGB
+	if *colorArg != "" {
+		c, err := parseColor(*colorArg)
+		if err != nil {
+			fmt.Println("error:", err)
+			exit(1)
+		}
+		color = c
+	}
+	if _, err := orderPanels(nil, *order); err != nil {
+		fmt.Println("error:", err)
+		exit(1)
+	}
+
+	sample, err := newSysmonSampler(*metric, *netMax*1e6/8)
+	if err != nil {
+		fmt.Println("error:", err)
+		exit(1)
+	}
+	target, err := sample()
+	if err != nil {
+		fmt.Println("error: failed to read", *metric, "usage:", err)
+		exit(1)
+	}
+
+	// The gauge eases towards each new sample, so it moves smoothly between
+	// them.
+	var load float64
+	var last, sampled time.Duration
+	runFx(client, *selection, func(t time.Duration, panels []PanelPosition) []SetPanelColor {
+		if t-sampled >= *interval {
+			sampled = t
+			v, err := sample()
+			if err != nil {
+				slog.Warn("failed to sample metric", "metric", *metric, "error", err)
+			} else {
+				target = v
+			}
+		}
+		load += (target - load) * math.Min(1, (t-last).Seconds()*4)
+		last = t
+
+		path, _ := orderPanels(panels, *order)
+		return gaugeFrames(path, math.Max(0, math.Min(1, load)), color)
+	})
+}
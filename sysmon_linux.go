@@ -0,0 +1,71 @@
+//go:build linux
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// newSysmonSampler returns a function that samples a system metric, from 0
+// to 1. netMax is the throughput, in bytes per second, that counts as full
+// for the net metric. Metrics that are rates read 0 on the first sample.
+func newSysmonSampler(metric string, netMax float64) (func() (float64, error), error) {
+	switch metric {
+	case "cpu":
+		var prev cpuTimes
+		return func() (float64, error) {
+			f, err := os.Open("/proc/stat")
+			if err != nil {
+				return 0, err
+			}
+			defer f.Close()
+
+			cur, err := parseCPUTimes(f)
+			if err != nil {
+				return 0, err
+			}
+			load := 0.0
+			if prev.Total > 0 {
+				load = cpuLoad(prev, cur)
+			}
+			prev = cur
+			return load, nil
+		}, nil
+	case "mem":
+		return func() (float64, error) {
+			f, err := os.Open("/proc/meminfo")
+			if err != nil {
+				return 0, err
+			}
+			defer f.Close()
+			return parseMemUsage(f)
+		}, nil
+	case "net":
+		var prev uint64
+		var prevAt time.Time
+		return func() (float64, error) {
+			f, err := os.Open("/proc/net/dev")
+			if err != nil {
+				return 0, err
+			}
+			defer f.Close()
+
+			cur, err := parseNetBytes(f)
+			if err != nil {
+				return 0, err
+			}
+			now := time.Now()
+			load := 0.0
+			if !prevAt.IsZero() && cur >= prev {
+				rate := float64(cur-prev) / now.Sub(prevAt).Seconds()
+				load = min(rate/netMax, 1)
+			}
+			prev, prevAt = cur, now
+			return load, nil
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown metric %q, expected one of: %s", metric, strings.Join(sysmonMetrics, ", "))
+}
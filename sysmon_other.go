@@ -0,0 +1,11 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+// newSysmonSampler would sample a system metric, but only Linux's /proc is
+// supported so far.
+func newSysmonSampler(metric string, netMax float64) (func() (float64, error), error) {
+	return nil, errors.New("fx sysmon is only supported on Linux")
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestParseCPUTimes(t *testing.T) {
+	stat := `cpu  100 0 50 800 50 0 0 0 0 0
+cpu0 50 0 25 400 25 0 0 0 0 0
+intr 12345
+`
+	times, err := parseCPUTimes(strings.NewReader(stat))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (cpuTimes{Total: 1000, Idle: 850}); times != want {
+		t.Errorf("parseCPUTimes = %+v, want %+v", times, want)
+	}
+
+	later := cpuTimes{Total: 1100, Idle: 900}
+	if load := cpuLoad(times, later); math.Abs(load-0.5) > 1e-9 {
+		t.Errorf("cpuLoad = %v, want 0.5", load)
+	}
+}
+
+func TestParseMemUsage(t *testing.T) {
+	meminfo := `MemTotal:        1000 kB
+MemFree:          100 kB
+MemAvailable:     250 kB
+`
+	usage, err := parseMemUsage(strings.NewReader(meminfo))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage != 0.75 {
+		t.Errorf("parseMemUsage = %v, want 0.75", usage)
+	}
+}
+
+func TestParseNetBytes(t *testing.T) {
+	dev := `Inter-|   Receive                                                |  Transmit
+ face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+    lo:  5000      10    0    0    0     0          0         0     5000      10    0    0    0     0       0          0
+  eth0:  1000      10    0    0    0     0          0         0      200      10    0    0    0     0       0          0
+`
+	total, err := parseNetBytes(strings.NewReader(dev))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 1200 {
+		t.Errorf("parseNetBytes = %d, want 1200", total)
+	}
+}
+
+func TestGaugeFrames(t *testing.T) {
+	path := []PanelPosition{{PanelID: 1}, {PanelID: 2}, {PanelID: 3}, {PanelID: 4}}
+	frames := gaugeFrames(path, 0.625, RGB{200, 200, 200})
+
+	want := []uint8{200, 200, 100, 0}
+	for i, f := range frames {
+		if f.Red != want[i] {
+			t.Errorf("panel %d red = %d, want %d", f.PanelID, f.Red, want[i])
+		}
+	}
+
+	if c := loadColor(0); c != (RGB{0, 255, 0}) {
+		t.Errorf("loadColor(0) = %v, want green", c)
+	}
+	if c := loadColor(1); c != (RGB{255, 0, 0}) {
+		t.Errorf("loadColor(1) = %v, want red", c)
+	}
+}
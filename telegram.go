@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// telegramAPI is the base URL of the Telegram Bot API.
+var telegramAPI = "https://api.telegram.org"
+
+// telegramPollTimeout is how long each getUpdates long poll waits for
+// messages.
+const telegramPollTimeout = 50 * time.Second
+
+// telegramHelp is the reply to /help and unknown commands.
+const telegramHelp = `/on - turn the lights on
+/off - turn the lights off
+/scene <name> - apply a scene
+/color <color> - set a color, as a name or #rrggbb
+/status - show the current state`
+
+// telegramMessage is the subset of a Telegram message we care about.
+type telegramMessage struct {
+	Chat struct {
+		ID int64 `json:"id"`
+	} `json:"chat"`
+	Text string `json:"text"`
+}
+
+// telegramBot answers commands sent to a Telegram bot, from allowed chats
+// only.
+type telegramBot struct {
+	token   string
+	allowed map[int64]bool
+
+	// run runs a picoleaf command, and status describes the current state.
+	run    func(args []string) error
+	status func() (string, error)
+}
+
+// call calls a Bot API method, decoding its result into v.
+func (b *telegramBot) call(method string, params interface{}, v interface{}) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+
+	client := http.Client{Timeout: telegramPollTimeout + 10*time.Second}
+	res, err := client.Post(telegramAPI+"/bot"+b.token+"/"+method, "application/json", bytes.NewReader(body))
+	if err != nil {
+		// Errors include the URL, which includes the token.
+		return errors.New(strings.ReplaceAll(err.Error(), b.token, "<token>"))
+	}
+	defer res.Body.Close()
+
+	var result struct {
+		OK          bool            `json:"ok"`
+		Description string          `json:"description"`
+		Result      json.RawMessage `json:"result"`
+	}
+	err = json.NewDecoder(res.Body).Decode(&result)
+	if err != nil {
+		return fmt.Errorf("telegram: %s: %v", res.Status, err)
+	}
+	if !result.OK {
+		return fmt.Errorf("telegram: %s: %s", method, result.Description)
+	}
+	if v == nil {
+		return nil
+	}
+	return json.Unmarshal(result.Result, v)
+}
+
+// handle runs the command in a message, and returns the reply.
+func (b *telegramBot) handle(msg telegramMessage) string {
+	if !b.allowed[msg.Chat.ID] {
+		return fmt.Sprintf("This chat (%d) isn't allowed to control the lights.", msg.Chat.ID)
+	}
+
+	fields := strings.Fields(msg.Text)
+	if len(fields) == 0 {
+		return telegramHelp
+	}
+	// In groups, commands may be addressed like /on@picoleaf_bot.
+	command, _, _ := strings.Cut(fields[0], "@")
+	args := fields[1:]
+
+	var err error
+	switch {
+	case command == "/on" && len(args) == 0:
+		err = b.run([]string{"on"})
+	case command == "/off" && len(args) == 0:
+		err = b.run([]string{"off"})
+	case command == "/scene" && len(args) == 1:
+		err = b.run([]string{"scene", args[0]})
+	case command == "/color" && len(args) == 1:
+		var c RGB
+		c, err = parseColor(args[0])
+		if err != nil {
+			return err.Error()
+		}
+		err = b.run([]string{"rgb", strconv.Itoa(int(c.Red)), strconv.Itoa(int(c.Green)), strconv.Itoa(int(c.Blue))})
+	case command == "/status" && len(args) == 0:
+	default:
+		return telegramHelp
+	}
+	if err != nil {
+		return "Failed: " + err.Error()
+	}
+
+	status, err := b.status()
+	if err != nil {
+		return "Done, but failed to get the state: " + err.Error()
+	}
+	return status
+}
+
+// describeSnapshot summarizes a state for humans.
+func describeSnapshot(s *Snapshot) string {
+	if !s.On {
+		return "Off"
+	}
+
+	var mode string
+	switch {
+	case s.ColorMode == "effect" && !strings.HasPrefix(s.Effect, "*"):
+		mode = "effect " + s.Effect
+	case s.ColorMode == "ct":
+		mode = fmt.Sprintf("%dK", s.ColorTemperature)
+	default:
+		mode = fmt.Sprintf("hue %d, saturation %d", s.Hue, s.Saturation)
+	}
+	return fmt.Sprintf("On, %s, brightness %d", mode, s.Brightness)
+}
+
+// doTelegramCommand runs a Telegram bot that controls the lights, until
+// interrupted.
+func doTelegramCommand(client Client, args []string) {
+	section := cfg.Section("telegram")
+	flags := flag.NewFlagSet("telegram", flag.ExitOnError)
+	token := flags.String("bot-token", section.Key("bot_token").MustString(os.Getenv("TELEGRAM_BOT_TOKEN")), "Bot token, from @BotFather")
+	allow := flags.String("allow", section.Key("allow").String(), "Comma-separated chat IDs allowed to control the lights")
+	flags.Usage = func() {
+		fmt.Println("usage: picoleaf telegram [--bot-token <token>] [--allow <chat id>,...]")
+		exit(1)
+	}
+	flags.Parse(args)
+	addLogSecret(*token)
+
+	if flags.NArg() > 0 {
+		flags.Usage()
+	}
+	if *token == "" {
+		fmt.Println("error: a bot token is required, via --bot-token or TELEGRAM_BOT_TOKEN")
+		exit(1)
+	}
+
+	bot := &telegramBot{
+		token:   *token,
+		allowed: make(map[int64]bool),
+		run:     runSubcommand,
+		status: func() (string, error) {
+			snapshot, err := client.Snapshot()
+			if err != nil {
+				return "", err
+			}
+			return describeSnapshot(snapshot), nil
+		},
+	}
+	for _, id := range strings.Split(*allow, ",") {
+		if strings.TrimSpace(id) == "" {
+			continue
+		}
+		n, err := strconv.ParseInt(strings.TrimSpace(id), 10, 64)
+		if err != nil {
+			fmt.Printf("error: invalid chat ID %q\n", id)
+			exit(1)
+		}
+		bot.allowed[n] = true
+	}
+	if len(bot.allowed) == 0 {
+		slog.Warn("no chats are allowed yet; message the bot to find your chat ID, then pass it to --allow")
+	}
+
+	slog.Info("telegram bot running", "allowed", len(bot.allowed))
+	offset := 0
+	for {
+		var updates []struct {
+			UpdateID int              `json:"update_id"`
+			Message  *telegramMessage `json:"message"`
+		}
+		err := bot.call("getUpdates", map[string]interface{}{
+			"offset":          offset,
+			"timeout":         int(telegramPollTimeout.Seconds()),
+			"allowed_updates": []string{"message"},
+		}, &updates)
+		if err != nil {
+			slog.Error("failed to get Telegram updates", "err", err)
+			if !sleepOrCancel(linkRetryInterval) {
+				return
+			}
+			continue
+		}
+
+		for _, update := range updates {
+			offset = update.UpdateID + 1
+			if update.Message == nil {
+				continue
+			}
+
+			slog.Info("telegram command", "chat", update.Message.Chat.ID, "text", update.Message.Text)
+			reply := bot.handle(*update.Message)
+			err := bot.call("sendMessage", map[string]interface{}{
+				"chat_id": update.Message.Chat.ID,
+				"text":    reply,
+			}, nil)
+			if err != nil {
+				slog.Error("failed to send Telegram reply", "err", err)
+			}
+		}
+	}
+}
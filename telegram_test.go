@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTelegramBotHandle(t *testing.T) {
+	var ran [][]string
+	bot := &telegramBot{
+		allowed: map[int64]bool{42: true},
+		run: func(args []string) error {
+			ran = append(ran, args)
+			return nil
+		},
+		status: func() (string, error) {
+			return describeSnapshot(&Snapshot{On: true, ColorMode: "hs", Hue: 120, Saturation: 100, Brightness: 60}), nil
+		},
+	}
+	message := func(chat int64, text string) telegramMessage {
+		var m telegramMessage
+		m.Chat.ID = chat
+		m.Text = text
+		return m
+	}
+
+	if reply := bot.handle(message(7, "/on")); !strings.Contains(reply, "(7) isn't allowed") {
+		t.Errorf("reply to a stranger = %q", reply)
+	}
+	if reply := bot.handle(message(42, "/on@picoleaf_bot")); reply != "On, hue 120, saturation 100, brightness 60" {
+		t.Errorf("reply to /on = %q", reply)
+	}
+	bot.handle(message(42, "/scene movie"))
+	bot.handle(message(42, "/color orange"))
+	if reply := bot.handle(message(42, "/color nope")); !strings.Contains(reply, "invalid color") {
+		t.Errorf("reply to a bad color = %q", reply)
+	}
+	if reply := bot.handle(message(42, "hello")); reply != telegramHelp {
+		t.Errorf("reply to an unknown command = %q", reply)
+	}
+
+	want := [][]string{{"on"}, {"scene", "movie"}, {"rgb", "255", "128", "0"}}
+	if !reflect.DeepEqual(ran, want) {
+		t.Errorf("ran %q, want %q", ran, want)
+	}
+}
+
+func TestTelegramBotCall(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/botsecret-token/sendMessage" {
+			w.Write([]byte(`{"ok":false,"description":"Not Found"}`))
+			return
+		}
+		var params map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&params)
+		w.Write([]byte(`{"ok":true,"result":{"text":"` + params["text"].(string) + `"}}`))
+	}))
+	defer server.Close()
+
+	saved := telegramAPI
+	telegramAPI = server.URL
+	defer func() { telegramAPI = saved }()
+
+	bot := &telegramBot{token: "secret-token"}
+	var sent struct {
+		Text string `json:"text"`
+	}
+	err := bot.call("sendMessage", map[string]interface{}{"chat_id": 42, "text": "hi"}, &sent)
+	if err != nil || sent.Text != "hi" {
+		t.Errorf("sendMessage = %+v, %v", sent, err)
+	}
+
+	err = bot.call("nope", nil, nil)
+	if err == nil || err.Error() != "telegram: nope: Not Found" {
+		t.Errorf("unknown method error = %v", err)
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import "io"
+
+// Special keys returned by readKey. Other keys are returned as typed.
+const (
+	keyUp    = "up"
+	keyDown  = "down"
+	keyLeft  = "left"
+	keyRight = "right"
+	keyEnter = "enter"
+	keyEsc   = "esc"
+	keyCtrlC = "ctrl-c"
+)
+
+// readKey reads a single key press from a terminal in raw mode. Arrow keys
+// arrive as escape sequences in a single read, which is how they're told
+// apart from a lone Esc.
+func readKey(r io.Reader) (string, error) {
+	buf := make([]byte, 8)
+	n, err := r.Read(buf)
+	if err != nil {
+		return "", err
+	}
+
+	switch string(buf[:n]) {
+	case "\x1b[A", "\x1bOA":
+		return keyUp, nil
+	case "\x1b[B", "\x1bOB":
+		return keyDown, nil
+	case "\x1b[C", "\x1bOC":
+		return keyRight, nil
+	case "\x1b[D", "\x1bOD":
+		return keyLeft, nil
+	case "\r", "\n":
+		return keyEnter, nil
+	case "\x1b":
+		return keyEsc, nil
+	case "\x03":
+		return keyCtrlC, nil
+	}
+	return string(buf[:n]), nil
+}
@@ -0,0 +1,11 @@
+//go:build windows
+
+package main
+
+import "errors"
+
+// makeRaw would put the terminal into raw mode, but interactive modes aren't
+// supported on Windows yet.
+func makeRaw() (func(), error) {
+	return nil, errors.New("interactive mode isn't supported on Windows")
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// sleepOrCancel blocks for the given duration. It returns false if the wait
+// was cancelled by SIGINT or SIGTERM before the duration elapsed.
+func sleepOrCancel(d time.Duration) bool {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-sigs:
+		return false
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+// transitionStep is how often client-side transitions update the Nanoleaf.
+const transitionStep = 100 * time.Millisecond
+
+// transitionCommands are the commands that the -transition flag applies to.
+var transitionCommands = map[string]bool{
+	"brightness": true,
+	"hsl":        true,
+	"rgb":        true,
+	"scene":      true,
+	"temp":       true,
+}
+
+// transitionSeconds converts a transition to the API's whole-second duration
+// fields. Any transition at all lasts at least a second.
+func transitionSeconds(d time.Duration) int {
+	if d <= 0 {
+		return 0
+	}
+	return max(int(math.Round(d.Seconds())), 1)
+}
+
+// ramp calls step with t rising to 1 over the client's transition.
+func (c Client) ramp(step func(t float64) error) error {
+	steps := max(int(c.Transition/transitionStep), 1)
+	for i := 1; i <= steps; i++ {
+		if i > 1 {
+			time.Sleep(transitionStep)
+		}
+		err := step(float64(i) / float64(steps))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lerpInt interpolates between two integers.
+func lerpInt(from, to int, t float64) int {
+	return from + int(math.Round(float64(to-from)*t))
+}
+
+// lerpHue interpolates between two hues, the short way around the color
+// wheel.
+func lerpHue(from, to int, t float64) int {
+	delta := (to - from) % 360
+	if delta > 180 {
+		delta -= 360
+	} else if delta < -180 {
+		delta += 360
+	}
+	return (lerpInt(from, from+delta, t) + 360) % 360
+}
+
+// rampHueSaturation fades the hue and saturation, either of which may be nil
+// to leave it alone, from their current values over the client's
+// transition.
+func (c Client) rampHueSaturation(hue, sat *int) error {
+	snapshot, err := c.Snapshot()
+	if err != nil {
+		return err
+	}
+
+	return c.ramp(func(t float64) error {
+		state := State{}
+		if hue != nil {
+			state.Hue = &HueProperty{Value: lerpHue(snapshot.Hue, *hue, t)}
+		}
+		if sat != nil {
+			state.Saturation = &SaturationProperty{Value: lerpInt(snapshot.Saturation, *sat, t)}
+		}
+		return c.PutState(state)
+	})
+}
+
+// rampColorTemperature fades the color temperature from its current value
+// over the client's transition.
+func (c Client) rampColorTemperature(temperature int) error {
+	snapshot, err := c.Snapshot()
+	if err != nil {
+		return err
+	}
+
+	return c.ramp(func(t float64) error {
+		return c.PutState(State{
+			ColorTemperature: &ColorTemperatureProperty{Value: lerpInt(snapshot.ColorTemperature, temperature, t)},
+		})
+	})
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/paulrosania/picoleaf/internal/nltest"
+)
+
+func TestLerpHue(t *testing.T) {
+	tests := []struct {
+		from, to int
+		t        float64
+		want     int
+	}{
+		{0, 100, 0.5, 50},
+		{350, 10, 0.5, 0},
+		{10, 350, 0.25, 5},
+		{10, 350, 1, 350},
+		{90, 270, 1, 270},
+	}
+	for _, tt := range tests {
+		if got := lerpHue(tt.from, tt.to, tt.t); got != tt.want {
+			t.Errorf("lerpHue(%d, %d, %v) = %d, want %d", tt.from, tt.to, tt.t, got, tt.want)
+		}
+	}
+}
+
+func TestTransitionSeconds(t *testing.T) {
+	tests := map[time.Duration]int{0: 0, 200 * time.Millisecond: 1, 2400 * time.Millisecond: 2}
+	for d, want := range tests {
+		if got := transitionSeconds(d); got != want {
+			t.Errorf("transitionSeconds(%s) = %d, want %d", d, got, want)
+		}
+	}
+}
+
+func TestSetHSLTransition(t *testing.T) {
+	client, server := newTestClient(t)
+	server.Update(func(d *nltest.Device) {
+		d.State.Hue = 300
+		d.State.Saturation = 0
+	})
+	client.Transition = 300 * time.Millisecond
+
+	err := client.SetHSL(20, 90, 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := server.Device().State
+	if state.Hue != 20 || state.Saturation != 90 || state.Brightness != 40 {
+		t.Errorf("state = %+v, want hue 20, saturation 90, brightness 40", state)
+	}
+
+	// Brightness fades on the Nanoleaf, and hue and saturation in steps.
+	var steps int
+	for _, req := range server.Requests() {
+		if req.Method == "PUT" && req.Path == "state" {
+			steps++
+		}
+	}
+	if steps != 4 {
+		t.Errorf("sent %d state updates, want 1 for brightness and 3 steps", steps)
+	}
+	if body := server.Requests()[0].Body; body != `{"brightness":{"value":40,"duration":1}}` {
+		t.Errorf("first request = %s, want brightness with a duration", body)
+	}
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// uiPage is the daemon's web UI: a single page with a power toggle,
+// brightness slider, color wheel, and effect list.
+//
+//go:embed ui.html
+var uiPage []byte
+
+// uiHandler serves the web UI at `/ui`. The page holds no secrets: it
+// controls the Nanoleaf through the API proxy, with the proxy's credentials.
+func uiHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(uiPage)
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// lastDeviceFile is the name of the file in the state directory that
+// remembers the device or group last selected with -d or `picoleaf use`.
+const lastDeviceFile = "device"
+
+// subcommandEnv is set for picoleaf processes started by another picoleaf,
+// e.g. for cron entries or group members, so they don't change the
+// remembered device.
+const subcommandEnv = "PICOLEAF_SUBCOMMAND"
+
+func lastDevicePath() (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, lastDeviceFile), nil
+}
+
+// loadLastDevice returns the remembered device or group, or "" if there
+// isn't one.
+func loadLastDevice() (string, error) {
+	path, err := lastDevicePath()
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	return strings.TrimSpace(string(data)), err
+}
+
+// saveLastDevice remembers a device or group. Remembering the default
+// device forgets any other.
+func saveLastDevice(name string) error {
+	path, err := lastDevicePath()
+	if err != nil {
+		return err
+	}
+	if name == "" || name == defaultDeviceName {
+		err = os.Remove(path)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	return writeFileAtomic(path, []byte(name+"\n"))
+}
+
+// isDeviceOrGroup reports whether a device or group is configured under
+// name.
+func isDeviceOrGroup(name string) bool {
+	return deviceExists(name) || isGroup(name)
+}
+
+// selectDevice applies the remembered device if -d wasn't given, and
+// otherwise remembers the one that was. A remembered device that's since
+// been removed from the config is ignored.
+func selectDevice() {
+	if *deviceName == "" {
+		name, err := loadLastDevice()
+		if err != nil {
+			slog.Warn("failed to read the last used device", "err", err)
+		} else if name != "" && isDeviceOrGroup(name) {
+			*deviceName = name
+		}
+		return
+	}
+
+	if os.Getenv(subcommandEnv) == "" && isDeviceOrGroup(*deviceName) {
+		err := saveLastDevice(*deviceName)
+		if err != nil {
+			slog.Warn("failed to remember the device", "err", err)
+		}
+	}
+}
+
+// doUseCommand prints or changes the device commands run on when -d isn't
+// given.
+func doUseCommand(args []string) {
+	if len(args) > 1 || (len(args) == 1 && strings.HasPrefix(args[0], "-")) {
+		fmt.Println("usage: picoleaf use [<device|group>]")
+		exit(1)
+	}
+
+	if len(args) == 0 {
+		name, err := loadLastDevice()
+		if err != nil {
+			fmt.Println("error: failed to read the last used device:", err)
+			exit(1)
+		}
+		if name == "" || !isDeviceOrGroup(name) {
+			name = defaultDeviceName
+		}
+		fmt.Println(name)
+		return
+	}
+
+	name := args[0]
+	if name != defaultDeviceName && !isDeviceOrGroup(name) {
+		fmt.Printf("error: no device or group named %q\n", name)
+		exit(1)
+	}
+	err := saveLastDevice(name)
+	if err != nil {
+		fmt.Println("error: failed to save the device:", err)
+		exit(1)
+	}
+}
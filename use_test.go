@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestSelectDevice(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv(subcommandEnv, "")
+	prev := *deviceName
+	t.Cleanup(func() { *deviceName = prev })
+	setTestConfig(t, "host=192.0.2.1\n[device.office]\nhost=192.0.2.2\n[group.all]\ndevices=default,office\n")
+
+	*deviceName = "office"
+	selectDevice()
+	*deviceName = ""
+	selectDevice()
+	if *deviceName != "office" {
+		t.Errorf("device after -d office = %q, want office", *deviceName)
+	}
+
+	// Subcommands, e.g. group members, don't change the remembered device.
+	t.Setenv(subcommandEnv, "1")
+	*deviceName = "default"
+	selectDevice()
+	*deviceName = ""
+	selectDevice()
+	if *deviceName != "office" {
+		t.Errorf("device after a subcommand's -d default = %q, want office", *deviceName)
+	}
+
+	if err := saveLastDevice("default"); err != nil {
+		t.Fatal(err)
+	}
+	*deviceName = ""
+	selectDevice()
+	if *deviceName != "" {
+		t.Errorf("device after use default = %q, want none", *deviceName)
+	}
+
+	// A device that's since been removed is ignored.
+	if err := saveLastDevice("gone"); err != nil {
+		t.Fatal(err)
+	}
+	*deviceName = ""
+	selectDevice()
+	if *deviceName != "" {
+		t.Errorf("device after removal = %q, want none", *deviceName)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// probeTimeout bounds each reachability check.
+const probeTimeout = 3 * time.Second
+
+// probe checks whether the Nanoleaf answers authorized API requests.
+func probe(client Client) error {
+	req, err := http.NewRequest(http.MethodGet, client.Endpoint("state/on"), nil)
+	if err != nil {
+		return err
+	}
+
+	httpClient := http.Client{Timeout: probeTimeout, Transport: client.transport()}
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return client.redactError(err)
+	}
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected response: %s", res.Status)
+	}
+	return nil
+}
+
+// waitUntilReachable polls the Nanoleaf until it answers, or the timeout
+// elapses. A zero timeout waits forever.
+func waitUntilReachable(client Client, timeout time.Duration, interval time.Duration) error {
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+
+	for {
+		err := probe(client)
+		if err == nil {
+			return nil
+		}
+		slog.Debug("not reachable yet", "host", client.Host, "err", err)
+
+		if !deadline.IsZero() && time.Now().Add(interval).After(deadline) {
+			return fmt.Errorf("timed out after %s: %v", timeout, err)
+		}
+		if !sleepOrCancel(interval) {
+			return fmt.Errorf("cancelled")
+		}
+	}
+}
+
+func doWaitCommand(client Client, args []string) {
+	flags := flag.NewFlagSet("wait", flag.ExitOnError)
+	timeout := flags.Duration("timeout", 2*time.Minute, "Maximum time to wait (0 waits forever)")
+	interval := flags.Duration("interval", 2*time.Second, "Time between attempts")
+	flags.Usage = func() {
+		fmt.Println("usage: picoleaf wait [--timeout <duration>] [--interval <duration>]")
+		exit(1)
+	}
+	flags.Parse(args)
+
+	if flags.NArg() > 0 || *timeout < 0 || *interval <= 0 {
+		flags.Usage()
+	}
+
+	err := waitUntilReachable(client, *timeout, *interval)
+	if err != nil {
+		fmt.Println("error: Nanoleaf not reachable:", err)
+		exit(1)
+	}
+}
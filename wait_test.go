@@ -0,0 +1,37 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWaitUntilReachable(t *testing.T) {
+	client, _ := newTestClient(t)
+	if err := waitUntilReachable(client, time.Second, 10*time.Millisecond); err != nil {
+		t.Errorf("waitUntilReachable: %v", err)
+	}
+
+	client.Token = "wrong"
+	err := waitUntilReachable(client, 50*time.Millisecond, 10*time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("waitUntilReachable with a bad token = %v, want a timeout", err)
+	}
+	if err != nil && strings.Contains(err.Error(), "wrong") {
+		t.Errorf("error %q contains the access token", err)
+	}
+}
+
+func TestWaitUntilReachableClosed(t *testing.T) {
+	client, server := newTestClient(t)
+	server.Close()
+
+	start := time.Now()
+	err := waitUntilReachable(client, 50*time.Millisecond, 10*time.Millisecond)
+	if err == nil {
+		t.Fatal("waitUntilReachable succeeded against a closed server")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("gave up after %s, want about 50ms", elapsed)
+	}
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// openMeteoURL is the Open-Meteo current conditions endpoint.
+const openMeteoURL = "https://api.open-meteo.com/v1/forecast"
+
+// Weather is a snapshot of the current weather.
+type Weather struct {
+	Condition   string // clear, cloudy, fog, drizzle, rain, snow, or thunderstorm
+	Temperature float64
+}
+
+// WeatherRule maps a condition or temperature range to a picoleaf command.
+type WeatherRule struct {
+	Match   string
+	Command []string
+}
+
+// defaultWeatherRules are used when the config has no `[weather]` section.
+// Their temperatures are in Celsius.
+var defaultWeatherRules = []WeatherRule{
+	{"thunderstorm", []string{"hsl", "275", "100", "50"}},
+	{"snow", []string{"temp", "6500"}},
+	{"rain", []string{"hsl", "220", "100", "50"}},
+	{"drizzle", []string{"hsl", "200", "80", "60"}},
+	{"temp>30", []string{"rgb", "255", "120", "0"}},
+	{"temp<0", []string{"hsl", "195", "60", "70"}},
+	{"fog", []string{"hsl", "0", "0", "40"}},
+	{"cloudy", []string{"temp", "5000"}},
+	{"clear", []string{"temp", "3000"}},
+}
+
+// Matches reports whether the rule applies to the given weather. Rules are
+// either a condition name, or a temperature comparison like `temp>30`, in the
+// same units as the weather's temperature.
+func (r WeatherRule) Matches(w Weather) (bool, error) {
+	if !strings.HasPrefix(r.Match, "temp") {
+		return r.Match == w.Condition, nil
+	}
+
+	op, threshold, err := parseTemperatureRule(r.Match)
+	if err != nil {
+		return false, err
+	}
+	if op == '<' {
+		return w.Temperature < threshold, nil
+	}
+	return w.Temperature > threshold, nil
+}
+
+// parseTemperatureRule splits a rule like `temp>30` into its comparison and
+// threshold.
+func parseTemperatureRule(match string) (byte, float64, error) {
+	expr := strings.TrimPrefix(match, "temp")
+	if len(expr) < 2 || (expr[0] != '<' && expr[0] != '>') {
+		return 0, 0, fmt.Errorf("invalid temperature rule %q, expected e.g. temp>30", match)
+	}
+
+	threshold, err := strconv.ParseFloat(expr[1:], 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid temperature rule %q, expected e.g. temp>30", match)
+	}
+	return expr[0], threshold, nil
+}
+
+// fahrenheitWeatherRules converts the temperatures in Celsius rules to
+// Fahrenheit.
+func fahrenheitWeatherRules(rules []WeatherRule) []WeatherRule {
+	converted := make([]WeatherRule, len(rules))
+	for i, rule := range rules {
+		converted[i] = rule
+		if !strings.HasPrefix(rule.Match, "temp") {
+			continue
+		}
+		op, celsius, err := parseTemperatureRule(rule.Match)
+		if err != nil {
+			continue
+		}
+		converted[i].Match = "temp" + string(op) + strconv.FormatFloat(celsius*9/5+32, 'f', -1, 64)
+	}
+	return converted
+}
+
+// loadWeatherRules reads the `[weather]` config section. Rules are checked
+// in order, and the first match wins, e.g.:
+//
+//	rain    = hsl 220 100 50
+//	temp>30 = rgb 255 120 0
+//
+// Temperatures in configured rules are in the given units, celsius or
+// fahrenheit; the default rules are converted to them.
+func loadWeatherRules(units string) []WeatherRule {
+	section, err := cfg.GetSection("weather")
+	if err != nil {
+		if units == "fahrenheit" {
+			return fahrenheitWeatherRules(defaultWeatherRules)
+		}
+		return defaultWeatherRules
+	}
+
+	var rules []WeatherRule
+	for _, key := range section.Keys() {
+		rules = append(rules, WeatherRule{
+			Match:   key.Name(),
+			Command: strings.Fields(key.String()),
+		})
+	}
+	return rules
+}
+
+// wmoCondition maps a WMO weather interpretation code to a condition name.
+func wmoCondition(code int) string {
+	switch {
+	case code <= 1:
+		return "clear"
+	case code <= 3:
+		return "cloudy"
+	case code == 45 || code == 48:
+		return "fog"
+	case code >= 51 && code <= 57:
+		return "drizzle"
+	case code >= 61 && code <= 67, code >= 80 && code <= 82:
+		return "rain"
+	case code >= 71 && code <= 77, code == 85 || code == 86:
+		return "snow"
+	case code >= 95:
+		return "thunderstorm"
+	}
+	return "cloudy"
+}
+
+// fetchOpenMeteoWeather returns the current weather at the given location.
+func fetchOpenMeteoWeather(coords Coordinates, units string) (Weather, error) {
+	query := url.Values{}
+	query.Set("latitude", strconv.FormatFloat(coords.Latitude, 'f', 4, 64))
+	query.Set("longitude", strconv.FormatFloat(coords.Longitude, 'f', 4, 64))
+	query.Set("current", "temperature_2m,weather_code")
+	query.Set("temperature_unit", units)
+
+	client := http.Client{Timeout: 30 * time.Second}
+	res, err := client.Get(openMeteoURL + "?" + query.Encode())
+	if err != nil {
+		return Weather{}, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return Weather{}, fmt.Errorf("open-meteo: %s", res.Status)
+	}
+
+	var body struct {
+		Current struct {
+			Temperature float64 `json:"temperature_2m"`
+			WeatherCode int     `json:"weather_code"`
+		} `json:"current"`
+	}
+	err = json.NewDecoder(res.Body).Decode(&body)
+	if err != nil {
+		return Weather{}, err
+	}
+
+	return Weather{
+		Condition:   wmoCondition(body.Current.WeatherCode),
+		Temperature: body.Current.Temperature,
+	}, nil
+}
+
+func doWeatherCommand(client Client, args []string) {
+	flags := flag.NewFlagSet("weather", flag.ExitOnError)
+	provider := flags.String("provider", "openmeteo", "Weather provider")
+	every := flags.Duration("every", 15*time.Minute, "Time between weather updates")
+	units := flags.String("units", "celsius", "Temperature units (celsius or fahrenheit)")
+	once := flags.Bool("once", false, "Update once and exit")
+	flags.Usage = func() {
+		fmt.Println("usage: picoleaf weather [--provider openmeteo] [--every <duration>] [--units celsius|fahrenheit] [--once]")
+		exit(1)
+	}
+	flags.Parse(args)
+
+	if flags.NArg() > 0 || *every <= 0 {
+		flags.Usage()
+	}
+	if *provider != "openmeteo" {
+		fmt.Printf("error: unsupported weather provider %q\n", *provider)
+		exit(1)
+	}
+	if *units != "celsius" && *units != "fahrenheit" {
+		fmt.Println("error: units must be celsius or fahrenheit")
+		exit(1)
+	}
+
+	coords, err := loadCoordinates()
+	if err != nil {
+		fmt.Println("error: failed to determine location:", err)
+		exit(1)
+	}
+
+	rules := loadWeatherRules(*units)
+	var current string
+	for {
+		weather, err := fetchOpenMeteoWeather(coords, *units)
+		if err != nil {
+			slog.Error("failed to fetch weather", "err", err)
+		} else {
+			rule, err := matchWeatherRule(rules, weather)
+			if err != nil {
+				fmt.Println("error:", err)
+				exit(1)
+			}
+
+			// Only touch the lights when the mapping changes, so manual
+			// adjustments stick until the weather does.
+			if rule != nil && rule.Match != current {
+				slog.Info("weather changed", "condition", weather.Condition, "temperature", weather.Temperature, "rule", rule.Match, "command", strings.Join(rule.Command, " "))
+				err = runSubcommand(rule.Command)
+				if err != nil {
+					slog.Error("weather command failed", "rule", rule.Match, "err", err)
+				} else {
+					current = rule.Match
+				}
+			}
+		}
+
+		if *once || !sleepOrCancel(*every) {
+			return
+		}
+	}
+}
+
+// matchWeatherRule returns the first rule matching the weather, if any.
+func matchWeatherRule(rules []WeatherRule, w Weather) (*WeatherRule, error) {
+	for i, rule := range rules {
+		ok, err := rule.Matches(w)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return &rules[i], nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWeatherRuleMatches(t *testing.T) {
+	tests := []struct {
+		rule    string
+		weather Weather
+		want    bool
+	}{
+		{"rain", Weather{Condition: "rain"}, true},
+		{"rain", Weather{Condition: "drizzle"}, false},
+		{"temp>30", Weather{Temperature: 31}, true},
+		{"temp>30", Weather{Temperature: 30}, false},
+		{"temp<0", Weather{Temperature: -0.5}, true},
+		{"temp<0", Weather{Temperature: 0}, false},
+		{"temp>-5.5", Weather{Temperature: -5}, true},
+	}
+	for _, tt := range tests {
+		got, err := WeatherRule{Match: tt.rule}.Matches(tt.weather)
+		if err != nil {
+			t.Errorf("%q: %v", tt.rule, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%q.Matches(%+v) = %v, want %v", tt.rule, tt.weather, got, tt.want)
+		}
+	}
+
+	for _, rule := range []string{"temp", "temp>", "temp=30", "temp>hot"} {
+		if _, err := (WeatherRule{Match: rule}).Matches(Weather{}); err == nil {
+			t.Errorf("%q matched without error, want an invalid rule error", rule)
+		}
+	}
+}
+
+func TestMatchWeatherRule(t *testing.T) {
+	tests := []struct {
+		weather Weather
+		want    string
+	}{
+		// Conditions listed first win over temperatures.
+		{Weather{Condition: "thunderstorm", Temperature: 35}, "thunderstorm"},
+		{Weather{Condition: "clear", Temperature: 35}, "temp>30"},
+		{Weather{Condition: "cloudy", Temperature: -3}, "temp<0"},
+		{Weather{Condition: "cloudy", Temperature: 15}, "cloudy"},
+	}
+	for _, tt := range tests {
+		rule, err := matchWeatherRule(defaultWeatherRules, tt.weather)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rule == nil || rule.Match != tt.want {
+			t.Errorf("matchWeatherRule(%+v) = %+v, want %q", tt.weather, rule, tt.want)
+		}
+	}
+
+	rule, err := matchWeatherRule([]WeatherRule{{Match: "snow"}}, Weather{Condition: "clear"})
+	if rule != nil || err != nil {
+		t.Errorf("matchWeatherRule with no match = %+v, %v, want nil", rule, err)
+	}
+}
+
+func TestLoadWeatherRules(t *testing.T) {
+	setTestConfig(t, "")
+	if rules := loadWeatherRules("celsius"); !reflect.DeepEqual(rules, defaultWeatherRules) {
+		t.Errorf("without [weather], rules = %+v, want the defaults", rules)
+	}
+
+	// The default rules' temperatures follow the units. 31°F is cold, not
+	// hot.
+	rules := loadWeatherRules("fahrenheit")
+	var matches []string
+	for _, rule := range rules {
+		if strings.HasPrefix(rule.Match, "temp") {
+			matches = append(matches, rule.Match)
+		}
+	}
+	if want := []string{"temp>86", "temp<32"}; !reflect.DeepEqual(matches, want) {
+		t.Errorf("fahrenheit temperature rules = %q, want %q", matches, want)
+	}
+	rule, err := matchWeatherRule(rules, Weather{Condition: "clear", Temperature: 31})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rule == nil || rule.Match != "temp<32" {
+		t.Errorf("rule for 31°F = %+v, want temp<32", rule)
+	}
+
+	setTestConfig(t, `
+[weather]
+rain    = hsl 220 100 50
+temp>30 = rgb 255 120 0
+`)
+	want := []WeatherRule{
+		{Match: "rain", Command: []string{"hsl", "220", "100", "50"}},
+		{Match: "temp>30", Command: []string{"rgb", "255", "120", "0"}},
+	}
+	// Configured rules are already in the chosen units.
+	if rules := loadWeatherRules("fahrenheit"); !reflect.DeepEqual(rules, want) {
+		t.Errorf("rules = %+v, want %+v", rules, want)
+	}
+}
+
+func TestWMOCondition(t *testing.T) {
+	tests := map[int]string{
+		0:  "clear",
+		1:  "clear",
+		3:  "cloudy",
+		45: "fog",
+		53: "drizzle",
+		63: "rain",
+		81: "rain",
+		75: "snow",
+		86: "snow",
+		95: "thunderstorm",
+		99: "thunderstorm",
+		20: "cloudy",
+	}
+	for code, want := range tests {
+		if got := wmoCondition(code); got != want {
+			t.Errorf("wmoCondition(%d) = %q, want %q", code, got, want)
+		}
+	}
+}
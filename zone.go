@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// zoneKeyPrefix prefixes zone names in a device's config section, e.g.
+// `zone.left = 101,102,103`.
+const zoneKeyPrefix = "zone."
+
+// panelKeyPrefix prefixes panel names in a device's config section, e.g.
+// `panel.door = 14231`.
+const panelKeyPrefix = "panel."
+
+// parsePanels parses a comma-separated list of panel IDs, panel names, and
+// zone names, e.g. `left,door,104`. Names are read from the current device's
+// config section.
+func parsePanels(s string) ([]uint16, error) {
+	var ids []uint16
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if id, err := strconv.ParseUint(item, 10, 16); err == nil {
+			ids = append(ids, uint16(id))
+			continue
+		}
+
+		id, ok, err := loadPanelName(item)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			ids = append(ids, id)
+			continue
+		}
+
+		zone, err := loadZone(item)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, zone...)
+	}
+	return ids, nil
+}
+
+// loadZone returns the panel IDs in the named zone of the current device.
+// The name may be given with its zone. prefix, as in the config file.
+func loadZone(name string) ([]uint16, error) {
+	name = strings.TrimPrefix(name, zoneKeyPrefix)
+	section, err := deviceSection(currentDeviceName())
+	if err != nil {
+		return nil, err
+	}
+	if name == "" || !section.HasKey(zoneKeyPrefix+name) {
+		return nil, fmt.Errorf("expected panel ID between 0-%d, panel name, or zone name, got %q", math.MaxUint16, name)
+	}
+
+	var ids []uint16
+	for _, item := range section.Key(zoneKeyPrefix + name).Strings(",") {
+		if id, err := strconv.ParseUint(item, 10, 16); err == nil {
+			ids = append(ids, uint16(id))
+			continue
+		}
+		id, ok, err := loadPanelName(item)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, fmt.Errorf("zone %s: expected panel ID between 0-%d or panel name, got %s", name, math.MaxUint16, item)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// loadPanelName returns the ID of the named panel of the current device, and
+// whether the name is defined. The name may be given with its panel. prefix,
+// as in the config file.
+func loadPanelName(name string) (uint16, bool, error) {
+	name = strings.TrimPrefix(name, panelKeyPrefix)
+	section, err := deviceSection(currentDeviceName())
+	if err != nil {
+		return 0, false, err
+	}
+	if name == "" || !section.HasKey(panelKeyPrefix+name) {
+		return 0, false, nil
+	}
+
+	value := section.Key(panelKeyPrefix + name).String()
+	id, err := strconv.ParseUint(value, 10, 16)
+	if err != nil {
+		return 0, false, fmt.Errorf("panel %s: expected panel ID between 0-%d, got %s", name, math.MaxUint16, value)
+	}
+	return uint16(id), true, nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePanels(t *testing.T) {
+	setTestConfig(t, `
+zone.left  = 101,102,103
+zone.right = 104, 105
+zone.top   = door,106
+panel.door = 14231
+
+[device.desk]
+zone.left = 7
+`)
+
+	tests := []struct {
+		s    string
+		want []uint16
+	}{
+		{"42", []uint16{42}},
+		{"left", []uint16{101, 102, 103}},
+		{"right,42", []uint16{104, 105, 42}},
+		{"zone.left", []uint16{101, 102, 103}},
+		{"door", []uint16{14231}},
+		{"panel.door,left", []uint16{14231, 101, 102, 103}},
+		{"top", []uint16{14231, 106}},
+	}
+	for _, tt := range tests {
+		got, err := parsePanels(tt.s)
+		if err != nil {
+			t.Errorf("parsePanels(%q): %v", tt.s, err)
+		} else if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parsePanels(%q) = %v, want %v", tt.s, got, tt.want)
+		}
+	}
+
+	for _, s := range []string{"middle", "left,", "70000"} {
+		if _, err := parsePanels(s); err == nil {
+			t.Errorf("parsePanels(%q) succeeded, want error", s)
+		}
+	}
+}
+
+func TestParseCustomFramesZone(t *testing.T) {
+	setTestConfig(t, "zone.left = 101,102\n")
+
+	frames, err := parseCustomFrames([]string{"left", "255", "0", "0", "10", "3", "0", "0", "255", "0"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []SetPanelColor{
+		{PanelID: 101, Red: 255, TransitionTime: 10},
+		{PanelID: 102, Red: 255, TransitionTime: 10},
+		{PanelID: 3, Blue: 255},
+	}
+	if !reflect.DeepEqual(frames, want) {
+		t.Errorf("parseCustomFrames = %+v, want %+v", frames, want)
+	}
+}